@@ -1,52 +1,149 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"time"
 
 	"gitlab.com/xrs-cloud/lockbox/core/internal/api"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/cli"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/healthcheck"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
 	app_log "gitlab.com/xrs-cloud/lockbox/core/internal/logger"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/metrics"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/reload"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/server"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/tracing"
 )
 
 func main() {
-	// Define command-line flags for configuration file path
-	configFile := flag.String("config-file", "/etc/lockbox/lockbox.conf", "Path to the configuration file (.conf)")
+	// Dispatch subcommands (e.g., `lockbox config check`, `lockbox import`, `lockbox migrate`) before parsing the server flags
+	// Each subcommand parses its own flags and exits when it is done
+	if len(os.Args) > 1 {
+		if command, found := subcommands[os.Args[1]]; found {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalf("Error running '%s': %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
+	// Define command-line flags for configuration file path and configuration overrides
+	// Overrides take precedence over the LOCKBOX_<SECTION>_<KEY> environment variables, which take precedence over the file
+	configFile := flag.String("config-file", config.DefaultFilePath, "Path to the configuration file (.conf, .yaml or .toml), or empty to only use the environment and flags")
+	var overrides config.Overrides
+	flag.Var(&overrides, "set", "Configuration value as section.key=value (e.g., server.port=9000); can be repeated")
 	flag.Parse()
 
 	// Load application configuration
 	// This step initializes all necessary settings for the application (server, database, logging, etc.)
-	config, err := config.LoadConfig(*configFile)
+	config, err := config.LoadConfig(*configFile, overrides...)
 	if err != nil {
 		// Use log.Fatalf to immediately exit if configuration loading fails
 		log.Fatalf("Error loading configuration file: %v", err)
 	}
+	global.Config = config
 
 	// Initialize and configure the application logger
 	// The logger is globally accessible through the `global` package
 	logger := app_log.InitLogger(config.Logging)
 	global.Logger = logger
 
-	// Establish a connection to the database
-	// If the connection cannot be established, the application will log and exit
+	// Install the tracer provider, so that requests, encryptions and database queries are traced
+	// Tracing is disabled unless the [tracing] section enables it
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		logger.Fatalf("Error setting up tracing: %v", err)
+	}
+
+	// Open the database connection pool
+	// The database is contacted in the background, so the server can answer health checks with "starting"
+	// while it waits for the database to come up; the application exits if it does not come up in time
 	logger.Infof("Creating connection to the database at %s", config.Database.Host)
-	db := database.InitDatabase(config.Database)
+	db := database.OpenDatabase(config.Database)
 	global.Database = db
 
+	// Expose the statistics of the connection pool
+	if sqlDB, err := db.DB(); err == nil {
+		if err := metrics.RegisterDatabase(sqlDB); err != nil {
+			logger.Warnf("Failed to register database metrics: %v", err)
+		}
+	}
+
+	// Register the checks behind the liveness, readiness and startup probes
+	// The free disk space of the log file is only checked if logs are written to a file
+	logFilePath := ""
+	if config.Logging.File {
+		logFilePath = config.Logging.FilePath
+	}
+	healthcheck.RegisterBuiltinChecks(db, logFilePath)
+
+	// Reload the configuration on SIGHUP or when the configuration file changes
+	// Only the log level, the connection pool limits and the database password can change without a restart
+	reloader := reload.NewReloader(config, *configFile, overrides, db, logger)
+
 	// Setup the API router for handling HTTP requests
 	router := api.SetupRouter()
 
-	// Start the HTTP server
-	// The server runs on the address and port specified in the configuration file
+	// Create the HTTP server
+	// The server runs on the address, port and timeouts specified in the configuration file,
+	// and shuts down gracefully on SIGTERM or SIGINT
+	srv := server.New(config.Server, router)
+
+	// Start the background workers; they are stopped when the server shuts down
+	srv.Go("database", func(ctx context.Context) {
+		if err := database.Start(ctx, db, config.Database); err != nil && ctx.Err() == nil {
+			logger.Fatal(err)
+		}
+	})
+	// The engines registered the revokers of their leases and their rotators when the router was set up
+	srv.Go("lease scheduler", func(ctx context.Context) {
+		leases.RunScheduler(ctx, leases.NewService(leases.NewRepository(db)))
+	})
+	srv.Go("metrics server", func(ctx context.Context) {
+		metrics.Serve(ctx, config.Metrics)
+	})
+	srv.Go("config reloader", reloader.Run)
+	srv.Go("rotation scheduler", func(ctx context.Context) {
+		rotation.RunScheduler(ctx, rotation.NewService(rotation.NewRepository(db), secrets.NewService(secrets.NewRepository(db))))
+	})
+
+	// Release the database pool and the log file once everything else has stopped
+	srv.OnShutdown("database connection pool", func() error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+	srv.OnShutdown("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+	srv.OnShutdown("log file", app_log.Close)
+
+	// Serve requests until the server is shut down
 	// If the server fails to start, the application logs the error and exits
-	serverAddressAndPort := fmt.Sprintf("%s:%s", config.Server.Host, config.Server.Port)
-	logger.Infof("Starting server on %s", serverAddressAndPort)
-	if err := http.ListenAndServe(serverAddressAndPort, router); err != nil {
+	if err := srv.Run(); err != nil {
 		// Use logger.Fatalf to log the error and terminate the application
-		logger.Fatalf("Error starting server: %v", err)
+		logger.Fatalf("Error running server: %v", err)
 	}
 }
+
+// tracingShutdownTimeout is how long the pending spans are given to be exported on shutdown.
+const tracingShutdownTimeout = 5 * time.Second
+
+// subcommands maps the name of each subcommand to the function that runs it with the remaining arguments.
+var subcommands = map[string]func(args []string) error{
+	"config":  cli.Config,
+	"import":  cli.Import,
+	"migrate": cli.Migrate,
+}
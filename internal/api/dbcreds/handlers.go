@@ -0,0 +1,210 @@
+package dbcreds
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/dbcreds"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// SaveConnection handles creating or replacing a connection to a PostgreSQL database.
+// The database is contacted before the connection is saved, and the URL is encrypted with the "MASTER_CRYPTO_PASS" environment variable.
+// The user of the URL must be allowed to create and drop roles.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "connection_url": "postgres://lockbox:password@db:5432/app?sslmode=require",
+//	    "allowed_roles": ["readonly"]
+//	}
+//
+// Responses:
+// - 200 OK: Returns the saved connection, without its URL.
+// - 400 Bad Request: Returns if the request body or name is invalid, or if the database cannot be reached.
+// - 500 Internal Server Error: Returns if the connection cannot be stored.
+func SaveConnection(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ConnectionURL string   `json:"connection_url" validate:"required"`
+		AllowedRoles  []string `json:"allowed_roles"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	connection, err := DatabaseService.SaveConnection(mux.Vars(r)["name"], req.ConnectionURL, req.AllowedRoles, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to save connection")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newConnectionResponse(connection))
+}
+
+// GetConnection retrieves a connection by name. Its URL is not returned.
+//
+// Responses:
+// - 200 OK: Returns the connection.
+// - 404 Not Found: Returns if the connection does not exist.
+// - 500 Internal Server Error: Returns if the connection cannot be retrieved.
+func GetConnection(w http.ResponseWriter, r *http.Request) {
+	connection, err := DatabaseService.GetConnection(mux.Vars(r)["name"])
+	if err != nil {
+		writeServiceError(w, err, "Failed to retrieve connection")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newConnectionResponse(connection))
+}
+
+// SaveRole handles creating or replacing a role.
+// Statements are templates in which "{{name}}", "{{password}}" and "{{expiration}}" are replaced by the user name,
+// its password and the time past which its lease cannot be renewed. When no revocation statement is given, the user is dropped with DROP ROLE.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "connection": "app",
+//	    "creation_statements": [
+//	        "CREATE ROLE \"{{name}}\" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}';",
+//	        "GRANT SELECT ON ALL TABLES IN SCHEMA public TO \"{{name}}\";"
+//	    ],
+//	    "revocation_statements": [
+//	        "REVOKE ALL ON ALL TABLES IN SCHEMA public FROM \"{{name}}\";",
+//	        "DROP ROLE IF EXISTS \"{{name}}\";"
+//	    ],
+//	    "ttl": 3600,
+//	    "max_ttl": 86400
+//	}
+//
+// Responses:
+// - 200 OK: Returns the saved role.
+// - 400 Bad Request: Returns if the request body or role settings are invalid, or if the connection does not allow the role.
+// - 404 Not Found: Returns if the connection does not exist.
+// - 500 Internal Server Error: Returns if the role cannot be stored.
+func SaveRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Connection           string   `json:"connection" validate:"required"`
+		CreationStatements   []string `json:"creation_statements" validate:"required,min=1"`
+		RevocationStatements []string `json:"revocation_statements"`
+		TTL                  int      `json:"ttl"`
+		MaxTTL               int      `json:"max_ttl"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	role := &dbcreds.Role{
+		Name:                 mux.Vars(r)["name"],
+		Connection:           req.Connection,
+		CreationStatements:   req.CreationStatements,
+		RevocationStatements: req.RevocationStatements,
+		TTL:                  req.TTL,
+		MaxTTL:               req.MaxTTL,
+	}
+	if err := DatabaseService.SaveRole(role); err != nil {
+		writeServiceError(w, err, "Failed to save role")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newRoleResponse(role))
+}
+
+// GetRole retrieves a role by name.
+//
+// Responses:
+// - 200 OK: Returns the role.
+// - 404 Not Found: Returns if the role does not exist.
+// - 500 Internal Server Error: Returns if the role cannot be retrieved.
+func GetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := DatabaseService.GetRole(mux.Vars(r)["name"])
+	if err != nil {
+		writeServiceError(w, err, "Failed to retrieve role")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newRoleResponse(role))
+}
+
+// GenerateCredentials creates a new database user with a role, and returns its credentials with a lease.
+// The user is dropped when the lease expires, or earlier with POST /database/revoke or POST /sys/leases/revoke.
+// The lease can be renewed with POST /sys/leases/renew.
+//
+// Responses:
+// - 200 OK: Returns the user name, password and lease.
+// - 404 Not Found: Returns if the role or its connection does not exist.
+// - 500 Internal Server Error: Returns if the user cannot be created.
+func GenerateCredentials(w http.ResponseWriter, r *http.Request) {
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	credentials, err := DatabaseService.GenerateCredentials(mux.Vars(r)["role"], masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create credentials")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &CredentialsResponse{
+		LeaseID:       credentials.LeaseID,
+		LeaseDuration: int(credentials.LeaseDuration.Seconds()),
+		ExpiresAt:     credentials.ExpiresAt,
+		Username:      credentials.Username,
+		Password:      credentials.Password,
+	})
+}
+
+// Revoke drops the user of a lease before it expires.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "lease_id": "database/creds/readonly/2f1c..."
+//	}
+//
+// Responses:
+// - 200 OK: Returns if the user was dropped.
+// - 400 Bad Request: Returns if the request body is invalid.
+// - 404 Not Found: Returns if the lease does not exist.
+// - 500 Internal Server Error: Returns if the user cannot be dropped.
+func Revoke(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		LeaseID string `json:"lease_id" validate:"required"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	if err := DatabaseService.Revoke(req.LeaseID, masterCryptoPass); err != nil {
+		writeServiceError(w, err, "Failed to revoke lease")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Lease revoked successfully"})
+}
+
+// writeServiceError maps an error returned by the dynamic database credentials service to an HTTP response.
+// Errors caused by the request are reported with their message; other errors use the generic fallback message.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, dbcreds.ErrConnectionNotFound),
+		errors.Is(err, dbcreds.ErrRoleNotFound),
+		errors.Is(err, leases.ErrLeaseNotFound):
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, dbcreds.ErrInvalidName),
+		errors.Is(err, dbcreds.ErrInvalidConnection),
+		errors.Is(err, dbcreds.ErrInvalidRole),
+		errors.Is(err, dbcreds.ErrRoleNotAllowed):
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
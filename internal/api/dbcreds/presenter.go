@@ -0,0 +1,72 @@
+package dbcreds
+
+import (
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/dbcreds"
+)
+
+// ConnectionResponse represents a connection. Its URL is never included, as it contains a password.
+type ConnectionResponse struct {
+	// Name is the unique name of the connection.
+	Name string `json:"name"`
+
+	// AllowedRoles lists the roles that may create users in this database.
+	AllowedRoles []string `json:"allowed_roles"`
+}
+
+// RoleResponse represents a role used to create database users.
+type RoleResponse struct {
+	// Name is the unique name of the role.
+	Name string `json:"name"`
+
+	// Connection is the name of the connection in which users are created.
+	Connection string `json:"connection"`
+
+	// CreationStatements create the user and grant its privileges.
+	CreationStatements []string `json:"creation_statements"`
+
+	// RevocationStatements drop the user.
+	RevocationStatements []string `json:"revocation_statements"`
+
+	// TTL is the lifetime of the leases, in seconds.
+	TTL int `json:"ttl"`
+
+	// MaxTTL is the longest a lease can be renewed to, in seconds.
+	MaxTTL int `json:"max_ttl"`
+}
+
+// CredentialsResponse represents the credentials of a database user and their lease.
+type CredentialsResponse struct {
+	// LeaseID identifies the lease, and is used to revoke the credentials early.
+	LeaseID string `json:"lease_id"`
+
+	// LeaseDuration is the lifetime of the credentials, in seconds.
+	LeaseDuration int `json:"lease_duration"`
+
+	// ExpiresAt is the time after which the user is dropped.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Username is the name of the database user.
+	Username string `json:"username"`
+
+	// Password is the password of the database user.
+	Password string `json:"password"`
+}
+
+// newConnectionResponse creates the presenter of a connection.
+func newConnectionResponse(connection *dbcreds.Connection) *ConnectionResponse {
+	return &ConnectionResponse{Name: connection.Name, AllowedRoles: connection.AllowedRoles}
+}
+
+// newRoleResponse creates the presenter of a role.
+func newRoleResponse(role *dbcreds.Role) *RoleResponse {
+	return &RoleResponse{
+		Name:                 role.Name,
+		Connection:           role.Connection,
+		CreationStatements:   role.CreationStatements,
+		RevocationStatements: role.RevocationStatements,
+		TTL:                  role.TTL,
+		MaxTTL:               role.MaxTTL,
+	}
+}
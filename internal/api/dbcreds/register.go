@@ -0,0 +1,47 @@
+package dbcreds
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/dbcreds"
+)
+
+// DatabaseService is the service layer that handles business logic for dynamic database credentials.
+// This package variable allows handlers to interact with the dynamic database credentials service.
+var DatabaseService dbcreds.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterDatabaseRoutes registers the HTTP routes of the database secrets engine, which creates a PostgreSQL user per request.
+//
+// Parameters:
+// - router: The main router to which the database subrouter will be attached.
+// - databaseService: The dynamic database credentials service that will be used to handle the business logic.
+//
+// Routes:
+// - PUT /database/config/{name}: Creates or replaces a connection.
+// - GET /database/config/{name}: Retrieves a connection, without its URL.
+// - PUT /database/roles/{name}: Creates or replaces a role.
+// - GET /database/roles/{name}: Retrieves a role.
+// - GET /database/creds/{role}: Creates a user with a role and returns its credentials and lease.
+// - POST /database/revoke: Drops the user of a lease before it expires.
+func RegisterDatabaseRoutes(router *mux.Router, databaseService dbcreds.Service) {
+	// Assign the provided service to the package-level variable for use in the handler functions.
+	DatabaseService = databaseService
+
+	// Create a subrouter for the database secrets engine under the /database path.
+	databaseRouter := router.PathPrefix("/database").Subrouter()
+
+	// Connection routes
+	databaseRouter.HandleFunc("/config/{name}", SaveConnection).Methods("PUT")
+	databaseRouter.HandleFunc("/config/{name}", GetConnection).Methods("GET")
+
+	// Role routes
+	databaseRouter.HandleFunc("/roles/{name}", SaveRole).Methods("PUT")
+	databaseRouter.HandleFunc("/roles/{name}", GetRole).Methods("GET")
+
+	// Credential routes
+	databaseRouter.HandleFunc("/creds/{role}", GenerateCredentials).Methods("GET")
+	databaseRouter.HandleFunc("/revoke", Revoke).Methods("POST")
+}
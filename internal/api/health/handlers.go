@@ -4,7 +4,10 @@ import (
 	"net/http"
 	"time"
 
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/healthcheck"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/server"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
 )
 
@@ -25,23 +28,43 @@ func basicHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 // detailedHealthCheck handles the detailed health check request.
 // This endpoint is used for a more detailed health check.
-// It checks the connection to the database
+// It checks the connection to the database, and reports "starting" while Lockbox is still waiting for it.
 func detailedHealthCheck(w http.ResponseWriter, r *http.Request) {
+	// Report that the application is stopping once a shutdown has started, so load balancers stop sending traffic
+	if server.Draining() {
+		response := HealthResponse{
+			Status:    "stopping",
+			Details:   "Shutting down",
+			Timestamp: time.Now(),
+		}
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	// Report that the application is starting until the database is ready for the first time
+	if database.State() == database.StateStarting {
+		response := HealthResponse{
+			Status:    "starting",
+			Details:   "Waiting for the database",
+			Timestamp: time.Now(),
+		}
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
 	// Status is ok by default and details is null
 	httpStatus := http.StatusOK
 	status := "ok"
 	details := "none"
 
 	// Perform database connectivity test
+	// The database object is only pinged if it could be retrieved
 	sqlDB, err := global.Database.DB()
 	if err != nil {
 		httpStatus = http.StatusInternalServerError
 		status = "degraded"
 		details = "Failed to get database object"
-	}
-
-	err = sqlDB.Ping()
-	if err != nil {
+	} else if err := sqlDB.PingContext(r.Context()); err != nil {
 		httpStatus = http.StatusInternalServerError
 		status = "degraded"
 		details = "Database connection failed"
@@ -57,3 +80,69 @@ func detailedHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Encode the response and return
 	utils.WriteJSONResponse(w, httpStatus, response)
 }
+
+// livenessProbe handles the Kubernetes liveness probe.
+// It runs the liveness checks of the registry, which do not depend on the database: a failing liveness probe
+// makes the orchestrator restart the server.
+//
+// Responses:
+// - 200 OK: Returns the result of each check if all of them passed.
+// - 503 Service Unavailable: Returns the result of each check if any of them failed.
+func livenessProbe(w http.ResponseWriter, r *http.Request) {
+	writeProbeReport(w, healthcheck.Run(r.Context(), healthcheck.ProbeLiveness))
+}
+
+// readinessProbe handles the Kubernetes readiness probe.
+// It runs the readiness checks of the registry (database, master key, disk space of the log file),
+// and reports "stopping" without running them once a shutdown has started, so load balancers stop sending traffic.
+//
+// Responses:
+// - 200 OK: Returns the result of each check if all of them passed.
+// - 503 Service Unavailable: Returns the result of each check if any of them failed, or "stopping".
+func readinessProbe(w http.ResponseWriter, r *http.Request) {
+	if server.Draining() {
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, &ProbeResponse{
+			Status:    "stopping",
+			Checks:    map[string]CheckResponse{},
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	writeProbeReport(w, healthcheck.Run(r.Context(), healthcheck.ProbeReadiness))
+}
+
+// startupProbe handles the Kubernetes startup probe.
+// It runs the startup checks of the registry, which pass once the database is reachable and migrated
+// and the master key is usable.
+//
+// Responses:
+// - 200 OK: Returns the result of each check if all of them passed.
+// - 503 Service Unavailable: Returns the result of each check if any of them failed.
+func startupProbe(w http.ResponseWriter, r *http.Request) {
+	writeProbeReport(w, healthcheck.Run(r.Context(), healthcheck.ProbeStartup))
+}
+
+// writeProbeReport writes the report of a probe, with 503 Service Unavailable if any check failed.
+func writeProbeReport(w http.ResponseWriter, report healthcheck.Report) {
+	response := &ProbeResponse{
+		Status:    string(report.Status),
+		Checks:    make(map[string]CheckResponse, len(report.Checks)),
+		Timestamp: time.Now(),
+	}
+	for name, result := range report.Checks {
+		response.Checks[name] = CheckResponse{
+			Status:    string(result.Status),
+			Error:     result.Error,
+			LatencyMS: float64(result.Latency.Microseconds()) / 1000,
+			CheckedAt: result.CheckedAt,
+			Cached:    result.Cached,
+		}
+	}
+
+	httpStatus := http.StatusOK
+	if report.Status != healthcheck.StatusOK {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	utils.WriteJSONResponse(w, httpStatus, response)
+}
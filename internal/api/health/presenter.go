@@ -13,3 +13,33 @@ type HealthResponse struct {
 	// The timestamp when the health check was performed
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// ProbeResponse represents the outcome of a liveness, readiness or startup probe.
+type ProbeResponse struct {
+	// The status of the probe: "ok" if every check passed, "fail" if any check failed, or "stopping" during a shutdown
+	Status string `json:"status"`
+
+	// The result of each check of the probe, by name
+	Checks map[string]CheckResponse `json:"checks"`
+
+	// The timestamp when the probe was answered
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CheckResponse represents the result of a single check of a probe.
+type CheckResponse struct {
+	// The status of the check ("ok" or "fail")
+	Status string `json:"status"`
+
+	// Why the check failed. Not set when it passed
+	Error string `json:"error,omitempty"`
+
+	// How long the check took to run, in milliseconds
+	LatencyMS float64 `json:"latency_ms"`
+
+	// The timestamp when the check ran
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Whether the result was reused from an earlier run rather than checked for this probe
+	Cached bool `json:"cached"`
+}
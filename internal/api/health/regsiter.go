@@ -12,4 +12,9 @@ func RegisterHealthRoutes(router *mux.Router) {
 	// The handler will respond to requests at /healthz, typically used for application health checks
 	healthRouter.HandleFunc("", basicHealthCheck).Methods("GET")
 	healthRouter.HandleFunc("/detailed", detailedHealthCheck).Methods("GET")
+
+	// Register the Kubernetes probes, backed by the checks of the health check registry
+	healthRouter.HandleFunc("/live", livenessProbe).Methods("GET")
+	healthRouter.HandleFunc("/ready", readinessProbe).Methods("GET")
+	healthRouter.HandleFunc("/startup", startupProbe).Methods("GET")
 }
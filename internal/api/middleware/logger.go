@@ -11,7 +11,7 @@ import (
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log_msg := fmt.Sprintf("Request: %s %s", r.Method, r.RequestURI)
-		global.Logger.Debug(log_msg)
+		global.Logger.WithContext(r.Context()).Debug(log_msg)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/metrics"
+)
+
+// statusRecorder is a ResponseWriter that remembers the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// MetricsMiddleware records the count and latency of requests by route template.
+// Requests are labelled with the template of the matched route (e.g., "/secrets/{query}") rather than their path,
+// so that secret keys never end up in the metrics.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		metrics.ObserveRequest(r.Method, routeTemplate(r), recorder.status, time.Since(start))
+	})
+}
+
+// routeTemplate returns the path template of the route that matched the request (e.g., "/secrets/{query}"),
+// or "unknown" if no route matched.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// PathVarsMiddleware decodes the variables of the matched route (e.g., {query} in "/secrets/{query}").
+// The router matches the encoded path, so that a variable can contain an escaped slash (e.g., the secret key
+// "payments/stripe-key" sent as "/secrets/payments%2Fstripe-key"); handlers still read decoded variables.
+// Requests whose variables are not validly escaped are rejected with 400 Bad Request.
+func PathVarsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if len(vars) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decoded := make(map[string]string, len(vars))
+		for name, value := range vars {
+			decodedValue, err := url.PathUnescape(value)
+			if err != nil {
+				utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid escape in request URL"})
+				return
+			}
+			decoded[name] = decodedValue
+		}
+		next.ServeHTTP(w, mux.SetURLVars(r, decoded))
+	})
+}
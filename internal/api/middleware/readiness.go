@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// ReadinessMiddleware rejects requests with 503 Service Unavailable while the database is still starting.
+// Health check endpoints are always served, so orchestrators can observe the startup.
+func ReadinessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if database.State() == database.StateStarting && !strings.HasPrefix(r.URL.Path, "/healthz") {
+			w.Header().Set("Retry-After", "5")
+			utils.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Service is starting"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
+)
+
+// RequestIDMiddleware tags every request with an ID, taken from the X-Request-ID header or generated.
+// The ID is stored in the request context, so that the entries logged by services and for database queries carry it,
+// and it is returned in the X-Request-ID header of every response, as well as in error bodies.
+// Client-provided IDs that are malformed (e.g., too long or with control characters) are replaced by a generated one.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
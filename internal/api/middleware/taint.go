@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/taint"
+)
+
+// TaintMiddleware gives every request an empty taint set in its context.
+// Services add the plaintext values they reveal to the set (e.g., decrypted secrets), and the log sanitizer
+// scrubs them from every entry logged for the request, including error messages that quote them.
+func TaintMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(taint.NewContext(r.Context())))
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware records a server span for every request, continuing the trace of the client if the request
+// carries a W3C traceparent header. The span is stored in the request context, so that the encryptions and
+// database queries made for the request are recorded as its children.
+// Like metrics, spans are named after the route template (e.g., "GET /secrets/{query}"): the path and the query string
+// are never recorded, since they may contain secret keys.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				attribute.String("lockbox.request_id", requestid.FromContext(ctx)),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
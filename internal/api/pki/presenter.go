@@ -0,0 +1,96 @@
+package pki
+
+import (
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/pki"
+)
+
+// CAResponse represents a CA. The private key is never included.
+type CAResponse struct {
+	// Name is the unique name of the CA.
+	Name string `json:"name"`
+
+	// Certificate is the PEM-encoded certificate of the CA.
+	Certificate string `json:"certificate"`
+
+	// Expiration is the expiration time of the CA certificate.
+	Expiration time.Time `json:"expiration"`
+}
+
+// RoleResponse represents a role used to issue certificates.
+type RoleResponse struct {
+	// Name is the unique name of the role.
+	Name string `json:"name"`
+
+	// Issuer is the name of the CA that signs the certificates.
+	Issuer string `json:"issuer"`
+
+	// AllowedDomains lists the domains that certificates may be issued for.
+	AllowedDomains []string `json:"allowed_domains"`
+
+	// AllowBareDomains allows the allowed domains themselves.
+	AllowBareDomains bool `json:"allow_bare_domains"`
+
+	// AllowSubdomains allows subdomains of the allowed domains.
+	AllowSubdomains bool `json:"allow_subdomains"`
+
+	// AllowIPSANs allows IP addresses in the subject alternative names.
+	AllowIPSANs bool `json:"allow_ip_sans"`
+
+	// KeyType is the algorithm of the generated key pairs.
+	KeyType string `json:"key_type"`
+
+	// TTL is the default lifetime of the certificates, in seconds.
+	TTL int `json:"ttl"`
+
+	// MaxTTL is the longest lifetime that may be requested, in seconds.
+	MaxTTL int `json:"max_ttl"`
+}
+
+// CertificateResponse represents an issued certificate and its private key.
+type CertificateResponse struct {
+	// SerialNumber is the hex-encoded serial number, used for revocation.
+	SerialNumber string `json:"serial_number"`
+
+	// Certificate is the PEM-encoded certificate.
+	Certificate string `json:"certificate"`
+
+	// PrivateKey is the PEM-encoded private key. Lockbox does not keep a copy.
+	PrivateKey string `json:"private_key"`
+
+	// CAChain holds the PEM-encoded certificates of the issuing CA and its parents, up to the root.
+	CAChain []string `json:"ca_chain"`
+
+	// Expiration is the expiration time of the certificate.
+	Expiration time.Time `json:"expiration"`
+}
+
+// RevokeResponse represents a revoked certificate.
+type RevokeResponse struct {
+	// SerialNumber is the hex-encoded serial number of the revoked certificate.
+	SerialNumber string `json:"serial_number"`
+
+	// RevokedAt is the revocation time.
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// newCAResponse creates the presenter of a CA.
+func newCAResponse(ca *pki.CA) *CAResponse {
+	return &CAResponse{Name: ca.Name, Certificate: ca.Certificate, Expiration: ca.NotAfter}
+}
+
+// newRoleResponse creates the presenter of a role.
+func newRoleResponse(role *pki.Role) *RoleResponse {
+	return &RoleResponse{
+		Name:             role.Name,
+		Issuer:           role.Issuer,
+		AllowedDomains:   role.AllowedDomains,
+		AllowBareDomains: role.AllowBareDomains,
+		AllowSubdomains:  role.AllowSubdomains,
+		AllowIPSANs:      role.AllowIPSANs,
+		KeyType:          string(role.KeyType),
+		TTL:              role.TTL,
+		MaxTTL:           role.MaxTTL,
+	}
+}
@@ -0,0 +1,53 @@
+package pki
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/pki"
+)
+
+// PKIService is the service layer that handles business logic for certificate authorities and certificates.
+// This package variable allows handlers to interact with the PKI service.
+var PKIService pki.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterPKIRoutes registers the HTTP routes of the PKI engine, which issues X.509 certificates from CAs held by Lockbox.
+//
+// Parameters:
+// - router: The main router to which the PKI subrouter will be attached.
+// - pkiService: The PKI service that will be used to handle the business logic.
+//
+// Routes:
+// - POST /pki/ca/root: Generates a self-signed root CA.
+// - POST /pki/ca/intermediate: Generates an intermediate CA signed by another CA.
+// - POST /pki/ca/import: Imports an existing CA certificate and private key.
+// - GET /pki/ca/{name}: Retrieves the certificate of a CA.
+// - GET /pki/crl/{name}: Retrieves the certificate revocation list of a CA.
+// - PUT /pki/roles/{name}: Creates or replaces a role.
+// - GET /pki/roles/{name}: Retrieves a role.
+// - POST /pki/issue/{role}: Issues a certificate with a role.
+// - POST /pki/revoke: Revokes a certificate by serial number.
+func RegisterPKIRoutes(router *mux.Router, pkiService pki.Service) {
+	// Assign the provided PKI service to the package-level variable for use in the handler functions.
+	PKIService = pkiService
+
+	// Create a subrouter for the PKI engine under the /pki path.
+	pkiRouter := router.PathPrefix("/pki").Subrouter()
+
+	// CA routes
+	pkiRouter.HandleFunc("/ca/root", GenerateRootCA).Methods("POST")
+	pkiRouter.HandleFunc("/ca/intermediate", GenerateIntermediateCA).Methods("POST")
+	pkiRouter.HandleFunc("/ca/import", ImportCA).Methods("POST")
+	pkiRouter.HandleFunc("/ca/{name}", GetCA).Methods("GET")
+	pkiRouter.HandleFunc("/crl/{name}", GetCRL).Methods("GET")
+
+	// Role routes
+	pkiRouter.HandleFunc("/roles/{name}", SaveRole).Methods("PUT")
+	pkiRouter.HandleFunc("/roles/{name}", GetRole).Methods("GET")
+
+	// Certificate routes
+	pkiRouter.HandleFunc("/issue/{role}", Issue).Methods("POST")
+	pkiRouter.HandleFunc("/revoke", Revoke).Methods("POST")
+}
@@ -1,41 +1,116 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
+	dbcreds_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/dbcreds"
 	health_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/health"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/api/middleware"
+	pki_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/pki"
 	secrets_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/secrets"
+	sshca_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/sshca"
+	sys_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/sys"
+	totp_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/totp"
+	transit_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/transit"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/dbcreds"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/pki"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/sshca"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/totp"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/transit"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
 )
 
+// middlewares are applied to every request, in order.
+var middlewares = []mux.MiddlewareFunc{
+	middleware.RequestIDMiddleware,
+	middleware.PathVarsMiddleware,
+	middleware.TaintMiddleware,
+	middleware.TracingMiddleware,
+	middleware.MetricsMiddleware,
+	middleware.LoggingMiddleware,
+	middleware.AuthenticationMiddleware,
+	middleware.CORSMiddleware,
+	middleware.ReadinessMiddleware,
+}
+
 // SetupRouter initializes the router and defines the routes for all services.
 // This function sets up the base router, applies any global middleware
 // and registers all service-specific routes.
 func SetupRouter() *mux.Router {
 	// Initialize a new router using Gorilla Mux
-	router := mux.NewRouter()
+	// Routes are matched on the encoded path, so that escaped slashes stay inside path variables (e.g., secret keys)
+	router := mux.NewRouter().UseEncodedPath()
 
 	// Apply global middleware for security, logging, CORS, etc.
 	global.Logger.Info("Adding middlewares to router")
-	router.Use(middleware.LoggingMiddleware)
-	router.Use(middleware.AuthenticationMiddleware)
-	router.Use(middleware.CORSMiddleware)
+	applyMiddlewares(router)
 
 	// Initialize the repositories
 	global.Logger.Info("Initializing repositories")
+	leaseRepository := leases.NewRepository(global.Database)
 	secretsRepository := secrets.NewRepository(global.Database)
+	rotationRepository := rotation.NewRepository(global.Database)
+	transitRepository := transit.NewRepository(global.Database)
+	pkiRepository := pki.NewRepository(global.Database)
+	sshRepository := sshca.NewRepository(global.Database)
+	totpRepository := totp.NewRepository(global.Database)
+	databaseRepository := dbcreds.NewRepository(global.Database)
 
 	// Initialize the services
 	global.Logger.Info("Initializing services")
+	leaseService := leases.NewService(leaseRepository)
 	secretsService := secrets.NewService(secretsRepository)
+	rotationService := rotation.NewService(rotationRepository, secretsService)
+	transitService := transit.NewService(transitRepository)
+	pkiService := pki.NewService(pkiRepository)
+	sshService := sshca.NewService(sshRepository)
+	totpService := totp.NewService(totpRepository)
+	databaseService := dbcreds.NewService(databaseRepository, leaseService)
 
 	// Register service-specific routes
 	// Each group of routes is handled by a dedicated function to maintain separation of concerns
 	global.Logger.Info("Registering routes")
 	health_handler.RegisterHealthRoutes(router)
-	secrets_handler.RegisterSecretsRoutes(router, secretsService)
+	sys_handler.RegisterSysRoutes(router, leaseService)
+	secrets_handler.RegisterSecretsRoutes(router, secretsService, rotationService)
+	transit_handler.RegisterTransitRoutes(router, transitService)
+	pki_handler.RegisterPKIRoutes(router, pkiService)
+	sshca_handler.RegisterSSHRoutes(router, sshService)
+	totp_handler.RegisterTOTPRoutes(router, totpService)
+	dbcreds_handler.RegisterDatabaseRoutes(router, databaseService)
 
 	// Return the configured router
 	return router
 }
+
+// applyMiddlewares applies the global middlewares to the routes of the router, and to its 404 and 405 responses.
+// The router only runs middlewares for matched routes, so its handlers of unmatched requests are wrapped separately;
+// this way, every response gets a request ID and unmatched requests are recorded under the "unknown" route.
+func applyMiddlewares(router *mux.Router) {
+	router.Use(middlewares...)
+	router.NotFoundHandler = withMiddlewares(http.HandlerFunc(notFound))
+	router.MethodNotAllowedHandler = withMiddlewares(http.HandlerFunc(methodNotAllowed))
+}
+
+// withMiddlewares wraps a handler in the global middlewares, the first one being the outermost.
+func withMiddlewares(handler http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// notFound responds to requests that match no route.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+}
+
+// methodNotAllowed responds to requests whose path matches a route, but not with their method.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/metrics"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
+)
+
+// TestUnmatchedRequestsGoThroughMiddlewares tests that 404 and 405 responses get a request ID, in their header
+// and body, and are recorded in the metrics under the "unknown" route.
+func TestUnmatchedRequestsGoThroughMiddlewares(t *testing.T) {
+	global.Logger = logrus.New()
+	router := mux.NewRouter().UseEncodedPath()
+	applyMiddlewares(router)
+	router.HandleFunc("/healthz/live", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	// Health check paths are served while the database is starting, so they are used to reach the 404 and 405 handlers
+	for _, test := range []struct {
+		path      string
+		status    int
+		requestID string
+	}{
+		{path: "/healthz/unknown", status: http.StatusNotFound, requestID: "not-found-1"},
+		{path: "/healthz/live", status: http.StatusMethodNotAllowed, requestID: "method-not-allowed-1"},
+	} {
+		request := httptest.NewRequest(http.MethodPost, test.path, nil)
+		request.Header.Set(requestid.Header, test.requestID)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, test.status, recorder.Code, test.path)
+		assert.Equal(t, test.requestID, recorder.Header().Get(requestid.Header), test.path)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body), test.path)
+		assert.Equal(t, test.requestID, body["request_id"], test.path)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	scraped, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(scraped), `lockbox_http_requests_total{method="POST",route="unknown",status="404"} 1`)
+	assert.Contains(t, string(scraped), `lockbox_http_requests_total{method="POST",route="unknown",status="405"} 1`)
+}
@@ -1,12 +1,15 @@
 package secrets
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
 	"os"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
 )
@@ -15,40 +18,79 @@ import (
 // It expects a JSON body with the "key" and "plain_text_secret" fields, and the secret is encrypted using
 // the "MASTER_CRYPTO_PASS" environment variable.
 // If successful, it returns the key of the newly created secret.
+// When "client_encrypted" is true, "secret_value" is an envelope encrypted by the client and is stored exactly as sent.
+// When "generate" is set instead of "secret_value", the value is generated with the named policy. It is only
+// included in the response when "return_value" is true, and cannot be read back except through GET /secrets/{query}.
 //
 // Expected JSON request body:
 //
 //	{
 //	    "secret_key": "unique_key_for_secret",
-//	    "secret_value": "sensitive_value_to_store"
+//	    "secret_value": "sensitive_value_to_store",
+//	    "client_encrypted": false
+//	}
+//
+// Expected JSON request body to generate the value:
+//
+//	{
+//	    "secret_key": "unique_key_for_secret",
+//	    "generate": "policy_name",
+//	    "return_value": true
 //	}
 //
 // Responses:
-// - 201 Created: Returns the key of the newly created secret.
-// - 400 Bad Request: Returns if the request body is invalid.
+// - 201 Created: Returns the key of the newly created secret, and the generated value if requested.
+// - 400 Bad Request: Returns if the request body is invalid or the policy does not exist.
 // - 500 Internal Server Error: Returns if the secret creation fails.
 func CreateSecret(w http.ResponseWriter, r *http.Request) {
 	// Get JSON request body
 	var req struct {
-		SecretKey   string `json:"secret_key" validate:"required"`
-		SecretValue string `json:"secret_value" validate:"required"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-		return
+		SecretKey       string `json:"secret_key" validate:"required"`
+		SecretValue     string `json:"secret_value" validate:"required_without=Generate,excluded_with=Generate"`
+		ClientEncrypted bool   `json:"client_encrypted"`
+		Generate        string `json:"generate" validate:"excluded_with=ClientEncrypted"`
+		ReturnValue     bool   `json:"return_value" validate:"excluded_without=Generate"`
 	}
-
-	// Validate the decoded struct using the validator package
-	if err := validate.Struct(&req); err != nil {
-		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if !utils.DecodeRequest(w, r, validate, &req) {
 		return
 	}
 
 	// Get master key from the environment
 	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
 
+	// Generate the value with the requested policy
+	if req.Generate != "" {
+		secretID, secretKey, value, err := SecretsService.GenerateSecret(r.Context(), req.SecretKey, req.Generate, masterCryptoPass)
+		if errors.Is(err, secrets.ErrPolicyNotFound) {
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create secret"})
+			return
+		}
+
+		// Only reveal the generated value when the caller asked for it
+		presenter := &SecretResponseUUID{
+			ID:  secretID,
+			Key: secretKey,
+		}
+		if req.ReturnValue {
+			presenter.Value = value
+		}
+		utils.WriteJSONResponse(w, http.StatusCreated, presenter)
+		return
+	}
+
 	// Create the secret using the service layer
-	secretID, secretKey, err := SecretsService.CreateSecret(req.SecretKey, req.SecretValue, masterCryptoPass)
+	// Client-encrypted envelopes are stored as sent, everything else is encrypted with the master key
+	var secretID, secretKey string
+	var err error
+	if req.ClientEncrypted {
+		secretID, secretKey, err = SecretsService.CreateClientEncryptedSecret(r.Context(), req.SecretKey, req.SecretValue)
+	} else {
+		secretID, secretKey, err = SecretsService.CreateSecret(r.Context(), req.SecretKey, req.SecretValue, masterCryptoPass)
+	}
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create secret"})
 		return
@@ -64,6 +106,7 @@ func CreateSecret(w http.ResponseWriter, r *http.Request) {
 
 // GetSecretByQuery retrieves an encrypted secret based on the provided query (UUID or key).
 // The secret is decrypted using the "MASTER_CRYPTO_PASS" environment variable before being returned.
+// Client-encrypted secrets are returned as the stored envelope, flagged with "client_encrypted".
 // It supports lookup by either the UUID or a unique key, depending on the query value.
 //
 // Responses:
@@ -80,7 +123,7 @@ func GetSecretByQuery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get secret based on query
-	secret, err := getSecretFromQuery(query)
+	secret, err := getSecretFromQuery(r.Context(), query)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
 		return
@@ -90,7 +133,7 @@ func GetSecretByQuery(w http.ResponseWriter, r *http.Request) {
 	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
 
 	// Decrypt the secret
-	decryptedSecret, err := SecretsService.DecryptSecret(*secret, masterCryptoPass)
+	decryptedSecret, err := SecretsService.DecryptSecret(r.Context(), *secret, masterCryptoPass)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Something went wrong"})
 		return
@@ -98,20 +141,24 @@ func GetSecretByQuery(w http.ResponseWriter, r *http.Request) {
 
 	// Create and return presenter
 	presenter := &SecretResponsePlain{
-		Key:   secret.Key,
-		Value: decryptedSecret,
+		Key:             secret.Key,
+		Value:           decryptedSecret,
+		ClientEncrypted: secret.ClientEncrypted,
 	}
 	utils.WriteJSONResponse(w, http.StatusOK, presenter)
 }
 
 // UpdateSecret handles updating an existing secret based on the provided query (UUID or key).
 // It expects a JSON body with the new "plain_text_secret" value, which will replace the old encrypted secret.
-// The secret is re-encrypted with the "MASTER_CRYPTO_PASS" environment variable.
+// The secret is re-encrypted with the "MASTER_CRYPTO_PASS" environment variable, unless it is client-encrypted,
+// in which case the new value is the client's envelope and is stored as sent.
+// The encryption mode of a secret cannot be changed: if "client_encrypted" is given, it must match the secret.
 //
 // Expected JSON request body:
 //
 //	{
-//	    "secret_value": "new_secret_value"
+//	    "secret_value": "new_secret_value",
+//	    "client_encrypted": false
 //	}
 //
 // Responses:
@@ -129,31 +176,36 @@ func UpdateSecret(w http.ResponseWriter, r *http.Request) {
 
 	// Get JSON request body
 	var req struct {
-		NewSecretValue string `json:"secret_value" validate:"required"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-		return
+		NewSecretValue  string `json:"secret_value" validate:"required"`
+		ClientEncrypted *bool  `json:"client_encrypted"`
 	}
-
-	// Validate the decoded struct using the validator package
-	if err := validate.Struct(&req); err != nil {
-		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if !utils.DecodeRequest(w, r, validate, &req) {
 		return
 	}
 
 	// Get secret based on query
-	secret, err := getSecretFromQuery(query)
+	secret, err := getSecretFromQuery(r.Context(), query)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
 		return
 	}
 
+	// Refuse to switch a secret between server-side and client-side encryption
+	if req.ClientEncrypted != nil && *req.ClientEncrypted != secret.ClientEncrypted {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "The encryption mode of a secret cannot be changed"})
+		return
+	}
+
 	// Get master key from the environment
 	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
 
-	// Update the secret with the new plain text secret
-	if err := SecretsService.UpdateSecret(secret.ID.String(), req.NewSecretValue, masterCryptoPass); err != nil {
+	// Update the secret with the new plain text secret (or the new envelope for client-encrypted secrets)
+	if secret.ClientEncrypted {
+		err = SecretsService.UpdateClientEncryptedSecret(r.Context(), secret.ID.String(), req.NewSecretValue)
+	} else {
+		err = SecretsService.UpdateSecret(r.Context(), secret.ID.String(), req.NewSecretValue, masterCryptoPass)
+	}
+	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update secret"})
 		return
 	}
@@ -178,14 +230,14 @@ func DeleteSecret(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get secret based on query
-	secret, err := getSecretFromQuery(query)
+	secret, err := getSecretFromQuery(r.Context(), query)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
 		return
 	}
 
 	// Delete the secret
-	if err := SecretsService.DeleteSecret(secret.ID.String()); err != nil {
+	if err := SecretsService.DeleteSecret(r.Context(), secret.ID.String()); err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete secret"})
 		return
 	}
@@ -193,15 +245,383 @@ func DeleteSecret(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Secret deleted successfully"})
 }
 
+// ImportSecrets handles importing a dotenv, JSON or YAML document as a set of secrets.
+// Every key in the document is stored under the given prefix, and keys that already exist are
+// handled according to the conflict policy ("skip" by default, "overwrite" or "fail").
+//
+// Expected JSON request body:
+//
+//	{
+//	    "prefix": "app/production/",
+//	    "format": "dotenv",
+//	    "content": "DB_PASSWORD=hunter2\nAPI_TOKEN=abc123\n",
+//	    "conflict_policy": "skip"
+//	}
+//
+// Responses:
+// - 200 OK: Returns the keys that were created, updated and skipped.
+// - 400 Bad Request: Returns if the request body, format, policy or document is invalid.
+// - 409 Conflict: Returns if the policy is "fail" and some keys already exist,
+// or if the policy is "overwrite" and some of the existing secrets are client-encrypted.
+// - 500 Internal Server Error: Returns if the import fails.
+func ImportSecrets(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Prefix         string `json:"prefix"`
+		Format         string `json:"format" validate:"required"`
+		Content        string `json:"content" validate:"required"`
+		ConflictPolicy string `json:"conflict_policy"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Validate the format and the conflict policy
+	format, err := secrets.ParseFormat(req.Format)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Unsupported format"})
+		return
+	}
+	policy, err := secrets.ParseConflictPolicy(req.ConflictPolicy)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Unsupported conflict policy"})
+		return
+	}
+
+	// Parse the document
+	values, err := secrets.ParseSecrets(format, []byte(req.Content))
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid document: " + err.Error()})
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	// Import the secrets using the service layer
+	result, err := SecretsService.ImportSecrets(r.Context(), req.Prefix, values, policy, masterCryptoPass)
+	if errors.Is(err, secrets.ErrImportConflict) || errors.Is(err, secrets.ErrClientEncrypted) {
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to import secrets"})
+		return
+	}
+
+	// Create and return presenter
+	presenter := &SecretsImportResponse{
+		Created: result.Created,
+		Updated: result.Updated,
+		Skipped: result.Skipped,
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, presenter)
+}
+
+// ExportSecrets renders every secret stored under a key prefix as a dotenv, JSON or YAML document.
+// The values are decrypted with the "MASTER_CRYPTO_PASS" environment variable, and the prefix is stripped from the keys.
+// Exporting must be enabled with "allow_export" in the [security] section, and every export is logged.
+//
+// Query parameters:
+// - prefix: The key prefix to export (required).
+// - format: "dotenv", "json" or "yaml" (defaults to "dotenv").
+//
+// Responses:
+// - 200 OK: Returns the rendered document.
+// - 400 Bad Request: Returns if the prefix is missing or the format is invalid.
+// - 403 Forbidden: Returns if exporting is disabled.
+// - 409 Conflict: Returns if some of the secrets are client-encrypted, listing their keys, since the server cannot decrypt them.
+// - 500 Internal Server Error: Returns if decryption or rendering fails.
+func ExportSecrets(w http.ResponseWriter, r *http.Request) {
+	// Refuse the export unless it was explicitly enabled
+	if global.Config == nil || !global.Config.Security.AllowExport {
+		utils.WriteJSONResponse(w, http.StatusForbidden, map[string]string{"error": "Secret export is disabled"})
+		return
+	}
+
+	// Get the prefix and format from the query string
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Missing prefix in request URL"})
+		return
+	}
+	formatValue := r.URL.Query().Get("format")
+	if formatValue == "" {
+		formatValue = string(secrets.FormatDotenv)
+	}
+	format, err := secrets.ParseFormat(formatValue)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Unsupported format"})
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	// Decrypt the secrets under the prefix
+	values, err := SecretsService.ExportSecrets(r.Context(), prefix, masterCryptoPass)
+	if errors.Is(err, secrets.ErrClientEncrypted) {
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to export secrets"})
+		return
+	}
+
+	// Render the document
+	document, err := secrets.RenderSecrets(format, values)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to export secrets"})
+		return
+	}
+
+	// Record who exported what, since an export reveals many secrets at once
+	// Only the peer address is logged, since forwarding headers are set by the client and cannot be trusted
+	global.Logger.WithContext(r.Context()).Infof(
+		"Secrets exported by %s (user agent '%s'): prefix '%s', format '%s', %d secrets",
+		r.RemoteAddr, r.UserAgent(), prefix, format, len(values),
+	)
+
+	utils.WriteRawResponse(w, http.StatusOK, exportContentTypes[format], document)
+}
+
+// SavePolicy handles creating or replacing a generation policy, used by the "generate" field of POST /secrets.
+// "length" is the number of characters of a password, the number of words of a passphrase, or the number of random bytes of a hex or base64 value.
+// "classes" maps the character classes of a password ("lowercase", "uppercase", "digits", "symbols") to the minimum number of characters of each.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "type": "password",
+//	    "length": 24,
+//	    "classes": {"lowercase": 1, "uppercase": 1, "digits": 1, "symbols": 0},
+//	    "exclude_ambiguous": true
+//	}
+//
+// Responses:
+// - 200 OK: Returns the saved policy.
+// - 400 Bad Request: Returns if the request body or policy settings are invalid.
+// - 500 Internal Server Error: Returns if the policy cannot be stored.
+func SavePolicy(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Type             string         `json:"type" validate:"required"`
+		Length           int            `json:"length" validate:"required"`
+		Classes          map[string]int `json:"classes"`
+		ExcludeAmbiguous bool           `json:"exclude_ambiguous"`
+		Separator        string         `json:"separator"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Build the policy
+	policy := &secrets.Policy{
+		Name:             mux.Vars(r)["name"],
+		Type:             secrets.PolicyType(req.Type),
+		Length:           req.Length,
+		Classes:          make(map[secrets.CharacterClass]int, len(req.Classes)),
+		ExcludeAmbiguous: req.ExcludeAmbiguous,
+		Separator:        req.Separator,
+	}
+	for class, count := range req.Classes {
+		policy.Classes[secrets.CharacterClass(class)] = count
+	}
+
+	// Save the policy using the service layer
+	err := SecretsService.SavePolicy(r.Context(), policy)
+	if errors.Is(err, secrets.ErrInvalidPolicy) {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save policy"})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newPolicyResponse(policy))
+}
+
+// GetPolicy retrieves a generation policy by name.
+//
+// Responses:
+// - 200 OK: Returns the policy.
+// - 404 Not Found: Returns if the policy does not exist.
+// - 500 Internal Server Error: Returns if the policy cannot be retrieved.
+func GetPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := SecretsService.GetPolicy(r.Context(), mux.Vars(r)["name"])
+	if errors.Is(err, secrets.ErrPolicyNotFound) {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Policy not found"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Something went wrong"})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newPolicyResponse(policy))
+}
+
+// newPolicyResponse creates the presenter of a generation policy.
+func newPolicyResponse(policy *secrets.Policy) *PolicyResponse {
+	classes := make(map[string]int, len(policy.Classes))
+	for class, count := range policy.Classes {
+		classes[string(class)] = count
+	}
+	return &PolicyResponse{
+		Name:             policy.Name,
+		Type:             string(policy.Type),
+		Length:           policy.Length,
+		Classes:          classes,
+		ExcludeAmbiguous: policy.ExcludeAmbiguous,
+		Separator:        policy.Separator,
+	}
+}
+
+// SaveRotation handles creating or replacing the rotation schedule of a secret.
+// "schedule" is a cron expression (e.g., "0 3 * * *") or an interval (e.g., "@every 720h"). "rotator" applies the new value:
+// "random" only stores it, "postgres" changes the password of a user through a database connection, and "webhook"
+// posts it to a local URL. New values follow the generation policy named by "policy", or a 32-character password.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "schedule": "0 3 * * 0",
+//	    "rotator": "postgres",
+//	    "options": {"connection": "app", "username": "app_user"}
+//	}
+//
+// Responses:
+// - 200 OK: Returns the rotation with its next run.
+// - 400 Bad Request: Returns if the request body, the schedule, the rotator or its options are invalid, or the secret is client-encrypted.
+// - 404 Not Found: Returns if the secret cannot be found using the given query.
+// - 500 Internal Server Error: Returns if the rotation cannot be stored.
+func SaveRotation(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Schedule string            `json:"schedule" validate:"required"`
+		Rotator  string            `json:"rotator" validate:"required"`
+		Policy   string            `json:"policy"`
+		Options  map[string]string `json:"options"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get secret based on query
+	secret, err := getSecretFromQuery(r.Context(), mux.Vars(r)["query"])
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
+		return
+	}
+
+	// Save the rotation using the service layer
+	err = RotationService.SaveRotation(&rotation.Rotation{
+		SecretID: secret.ID,
+		Schedule: req.Schedule,
+		Rotator:  req.Rotator,
+		Policy:   req.Policy,
+		Options:  req.Options,
+	})
+	if errors.Is(err, rotation.ErrInvalidRotation) || errors.Is(err, secrets.ErrPolicyNotFound) {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save rotation"})
+		return
+	}
+
+	writeRotation(w, secret)
+}
+
+// GetRotation retrieves the rotation schedule of a secret, with the outcome of its last run and the time of the next one.
+//
+// Responses:
+// - 200 OK: Returns the rotation.
+// - 404 Not Found: Returns if the secret cannot be found using the given query, or if it is not rotated.
+// - 500 Internal Server Error: Returns if the rotation cannot be retrieved.
+func GetRotation(w http.ResponseWriter, r *http.Request) {
+	// Get secret based on query
+	secret, err := getSecretFromQuery(r.Context(), mux.Vars(r)["query"])
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
+		return
+	}
+
+	writeRotation(w, secret)
+}
+
+// DeleteRotation stops rotating a secret. The secret keeps its current value.
+//
+// Responses:
+// - 200 OK: Returns if the rotation was deleted.
+// - 404 Not Found: Returns if the secret cannot be found using the given query, or if it is not rotated.
+// - 500 Internal Server Error: Returns if the rotation cannot be deleted.
+func DeleteRotation(w http.ResponseWriter, r *http.Request) {
+	// Get secret based on query
+	secret, err := getSecretFromQuery(r.Context(), mux.Vars(r)["query"])
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
+		return
+	}
+
+	err = RotationService.DeleteRotation(secret.ID)
+	if errors.Is(err, rotation.ErrRotationNotFound) {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Rotation not found"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete rotation"})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Rotation deleted successfully"})
+}
+
+// writeRotation loads the rotation of a secret and writes it as the response.
+func writeRotation(w http.ResponseWriter, secret *secrets.Secret) {
+	secretRotation, err := RotationService.GetRotation(secret.ID)
+	if errors.Is(err, rotation.ErrRotationNotFound) {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Rotation not found"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Something went wrong"})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &RotationResponse{
+		Key:       secret.Key,
+		Schedule:  secretRotation.Schedule,
+		Rotator:   secretRotation.Rotator,
+		Policy:    secretRotation.Policy,
+		Options:   secretRotation.Options,
+		Status:    string(secretRotation.Status),
+		LastRunAt: secretRotation.LastRunAt,
+		LastError: secretRotation.LastError,
+		NextRunAt: secretRotation.NextRunAt,
+	})
+}
+
+// exportContentTypes maps each export format to the Content-Type of the response.
+var exportContentTypes = map[secrets.Format]string{
+	secrets.FormatDotenv: "text/plain; charset=utf-8",
+	secrets.FormatJSON:   "application/json",
+	secrets.FormatYAML:   "application/yaml",
+}
+
 // getSecretFromQuery determines whether the query is a UUID or a unique key and retrieves the corresponding secret.
 // If the query is a valid UUID, it retrieves the secret by ID; otherwise, it retrieves the secret by key.
 //
 // Parameters:
+// - ctx: The context of the request, passed down to the service.
 // - query: The UUID or key used to look up the secret.
 //
 // Returns:
 // - *secrets.Secret: The retrieved secret, or an error if not found or if the query is invalid.
-func getSecretFromQuery(query string) (*secrets.Secret, error) {
+func getSecretFromQuery(ctx context.Context, query string) (*secrets.Secret, error) {
 	// Define if it's a UUID or a key
 	queryType := "uuid"
 	_, err := uuid.Parse(query)
@@ -213,9 +633,9 @@ func getSecretFromQuery(query string) (*secrets.Secret, error) {
 	var secret *secrets.Secret
 	switch queryType {
 	case "uuid":
-		secret, err = SecretsService.GetEncryptedSecretByID(query)
+		secret, err = SecretsService.GetEncryptedSecretByID(ctx, query)
 	case "key":
-		secret, err = SecretsService.GetEncryptedSecretByKey(query)
+		secret, err = SecretsService.GetEncryptedSecretByKey(ctx, query)
 	}
 
 	return secret, err
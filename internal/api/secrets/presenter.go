@@ -1,5 +1,7 @@
 package secrets
 
+import "time"
+
 // SecretResponseUUID represents the structure of a secret containing the UUID.
 type SecretResponseUUID struct {
 	// ID is the UUId associated with the secret
@@ -7,6 +9,9 @@ type SecretResponseUUID struct {
 
 	// Key is the unique identifier or key associated with the secret.
 	Key string `json:"key"`
+
+	// Value is the generated value of the secret. It is only set when the value was generated and the client asked for it.
+	Value string `json:"value,omitempty"`
 }
 
 // SecretResponsePlain represents the structure of a secret without encryption.
@@ -17,5 +22,72 @@ type SecretResponsePlain struct {
 
 	// Value is the decrypted value of the secret.
 	// This represents the actual sensitive information that was previously encrypted and is now being returned in plain text.
+	// For client-encrypted secrets, this is the envelope exactly as it was sent by the client.
 	Value string `json:"value"`
+
+	// ClientEncrypted indicates that Value is a client-encrypted envelope that the client must decrypt itself.
+	ClientEncrypted bool `json:"client_encrypted"`
+}
+
+// SecretsImportResponse represents the outcome of a bulk import.
+type SecretsImportResponse struct {
+	// Created lists the keys of the secrets that were created.
+	Created []string `json:"created"`
+
+	// Updated lists the keys of the existing secrets that were overwritten.
+	Updated []string `json:"updated"`
+
+	// Skipped lists the keys of the existing secrets that were left untouched.
+	Skipped []string `json:"skipped"`
+}
+
+// PolicyResponse represents a generation policy.
+type PolicyResponse struct {
+	// Name is the unique name of the policy.
+	Name string `json:"name"`
+
+	// Type is the kind of value generated by the policy ("password", "passphrase", "hex" or "base64").
+	Type string `json:"type"`
+
+	// Length is the number of characters, words or random bytes of the generated values.
+	Length int `json:"length"`
+
+	// Classes maps the character classes of a password to the minimum number of characters of each.
+	Classes map[string]int `json:"classes"`
+
+	// ExcludeAmbiguous indicates that easily confused characters are left out of passwords.
+	ExcludeAmbiguous bool `json:"exclude_ambiguous"`
+
+	// Separator joins the words of a passphrase.
+	Separator string `json:"separator"`
+}
+
+// RotationResponse represents the rotation schedule of a secret and the outcome of its last run.
+type RotationResponse struct {
+	// Key is the key of the rotated secret.
+	Key string `json:"key"`
+
+	// Schedule is the cron expression or interval of the rotation.
+	Schedule string `json:"schedule"`
+
+	// Rotator is the name of the rotator that applies new values.
+	Rotator string `json:"rotator"`
+
+	// Policy is the name of the generation policy of new values, if any.
+	Policy string `json:"policy,omitempty"`
+
+	// Options holds the settings of the rotator.
+	Options map[string]string `json:"options"`
+
+	// Status is the outcome of the last run ("scheduled", "succeeded" or "failed").
+	Status string `json:"status"`
+
+	// LastRunAt is the time of the last run, if the rotation has run.
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+
+	// LastError is the error of the last run, if it failed.
+	LastError string `json:"last_error,omitempty"`
+
+	// NextRunAt is the time of the next run.
+	NextRunAt time.Time `json:"next_run_at"`
 }
@@ -3,6 +3,7 @@ package secrets
 import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
 )
 
@@ -10,6 +11,9 @@ import (
 // This package variable allows handlers to interact with the secret management service.
 var SecretsService secrets.Service
 
+// RotationService is the service layer that handles the scheduled rotation of secrets.
+var RotationService rotation.Service
+
 // validate is a JSON validator to check JSON request bodies
 var validate = validator.New()
 
@@ -20,15 +24,24 @@ var validate = validator.New()
 // Parameters:
 // - router: The main router to which the secrets subrouter will be attached.
 // - secretsService: The secrets service that will be used to handle the business logic related to secret management.
+// - rotationService: The rotation service that will be used to schedule the rotation of secrets.
 //
 // Routes:
 // - POST /secrets: Creates a new secret.
+// - POST /secrets/import: Imports a dotenv, JSON or YAML document as secrets.
+// - GET /secrets/export: Exports the secrets under a key prefix as a dotenv, JSON or YAML document.
+// - PUT /secrets/policies/{name}: Creates or replaces a generation policy.
+// - GET /secrets/policies/{name}: Retrieves a generation policy.
 // - GET /secrets/{query}: Retrieves a secret by its UUID or key.
 // - PUT /secrets/{query}: Updates an existing secret by its UUID or key.
 // - DELETE /secrets/{query}: Deletes a secret by its UUID or key.
-func RegisterSecretsRoutes(router *mux.Router, secretsService secrets.Service) {
-	// Assign the provided secrets service to the package-level variable for use in the handler functions.
+// - PUT /secrets/{query}/rotation: Creates or replaces the rotation schedule of a secret.
+// - GET /secrets/{query}/rotation: Retrieves the rotation schedule and status of a secret.
+// - DELETE /secrets/{query}/rotation: Stops rotating a secret.
+func RegisterSecretsRoutes(router *mux.Router, secretsService secrets.Service, rotationService rotation.Service) {
+	// Assign the provided services to the package-level variables for use in the handler functions.
 	SecretsService = secretsService
+	RotationService = rotationService
 
 	// Create a subrouter for secret management under the /secrets path.
 	secretsRouter := router.PathPrefix("/secrets").Subrouter()
@@ -38,6 +51,17 @@ func RegisterSecretsRoutes(router *mux.Router, secretsService secrets.Service) {
 	// POST /secrets: This route is used to create a new secret.
 	secretsRouter.HandleFunc("", CreateSecret).Methods("POST")
 
+	// POST /secrets/import: This route imports a document of secrets under a key prefix.
+	secretsRouter.HandleFunc("/import", ImportSecrets).Methods("POST")
+
+	// GET /secrets/export: This route exports the secrets under a key prefix.
+	// It is registered before /{query} so that "export" is not treated as a secret key.
+	secretsRouter.HandleFunc("/export", ExportSecrets).Methods("GET")
+
+	// PUT and GET /secrets/policies/{name}: These routes manage the policies used to generate secret values.
+	secretsRouter.HandleFunc("/policies/{name}", SavePolicy).Methods("PUT")
+	secretsRouter.HandleFunc("/policies/{name}", GetPolicy).Methods("GET")
+
 	// GET /secrets/{query}: This route retrieves a secret by its UUID or unique key.
 	secretsRouter.HandleFunc("/{query}", GetSecretByQuery).Methods("GET")
 
@@ -46,4 +70,9 @@ func RegisterSecretsRoutes(router *mux.Router, secretsService secrets.Service) {
 
 	// DELETE /secrets/{query}: This route deletes a secret by its UUID or key.
 	secretsRouter.HandleFunc("/{query}", DeleteSecret).Methods("DELETE")
+
+	// PUT, GET and DELETE /secrets/{query}/rotation: These routes manage the scheduled rotation of a secret.
+	secretsRouter.HandleFunc("/{query}/rotation", SaveRotation).Methods("PUT")
+	secretsRouter.HandleFunc("/{query}/rotation", GetRotation).Methods("GET")
+	secretsRouter.HandleFunc("/{query}/rotation", DeleteRotation).Methods("DELETE")
 }
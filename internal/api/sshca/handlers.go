@@ -0,0 +1,226 @@
+package sshca
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/sshca"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// CreateCA handles generating the SSH CA key, or importing an existing one when "private_key" is set.
+// The private key is encrypted with the "MASTER_CRYPTO_PASS" environment variable and never leaves Lockbox.
+// There is a single SSH CA, which cannot be replaced once created.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "key_type": "ed25519",
+//	    "private_key": ""
+//	}
+//
+// Responses:
+// - 201 Created: Returns the CA public key.
+// - 400 Bad Request: Returns if the request body, key type or private key is invalid.
+// - 409 Conflict: Returns if the CA already exists.
+// - 500 Internal Server Error: Returns if the CA cannot be created.
+func CreateCA(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		KeyType    string `json:"key_type"`
+		PrivateKey string `json:"private_key" validate:"excluded_with=KeyType"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	var ca *sshca.CA
+	var err error
+	if req.PrivateKey != "" {
+		ca, err = SSHService.ImportCA(req.PrivateKey, masterCryptoPass)
+	} else {
+		keyType := sshca.KeyType(req.KeyType)
+		if keyType == "" {
+			keyType = sshca.KeyTypeEd25519
+		}
+		ca, err = SSHService.GenerateCA(keyType, masterCryptoPass)
+	}
+	if err != nil {
+		writeServiceError(w, err, "Failed to create SSH CA")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, newCAResponse(ca))
+}
+
+// GetCAPublicKey retrieves the CA public key as plain text in authorized_keys format,
+// ready to be written to the file referenced by TrustedUserCAKeys in sshd_config.
+//
+// Responses:
+// - 200 OK: Returns the CA public key.
+// - 404 Not Found: Returns if the CA does not exist.
+// - 500 Internal Server Error: Returns if the retrieval fails.
+func GetCAPublicKey(w http.ResponseWriter, r *http.Request) {
+	ca, err := SSHService.GetCA()
+	if err != nil {
+		writeServiceError(w, err, "Failed to retrieve SSH CA")
+		return
+	}
+
+	utils.WriteRawResponse(w, http.StatusOK, "text/plain; charset=utf-8", []byte(ca.PublicKey+"\n"))
+}
+
+// SaveRole handles creating or replacing a role, which restricts the certificates signed with it.
+// Extensions and critical options only apply to user certificates.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "cert_type": "user",
+//	    "allowed_principals": ["deploy", "alice"],
+//	    "default_principals": ["deploy"],
+//	    "allowed_extensions": ["permit-pty", "permit-port-forwarding"],
+//	    "default_extensions": ["permit-pty"],
+//	    "critical_options": {"source-address": "10.0.0.0/8"},
+//	    "ttl": 3600,
+//	    "max_ttl": 28800
+//	}
+//
+// Responses:
+// - 200 OK: Returns the saved role.
+// - 400 Bad Request: Returns if the request body or role settings are invalid.
+// - 500 Internal Server Error: Returns if the role cannot be stored.
+func SaveRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CertType          string            `json:"cert_type" validate:"required"`
+		AllowedPrincipals []string          `json:"allowed_principals" validate:"required,min=1,dive,required"`
+		DefaultPrincipals []string          `json:"default_principals"`
+		AllowedExtensions []string          `json:"allowed_extensions"`
+		DefaultExtensions []string          `json:"default_extensions"`
+		CriticalOptions   map[string]string `json:"critical_options"`
+		TTL               int               `json:"ttl" validate:"required,min=1"`
+		MaxTTL            int               `json:"max_ttl"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	if req.MaxTTL == 0 {
+		req.MaxTTL = req.TTL
+	}
+
+	role := &sshca.Role{
+		Name:              mux.Vars(r)["name"],
+		CertType:          sshca.CertType(req.CertType),
+		AllowedPrincipals: req.AllowedPrincipals,
+		DefaultPrincipals: emptyIfNil(req.DefaultPrincipals),
+		AllowedExtensions: emptyIfNil(req.AllowedExtensions),
+		DefaultExtensions: emptyIfNil(req.DefaultExtensions),
+		CriticalOptions:   req.CriticalOptions,
+		TTL:               req.TTL,
+		MaxTTL:            req.MaxTTL,
+	}
+	if role.CriticalOptions == nil {
+		role.CriticalOptions = map[string]string{}
+	}
+	if err := SSHService.SaveRole(role); err != nil {
+		writeServiceError(w, err, "Failed to save role")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newRoleResponse(role))
+}
+
+// GetRole retrieves a role.
+//
+// Responses:
+// - 200 OK: Returns the role.
+// - 404 Not Found: Returns if the role does not exist.
+// - 500 Internal Server Error: Returns if the retrieval fails.
+func GetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := SSHService.GetRole(mux.Vars(r)["name"])
+	if err != nil {
+		writeServiceError(w, err, "Failed to retrieve role")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newRoleResponse(role))
+}
+
+// Sign handles signing a user or host public key with a role.
+// When "valid_principals" or "extensions" are omitted, the role's defaults are used.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "public_key": "ssh-ed25519 AAAA... alice@laptop",
+//	    "valid_principals": ["alice"],
+//	    "extensions": ["permit-pty"],
+//	    "ttl": 3600
+//	}
+//
+// Responses:
+// - 200 OK: Returns the signed key.
+// - 400 Bad Request: Returns if the request body or public key is invalid, or if a principal, an extension or the TTL is not allowed by the role.
+// - 404 Not Found: Returns if the role or the CA does not exist.
+// - 500 Internal Server Error: Returns if the key cannot be signed.
+func Sign(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PublicKey       string   `json:"public_key" validate:"required"`
+		ValidPrincipals []string `json:"valid_principals"`
+		Extensions      []string `json:"extensions"`
+		TTL             int      `json:"ttl" validate:"min=0"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	signed, err := SSHService.Sign(mux.Vars(r)["role"], sshca.SignRequest{
+		PublicKey:  req.PublicKey,
+		Principals: req.ValidPrincipals,
+		Extensions: req.Extensions,
+		TTL:        time.Duration(req.TTL) * time.Second,
+	}, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to sign key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &SignResponse{SerialNumber: signed.SerialNumber, SignedKey: signed.SignedKey, Expiration: signed.Expiration})
+}
+
+// emptyIfNil replaces a nil slice with an empty slice, so that it is stored and presented as an empty list.
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// writeServiceError maps an error returned by the SSH CA service to an HTTP response.
+// Errors caused by the request are reported with their message; other errors use the generic fallback message.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, sshca.ErrCANotFound),
+		errors.Is(err, sshca.ErrRoleNotFound):
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, sshca.ErrCAExists):
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, sshca.ErrInvalidName),
+		errors.Is(err, sshca.ErrUnsupportedKeyType),
+		errors.Is(err, sshca.ErrInvalidKey),
+		errors.Is(err, sshca.ErrInvalidRole),
+		errors.Is(err, sshca.ErrNotAllowed),
+		errors.Is(err, sshca.ErrTTLTooLong):
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
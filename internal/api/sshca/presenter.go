@@ -0,0 +1,78 @@
+package sshca
+
+import (
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/sshca"
+)
+
+// CAResponse represents the SSH CA. The private key is never included.
+type CAResponse struct {
+	// KeyType is the algorithm of the CA key.
+	KeyType string `json:"key_type"`
+
+	// PublicKey is the CA public key in authorized_keys format.
+	PublicKey string `json:"public_key"`
+}
+
+// RoleResponse represents a role used to sign SSH keys.
+type RoleResponse struct {
+	// Name is the unique name of the role.
+	Name string `json:"name"`
+
+	// CertType is the type of the signed certificates ("user" or "host").
+	CertType string `json:"cert_type"`
+
+	// AllowedPrincipals lists the principals that may be requested.
+	AllowedPrincipals []string `json:"allowed_principals"`
+
+	// DefaultPrincipals are used when a request does not list any principal.
+	DefaultPrincipals []string `json:"default_principals"`
+
+	// AllowedExtensions lists the extensions that may be requested.
+	AllowedExtensions []string `json:"allowed_extensions"`
+
+	// DefaultExtensions are granted when a request does not list any extension.
+	DefaultExtensions []string `json:"default_extensions"`
+
+	// CriticalOptions are added to every user certificate.
+	CriticalOptions map[string]string `json:"critical_options"`
+
+	// TTL is the default lifetime of the certificates, in seconds.
+	TTL int `json:"ttl"`
+
+	// MaxTTL is the longest lifetime that may be requested, in seconds.
+	MaxTTL int `json:"max_ttl"`
+}
+
+// SignResponse represents a signed SSH key.
+type SignResponse struct {
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber uint64 `json:"serial_number"`
+
+	// SignedKey is the certificate in authorized_keys format.
+	SignedKey string `json:"signed_key"`
+
+	// Expiration is the expiration time of the certificate.
+	Expiration time.Time `json:"expiration"`
+}
+
+// newCAResponse creates the presenter of the SSH CA.
+func newCAResponse(ca *sshca.CA) *CAResponse {
+	return &CAResponse{KeyType: string(ca.KeyType), PublicKey: ca.PublicKey}
+}
+
+// newRoleResponse creates the presenter of a role.
+func newRoleResponse(role *sshca.Role) *RoleResponse {
+	return &RoleResponse{
+		Name:              role.Name,
+		CertType:          string(role.CertType),
+		AllowedPrincipals: role.AllowedPrincipals,
+		DefaultPrincipals: role.DefaultPrincipals,
+		AllowedExtensions: role.AllowedExtensions,
+		DefaultExtensions: role.DefaultExtensions,
+		CriticalOptions:   role.CriticalOptions,
+		TTL:               role.TTL,
+		MaxTTL:            role.MaxTTL,
+	}
+}
@@ -0,0 +1,45 @@
+package sshca
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/sshca"
+)
+
+// SSHService is the service layer that handles business logic for the SSH certificate authority.
+// This package variable allows handlers to interact with the SSH CA service.
+var SSHService sshca.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterSSHRoutes registers the HTTP routes of the SSH certificate authority, which signs user and host keys.
+//
+// Parameters:
+// - router: The main router to which the SSH subrouter will be attached.
+// - sshService: The SSH CA service that will be used to handle the business logic.
+//
+// Routes:
+// - POST /ssh/ca: Generates or imports the CA key.
+// - GET /ssh/ca/public_key: Retrieves the CA public key, in authorized_keys format.
+// - PUT /ssh/roles/{name}: Creates or replaces a role.
+// - GET /ssh/roles/{name}: Retrieves a role.
+// - POST /ssh/sign/{role}: Signs a public key with a role.
+func RegisterSSHRoutes(router *mux.Router, sshService sshca.Service) {
+	// Assign the provided SSH CA service to the package-level variable for use in the handler functions.
+	SSHService = sshService
+
+	// Create a subrouter for the SSH CA under the /ssh path.
+	sshRouter := router.PathPrefix("/ssh").Subrouter()
+
+	// CA routes
+	sshRouter.HandleFunc("/ca", CreateCA).Methods("POST")
+	sshRouter.HandleFunc("/ca/public_key", GetCAPublicKey).Methods("GET")
+
+	// Role routes
+	sshRouter.HandleFunc("/roles/{name}", SaveRole).Methods("PUT")
+	sshRouter.HandleFunc("/roles/{name}", GetRole).Methods("GET")
+
+	// Signing routes
+	sshRouter.HandleFunc("/sign/{role}", Sign).Methods("POST")
+}
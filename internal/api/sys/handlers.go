@@ -0,0 +1,134 @@
+package sys
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/reload"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// RenewLease extends a lease by "increment" seconds, or by its TTL when the increment is omitted.
+// A lease cannot be renewed past its maximum expiration, nor once it has expired.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "lease_id": "database/creds/readonly/2f1c...",
+//	    "increment": 3600
+//	}
+//
+// Responses:
+// - 200 OK: Returns the renewed lease.
+// - 400 Bad Request: Returns if the request body is invalid or the lease has expired.
+// - 404 Not Found: Returns if the lease does not exist.
+// - 500 Internal Server Error: Returns if the lease cannot be renewed.
+func RenewLease(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		LeaseID   string `json:"lease_id" validate:"required"`
+		Increment int    `json:"increment" validate:"min=0"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	lease, err := LeaseService.Renew(req.LeaseID, time.Duration(req.Increment)*time.Second)
+	if err != nil {
+		writeServiceError(w, err, "Failed to renew lease")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newLeaseResponse(lease))
+}
+
+// RevokeLease revokes a lease right away, e.g. drops its database user.
+// If the engine fails to revoke it, the lease is kept and revoked later by the scheduler.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "lease_id": "database/creds/readonly/2f1c..."
+//	}
+//
+// Responses:
+// - 200 OK: Returns if the lease was revoked.
+// - 400 Bad Request: Returns if the request body is invalid.
+// - 404 Not Found: Returns if the lease does not exist.
+// - 500 Internal Server Error: Returns if the lease cannot be revoked now.
+func RevokeLease(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		LeaseID string `json:"lease_id" validate:"required"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	if err := LeaseService.Revoke(req.LeaseID, masterCryptoPass); err != nil {
+		writeServiceError(w, err, "Failed to revoke lease, it will be retried")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Lease revoked successfully"})
+}
+
+// RevokeLeasePrefix revokes every lease whose ID starts with the prefix, e.g. "database/creds/readonly/"
+// to drop every user of a role. Leases that cannot be revoked now are revoked later by the scheduler.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "prefix": "database/creds/readonly/"
+//	}
+//
+// Responses:
+// - 200 OK: Returns the number of revoked leases.
+// - 400 Bad Request: Returns if the request body is invalid.
+// - 500 Internal Server Error: Returns if some of the leases cannot be revoked now.
+func RevokeLeasePrefix(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prefix string `json:"prefix" validate:"required"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	revoked, err := LeaseService.RevokePrefix(req.Prefix, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to revoke some of the leases, they will be retried")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &RevokePrefixResponse{Revoked: revoked})
+}
+
+// GetConfig returns the configuration in use, including the settings applied by the last reload
+// and the defaults of the settings that are not set. Secret settings such as the database password are redacted.
+//
+// Responses:
+// - 200 OK: Returns the active configuration, by section and key.
+func GetConfig(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusOK, &ConfigResponse{Config: reload.Active().Redacted()})
+}
+
+// writeServiceError maps an error returned by the lease service to an HTTP response.
+// Errors caused by the request are reported with their message; other errors use the generic fallback message.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, leases.ErrLeaseNotFound):
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, leases.ErrLeaseExpired),
+		errors.Is(err, leases.ErrInvalidLease):
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
@@ -0,0 +1,44 @@
+package sys
+
+import (
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+)
+
+// LeaseResponse represents a lease.
+type LeaseResponse struct {
+	// LeaseID is the unique ID of the lease.
+	LeaseID string `json:"lease_id"`
+
+	// LeaseDuration is the time left before the lease expires, in seconds.
+	LeaseDuration int `json:"lease_duration"`
+
+	// ExpiresAt is the time after which the lease is revoked.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// MaxExpiresAt is the time beyond which the lease cannot be renewed.
+	MaxExpiresAt time.Time `json:"max_expires_at"`
+}
+
+// RevokePrefixResponse represents the outcome of revoking the leases under a prefix.
+type RevokePrefixResponse struct {
+	// Revoked is the number of revoked leases.
+	Revoked int `json:"revoked"`
+}
+
+// ConfigResponse represents the configuration in use.
+type ConfigResponse struct {
+	// Config holds the value of every setting by section and key, with secret values redacted.
+	Config map[string]map[string]string `json:"config"`
+}
+
+// newLeaseResponse creates the presenter of a lease.
+func newLeaseResponse(lease *leases.Lease) *LeaseResponse {
+	return &LeaseResponse{
+		LeaseID:       lease.ID,
+		LeaseDuration: int(time.Until(lease.ExpiresAt).Round(time.Second).Seconds()),
+		ExpiresAt:     lease.ExpiresAt,
+		MaxExpiresAt:  lease.MaxExpiresAt,
+	}
+}
@@ -0,0 +1,41 @@
+package sys
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+)
+
+// LeaseService is the service layer that handles the lifecycle of leases.
+// This package variable allows handlers to interact with the lease service.
+var LeaseService leases.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterSysRoutes registers the HTTP routes of the system backend, which manages what is shared by all engines.
+//
+// Parameters:
+// - router: The main router to which the sys subrouter will be attached.
+// - leaseService: The lease service that will be used to renew and revoke leases.
+//
+// Routes:
+// - POST /sys/leases/renew: Extends a lease.
+// - POST /sys/leases/revoke: Revokes a lease.
+// - POST /sys/leases/revoke-prefix: Revokes every lease under a prefix.
+// - GET /sys/config: Returns the active configuration, with secrets redacted.
+func RegisterSysRoutes(router *mux.Router, leaseService leases.Service) {
+	// Assign the provided lease service to the package-level variable for use in the handler functions.
+	LeaseService = leaseService
+
+	// Create a subrouter for the system backend under the /sys path.
+	sysRouter := router.PathPrefix("/sys").Subrouter()
+
+	// Lease routes
+	sysRouter.HandleFunc("/leases/renew", RenewLease).Methods("POST")
+	sysRouter.HandleFunc("/leases/revoke", RevokeLease).Methods("POST")
+	sysRouter.HandleFunc("/leases/revoke-prefix", RevokeLeasePrefix).Methods("POST")
+
+	// Configuration routes
+	sysRouter.HandleFunc("/config", GetConfig).Methods("GET")
+}
@@ -0,0 +1,167 @@
+package totp
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/totp"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// CreateKey handles storing a TOTP seed, encrypted with the "MASTER_CRYPTO_PASS" environment variable.
+// When "url" is set, the seed of an existing otpauth URL is imported. Otherwise a new seed is generated,
+// and its otpauth URL and QR code are returned once so that it can be enrolled with the service.
+//
+// Expected JSON request body to import a key:
+//
+//	{
+//	    "name": "vendor-dashboard",
+//	    "url": "otpauth://totp/Vendor:ops@example.com?secret=...&issuer=Vendor"
+//	}
+//
+// Expected JSON request body to generate a key:
+//
+//	{
+//	    "name": "vendor-dashboard",
+//	    "issuer": "Vendor",
+//	    "account_name": "ops@example.com",
+//	    "algorithm": "SHA1",
+//	    "digits": 6,
+//	    "period": 30
+//	}
+//
+// Responses:
+// - 201 Created: Returns the key, with its URL and QR code when generated.
+// - 400 Bad Request: Returns if the request body, name, URL or parameters are invalid.
+// - 409 Conflict: Returns if a key with the same name already exists.
+// - 500 Internal Server Error: Returns if the key cannot be stored.
+func CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name" validate:"required"`
+		URL         string `json:"url"`
+		Issuer      string `json:"issuer" validate:"excluded_with=URL"`
+		AccountName string `json:"account_name" validate:"required_without=URL,excluded_with=URL"`
+		Algorithm   string `json:"algorithm" validate:"excluded_with=URL"`
+		Digits      int    `json:"digits" validate:"excluded_with=URL"`
+		Period      int    `json:"period" validate:"excluded_with=URL"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	// Import the URL, or generate a new seed
+	if req.URL != "" {
+		key, err := TOTPService.ImportKey(req.Name, req.URL, masterCryptoPass)
+		if err != nil {
+			writeServiceError(w, err, "Failed to import key")
+			return
+		}
+		utils.WriteJSONResponse(w, http.StatusCreated, newKeyResponse(key))
+		return
+	}
+
+	key, otpauthURL, qrCode, err := TOTPService.GenerateKey(totp.KeyRequest{
+		Name:        req.Name,
+		Issuer:      req.Issuer,
+		AccountName: req.AccountName,
+		Algorithm:   totp.Algorithm(req.Algorithm),
+		Digits:      req.Digits,
+		Period:      req.Period,
+	}, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to generate key")
+		return
+	}
+
+	response := newKeyResponse(key)
+	response.URL = otpauthURL
+	response.Barcode = base64.StdEncoding.EncodeToString(qrCode)
+	utils.WriteJSONResponse(w, http.StatusCreated, response)
+}
+
+// GetCode retrieves the current code of a TOTP key.
+//
+// Responses:
+// - 200 OK: Returns the code and the end of its period.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the code cannot be computed.
+func GetCode(w http.ResponseWriter, r *http.Request) {
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	code, err := TOTPService.GetCode(mux.Vars(r)["name"], masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to compute code")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &CodeResponse{Code: code.Code, ValidUntil: code.ValidUntil})
+}
+
+// ValidateCode checks a submitted code. A code is only accepted once: replaying it, or an older code, is rejected.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "code": "123456"
+//	}
+//
+// Responses:
+// - 200 OK: Returns whether the code is valid.
+// - 400 Bad Request: Returns if the request body is invalid.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the validation fails.
+func ValidateCode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code" validate:"required,numeric"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	valid, err := TOTPService.Validate(mux.Vars(r)["name"], req.Code, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to validate code")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &ValidateResponse{Valid: valid})
+}
+
+// newKeyResponse creates the presenter of a TOTP key, without its seed.
+func newKeyResponse(key *totp.Key) *KeyResponse {
+	return &KeyResponse{
+		Name:        key.Name,
+		Issuer:      key.Issuer,
+		AccountName: key.AccountName,
+		Algorithm:   string(key.Algorithm),
+		Digits:      key.Digits,
+		Period:      key.Period,
+	}
+}
+
+// writeServiceError maps an error returned by the TOTP service to an HTTP response.
+// Errors caused by the request are reported with their message; other errors use the generic fallback message.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, totp.ErrKeyNotFound):
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Key not found"})
+	case errors.Is(err, totp.ErrKeyExists):
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": "Key already exists"})
+	case errors.Is(err, totp.ErrInvalidKeyName),
+		errors.Is(err, totp.ErrInvalidURL),
+		errors.Is(err, totp.ErrInvalidParameters):
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
@@ -0,0 +1,45 @@
+package totp
+
+import "time"
+
+// KeyResponse represents a TOTP key. The seed is only included, as an otpauth URL and a QR code, when Lockbox generated it.
+type KeyResponse struct {
+	// Name is the unique name of the key.
+	Name string `json:"name"`
+
+	// Issuer is the service the codes are used for.
+	Issuer string `json:"issuer"`
+
+	// AccountName is the account the codes are used for.
+	AccountName string `json:"account_name"`
+
+	// Algorithm is the HMAC hash function used to compute codes.
+	Algorithm string `json:"algorithm"`
+
+	// Digits is the number of digits of a code.
+	Digits int `json:"digits"`
+
+	// Period is the number of seconds a code is valid for.
+	Period int `json:"period"`
+
+	// URL is the otpauth URL of a generated key, to be enrolled with the service.
+	URL string `json:"url,omitempty"`
+
+	// Barcode is the base64-encoded PNG QR code of the URL of a generated key.
+	Barcode string `json:"barcode,omitempty"`
+}
+
+// CodeResponse represents the current code of a key.
+type CodeResponse struct {
+	// Code is the one-time code.
+	Code string `json:"code"`
+
+	// ValidUntil is the end of the period of the code.
+	ValidUntil time.Time `json:"valid_until"`
+}
+
+// ValidateResponse represents the result of a code validation.
+type ValidateResponse struct {
+	// Valid is true when the code is correct and was not used before.
+	Valid bool `json:"valid"`
+}
@@ -0,0 +1,37 @@
+package totp
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/totp"
+)
+
+// TOTPService is the service layer that handles business logic for TOTP keys.
+// This package variable allows handlers to interact with the TOTP service.
+var TOTPService totp.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterTOTPRoutes registers the HTTP routes of the TOTP engine, which holds the seeds of shared 2FA accounts.
+//
+// Parameters:
+// - router: The main router to which the TOTP subrouter will be attached.
+// - totpService: The TOTP service that will be used to handle the business logic.
+//
+// Routes:
+// - POST /totp/keys: Generates a new key, or imports an otpauth URL.
+// - GET /totp/code/{name}: Retrieves the current code of a key.
+// - POST /totp/validate/{name}: Validates a submitted code.
+func RegisterTOTPRoutes(router *mux.Router, totpService totp.Service) {
+	// Assign the provided TOTP service to the package-level variable for use in the handler functions.
+	TOTPService = totpService
+
+	// Create a subrouter for the TOTP engine under the /totp path.
+	totpRouter := router.PathPrefix("/totp").Subrouter()
+
+	// Define the routes for TOTP operations
+	totpRouter.HandleFunc("/keys", CreateKey).Methods("POST")
+	totpRouter.HandleFunc("/code/{name}", GetCode).Methods("GET")
+	totpRouter.HandleFunc("/validate/{name}", ValidateCode).Methods("POST")
+}
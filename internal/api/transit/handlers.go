@@ -0,0 +1,465 @@
+package transit
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/transit"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// CreateKey handles creating a new named transit key.
+// The key material is generated by Lockbox and encrypted with the "MASTER_CRYPTO_PASS" environment variable.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "name": "customers-pii",
+//	    "type": "aes256-gcm"
+//	}
+//
+// Supported types are "aes256-gcm" (encryption and HMAC), and "ed25519", "ecdsa-p256" and "rsa-pss-2048" (signatures).
+//
+// Responses:
+// - 201 Created: Returns the metadata of the new key.
+// - 400 Bad Request: Returns if the request body, name or type is invalid.
+// - 409 Conflict: Returns if a key with the same name already exists.
+// - 500 Internal Server Error: Returns if the key creation fails.
+func CreateKey(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Name string `json:"name" validate:"required"`
+		Type string `json:"type"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	if req.Type == "" {
+		req.Type = string(transit.KeyTypeAES256GCM)
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	// Create the key using the service layer
+	key, err := TransitService.CreateKey(req.Name, transit.KeyType(req.Type), masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to create key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, newKeyResponse(key))
+}
+
+// GetKey retrieves the metadata of a transit key: its type, versions and minimum decryption version.
+//
+// Responses:
+// - 200 OK: Returns the metadata of the key.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the retrieval fails.
+func GetKey(w http.ResponseWriter, r *http.Request) {
+	key, err := TransitService.GetKey(mux.Vars(r)["name"])
+	if err != nil {
+		writeServiceError(w, err, "Failed to retrieve key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newKeyResponse(key))
+}
+
+// RotateKey adds a new version to a transit key. New encryptions use the new version,
+// while ciphertexts produced by older versions remain decryptable.
+//
+// Responses:
+// - 200 OK: Returns the metadata of the rotated key.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the rotation fails.
+func RotateKey(w http.ResponseWriter, r *http.Request) {
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	key, err := TransitService.RotateKey(mux.Vars(r)["name"], masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to rotate key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newKeyResponse(key))
+}
+
+// ConfigureKey updates the minimum decryption version of a transit key.
+// Ciphertexts produced by older versions can no longer be decrypted or rewrapped.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "min_decryption_version": 2
+//	}
+//
+// Responses:
+// - 200 OK: Returns the metadata of the updated key.
+// - 400 Bad Request: Returns if the request body or the version is invalid.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the update fails.
+func ConfigureKey(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		MinDecryptionVersion int `json:"min_decryption_version" validate:"required,min=1"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+
+	key, err := TransitService.SetMinDecryptionVersion(mux.Vars(r)["name"], req.MinDecryptionVersion)
+	if err != nil {
+		writeServiceError(w, err, "Failed to update key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, newKeyResponse(key))
+}
+
+// Encrypt encrypts base64-encoded data with the latest version of a transit key.
+// The optional associated data is authenticated but not encrypted, and must be provided again to decrypt.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "plaintext": "NDExMSAxMTExIDExMTEgMTExMQ==",
+//	    "associated_data": "Y3VzdG9tZXItNDI="
+//	}
+//
+// Responses:
+// - 200 OK: Returns the versioned ciphertext.
+// - 400 Bad Request: Returns if the request body is invalid or the key cannot encrypt.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the encryption fails.
+func Encrypt(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Plaintext      string `json:"plaintext" validate:"required,base64"`
+		AssociatedData string `json:"associated_data" validate:"omitempty,base64"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	plainText, _ := base64.StdEncoding.DecodeString(req.Plaintext)
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	ciphertext, version, err := TransitService.Encrypt(mux.Vars(r)["name"], plainText, associatedData, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to encrypt")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &CiphertextResponse{Ciphertext: ciphertext, KeyVersion: version})
+}
+
+// Decrypt decrypts a ciphertext produced by the same transit key and returns the base64-encoded plaintext.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "ciphertext": "lockbox:v1:...",
+//	    "associated_data": "Y3VzdG9tZXItNDI="
+//	}
+//
+// Responses:
+// - 200 OK: Returns the base64-encoded plaintext.
+// - 400 Bad Request: Returns if the request body or ciphertext is invalid, or if its version is below the minimum decryption version.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the decryption fails.
+func Decrypt(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Ciphertext     string `json:"ciphertext" validate:"required"`
+		AssociatedData string `json:"associated_data" validate:"omitempty,base64"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	plainText, err := TransitService.Decrypt(mux.Vars(r)["name"], req.Ciphertext, associatedData, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to decrypt")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &PlaintextResponse{Plaintext: base64.StdEncoding.EncodeToString(plainText)})
+}
+
+// Rewrap re-encrypts a ciphertext with the latest version of the transit key, without revealing the plaintext.
+// This is used after a rotation to upgrade stored ciphertexts before retiring old versions.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "ciphertext": "lockbox:v1:...",
+//	    "associated_data": "Y3VzdG9tZXItNDI="
+//	}
+//
+// Responses:
+// - 200 OK: Returns the new versioned ciphertext.
+// - 400 Bad Request: Returns if the request body or ciphertext is invalid, or if its version is below the minimum decryption version.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the rewrap fails.
+func Rewrap(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Ciphertext     string `json:"ciphertext" validate:"required"`
+		AssociatedData string `json:"associated_data" validate:"omitempty,base64"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	ciphertext, version, err := TransitService.Rewrap(mux.Vars(r)["name"], req.Ciphertext, associatedData, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to rewrap")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &CiphertextResponse{Ciphertext: ciphertext, KeyVersion: version})
+}
+
+// Data key modes accepted by GenerateDataKey.
+const (
+	dataKeyModePlaintext   = "plaintext"
+	dataKeyModeWrappedOnly = "wrapped-only"
+)
+
+// GenerateDataKey generates a random 256-bit data key for local envelope encryption.
+// The data key is wrapped with the latest version of a symmetric transit key and is never stored by Lockbox.
+// Clients encrypt data locally with the plaintext key, store the wrapped key next to the data,
+// and unwrap it later with POST /transit/decrypt/{name}.
+//
+// Expected JSON request body (optional):
+//
+//	{
+//	    "mode": "wrapped-only",
+//	    "associated_data": "YmFja3Vwcy8yMDI0LTEwLTIz"
+//	}
+//
+// The mode is "plaintext" (default), which returns both the plaintext and the wrapped key,
+// or "wrapped-only", which only returns the wrapped key for a later use.
+//
+// Responses:
+// - 200 OK: Returns the data key.
+// - 400 Bad Request: Returns if the request body or mode is invalid, or the key is not symmetric.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the generation fails.
+func GenerateDataKey(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body, which may be empty
+	var req struct {
+		Mode           string `json:"mode" validate:"omitempty,oneof=plaintext wrapped-only"`
+		AssociatedData string `json:"associated_data" validate:"omitempty,base64"`
+	}
+	if r.ContentLength != 0 && !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	dataKey, wrapped, version, err := TransitService.GenerateDataKey(mux.Vars(r)["name"], associatedData, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to generate data key")
+		return
+	}
+
+	response := &DataKeyResponse{Ciphertext: wrapped, KeyVersion: version}
+	if req.Mode != dataKeyModeWrappedOnly {
+		response.Plaintext = base64.StdEncoding.EncodeToString(dataKey)
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// GetPublicKey exports the public keys of an asymmetric transit key, one per version allowed for verification.
+// The format is selected with the "format" query parameter ("pem" or "jwk", defaults to "pem").
+// Private keys never leave Lockbox.
+//
+// Responses:
+// - 200 OK: Returns the public keys of the key.
+// - 400 Bad Request: Returns if the format is invalid or the key is not asymmetric.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the export fails.
+func GetPublicKey(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	// Get the requested format
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transit.PublicKeyFormatPEM
+	}
+	if format != transit.PublicKeyFormatPEM && format != transit.PublicKeyFormatJWK {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid format, expected 'pem' or 'jwk'"})
+		return
+	}
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	keys, err := TransitService.PublicKeys(name, format, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to export public key")
+		return
+	}
+	key, err := TransitService.GetKey(name)
+	if err != nil {
+		writeServiceError(w, err, "Failed to export public key")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &PublicKeyResponse{Name: key.Name, Type: string(key.Type), Format: format, Keys: keys})
+}
+
+// Sign signs base64-encoded data with the latest version of an asymmetric transit key.
+// The signature is prefixed with the key version, so verifiers can select the matching public key after a rotation.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "input": "cmVsZWFzZS0xLjIuMy50YXIuZ3o="
+//	}
+//
+// Responses:
+// - 200 OK: Returns the versioned signature.
+// - 400 Bad Request: Returns if the request body is invalid or the key cannot sign.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the signature fails.
+func Sign(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Input string `json:"input" validate:"required,base64"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	input, _ := base64.StdEncoding.DecodeString(req.Input)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	signature, version, err := TransitService.Sign(mux.Vars(r)["name"], input, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to sign")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &SignatureResponse{Signature: signature, KeyVersion: version})
+}
+
+// Verify checks a signature produced by Sign, or an HMAC produced by HMAC, against base64-encoded data.
+// Exactly one of "signature" and "hmac" must be provided.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "input": "cmVsZWFzZS0xLjIuMy50YXIuZ3o=",
+//	    "signature": "lockbox:v1:..."
+//	}
+//
+// Responses:
+// - 200 OK: Returns whether the signature or HMAC is valid.
+// - 400 Bad Request: Returns if the request body or the signature is malformed, or if its version is below the minimum decryption version.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the verification fails.
+func Verify(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Input     string `json:"input" validate:"required,base64"`
+		Signature string `json:"signature" validate:"required_without=HMAC,excluded_with=HMAC"`
+		HMAC      string `json:"hmac" validate:"required_without=Signature,excluded_with=Signature"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	input, _ := base64.StdEncoding.DecodeString(req.Input)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	var valid bool
+	var err error
+	if req.Signature != "" {
+		valid, err = TransitService.Verify(mux.Vars(r)["name"], input, req.Signature, masterCryptoPass)
+	} else {
+		valid, err = TransitService.VerifyHMAC(mux.Vars(r)["name"], input, req.HMAC, masterCryptoPass)
+	}
+	if err != nil {
+		writeServiceError(w, err, "Failed to verify")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &VerifyResponse{Valid: valid})
+}
+
+// HMAC computes the HMAC-SHA256 of base64-encoded data with the latest version of a symmetric transit key.
+//
+// Expected JSON request body:
+//
+//	{
+//	    "input": "eyJldmVudCI6InBheW1lbnQifQ=="
+//	}
+//
+// Responses:
+// - 200 OK: Returns the versioned HMAC.
+// - 400 Bad Request: Returns if the request body is invalid or the key is not symmetric.
+// - 404 Not Found: Returns if the key does not exist.
+// - 500 Internal Server Error: Returns if the computation fails.
+func HMAC(w http.ResponseWriter, r *http.Request) {
+	// Get JSON request body
+	var req struct {
+		Input string `json:"input" validate:"required,base64"`
+	}
+	if !utils.DecodeRequest(w, r, validate, &req) {
+		return
+	}
+	input, _ := base64.StdEncoding.DecodeString(req.Input)
+
+	// Get master key from the environment
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+
+	mac, version, err := TransitService.HMAC(mux.Vars(r)["name"], input, masterCryptoPass)
+	if err != nil {
+		writeServiceError(w, err, "Failed to compute HMAC")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, &HMACResponse{HMAC: mac, KeyVersion: version})
+}
+
+// writeServiceError maps an error returned by the transit service to an HTTP response.
+// Errors caused by the request are reported with their message; other errors use the generic fallback message.
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, transit.ErrKeyNotFound):
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Key not found"})
+	case errors.Is(err, transit.ErrKeyExists):
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": "Key already exists"})
+	case errors.Is(err, transit.ErrInvalidKeyName),
+		errors.Is(err, transit.ErrUnsupportedKeyType),
+		errors.Is(err, transit.ErrInvalidVersion),
+		errors.Is(err, transit.ErrInvalidCiphertext),
+		errors.Is(err, transit.ErrInvalidSignature),
+		errors.Is(err, transit.ErrVersionNotAllowed):
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
@@ -0,0 +1,107 @@
+package transit
+
+import (
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/transit"
+)
+
+// KeyResponse represents the metadata of a transit key. The key material is never included.
+type KeyResponse struct {
+	// Name is the unique name of the key.
+	Name string `json:"name"`
+
+	// Type is the algorithm of the key.
+	Type string `json:"type"`
+
+	// LatestVersion is the version used for new operations.
+	LatestVersion int `json:"latest_version"`
+
+	// MinDecryptionVersion is the oldest version that may still be used to decrypt.
+	MinDecryptionVersion int `json:"min_decryption_version"`
+
+	// Versions maps each version number to its creation time.
+	Versions map[int]time.Time `json:"versions"`
+}
+
+// CiphertextResponse represents the result of an encryption or a rewrap.
+type CiphertextResponse struct {
+	// Ciphertext is the versioned ciphertext (e.g., "lockbox:v1:...").
+	Ciphertext string `json:"ciphertext"`
+
+	// KeyVersion is the version of the key that produced the ciphertext.
+	KeyVersion int `json:"key_version"`
+}
+
+// PlaintextResponse represents the result of a decryption.
+type PlaintextResponse struct {
+	// Plaintext is the base64-encoded decrypted data.
+	Plaintext string `json:"plaintext"`
+}
+
+// DataKeyResponse represents a generated data key.
+type DataKeyResponse struct {
+	// Plaintext is the base64-encoded data key. It is omitted in "wrapped-only" mode.
+	Plaintext string `json:"plaintext,omitempty"`
+
+	// Ciphertext is the data key wrapped with the transit key (e.g., "lockbox:v1:...").
+	Ciphertext string `json:"ciphertext"`
+
+	// KeyVersion is the version of the key that wrapped the data key.
+	KeyVersion int `json:"key_version"`
+}
+
+// SignatureResponse represents the result of a signature.
+type SignatureResponse struct {
+	// Signature is the versioned signature (e.g., "lockbox:v1:...").
+	Signature string `json:"signature"`
+
+	// KeyVersion is the version of the key that produced the signature.
+	KeyVersion int `json:"key_version"`
+}
+
+// HMACResponse represents the result of an HMAC computation.
+type HMACResponse struct {
+	// HMAC is the versioned HMAC (e.g., "lockbox:v1:...").
+	HMAC string `json:"hmac"`
+
+	// KeyVersion is the version of the key that produced the HMAC.
+	KeyVersion int `json:"key_version"`
+}
+
+// VerifyResponse represents the result of a signature or HMAC verification.
+type VerifyResponse struct {
+	// Valid is true when the signature or HMAC matches the data.
+	Valid bool `json:"valid"`
+}
+
+// PublicKeyResponse represents the public keys of an asymmetric transit key.
+type PublicKeyResponse struct {
+	// Name is the unique name of the key.
+	Name string `json:"name"`
+
+	// Type is the algorithm of the key.
+	Type string `json:"type"`
+
+	// Format is the encoding of the public keys ("pem" or "jwk").
+	Format string `json:"format"`
+
+	// Keys maps each version allowed for verification to its public key.
+	Keys map[int]interface{} `json:"keys"`
+}
+
+// newKeyResponse creates the presenter of a transit key.
+func newKeyResponse(key *transit.Key) *KeyResponse {
+	versions := make(map[int]time.Time, len(key.Versions))
+	for _, version := range key.Versions {
+		versions[version.Version] = version.CreatedAt
+	}
+
+	return &KeyResponse{
+		Name:                 key.Name,
+		Type:                 string(key.Type),
+		LatestVersion:        key.LatestVersion,
+		MinDecryptionVersion: key.MinDecryptionVersion,
+		Versions:             versions,
+	}
+}
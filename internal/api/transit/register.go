@@ -0,0 +1,60 @@
+package transit
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/transit"
+)
+
+// TransitService is the service layer that handles business logic for transit keys.
+// This package variable allows handlers to interact with the transit service.
+var TransitService transit.Service
+
+// validate is a JSON validator to check JSON request bodies
+var validate = validator.New()
+
+// RegisterTransitRoutes registers the HTTP routes of the transit (encryption as a service) engine.
+// Applications send data to these routes to be encrypted or decrypted with named keys that never leave Lockbox.
+//
+// Parameters:
+// - router: The main router to which the transit subrouter will be attached.
+// - transitService: The transit service that will be used to handle the business logic.
+//
+// Routes:
+// - POST /transit/keys: Creates a new named key.
+// - GET /transit/keys/{name}: Retrieves the metadata of a key.
+// - POST /transit/keys/{name}/rotate: Adds a new version to a key.
+// - PUT /transit/keys/{name}/config: Updates the minimum decryption version of a key.
+// - POST /transit/encrypt/{name}: Encrypts data with the latest version of a key.
+// - POST /transit/decrypt/{name}: Decrypts a ciphertext.
+// - POST /transit/rewrap/{name}: Re-encrypts a ciphertext with the latest version of a key.
+// - GET /transit/keys/{name}/public: Exports the public keys of an asymmetric key.
+// - POST /transit/sign/{name}: Signs data with the latest version of an asymmetric key.
+// - POST /transit/verify/{name}: Verifies a signature or an HMAC.
+// - POST /transit/hmac/{name}: Computes the HMAC of data with the latest version of a symmetric key.
+// - POST /datakey/{name}: Generates a data key wrapped with a symmetric key, for local envelope encryption.
+func RegisterTransitRoutes(router *mux.Router, transitService transit.Service) {
+	// Assign the provided transit service to the package-level variable for use in the handler functions.
+	TransitService = transitService
+
+	// Create a subrouter for the transit engine under the /transit path.
+	transitRouter := router.PathPrefix("/transit").Subrouter()
+
+	// Key management routes
+	transitRouter.HandleFunc("/keys", CreateKey).Methods("POST")
+	transitRouter.HandleFunc("/keys/{name}", GetKey).Methods("GET")
+	transitRouter.HandleFunc("/keys/{name}/rotate", RotateKey).Methods("POST")
+	transitRouter.HandleFunc("/keys/{name}/config", ConfigureKey).Methods("PUT")
+	transitRouter.HandleFunc("/keys/{name}/public", GetPublicKey).Methods("GET")
+
+	// Cryptographic operation routes
+	transitRouter.HandleFunc("/encrypt/{name}", Encrypt).Methods("POST")
+	transitRouter.HandleFunc("/decrypt/{name}", Decrypt).Methods("POST")
+	transitRouter.HandleFunc("/rewrap/{name}", Rewrap).Methods("POST")
+	transitRouter.HandleFunc("/sign/{name}", Sign).Methods("POST")
+	transitRouter.HandleFunc("/verify/{name}", Verify).Methods("POST")
+	transitRouter.HandleFunc("/hmac/{name}", HMAC).Methods("POST")
+
+	// Data keys are served outside of /transit, as they are used without any further transit call until unwrapped
+	router.HandleFunc("/datakey/{name}", GenerateDataKey).Methods("POST")
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	app_log "gitlab.com/xrs-cloud/lockbox/core/internal/logger"
+)
+
+// bootstrap loads the configuration file and initializes the global logger and database connection,
+// the same way the server does, so that subcommands operate on the same data as a running Lockbox.
+// When migrate is false the schema is left untouched, which is what the `migrate` subcommand needs.
+func bootstrap(configFile string, migrate bool) (*config.Config, error) {
+	// Load application configuration
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	global.Config = cfg
+
+	// Initialize the logger and the database connection
+	global.Logger = app_log.InitLogger(cfg.Logging)
+	if migrate {
+		global.Database = database.InitDatabase(cfg.Database)
+	} else {
+		global.Database = database.OpenDatabase(cfg.Database)
+	}
+
+	return cfg, nil
+}
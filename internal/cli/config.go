@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+)
+
+// Config implements the `lockbox config` subcommand, which inspects the configuration without starting the server.
+//
+// Usage:
+//
+//	lockbox config check [--set section.key=value]... <file>
+//
+// The file is merged with the LOCKBOX_<SECTION>_<KEY> environment variables and the --set flags, as the server does,
+// and every problem is listed. It returns an error, so that Lockbox exits with a non-zero status, if there is any.
+func Config(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("expected an action: check")
+	}
+
+	// Define the subcommand flags
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	var overrides config.Overrides
+	flags.Var(&overrides, "set", "Configuration value as section.key=value (e.g., server.port=9000); can be repeated")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected exactly one configuration file to check")
+	}
+	filePath := flags.Arg(0)
+
+	// Load the configuration as the server would, and list its problems
+	if _, err := config.LoadConfig(filePath, overrides...); err != nil {
+		var validationError *config.ValidationError
+		if errors.As(err, &validationError) {
+			for _, problem := range validationError.Problems {
+				fmt.Fprintln(os.Stderr, problem)
+			}
+			return fmt.Errorf("%s is invalid", filePath)
+		}
+		return err
+	}
+
+	fmt.Printf("%s is valid\n", filePath)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+)
+
+// Import implements the `lockbox import` subcommand.
+// It reads a dotenv, JSON or YAML file and stores every entry as a secret under the given key prefix.
+//
+// Usage:
+//
+//	lockbox import [--config-file path] [--prefix prefix] [--format dotenv|json|yaml] [--policy skip|overwrite|fail] <file>
+//
+// When --format is omitted, the format is detected from the file extension.
+func Import(args []string) error {
+	// Define the subcommand flags
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	configFile := flags.String("config-file", config.DefaultFilePath, "Path to the configuration file (.conf, .yaml or .toml)")
+	prefix := flags.String("prefix", "", "Key prefix under which the secrets are stored (e.g., app/production/)")
+	formatValue := flags.String("format", "", "Format of the file: dotenv, json or yaml (detected from the extension by default)")
+	policyValue := flags.String("policy", string(secrets.ConflictSkip), "What to do with keys that already exist: skip, overwrite or fail")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected exactly one file to import")
+	}
+	filePath := flags.Arg(0)
+
+	// Resolve the format and the conflict policy
+	if *formatValue == "" {
+		*formatValue = filepath.Ext(filePath)
+	}
+	format, err := secrets.ParseFormat(*formatValue)
+	if err != nil {
+		return err
+	}
+	policy, err := secrets.ParseConflictPolicy(*policyValue)
+	if err != nil {
+		return err
+	}
+
+	// Read and parse the file before touching the database
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %v", filePath, err)
+	}
+	values, err := secrets.ParseSecrets(format, content)
+	if err != nil {
+		return err
+	}
+
+	// A random master key would make the imported secrets unreadable by the server, so require a real one
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+	if masterCryptoPass == "" {
+		return fmt.Errorf("the MASTER_CRYPTO_PASS environment variable must be set to import secrets")
+	}
+
+	// Connect to the database and import the secrets
+	if _, err := bootstrap(*configFile, true); err != nil {
+		return err
+	}
+	service := secrets.NewService(secrets.NewRepository(global.Database))
+	result, err := service.ImportSecrets(context.Background(), *prefix, values, policy, masterCryptoPass)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d secrets: %d created, %d updated, %d skipped\n",
+		len(values), len(result.Created), len(result.Updated), len(result.Skipped))
+	return nil
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// Migrate implements the `lockbox migrate` subcommand, which manages the database schema.
+//
+// Usage:
+//
+//	lockbox migrate status [--config-file path]
+//	lockbox migrate up [--config-file path]
+//	lockbox migrate down [--config-file path] [--steps n]
+func Migrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected an action: status, up or down")
+	}
+	action := args[0]
+	if action != "status" && action != "up" && action != "down" {
+		return fmt.Errorf("unknown action '%s': expected status, up or down", action)
+	}
+
+	// Define the subcommand flags
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	configFile := flags.String("config-file", config.DefaultFilePath, "Path to the configuration file (.conf, .yaml or .toml)")
+	steps := flags.Int("steps", 1, "Number of migrations to revert (down only)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	// Connect to the database without applying migrations automatically
+	if _, err := bootstrap(*configFile, false); err != nil {
+		return err
+	}
+	sqlDB, err := global.Database.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := database.NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", "-"
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\t%s\n", status.Migration.Version, status.Migration.Name, state, appliedAt)
+		}
+		return writer.Flush()
+
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+		return err
+
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("No migrations to revert")
+		}
+		return err
+	}
+
+	return nil
+}
@@ -3,13 +3,15 @@ package config
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
-
-	"github.com/alyu/configparser"
 )
 
+// DefaultFilePath is the configuration file used when no --config-file flag is given.
+const DefaultFilePath = "/etc/lockbox/lockbox.conf"
+
 // Config contains all configurations for the application.
 // It aggregates server, security, database, and logging settings.
 type Config struct {
@@ -24,6 +26,15 @@ type Config struct {
 
 	// Logging holds configurations related to application logging, including log level and file location.
 	Logging LoggingConfig
+
+	// Metrics holds configurations related to the Prometheus metrics endpoint.
+	Metrics MetricsConfig
+
+	// Tracing holds configurations related to OpenTelemetry tracing.
+	Tracing TracingConfig
+
+	// settings holds the effective value of every key as "section.key", to compare and display configurations.
+	settings map[string]string
 }
 
 // ServerConfig contains server-related configurations.
@@ -34,6 +45,23 @@ type ServerConfig struct {
 
 	// Port defines the port on which the server listens (e.g., "8080").
 	Port string
+
+	// ReadTimeout defines the maximum duration (in seconds) for reading a request, including its body.
+	ReadTimeout int
+
+	// WriteTimeout defines the maximum duration (in seconds) before timing out writes of the response.
+	WriteTimeout int
+
+	// IdleTimeout defines the maximum duration (in seconds) to wait for the next request on a keep-alive connection.
+	IdleTimeout int
+
+	// ShutdownGracePeriod defines how long (in seconds) in-flight requests and background workers
+	// are given to complete after a SIGTERM or SIGINT before the server stops anyway.
+	ShutdownGracePeriod int
+
+	// ShutdownDelay defines how long (in seconds) the server keeps serving requests after a SIGTERM or SIGINT,
+	// while the readiness probe fails, so that load balancers stop routing to it before its listeners close.
+	ShutdownDelay int
 }
 
 // SecurityConfig contains security-related configurations.
@@ -44,6 +72,10 @@ type SecurityConfig struct {
 
 	// APIKeyValidity defines the duration (in seconds) for which the API key remains valid.
 	APIKeyValidity int
+
+	// AllowExport enables the bulk secret export endpoint, which returns decrypted values in bulk.
+	// It is disabled by default.
+	AllowExport bool
 }
 
 // DatabaseConfig contains database-related configurations.
@@ -75,6 +107,19 @@ type DatabaseConfig struct {
 
 	// MaxConnLife defines the maximum lifetime (in seconds) of a connection before it is reused.
 	MaxConnLife int
+
+	// ConnectInitialBackoff defines the delay (in seconds) before the first retry when the database is unreachable.
+	ConnectInitialBackoff int
+
+	// ConnectMaxBackoff defines the maximum delay (in seconds) between two connection attempts.
+	ConnectMaxBackoff int
+
+	// ConnectMaxWait defines how long (in seconds) Lockbox waits for the database on startup before giving up.
+	ConnectMaxWait int
+
+	// AutoMigrate defines whether pending schema migrations are applied on startup.
+	// When disabled, the server refuses to start until `lockbox migrate up` has been run.
+	AutoMigrate bool
 }
 
 // LoggingConfig contains logging-related configurations.
@@ -86,30 +131,93 @@ type LoggingConfig struct {
 	// FilePath specifies the file path where log files will be written.
 	FilePath string
 
-	// MaxLogLength defines the maximum size (in characters) before log files are rotated.
+	// MaxLogLength defines the maximum length (in characters) of a log message or field value before it is truncated.
+	// Zero or a negative value disables truncation.
 	MaxLogLength int
+
+	// Format defines how log entries are written: "text" for people, or "json" for log shippers.
+	Format string
+
+	// MaxSize defines the maximum size (in megabytes) of the log file before it is rotated.
+	MaxSize int
+
+	// MaxBackups defines how many rotated log files are kept. Zero keeps all of them.
+	MaxBackups int
+
+	// MaxAge defines how many days rotated log files are kept. Zero keeps them regardless of their age.
+	MaxAge int
+
+	// Compress defines whether rotated log files are compressed with gzip.
+	Compress bool
+
+	// Stdout defines whether log entries are written to the standard output.
+	Stdout bool
+
+	// File defines whether log entries are written to the log file at FilePath.
+	// Containers usually only need the standard output.
+	File bool
 }
 
-// LoadConfig loads the configuration from a .conf file and environment variables.
-// The MASTER_CRYPTO_PASS environment variable is required for production security, and if it is not set, a random key is generated with a warning.
-func LoadConfig(filePath string) (*Config, error) {
-	// Read the .conf file using configparser
-	configFile, err := configparser.Read(filePath)
-	if err != nil {
-		return nil, err
-	}
+// MetricsConfig contains the configuration of the Prometheus metrics endpoint.
+// Metrics are served on their own address, so they are not exposed on the public port of the API.
+type MetricsConfig struct {
+	// Enabled defines whether GET /metrics is served.
+	Enabled bool
 
-	// Load the server configuration section
-	serverSection, err := configFile.Section("server")
-	if err != nil {
-		log.Fatalf("Error accessing 'server' section: %v", err)
-	}
+	// Host defines the address the metrics endpoint listens on (e.g., "127.0.0.1" to only allow local scrapes).
+	Host string
+
+	// Port defines the port the metrics endpoint listens on (e.g., "9090").
+	Port string
+}
+
+// TracingConfig contains the configuration of OpenTelemetry tracing.
+// Tracing is disabled by default; when enabled, spans are sent to an OTLP collector or written as JSON for local testing.
+type TracingConfig struct {
+	// Enabled defines whether spans are recorded and exported.
+	Enabled bool
+
+	// Exporter defines where spans are sent: "otlp" (OTLP over HTTP), "stdout" or "file".
+	Exporter string
+
+	// Endpoint defines the host and port of the OTLP collector (e.g., "localhost:4318").
+	Endpoint string
+
+	// Insecure defines whether the OTLP collector is reached over plain HTTP instead of HTTPS.
+	Insecure bool
 
-	// Load the security configuration section
-	securitySection, err := configFile.Section("security")
+	// FilePath defines the file spans are written to by the "file" exporter.
+	FilePath string
+
+	// SampleRatio defines the fraction of new traces that are recorded, between 0 and 1.
+	// Requests that are part of a trace started by a client follow the sampling decision of the client.
+	SampleRatio float64
+}
+
+// LoadConfig loads the configuration, merging its sources in order of precedence:
+// the defaults, then the configuration file, then the LOCKBOX_<SECTION>_<KEY> environment variables,
+// then the overrides given on the command line as "section.key=value".
+// The file is read in the format given by its extension (YAML, TOML or .conf), and skipped if filePath is empty.
+// Every section is optional. A missing file or a malformed source is reported as an error, and so are invalid values:
+// the error is then a *ValidationError listing every problem of the configuration.
+// The MASTER_CRYPTO_PASS environment variable is required for production security, and if it is not set, a random key is generated with a warning.
+func LoadConfig(filePath string, overrides ...string) (*Config, error) {
+	// Merge the sources, each one taking precedence over the previous ones
+	// Defaults are applied afterwards, to the keys that no source sets
+	merged := values{}
+	if filePath != "" {
+		fileValues, err := readFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(fileValues)
+	}
+	merged.merge(readEnvironment(os.Environ()))
+	overrideValues, err := readOverrides(overrides)
 	if err != nil {
-		log.Fatalf("Error accessing 'security' section: %v", err)
+		return nil, err
 	}
+	merged.merge(overrideValues)
 
 	// Load the master cryptographic passphrase from the environment
 	// If it is not defined, generate a random one and save it
@@ -123,27 +231,32 @@ func LoadConfig(filePath string) (*Config, error) {
 		os.Setenv(envFieldName, masterCryptoPass)
 	}
 
-	// Load the database configuration section
-	databaseSection, err := configFile.Section("database")
-	if err != nil {
-		log.Fatalf("Error accessing 'database' section: %v", err)
-	}
-
-	// Load the logging configuration section
-	loggingSection, err := configFile.Section("logging")
-	if err != nil {
-		log.Fatalf("Error accessing 'logging' section: %v", err)
-	}
+	// Get the sections of the configuration
+	// A missing section has no values, so all of its keys take their default value
+	reader := newReader(merged)
+	serverSection := reader.section("server")
+	securitySection := reader.section("security")
+	databaseSection := reader.section("database")
+	loggingSection := reader.section("logging")
+	metricsSection := reader.section("metrics")
+	tracingSection := reader.section("tracing")
 
 	// Fill in the configuration values using defaults where applicable
 	config := &Config{
 		Server: ServerConfig{
 			Host: getValueOrDefault(serverSection, "host", "0.0.0.0"),
 			Port: getValueOrDefault(serverSection, "port", "8080"),
+
+			ReadTimeout:         getValueOrDefaultAsInt(serverSection, "read_timeout", 15),
+			WriteTimeout:        getValueOrDefaultAsInt(serverSection, "write_timeout", 15),
+			IdleTimeout:         getValueOrDefaultAsInt(serverSection, "idle_timeout", 60),
+			ShutdownGracePeriod: getValueOrDefaultAsInt(serverSection, "shutdown_grace_period", 30),
+			ShutdownDelay:       getValueOrDefaultAsInt(serverSection, "shutdown_delay", 5),
 		},
 		Security: SecurityConfig{
 			APIKeyLength:   getValueOrDefaultAsInt(securitySection, "api_key_length", 32),
 			APIKeyValidity: getValueOrDefaultAsInt(securitySection, "api_key_validity", 60), // 1 minute
+			AllowExport:    getValueOrDefaultAsBool(securitySection, "allow_export", false),
 		},
 		Database: DatabaseConfig{
 			Host:         getValueOrDefault(databaseSection, "host", "localhost"),
@@ -155,45 +268,106 @@ func LoadConfig(filePath string) (*Config, error) {
 			MaxIdleConns: getValueOrDefaultAsInt(databaseSection, "max_idle_conns", 10),
 			MaxOpenConns: getValueOrDefaultAsInt(databaseSection, "max_open_conns", 100),
 			MaxConnLife:  getValueOrDefaultAsInt(databaseSection, "max_conn_life", 60),
+			AutoMigrate:  getValueOrDefaultAsBool(databaseSection, "auto_migrate", true),
+
+			ConnectInitialBackoff: getValueOrDefaultAsInt(databaseSection, "connect_initial_backoff", 1),
+			ConnectMaxBackoff:     getValueOrDefaultAsInt(databaseSection, "connect_max_backoff", 30),
+			ConnectMaxWait:        getValueOrDefaultAsInt(databaseSection, "connect_max_wait", 300),
 		},
 		Logging: LoggingConfig{
 			Level:        getValueOrDefault(loggingSection, "level", "info"),
 			FilePath:     getValueOrDefault(loggingSection, "filepath", "lockbox.log"),
 			MaxLogLength: getValueOrDefaultAsInt(loggingSection, "max_log_length", 1000),
+			Format:       getValueOrDefault(loggingSection, "format", "text"),
+			MaxSize:      getValueOrDefaultAsInt(loggingSection, "max_size", 10),
+			MaxBackups:   getValueOrDefaultAsInt(loggingSection, "max_backups", 3),
+			MaxAge:       getValueOrDefaultAsInt(loggingSection, "max_age", 28),
+			Compress:     getValueOrDefaultAsBool(loggingSection, "compress", true),
+			Stdout:       getValueOrDefaultAsBool(loggingSection, "output_stdout", true),
+			File:         getValueOrDefaultAsBool(loggingSection, "output_file", true),
 		},
+		Metrics: MetricsConfig{
+			Enabled: getValueOrDefaultAsBool(metricsSection, "enabled", true),
+			Host:    getValueOrDefault(metricsSection, "host", "127.0.0.1"),
+			Port:    getValueOrDefault(metricsSection, "port", "9090"),
+		},
+		Tracing: TracingConfig{
+			Enabled:     getValueOrDefaultAsBool(tracingSection, "enabled", false),
+			Exporter:    getValueOrDefault(tracingSection, "exporter", "otlp"),
+			Endpoint:    getValueOrDefault(tracingSection, "endpoint", "localhost:4318"),
+			Insecure:    getValueOrDefaultAsBool(tracingSection, "insecure", false),
+			FilePath:    getValueOrDefault(tracingSection, "filepath", "lockbox-traces.json"),
+			SampleRatio: getValueOrDefaultAsFloat(tracingSection, "sample_ratio", 1),
+		},
+	}
+
+	// Report every problem at once: values that could not be parsed, unknown keys,
+	// and values that are out of range or contradict each other
+	reader.reportUnknownKeys()
+	reader.validate(config)
+	if len(reader.problems) > 0 {
+		return nil, &ValidationError{Problems: reader.problems}
 	}
+	config.settings = reader.settings
 
 	return config, nil
 }
 
 // getValueOrDefault retrieves a string value from the configuration section, or returns a default value if not found.
 // This ensures the application has reasonable defaults even if some configuration parameters are missing.
-func getValueOrDefault(section *configparser.Section, key, defaultValue string) string {
-	if section == nil {
-		return defaultValue
-	}
-
-	value := section.ValueOf(key)
+// The key is recorded as known, so that it is not reported as unknown, and its effective value is recorded as a setting.
+func getValueOrDefault(section *configSection, key, defaultValue string) string {
+	value := section.get(key)
 	if value == "" {
-		return defaultValue
+		value = defaultValue
 	}
 
+	section.reader.settings[section.name+"."+key] = value
 	return value
 }
 
 // getValueOrDefaultAsInt retrieves an integer value from the configuration section, or returns a default value if not found.
-// This converts the string value from the config file into an integer, with error handling for invalid formats.
-func getValueOrDefaultAsInt(section *configparser.Section, key string, defaultValue int) int {
+// This converts the string value from the config file into an integer; invalid formats are reported as problems.
+func getValueOrDefaultAsInt(section *configSection, key string, defaultValue int) int {
 	value := getValueOrDefault(section, key, strconv.Itoa(defaultValue))
 
 	valueAsInt, err := strconv.Atoi(value)
 	if err != nil {
+		section.problem(key, fmt.Sprintf("'%s' is not an integer", value))
 		return defaultValue
 	}
 
 	return valueAsInt
 }
 
+// getValueOrDefaultAsBool retrieves a boolean value from the configuration section, or returns a default value if not found.
+// Accepted values are the ones understood by strconv.ParseBool (e.g., "true", "false", "1", "0"); others are reported as problems.
+func getValueOrDefaultAsBool(section *configSection, key string, defaultValue bool) bool {
+	value := getValueOrDefault(section, key, strconv.FormatBool(defaultValue))
+
+	valueAsBool, err := strconv.ParseBool(value)
+	if err != nil {
+		section.problem(key, fmt.Sprintf("'%s' is not a boolean (expected true or false)", value))
+		return defaultValue
+	}
+
+	return valueAsBool
+}
+
+// getValueOrDefaultAsFloat retrieves a decimal value from the configuration section, or returns a default value if not found.
+// This converts the string value from the config file into a float; invalid formats are reported as problems.
+func getValueOrDefaultAsFloat(section *configSection, key string, defaultValue float64) float64 {
+	value := getValueOrDefault(section, key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+
+	valueAsFloat, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		section.problem(key, fmt.Sprintf("'%s' is not a number", value))
+		return defaultValue
+	}
+
+	return valueAsFloat
+}
+
 // generateRandomKey generates a secure random key of the specified length (in bytes) and returns it as a hexadecimal string.
 // This is used when the MASTER_CRYPTO_PASS environment variable is not set, generating a 64-character random key (32 bytes).
 func generateRandomKey(length int) string {
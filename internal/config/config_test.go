@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes a configuration file with the given name and content in a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	filePath := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0o600))
+	return filePath
+}
+
+// TestLoadConfigFormats tests that .conf, YAML and TOML files are read by extension, with the same result.
+func TestLoadConfigFormats(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	files := map[string]string{
+		"lockbox.conf": "[server]\nport = 9000\n\n[database]\npassword = 0123\n\n[tracing]\nenabled = true\nsample_ratio = 0.25\n",
+		"lockbox.yaml": "server:\n  port: 9000\ndatabase:\n  password: 0123\ntracing:\n  enabled: true\n  sample_ratio: 0.25\n",
+		"lockbox.toml": "[server]\nport = 9000\n\n[database]\npassword = \"0123\"\n\n[tracing]\nenabled = true\nsample_ratio = 0.25\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfig(writeConfigFile(t, name, content))
+			require.NoError(t, err)
+			assert.Equal(t, "9000", config.Server.Port)
+			assert.Equal(t, "0123", config.Database.Password)
+			assert.True(t, config.Tracing.Enabled)
+			assert.Equal(t, 0.25, config.Tracing.SampleRatio)
+
+			// Missing sections and keys take their default value
+			assert.Equal(t, "0.0.0.0", config.Server.Host)
+			assert.Equal(t, "info", config.Logging.Level)
+		})
+	}
+}
+
+// TestLoadConfigPrecedence tests that the environment overrides the file, and that flags override the environment.
+func TestLoadConfigPrecedence(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+	t.Setenv("LOCKBOX_SERVER_PORT", "9001")
+	t.Setenv("LOCKBOX_LOGGING_MAX_LOG_LENGTH", "500")
+	t.Setenv("LOCKBOX_DATABASE_HOST", "db.internal")
+
+	filePath := writeConfigFile(t, "lockbox.conf", "[server]\nport = 9000\nhost = 127.0.0.1\n\n[logging]\nlevel = warn\n")
+	config, err := LoadConfig(filePath, "server.port=9002", "logging.level=debug")
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1", config.Server.Host, "The file overrides the defaults")
+	assert.Equal(t, "db.internal", config.Database.Host, "The environment overrides the defaults")
+	assert.Equal(t, 500, config.Logging.MaxLogLength, "The environment overrides the defaults")
+	assert.Equal(t, "9002", config.Server.Port, "Flags override the environment")
+	assert.Equal(t, "debug", config.Logging.Level, "Flags override the file")
+}
+
+// TestLoadConfigWithoutFile tests that the configuration can come from the environment only.
+func TestLoadConfigWithoutFile(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+	t.Setenv("LOCKBOX_DATABASE_SSL_MODE", "require")
+
+	config, err := LoadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "require", config.Database.SSLMode)
+	assert.Equal(t, "8080", config.Server.Port)
+}
+
+// TestLoadConfigIgnoresServiceLinks tests that the LOCKBOX_ variables Kubernetes sets for a service named "lockbox"
+// are not mistaken for configuration values.
+func TestLoadConfigIgnoresServiceLinks(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+	t.Setenv("LOCKBOX_SERVICE_HOST", "10.96.0.12")
+	t.Setenv("LOCKBOX_PORT_8080_TCP", "tcp://10.96.0.12:8080")
+	t.Setenv("LOCKBOX_SERVER_PORT", "9000")
+
+	config, err := LoadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "9000", config.Server.Port)
+}
+
+// TestLoadConfigErrors tests that missing files and malformed sources are reported instead of ignored.
+func TestLoadConfigErrors(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.conf"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(writeConfigFile(t, "lockbox.yaml", "server:\n  port: [9000, 9001]\n"))
+	assert.ErrorContains(t, err, "'server.port' must be a single value")
+
+	_, err = LoadConfig(writeConfigFile(t, "lockbox.toml", "port = 9000\n"))
+	assert.ErrorContains(t, err, "'port' must be a [port] table")
+
+	_, err = LoadConfig("", "password=hunter2")
+	assert.ErrorContains(t, err, "expected section.key=value")
+	assert.NotContains(t, err.Error(), "hunter2")
+}
+
+// TestOverridesFlag tests that the --set flag can be repeated, and rejects malformed values.
+func TestOverridesFlag(t *testing.T) {
+	var overrides Overrides
+	require.NoError(t, overrides.Set("server.port=9000"))
+	require.NoError(t, overrides.Set("database.password=a=b"))
+	assert.Equal(t, "server.port=9000,database.password=a=b", overrides.String())
+
+	assert.Error(t, overrides.Set("server.port"))
+	assert.Error(t, overrides.Set(".port=9000"))
+	assert.Len(t, overrides, 2)
+}
+
+// TestReadEnvironment tests how LOCKBOX_<SECTION>_<KEY> variables are split into sections and keys.
+func TestReadEnvironment(t *testing.T) {
+	envValues := readEnvironment([]string{
+		"LOCKBOX_DATABASE_MAX_OPEN_CONNS=50",
+		"LOCKBOX_TRACING_ENABLED=true",
+		"LOCKBOX_=ignored",
+		"LOCKBOX_SERVER=ignored",
+		"LOCKBOX_TRACNG_ENABLED=ignored",
+		"LOCKBOX_SERVICE_HOST=ignored",
+		"MASTER_CRYPTO_PASS=ignored",
+	})
+	assert.Equal(t, values{
+		"database": {"max_open_conns": {text: "50", source: "LOCKBOX_DATABASE_MAX_OPEN_CONNS"}},
+		"tracing":  {"enabled": {text: "true", source: "LOCKBOX_TRACING_ENABLED"}},
+	}, envValues)
+}
+
+// TestLoadConfigReportsEveryProblem tests that bad types, unknown keys, out-of-range values and contradictions
+// are all reported at once, with the source that set them.
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+	t.Setenv("LOCKBOX_TRACING_ENABLD", "true")
+
+	filePath := writeConfigFile(t, "lockbox.conf", "[database]\nmax_open_conns = 1OO\nmax_open_con = 100\nmax_idle_conns = 20\n\n[tracing]\nsample_ratio = 2\n")
+	_, err := LoadConfig(filePath, "logging.output_stdout=false", "logging.output_file=false", "database.max_open_conns=10")
+
+	var validationError *ValidationError
+	require.ErrorAs(t, err, &validationError)
+	assert.ElementsMatch(t, []string{
+		"database.max_open_con: unknown key (set in " + filePath + ")",
+		"tracing.enabld: unknown key (set in LOCKBOX_TRACING_ENABLD)",
+		"database.max_idle_conns: must not be greater than max_open_conns (20 > 10) (set in " + filePath + ")",
+		"logging.output_file: must be enabled when output_stdout is disabled (set in --set logging.output_file)",
+		"tracing.sample_ratio: must be between 0 and 1 (set in " + filePath + ")",
+	}, validationError.Problems)
+	assert.Contains(t, err.Error(), "invalid configuration (5 problems)")
+}
+
+// TestLoadConfigReportsBadTypes tests that values that cannot be parsed are reported instead of replaced by their default.
+func TestLoadConfigReportsBadTypes(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	_, err := LoadConfig("", "database.max_open_conns=1OO", "tracing.enabled=yes", "tracing.sample_ratio=half")
+
+	var validationError *ValidationError
+	require.ErrorAs(t, err, &validationError)
+	assert.Equal(t, []string{
+		"database.max_open_conns: '1OO' is not an integer (set in --set database.max_open_conns)",
+		"tracing.enabled: 'yes' is not a boolean (expected true or false) (set in --set tracing.enabled)",
+		"tracing.sample_ratio: 'half' is not a number (set in --set tracing.sample_ratio)",
+	}, validationError.Problems)
+}
+
+// TestLoadConfigSkipsComments tests that comments and blank lines of .conf files are not reported as unknown keys.
+func TestLoadConfigSkipsComments(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	filePath := writeConfigFile(t, "lockbox.conf", "# Lockbox\n[server]\n; API port\nport = 9000\n\n# port = 9001\n")
+	config, err := LoadConfig(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "9000", config.Server.Port)
+}
+
+// TestChanges tests that the settings whose effective value changed are listed, including the defaults that are overridden.
+func TestChanges(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	current, err := LoadConfig("", "logging.level=info")
+	require.NoError(t, err)
+	same, err := LoadConfig("")
+	require.NoError(t, err)
+	changed, err := LoadConfig("", "logging.level=debug", "database.password=hunter2", "server.port=9000")
+	require.NoError(t, err)
+
+	assert.Empty(t, current.Changes(same), "A value equal to the default is not a change")
+	assert.Equal(t, []string{"database.password", "logging.level", "server.port"}, current.Changes(changed))
+}
+
+// TestRedacted tests that every setting is displayed with its effective value, except for secrets.
+func TestRedacted(t *testing.T) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	config, err := LoadConfig("", "database.password=hunter2", "server.port=9000")
+	require.NoError(t, err)
+
+	redacted := config.Redacted()
+	assert.Equal(t, "9000", redacted["server"]["port"])
+	assert.Equal(t, "info", redacted["logging"]["level"], "Defaults are displayed")
+	assert.Equal(t, RedactedValue, redacted["database"]["password"])
+	assert.NotContains(t, fmt.Sprint(redacted), "hunter2")
+}
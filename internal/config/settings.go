@@ -0,0 +1,58 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// RedactedValue replaces the value of secret settings when the configuration is displayed.
+const RedactedValue = "*****"
+
+// secretSettings are the settings whose value is never displayed.
+var secretSettings = map[string]bool{
+	"database.password": true,
+}
+
+// ReloadableSettings are the settings that can change while Lockbox is running.
+// A change to any other setting only takes effect after a restart.
+var ReloadableSettings = map[string]bool{
+	"logging.level":           true,
+	"database.max_idle_conns": true,
+	"database.max_open_conns": true,
+	"database.max_conn_life":  true,
+	"database.password":       true,
+}
+
+// Redacted returns the effective value of every setting by section and key, with the value of secret settings replaced
+// by RedactedValue. It includes the defaults, so that it shows the configuration that is actually in use.
+func (c *Config) Redacted() map[string]map[string]string {
+	redacted := map[string]map[string]string{}
+	for name, value := range c.settings {
+		if secretSettings[name] {
+			value = RedactedValue
+		}
+		sectionName, key, _ := strings.Cut(name, ".")
+		if redacted[sectionName] == nil {
+			redacted[sectionName] = map[string]string{}
+		}
+		redacted[sectionName][key] = value
+	}
+	return redacted
+}
+
+// Changes returns the settings, as "section.key", whose effective value differs in the other configuration, in order.
+func (c *Config) Changes(other *Config) []string {
+	var changes []string
+	for name, value := range c.settings {
+		if otherValue, found := other.settings[name]; !found || otherValue != value {
+			changes = append(changes, name)
+		}
+	}
+	for name := range other.settings {
+		if _, found := c.settings[name]; !found {
+			changes = append(changes, name)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alyu/configparser"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables that override configuration values.
+// The variable LOCKBOX_<SECTION>_<KEY> overrides the key of the section (e.g., LOCKBOX_DATABASE_MAX_OPEN_CONNS).
+const EnvPrefix = "LOCKBOX_"
+
+// value is a raw configuration value, with the source that set it (e.g., the file path or the environment variable),
+// so that problems can be reported where they can be fixed.
+type value struct {
+	text   string
+	source string
+}
+
+// sectionValues holds the raw values of a configuration section, by key.
+type sectionValues map[string]value
+
+// values holds the raw values of every configuration section, by section name.
+// Section names and keys are lowercase, whatever the source they were read from.
+type values map[string]sectionValues
+
+// set stores a value and its source, creating its section if needed.
+func (v values) set(sectionName, key, text, source string) {
+	sectionName, key = strings.ToLower(sectionName), strings.ToLower(key)
+	if v[sectionName] == nil {
+		v[sectionName] = sectionValues{}
+	}
+	v[sectionName][key] = value{text: text, source: source}
+}
+
+// merge copies the values of another source over these ones, so that the other source takes precedence.
+func (v values) merge(other values) {
+	for sectionName, options := range other {
+		for key, value := range options {
+			v.set(sectionName, key, value.text, value.source)
+		}
+	}
+}
+
+// readFile reads the values of a configuration file, in the format given by its extension:
+// ".yaml" or ".yml" for YAML, ".toml" for TOML, and the INI format of .conf files for any other extension.
+func readFile(filePath string) (values, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return readYAML(filePath)
+	case ".toml":
+		return readTOML(filePath)
+	default:
+		return readINI(filePath)
+	}
+}
+
+// readINI reads the values of a .conf file, made of [section] headers followed by key = value lines.
+// The parser keeps blank lines and comments as keys without values, so they are skipped.
+func readINI(filePath string) (values, error) {
+	configFile, err := configparser.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := configFile.AllSections()
+	if err != nil {
+		return nil, err
+	}
+
+	fileValues := values{}
+	for _, fileSection := range sections {
+		for key, value := range fileSection.Options() {
+			if key == "" || strings.HasPrefix(key, "#") || strings.HasPrefix(key, ";") {
+				continue
+			}
+			fileValues.set(fileSection.Name(), key, value, filePath)
+		}
+	}
+	return fileValues, nil
+}
+
+// readYAML reads the values of a YAML file, made of a mapping per section.
+// Scalars are kept as written (e.g., a password "0123" is not read as an octal number).
+func readYAML(filePath string) (values, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("invalid YAML in %s: %v", filePath, err)
+	}
+
+	// An empty file has no content
+	fileValues := values{}
+	if len(document.Content) == 0 {
+		return fileValues, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid YAML in %s: expected a mapping of sections", filePath)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		sectionName, options := root.Content[i].Value, root.Content[i+1]
+		if options.Tag == "!!null" {
+			continue
+		}
+		if options.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid YAML in %s: section '%s' must be a mapping of keys", filePath, sectionName)
+		}
+		for j := 0; j+1 < len(options.Content); j += 2 {
+			key, value := options.Content[j].Value, options.Content[j+1]
+			if value.Tag == "!!null" {
+				continue
+			}
+			if value.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("invalid YAML in %s: '%s.%s' must be a single value", filePath, sectionName, key)
+			}
+			fileValues.set(sectionName, key, value.Value, filePath)
+		}
+	}
+	return fileValues, nil
+}
+
+// readTOML reads the values of a TOML file, made of a table per section.
+func readTOML(filePath string) (values, error) {
+	var document map[string]any
+	if _, err := toml.DecodeFile(filePath, &document); err != nil {
+		return nil, fmt.Errorf("invalid TOML in %s: %v", filePath, err)
+	}
+
+	fileValues := values{}
+	for sectionName, options := range document {
+		table, ok := options.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid TOML in %s: '%s' must be a [%s] table", filePath, sectionName, sectionName)
+		}
+		for key, value := range table {
+			var text string
+			switch value := value.(type) {
+			case string:
+				text = value
+			case int64:
+				text = strconv.FormatInt(value, 10)
+			case float64:
+				text = strconv.FormatFloat(value, 'f', -1, 64)
+			case bool:
+				text = strconv.FormatBool(value)
+			case time.Time:
+				text = value.Format(time.RFC3339)
+			default:
+				return nil, fmt.Errorf("invalid TOML in %s: '%s.%s' must be a single value", filePath, sectionName, key)
+			}
+			fileValues.set(sectionName, key, text, filePath)
+		}
+	}
+	return fileValues, nil
+}
+
+// envSections are the sections that can be set by environment variables.
+// The environment is shared with other software, so the other LOCKBOX_ variables are ignored rather than reported
+// as unknown sections: e.g., Kubernetes sets LOCKBOX_SERVICE_HOST and LOCKBOX_PORT_8080_TCP for a service named "lockbox".
+var envSections = map[string]bool{
+	"server":   true,
+	"security": true,
+	"database": true,
+	"logging":  true,
+	"metrics":  true,
+	"tracing":  true,
+}
+
+// readEnvironment reads the values overridden by LOCKBOX_<SECTION>_<KEY> environment variables.
+// Section names have no underscore, so the section ends at the first underscore after the prefix
+// (e.g., LOCKBOX_LOGGING_MAX_LOG_LENGTH sets max_log_length in [logging]).
+// Variables of other sections are ignored (see envSections).
+func readEnvironment(environment []string) values {
+	envValues := values{}
+	for _, variable := range environment {
+		name, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		sectionName, key, found := strings.Cut(strings.TrimPrefix(name, EnvPrefix), "_")
+		if !found || key == "" || !envSections[strings.ToLower(sectionName)] {
+			continue
+		}
+		envValues.set(sectionName, key, value, name)
+	}
+	return envValues
+}
+
+// Overrides holds the configuration values set on the command line, as "section.key=value".
+// It implements flag.Value, so that the flag can be repeated (e.g., --set server.port=9000 --set logging.level=debug).
+type Overrides []string
+
+// String returns the overrides, separated by commas.
+func (o *Overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+// Set adds an override, after checking its format.
+func (o *Overrides) Set(override string) error {
+	if _, _, _, err := parseOverride(override); err != nil {
+		return err
+	}
+	*o = append(*o, override)
+	return nil
+}
+
+// parseOverride splits an override into its section, key and value.
+func parseOverride(override string) (string, string, string, error) {
+	name, value, found := strings.Cut(override, "=")
+	sectionName, key, dotted := strings.Cut(name, ".")
+	if !found || !dotted || sectionName == "" || key == "" {
+		return "", "", "", fmt.Errorf("invalid override '%s': expected section.key=value", name)
+	}
+	return sectionName, key, value, nil
+}
+
+// readOverrides reads the values set on the command line.
+func readOverrides(overrides []string) (values, error) {
+	overrideValues := values{}
+	for _, override := range overrides {
+		sectionName, key, value, err := parseOverride(override)
+		if err != nil {
+			return nil, err
+		}
+		overrideValues.set(sectionName, key, value, "--set "+sectionName+"."+key)
+	}
+	return overrideValues, nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ValidationError reports every problem found in the configuration, so that they can all be fixed at once.
+type ValidationError struct {
+	// Problems describes each problem as "section.key: what is wrong (where it is set)".
+	Problems []string
+}
+
+// Error lists the problems, one per line.
+func (e *ValidationError) Error() string {
+	noun := "problems"
+	if len(e.Problems) == 1 {
+		noun = "problem"
+	}
+	return fmt.Sprintf("invalid configuration (%d %s):\n  - %s", len(e.Problems), noun, strings.Join(e.Problems, "\n  - "))
+}
+
+// reader reads the sections of the merged configuration values, and collects the problems found along the way.
+type reader struct {
+	values   values
+	sections map[string]*configSection
+	problems []string
+	settings map[string]string
+}
+
+// configSection reads the values of a section, recording the keys that are read.
+type configSection struct {
+	name   string
+	reader *reader
+	read   map[string]bool
+}
+
+// newReader creates a reader of the merged configuration values.
+func newReader(merged values) *reader {
+	return &reader{values: merged, sections: map[string]*configSection{}, settings: map[string]string{}}
+}
+
+// section returns the reader of a section, which does not need to exist in the values.
+func (r *reader) section(name string) *configSection {
+	if r.sections[name] == nil {
+		r.sections[name] = &configSection{name: name, reader: r, read: map[string]bool{}}
+	}
+	return r.sections[name]
+}
+
+// get returns the raw value of a key, or an empty string if no source sets it, and records the key as known.
+func (s *configSection) get(key string) string {
+	s.read[key] = true
+	return s.reader.values[s.name][key].text
+}
+
+// problem reports a problem with the value of a key.
+func (s *configSection) problem(key, message string) {
+	s.reader.problem(s.name, key, message)
+}
+
+// problem reports a problem with the value of a key, along with the source that set it, if any.
+func (r *reader) problem(sectionName, key, message string) {
+	problem := fmt.Sprintf("%s.%s: %s", sectionName, key, message)
+	if source := r.values[sectionName][key].source; source != "" {
+		problem += fmt.Sprintf(" (set in %s)", source)
+	}
+	r.problems = append(r.problems, problem)
+}
+
+// check reports a problem with the value of a key unless the condition holds.
+func (r *reader) check(condition bool, sectionName, key, message string) {
+	if !condition {
+		r.problem(sectionName, key, message)
+	}
+}
+
+// reportUnknownKeys reports the keys that were never read, in sections and keys order.
+// They are usually typos (e.g., "max_open_con"), which would otherwise silently leave the default value in place.
+func (r *reader) reportUnknownKeys() {
+	sectionNames := make([]string, 0, len(r.values))
+	for sectionName := range r.values {
+		sectionNames = append(sectionNames, sectionName)
+	}
+	sort.Strings(sectionNames)
+
+	for _, sectionName := range sectionNames {
+		keys := make([]string, 0, len(r.values[sectionName]))
+		for key := range r.values[sectionName] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		section, known := r.sections[sectionName]
+		for _, key := range keys {
+			switch {
+			case !known:
+				r.problem(sectionName, key, fmt.Sprintf("unknown section [%s]", sectionName))
+			case !section.read[key]:
+				r.problem(sectionName, key, "unknown key")
+			}
+		}
+	}
+}
+
+// validate reports the values that are out of range, and the values that contradict each other.
+func (r *reader) validate(config *Config) {
+	// Server
+	r.check(validPort(config.Server.Port), "server", "port", "must be a port number between 1 and 65535")
+	r.check(config.Server.ReadTimeout >= 0, "server", "read_timeout", "must not be negative")
+	r.check(config.Server.WriteTimeout >= 0, "server", "write_timeout", "must not be negative")
+	r.check(config.Server.IdleTimeout >= 0, "server", "idle_timeout", "must not be negative")
+	r.check(config.Server.ShutdownGracePeriod >= 0, "server", "shutdown_grace_period", "must not be negative")
+	r.check(config.Server.ShutdownDelay >= 0, "server", "shutdown_delay", "must not be negative")
+
+	// Security
+	r.check(config.Security.APIKeyLength > 0, "security", "api_key_length", "must be greater than 0")
+	r.check(config.Security.APIKeyValidity > 0, "security", "api_key_validity", "must be greater than 0")
+
+	// Database
+	database := config.Database
+	r.check(validPort(database.Port), "database", "port", "must be a port number between 1 and 65535")
+	r.check(oneOf(database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		"database", "ssl_mode", "must be one of disable, allow, prefer, require, verify-ca or verify-full")
+	r.check(database.MaxIdleConns >= 0, "database", "max_idle_conns", "must not be negative")
+	r.check(database.MaxOpenConns >= 0, "database", "max_open_conns", "must not be negative (0 means unlimited)")
+	r.check(database.MaxOpenConns == 0 || database.MaxIdleConns <= database.MaxOpenConns, "database", "max_idle_conns",
+		fmt.Sprintf("must not be greater than max_open_conns (%d > %d)", database.MaxIdleConns, database.MaxOpenConns))
+	r.check(database.MaxConnLife >= 0, "database", "max_conn_life", "must not be negative")
+	r.check(database.ConnectInitialBackoff > 0, "database", "connect_initial_backoff", "must be greater than 0")
+	r.check(database.ConnectMaxBackoff >= database.ConnectInitialBackoff, "database", "connect_max_backoff",
+		fmt.Sprintf("must not be less than connect_initial_backoff (%d < %d)", database.ConnectMaxBackoff, database.ConnectInitialBackoff))
+	r.check(database.ConnectMaxWait >= 0, "database", "connect_max_wait", "must not be negative")
+
+	// Logging
+	logging := config.Logging
+	_, err := logrus.ParseLevel(strings.ToLower(logging.Level))
+	r.check(err == nil, "logging", "level", "must be one of trace, debug, info, warn, error, fatal or panic")
+	r.check(oneOf(strings.ToLower(logging.Format), "text", "json"), "logging", "format", "must be text or json")
+	r.check(logging.MaxSize > 0, "logging", "max_size", "must be greater than 0")
+	r.check(logging.MaxBackups >= 0, "logging", "max_backups", "must not be negative (0 keeps all rotated files)")
+	r.check(logging.MaxAge >= 0, "logging", "max_age", "must not be negative (0 keeps rotated files regardless of their age)")
+	r.check(logging.Stdout || logging.File, "logging", "output_file", "must be enabled when output_stdout is disabled")
+
+	// Metrics
+	// The metrics endpoint cannot listen on the port of the API, unless they are bound to different addresses
+	metrics := config.Metrics
+	if metrics.Enabled {
+		r.check(validPort(metrics.Port), "metrics", "port", "must be a port number between 1 and 65535")
+		r.check(metrics.Port != config.Server.Port || !sameInterface(metrics.Host, config.Server.Host), "metrics", "port",
+			"must be different from server.port, since the metrics endpoint is served separately")
+	}
+
+	// Tracing
+	tracing := config.Tracing
+	r.check(oneOf(tracing.Exporter, "otlp", "stdout", "file"), "tracing", "exporter", "must be otlp, stdout or file")
+	r.check(tracing.SampleRatio >= 0 && tracing.SampleRatio <= 1, "tracing", "sample_ratio", "must be between 0 and 1")
+	r.check(!tracing.Enabled || tracing.Exporter != "file" || !logging.File || tracing.FilePath != logging.FilePath,
+		"tracing", "filepath", "must be different from logging.filepath")
+}
+
+// validPort reports whether a port is a number between 1 and 65535.
+func validPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number >= 1 && number <= 65535
+}
+
+// oneOf reports whether a value is one of the allowed values.
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// sameInterface reports whether two listen hosts may bind the same interface, i.e., they are equal or either is a wildcard.
+func sameInterface(host, otherHost string) bool {
+	wildcard := func(host string) bool { return host == "" || host == "0.0.0.0" || host == "::" }
+	return host == otherHost || wildcard(host) || wildcard(otherHost)
+}
@@ -1,43 +1,94 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
-	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	app_log "gitlab.com/xrs-cloud/lockbox/core/internal/logger"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/tracing"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// monitorInterval is how often the connection is checked once the database is ready.
+const monitorInterval = 10 * time.Second
+
+// passwordCheckTimeout is how long Reconfigure waits for the database to accept a new password.
+const passwordCheckTimeout = 10 * time.Second
+
+// password is the password used to open new connections, replaced by Reconfigure when the configuration is reloaded.
+var password atomic.Value
+
 // InitDatabase initializes the database connection using the provided configuration.
-// It connects to the PostgreSQL database, configures connection pool settings, and performs schema migrations.
+// It connects to the PostgreSQL database (retrying while it is unavailable), configures connection pool settings,
+// and brings the schema up to date. The application exits if the database cannot be reached in time.
 func InitDatabase(dbConfig config.DatabaseConfig) *gorm.DB {
+	// Open the connection and configure the pool
+	db := OpenDatabase(dbConfig)
+
+	// Wait for the database and apply the migrations
+	if err := Start(context.Background(), db, dbConfig); err != nil {
+		global.Logger.Fatal(err)
+	}
+
+	// Return the initialized *gorm.DB object for use in the application.
+	return db
+}
+
+// OpenDatabase opens the database connection and configures the connection pool, without touching the schema.
+// The database is not contacted yet: connections are established lazily, so this only fails if the configuration is invalid.
+// Use WaitForDatabase or Start to make sure the database is reachable.
+func OpenDatabase(dbConfig config.DatabaseConfig) *gorm.DB {
 	// Construct the DSN (Data Source Name) using the provided database configuration
 	// This string contains the necessary information to connect to the database.
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		dbConfig.Host,
-		dbConfig.Username,
-		dbConfig.Password,
-		dbConfig.DatabaseName,
-		dbConfig.Port,
-		dbConfig.SSLMode,
-	)
-	global.Logger.Debugf("Connecting to database with %s", dsn)
+	// Only a copy with the password masked is logged.
+	dsn := dataSourceName(dbConfig, dbConfig.Password)
+	global.Logger.Debugf("Connecting to database with %s", dataSourceName(dbConfig, "*****"))
+
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		// If the connection settings are invalid, log the error and exit the application.
+		global.Logger.Fatalf("Invalid database connection settings: %v", err)
+	}
+
+	// Open the connection pool with the password of the configuration.
+	// Each new connection reads the current password, so that Reconfigure can change it without reopening the pool.
+	password.Store(dbConfig.Password)
+	pool := stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, newConnConfig *pgx.ConnConfig) error {
+		newConnConfig.Password = password.Load().(string)
+		return nil
+	}))
 
 	// Open a connection to the PostgreSQL database using GORM.
-	// The connection uses the provided DSN, and GORM is configured with silent logging mode to suppress unnecessary logs.
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+	// GORM uses the connection pool, and is configured with silent logging mode to suppress unnecessary logs.
+	// The automatic ping is disabled so that an unavailable database can be retried instead of failing immediately.
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
 	})
 	if err != nil {
-		// If the connection fails, log the error and exit the application.
+		// If the connection cannot be configured, log the error and exit the application.
 		global.Logger.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Record a span for each query made while serving a traced request.
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		global.Logger.Fatalf("Failed to register the database tracing plugin: %v", err)
+	}
+
+	// Log each query at debug level, tagged with the request ID of its context.
+	if err := db.Use(app_log.GormPlugin{Logger: global.Logger}); err != nil {
+		global.Logger.Fatalf("Failed to register the database logging plugin: %v", err)
+	}
+
 	// Retrieve the underlying *sql.DB object to configure low-level database connection settings.
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -53,10 +104,198 @@ func InitDatabase(dbConfig config.DatabaseConfig) *gorm.DB {
 	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(time.Duration(dbConfig.MaxConnLife) * time.Second)
 
-	// Automatically migrate the database schema based on the Secret model.
-	// This ensures that the schema in the database stays up-to-date with the application's data models.
-	db.AutoMigrate(&secrets.Secret{})
-
-	// Return the initialized *gorm.DB object for use in the application.
+	// Return the opened *gorm.DB object
 	return db
 }
+
+// Reconfigure applies the settings of the database configuration that can change while Lockbox is running:
+// the limits of the connection pool, and the password. Connections that are already open keep working
+// with the previous password; connections opened from now on use the new one.
+// A new password is only applied once a connection has been opened with it, so that a wrong password is rejected
+// (with nothing applied) instead of making every new connection fail.
+func Reconfigure(db *gorm.DB, dbConfig config.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database object: %v", err)
+	}
+
+	if dbConfig.Password != password.Load().(string) {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordCheckTimeout)
+		defer cancel()
+		if err := checkConnection(ctx, dbConfig); err != nil {
+			return fmt.Errorf("failed to connect to the database with the new database.password: %v", err)
+		}
+	}
+
+	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(dbConfig.MaxConnLife) * time.Second)
+	password.Store(dbConfig.Password)
+	return nil
+}
+
+// checkConnection opens a connection outside of the pool with the settings of the configuration, and pings the database.
+func checkConnection(ctx context.Context, dbConfig config.DatabaseConfig) error {
+	connConfig, err := pgx.ParseConfig(dataSourceName(dbConfig, dbConfig.Password))
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	return conn.Ping(ctx)
+}
+
+// dataSourceName builds the DSN (Data Source Name) of the database, with the given password.
+func dataSourceName(dbConfig config.DatabaseConfig, password string) string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		dbConfig.Host,
+		dbConfig.Username,
+		password,
+		dbConfig.DatabaseName,
+		dbConfig.Port,
+		dbConfig.SSLMode,
+	)
+}
+
+// Start waits for the database to become reachable, applies the schema migrations and marks the database as ready.
+// It then keeps monitoring the connection until the context is cancelled, so that a database that goes away later
+// is reported and reconnected without restarting Lockbox. It only returns once monitoring has stopped, so that a caller
+// running it as a background worker can close the connection pool afterwards.
+func Start(ctx context.Context, db *gorm.DB, dbConfig config.DatabaseConfig) error {
+	setState(StateStarting)
+
+	// Wait until the database accepts connections
+	if err := WaitForDatabase(ctx, db, dbConfig); err != nil {
+		return err
+	}
+
+	// Apply the embedded schema migrations (or verify that none are pending when auto-migration is disabled).
+	// A schema that is newer than this binary is always a fatal error, so an old release cannot corrupt it.
+	if err := migrateOnStartup(db, dbConfig.AutoMigrate); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %v", err)
+	}
+
+	setState(StateReady)
+	global.Logger.Info("Database is ready")
+
+	monitor(ctx, db, dbConfig)
+	return nil
+}
+
+// WaitForDatabase pings the database until it answers, waiting between attempts with exponential backoff and jitter.
+// It returns an error if the database is still unreachable after the configured maximum wait, or if the context is cancelled.
+func WaitForDatabase(ctx context.Context, db *gorm.DB, dbConfig config.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database object: %v", err)
+	}
+
+	backoff := &utils.Backoff{
+		Initial: time.Duration(dbConfig.ConnectInitialBackoff) * time.Second,
+		Max:     time.Duration(dbConfig.ConnectMaxBackoff) * time.Second,
+	}
+	deadline := time.Now().Add(time.Duration(dbConfig.ConnectMaxWait) * time.Second)
+
+	for attempt := 1; ; attempt++ {
+		// Try to reach the database
+		err := sqlDB.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		// Give up once the maximum wait has been reached
+		delay := backoff.Next()
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("failed to connect to database after %d attempts: %v", attempt, err)
+		}
+		global.Logger.Warnf("Database is not reachable (attempt %d), retrying in %s: %v", attempt, delay.Round(time.Millisecond), err)
+
+		// Wait before the next attempt
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// monitor periodically pings the database and updates the database state.
+// The connection pool re-establishes connections on its own; monitoring makes the outage visible
+// in the health checks and logs, and retries with backoff while the database is unavailable.
+func monitor(ctx context.Context, db *gorm.DB, dbConfig config.DatabaseConfig) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		global.Logger.Errorf("Failed to get database object, connection monitoring is disabled: %v", err)
+		return
+	}
+
+	backoff := &utils.Backoff{
+		Initial: time.Duration(dbConfig.ConnectInitialBackoff) * time.Second,
+		Max:     time.Duration(dbConfig.ConnectMaxBackoff) * time.Second,
+	}
+	delay := monitorInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if err := sqlDB.PingContext(ctx); err != nil {
+			if State() == StateReady {
+				global.Logger.Errorf("Lost connection to the database, reconnecting: %v", err)
+			}
+			setState(StateUnavailable)
+			delay = backoff.Next()
+			continue
+		}
+
+		if State() == StateUnavailable {
+			global.Logger.Info("Reconnected to the database")
+		}
+		setState(StateReady)
+		backoff.Reset()
+		delay = monitorInterval
+	}
+}
+
+// migrateOnStartup applies pending migrations when autoMigrate is enabled.
+// Otherwise it only checks the schema and returns an error if migrations are pending.
+func migrateOnStartup(db *gorm.DB, autoMigrate bool) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	migrator, err := NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	// Only verify the schema if migrations are managed by an operator
+	ctx := context.Background()
+	if !autoMigrate {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations are pending, run `lockbox migrate up` to apply them", len(pending))
+		}
+		return nil
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		global.Logger.Infof("Applied database migration %04d_%s", migration.Version, migration.Name)
+	}
+	return nil
+}
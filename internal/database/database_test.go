@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// TestReconfigureChecksNewPassword tests that a new password is rejected, with nothing applied,
+// if no connection can be opened with it, and that other settings are applied without connecting.
+func TestReconfigureChecksNewPassword(t *testing.T) {
+	global.Logger = logrus.New()
+
+	// Nothing listens on port 1, so every connection attempt fails
+	dbConfig := config.DatabaseConfig{
+		Host: "127.0.0.1", Port: "1", Username: "lockbox", Password: "old", DatabaseName: "lockbox", SSLMode: "disable",
+		MaxIdleConns: 5, MaxOpenConns: 10, MaxConnLife: 300,
+	}
+	db := OpenDatabase(dbConfig)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// A new password is rejected, and the pool limits of the same reload are not applied
+	rejected := dbConfig
+	rejected.Password = "n3w-s3cret"
+	rejected.MaxOpenConns = 20
+	err = Reconfigure(db, rejected)
+	assert.ErrorContains(t, err, "failed to connect to the database with the new database.password")
+	assert.NotContains(t, err.Error(), "n3w-s3cret")
+	assert.Equal(t, "old", password.Load())
+	assert.Equal(t, 10, sqlDB.Stats().MaxOpenConnections)
+
+	// The pool limits are applied without connecting when the password is unchanged
+	resized := dbConfig
+	resized.MaxOpenConns = 20
+	assert.NoError(t, Reconfigure(db, resized))
+	assert.Equal(t, 20, sqlDB.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the SQL migrations shipped with the binary.
+// Each migration is a pair of files named NNNN_description.up.sql and NNNN_description.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the PostgreSQL advisory lock held while migrating.
+// It is a fixed value ("lockbox" in ASCII) so every replica contends for the same lock.
+const migrationLockID = 0x6c6f636b626f78
+
+// migrationFilePattern matches migration file names and captures the version, the description and the direction.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	// Version is the sequence number of the migration, starting at 1.
+	Version int
+
+	// Name is the human-readable description taken from the file name.
+	Name string
+
+	// Up is the SQL that applies the migration.
+	Up string
+
+	// Down is the SQL that reverts the migration.
+	Down string
+}
+
+// MigrationStatus describes whether a migration has been applied to the database.
+type MigrationStatus struct {
+	// Migration is the migration being described.
+	Migration Migration
+
+	// Applied is true if the migration has been applied.
+	Applied bool
+
+	// AppliedAt is the time at which the migration was applied, if it was.
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts the embedded migrations, recording progress in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB     // The database connection pool
+	migrations []Migration // The known migrations, ordered by version
+}
+
+// NewMigrator creates a migrator for the migrations embedded in the binary.
+// It returns an error if the embedded migrations are malformed (e.g., missing down files or gaps in the numbering).
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LatestVersion returns the version of the newest migration known to this binary.
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns every known migration along with whether it has been applied.
+// It returns an error if the database contains migrations this binary does not know about,
+// which means the schema was created by a newer version of Lockbox.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, isApplied := applied[migration.Version]
+			statuses = append(statuses, MigrationStatus{Migration: migration, Applied: isApplied, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies every pending migration, in order, and returns the migrations that were applied.
+// Each migration runs in its own transaction together with the update of the schema_migrations table.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var appliedNow []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, isApplied := applied[migration.Version]; isApplied {
+				continue
+			}
+
+			err := runInTransaction(ctx, conn, migration.Up,
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+				migration.Version, migration.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %v", migration.Version, migration.Name, err)
+			}
+			appliedNow = append(appliedNow, migration)
+		}
+		return nil
+	})
+	return appliedNow, err
+}
+
+// Down reverts the given number of most recently applied migrations, newest first,
+// and returns the migrations that were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, isApplied := applied[migration.Version]; !isApplied {
+				continue
+			}
+
+			err := runInTransaction(ctx, conn, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %v", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs the function on a dedicated connection while holding the migration advisory lock.
+// The lock is session-scoped, so the same connection must be used for the whole operation.
+// Concurrent replicas block on the lock and then find the migrations already applied.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a database connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire the migration lock: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	// Make sure the bookkeeping table exists before reading it
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create the schema_migrations table: %v", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions and when they were applied.
+// It returns an error if a version is newer than any migration known to this binary.
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema_migrations table: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read the schema_migrations table: %v", err)
+		}
+		if version > m.LatestVersion() {
+			return nil, fmt.Errorf(
+				"database schema version %d is newer than the latest version known to this binary (%d): refusing to continue",
+				version, m.LatestVersion(),
+			)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// runInTransaction executes a migration script and a bookkeeping statement atomically.
+func runInTransaction(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadMigrations reads the migration files in the given directory and pairs up and down scripts by version.
+// Versions must start at 1 and have no gaps, so that two branches adding the same version number are caught early.
+func loadMigrations(files fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name '%s'", entry.Name())
+		}
+		version, _ := strconv.Atoi(matches[1])
+		name, direction := matches[2], matches[3]
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration '%s': %v", entry.Name(), err)
+		}
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has two different names: '%s' and '%s'", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential: expected %d, found %d", i+1, migration.Version)
+		}
+	}
+
+	return migrations, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadEmbeddedMigrations tests that the migrations shipped with the binary are well-formed.
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+// TestLoadMigrationsOrdering tests that migrations are paired and sorted by version.
+func TestLoadMigrationsOrdering(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN c TEXT;")},
+		"migrations/0002_add_column.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"migrations/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"migrations/0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	migrations, err := loadMigrations(files, "migrations")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, "create_table", migrations[0].Name)
+	assert.Equal(t, "add_column", migrations[1].Name)
+	assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+}
+
+// TestLoadMigrationsNegative tests that malformed migration sets are rejected.
+func TestLoadMigrationsNegative(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"gap in versions": {
+			"migrations/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"migrations/0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+			"migrations/0003_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN c TEXT;")},
+			"migrations/0003_add_column.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		},
+		"invalid name": {
+			"migrations/create_table.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+	}
+
+	for name, files := range cases {
+		_, err := loadMigrations(files, "migrations")
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,9 @@
+-- The secrets table was previously created by gorm's AutoMigrate, so existing deployments already have it.
+-- IF NOT EXISTS lets those deployments adopt versioned migrations without changes.
+CREATE TABLE IF NOT EXISTS secrets (
+    id UUID PRIMARY KEY,
+    key TEXT CONSTRAINT uni_secrets_key UNIQUE,
+    encrypted_value TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
@@ -0,0 +1 @@
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS client_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transit_key_versions;
+DROP TABLE IF EXISTS transit_keys;
@@ -0,0 +1,18 @@
+CREATE TABLE transit_keys (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_transit_keys_name UNIQUE,
+    type TEXT NOT NULL,
+    latest_version INTEGER NOT NULL,
+    min_decryption_version INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE transit_key_versions (
+    id UUID PRIMARY KEY,
+    key_id UUID NOT NULL REFERENCES transit_keys (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    encrypted_material TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT uni_transit_key_versions_key_version UNIQUE (key_id, version)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS pki_certificates;
+DROP TABLE IF EXISTS pki_roles;
+DROP TABLE IF EXISTS pki_cas;
@@ -0,0 +1,37 @@
+CREATE TABLE pki_cas (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_pki_cas_name UNIQUE,
+    parent_id UUID REFERENCES pki_cas (id),
+    certificate TEXT NOT NULL,
+    encrypted_private_key TEXT NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE pki_roles (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_pki_roles_name UNIQUE,
+    issuer TEXT NOT NULL,
+    allowed_domains TEXT NOT NULL,
+    allow_bare_domains BOOLEAN NOT NULL,
+    allow_subdomains BOOLEAN NOT NULL,
+    allow_ip_sans BOOLEAN NOT NULL,
+    key_type TEXT NOT NULL,
+    ttl INTEGER NOT NULL,
+    max_ttl INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE pki_certificates (
+    serial_number TEXT PRIMARY KEY,
+    ca_id UUID NOT NULL REFERENCES pki_cas (id),
+    role TEXT NOT NULL,
+    common_name TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_pki_certificates_revoked ON pki_certificates (ca_id, revoked_at) WHERE revoked_at IS NOT NULL;
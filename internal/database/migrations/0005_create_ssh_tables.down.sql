@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ssh_roles;
+DROP TABLE IF EXISTS ssh_ca;
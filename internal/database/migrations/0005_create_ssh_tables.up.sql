@@ -0,0 +1,22 @@
+CREATE TABLE ssh_ca (
+    id INTEGER PRIMARY KEY CONSTRAINT chk_ssh_ca_single_row CHECK (id = 1),
+    key_type TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    encrypted_private_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE ssh_roles (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_ssh_roles_name UNIQUE,
+    cert_type TEXT NOT NULL,
+    allowed_principals TEXT NOT NULL,
+    default_principals TEXT NOT NULL,
+    allowed_extensions TEXT NOT NULL,
+    default_extensions TEXT NOT NULL,
+    critical_options TEXT NOT NULL,
+    ttl INTEGER NOT NULL,
+    max_ttl INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
@@ -0,0 +1,13 @@
+CREATE TABLE totp_keys (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_totp_keys_name UNIQUE,
+    issuer TEXT NOT NULL,
+    account_name TEXT NOT NULL,
+    encrypted_secret TEXT NOT NULL,
+    algorithm TEXT NOT NULL,
+    digits INTEGER NOT NULL,
+    period INTEGER NOT NULL,
+    skew INTEGER NOT NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ
+);
@@ -0,0 +1,11 @@
+CREATE TABLE secret_policies (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_secret_policies_name UNIQUE,
+    type TEXT NOT NULL,
+    length INTEGER NOT NULL,
+    classes TEXT NOT NULL,
+    exclude_ambiguous BOOLEAN NOT NULL DEFAULT FALSE,
+    separator TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
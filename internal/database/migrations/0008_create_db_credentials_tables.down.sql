@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS db_leases;
+DROP TABLE IF EXISTS db_roles;
+DROP TABLE IF EXISTS db_connections;
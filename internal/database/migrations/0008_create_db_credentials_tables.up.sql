@@ -0,0 +1,29 @@
+CREATE TABLE db_connections (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_db_connections_name UNIQUE,
+    encrypted_connection_url TEXT NOT NULL,
+    allowed_roles TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE db_roles (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL CONSTRAINT uni_db_roles_name UNIQUE,
+    connection TEXT NOT NULL,
+    creation_statements TEXT NOT NULL,
+    revocation_statements TEXT NOT NULL,
+    ttl INTEGER NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE db_leases (
+    id TEXT PRIMARY KEY,
+    role TEXT NOT NULL,
+    username TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_db_leases_expires_at ON db_leases (expires_at);
@@ -0,0 +1,18 @@
+ALTER TABLE db_roles DROP COLUMN IF EXISTS max_ttl;
+
+CREATE TABLE db_leases (
+    id TEXT PRIMARY KEY,
+    role TEXT NOT NULL,
+    username TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_db_leases_expires_at ON db_leases (expires_at);
+
+-- Move the leases of the database engine back to their own table; leases of other engines are lost
+INSERT INTO db_leases (id, role, username, expires_at, created_at)
+SELECT id, data::JSON->>'role', data::JSON->>'username', expires_at, issued_at
+FROM leases WHERE engine = 'database';
+
+DROP TABLE IF EXISTS leases;
@@ -0,0 +1,34 @@
+CREATE TABLE leases (
+    id TEXT PRIMARY KEY,
+    engine TEXT NOT NULL,
+    data TEXT NOT NULL,
+    ttl INTEGER NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    max_expires_at TIMESTAMPTZ NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    failed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_leases_next_attempt_at ON leases (next_attempt_at);
+
+-- Move the leases of the database engine to the shared table
+INSERT INTO leases (id, engine, data, ttl, issued_at, expires_at, max_expires_at, next_attempt_at)
+SELECT
+    db_leases.id,
+    'database',
+    json_build_object('role', db_leases.role, 'username', db_leases.username)::TEXT,
+    COALESCE(db_roles.ttl, 3600),
+    COALESCE(db_leases.created_at, NOW()),
+    db_leases.expires_at,
+    db_leases.expires_at,
+    db_leases.expires_at
+FROM db_leases LEFT JOIN db_roles ON db_roles.name = db_leases.role;
+
+DROP TABLE db_leases;
+
+ALTER TABLE db_roles ADD COLUMN max_ttl INTEGER;
+UPDATE db_roles SET max_ttl = GREATEST(ttl, 86400);
+ALTER TABLE db_roles ALTER COLUMN max_ttl SET NOT NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE secret_rotations (
+    id UUID PRIMARY KEY,
+    secret_id UUID NOT NULL CONSTRAINT uni_secret_rotations_secret_id UNIQUE REFERENCES secrets (id) ON DELETE CASCADE,
+    schedule TEXT NOT NULL,
+    rotator TEXT NOT NULL,
+    policy TEXT NOT NULL DEFAULT '',
+    options TEXT NOT NULL,
+    status TEXT NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_run_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_secret_rotations_next_run_at ON secret_rotations (next_run_at);
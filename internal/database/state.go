@@ -0,0 +1,34 @@
+package database
+
+import "sync/atomic"
+
+// ConnectionState describes the availability of the database as seen by Lockbox.
+type ConnectionState string
+
+const (
+	// StateStarting means Lockbox is still waiting for the database or applying migrations.
+	StateStarting ConnectionState = "starting"
+
+	// StateReady means the database is reachable and the schema is up to date.
+	StateReady ConnectionState = "ready"
+
+	// StateUnavailable means the database was ready but has stopped answering, and Lockbox is reconnecting.
+	StateUnavailable ConnectionState = "unavailable"
+)
+
+// state holds the current ConnectionState. It is read by request handlers and written by the connection monitor.
+var state atomic.Value
+
+func init() {
+	state.Store(StateStarting)
+}
+
+// State returns the current availability of the database.
+func State() ConnectionState {
+	return state.Load().(ConnectionState)
+}
+
+// setState records a new availability of the database.
+func setState(newState ConnectionState) {
+	state.Store(newState)
+}
@@ -0,0 +1,76 @@
+package dbcreds
+
+import (
+	"context"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Executor runs SQL statements in the databases that users are created in.
+type Executor interface {
+	// Verify checks that the database can be reached with the connection URL.
+	Verify(ctx context.Context, connectionURL string) error
+
+	// Execute runs the statements, in order, in a single transaction.
+	Execute(ctx context.Context, connectionURL string, statements []string) error
+}
+
+// postgresExecutor is the Executor for PostgreSQL databases. It opens a short-lived connection for every call,
+// so that the credentials of a connection are only held in memory while they are used.
+type postgresExecutor struct{}
+
+// Verify opens a connection and pings the database.
+func (postgresExecutor) Verify(ctx context.Context, connectionURL string) error {
+	db, err := openTarget(connectionURL)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	return sqlDB.PingContext(ctx)
+}
+
+// Execute opens a connection and runs the statements in a transaction, which is rolled back if any of them fails.
+func (postgresExecutor) Execute(ctx context.Context, connectionURL string, statements []string) error {
+	db, err := openTarget(connectionURL)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// openTarget opens a connection to a database users are created in, with a single connection in its pool.
+func openTarget(connectionURL string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(connectionURL), &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
+}
@@ -0,0 +1,54 @@
+package dbcreds
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+)
+
+// testConnectionURL builds the URL of the Postgres container of deploy/docker-compose-test.yaml.
+func testConnectionURL() string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		utils.GetEnvOrFallback("POSTGRES_USER", "testuser"),
+		utils.GetEnvOrFallback("POSTGRES_PASSWORD", "testpassword"),
+		utils.GetEnvOrFallback("DB_HOST", "localhost"),
+		utils.GetEnvOrFallback("DB_PORT", "5432"),
+		utils.GetEnvOrFallback("POSTGRES_DB", "testdb"),
+	)
+}
+
+// TestPostgresExecutor tests creating and dropping a user in a real PostgreSQL database.
+func TestPostgresExecutor(t *testing.T) {
+	executor := postgresExecutor{}
+	connectionURL := testConnectionURL()
+	if err := executor.Verify(context.Background(), connectionURL); err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+
+	statements := renderStatements(
+		[]string{`CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}';`},
+		"v-test-executor", "Password1", time.Now().Add(time.Hour),
+	)
+	assert.NoError(t, executor.Execute(context.Background(), connectionURL, statements))
+
+	// The user can log in
+	userURL := fmt.Sprintf("postgres://v-test-executor:Password1@%s:%s/%s?sslmode=disable",
+		utils.GetEnvOrFallback("DB_HOST", "localhost"),
+		utils.GetEnvOrFallback("DB_PORT", "5432"),
+		utils.GetEnvOrFallback("POSTGRES_DB", "testdb"),
+	)
+	assert.NoError(t, executor.Verify(context.Background(), userURL))
+
+	// A failed statement rolls back the transaction
+	err := executor.Execute(context.Background(), connectionURL, []string{`DROP ROLE "v-test-executor";`, `SELECT nonsense();`})
+	assert.Error(t, err)
+
+	statements = renderStatements(defaultRevocationStatements, "v-test-executor", "", time.Now().Add(time.Hour))
+	assert.NoError(t, executor.Execute(context.Background(), connectionURL, statements))
+	assert.Error(t, executor.Verify(context.Background(), userURL))
+}
@@ -0,0 +1,89 @@
+package dbcreds
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Connection is a PostgreSQL database in which Lockbox creates users on demand.
+type Connection struct {
+	// ID is the unique identifier of the connection.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name of the connection, referenced by roles.
+	Name string `gorm:"unique;not null"`
+
+	// EncryptedConnectionURL holds the connection URL (e.g., "postgres://admin:password@db:5432/app"), encrypted with the master key
+	// using secrets.Encrypt. Its user must be allowed to create and drop roles.
+	EncryptedConnectionURL string `gorm:"not null"`
+
+	// AllowedRoles lists the roles that may create users in this database. "*" allows any role.
+	AllowedRoles []string `gorm:"serializer:json;not null"`
+
+	// CreatedAt stores the timestamp of when the connection was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the connection was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Connection model.
+func (Connection) TableName() string {
+	return "db_connections"
+}
+
+// Role defines the SQL statements that create and drop the users handed out by GET /database/creds/{role}.
+// Statements are templates in which "{{name}}", "{{password}}" and "{{expiration}}" are replaced by the
+// generated user name, its password and the time past which its lease cannot be renewed.
+type Role struct {
+	// ID is the unique identifier of the role.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name of the role.
+	Name string `gorm:"unique;not null"`
+
+	// Connection is the name of the connection in which users are created.
+	Connection string `gorm:"not null"`
+
+	// CreationStatements create the user and grant its privileges.
+	CreationStatements []string `gorm:"serializer:json;not null"`
+
+	// RevocationStatements drop the user when its lease expires or is revoked.
+	RevocationStatements []string `gorm:"serializer:json;not null"`
+
+	// TTL is the lifetime of the leases, in seconds.
+	TTL int `gorm:"not null"`
+
+	// MaxTTL is the longest a lease can be renewed to, in seconds, counted from its creation.
+	MaxTTL int `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the role was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the role was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Role model.
+func (Role) TableName() string {
+	return "db_roles"
+}
+
+// Credentials are the user name and password of a database user created by Lockbox, and their lease.
+type Credentials struct {
+	// LeaseID identifies the lease, and is used to revoke the credentials early.
+	LeaseID string
+
+	// LeaseDuration is the lifetime of the credentials.
+	LeaseDuration time.Duration
+
+	// ExpiresAt is the time after which the user is dropped.
+	ExpiresAt time.Time
+
+	// Username is the name of the database user.
+	Username string
+
+	// Password is the password of the database user.
+	Password string
+}
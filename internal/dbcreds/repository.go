@@ -0,0 +1,89 @@
+package dbcreds
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface defines methods for database interactions related to dynamic database credentials.
+type Repository interface {
+	// Saves a connection, replacing the existing connection with the same name
+	SaveConnection(connection *Connection) error
+
+	// Retrieves a connection by its name
+	GetConnection(name string) (*Connection, error)
+
+	// Saves a role, replacing the existing role with the same name
+	SaveRole(role *Role) error
+
+	// Retrieves a role by its name
+	GetRole(name string) (*Role, error)
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the dynamic database credentials repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// SaveConnection inserts a connection, or updates the existing connection with the same name.
+//
+// Parameters:
+// - connection: The Connection model, including its encrypted URL.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) SaveConnection(connection *Connection) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"encrypted_connection_url", "allowed_roles", "updated_at"}),
+	}).Create(connection).Error
+}
+
+// GetConnection retrieves a connection by its name.
+//
+// Parameters:
+// - name: The name of the connection to retrieve.
+//
+// Returns:
+// - Connection: The retrieved Connection model.
+// - error: Returns an error if no connection with the given name is found or if the query fails.
+func (r *repository) GetConnection(name string) (*Connection, error) {
+	var connection *Connection
+	err := r.db.First(&connection, "name = ?", name).Error
+	return connection, err
+}
+
+// SaveRole inserts a role, or updates every setting of the existing role with the same name.
+//
+// Parameters:
+// - role: The Role model.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) SaveRole(role *Role) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"connection", "creation_statements", "revocation_statements", "ttl", "max_ttl", "updated_at",
+		}),
+	}).Create(role).Error
+}
+
+// GetRole retrieves a role by its name.
+//
+// Parameters:
+// - name: The name of the role to retrieve.
+//
+// Returns:
+// - Role: The retrieved Role model.
+// - error: Returns an error if no role with the given name is found or if the query fails.
+func (r *repository) GetRole(name string) (*Role, error) {
+	var role *Role
+	err := r.db.First(&role, "name = ?", name).Error
+	return role, err
+}
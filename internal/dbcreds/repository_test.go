@@ -0,0 +1,74 @@
+package dbcreds
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// Set up the database connection and return the repository instance, with a unique name
+// whose connection and role are deleted when the test ends
+func setupTestRepository(t *testing.T) (Repository, string) {
+	db := testdb.Open(t)
+	name := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		db.Delete(&Connection{}, "name = ?", name)
+		db.Delete(&Role{}, "name = ?", name)
+	})
+	return NewRepository(db), name
+}
+
+// TestRepoSaveConnection tests that saving a connection again replaces its URL and allowed roles but keeps its ID.
+func TestRepoSaveConnection(t *testing.T) {
+	repo, name := setupTestRepository(t)
+
+	connection := &Connection{ID: uuid.New(), Name: name, EncryptedConnectionURL: "encrypted-1", AllowedRoles: []string{"*"}}
+	require.NoError(t, repo.SaveConnection(connection))
+	assert.NoError(t, repo.SaveConnection(&Connection{
+		ID: uuid.New(), Name: name, EncryptedConnectionURL: "encrypted-2", AllowedRoles: []string{"readonly", "readwrite"},
+	}))
+
+	stored, err := repo.GetConnection(name)
+	assert.NoError(t, err)
+	assert.Equal(t, connection.ID, stored.ID)
+	assert.Equal(t, "encrypted-2", stored.EncryptedConnectionURL)
+	assert.Equal(t, []string{"readonly", "readwrite"}, stored.AllowedRoles)
+}
+
+// TestRepoSaveRole tests that saving a role again replaces its statements and TTLs but keeps its ID.
+func TestRepoSaveRole(t *testing.T) {
+	repo, name := setupTestRepository(t)
+
+	role := &Role{
+		ID: uuid.New(), Name: name, Connection: "app", CreationStatements: []string{`CREATE ROLE "{{name}}"`},
+		RevocationStatements: []string{`DROP ROLE "{{name}}"`}, TTL: 3600, MaxTTL: 86400,
+	}
+	require.NoError(t, repo.SaveRole(role))
+	assert.NoError(t, repo.SaveRole(&Role{
+		ID: uuid.New(), Name: name, Connection: "reporting",
+		CreationStatements:   []string{`CREATE ROLE "{{name}}" LOGIN PASSWORD '{{password}}'`, `GRANT SELECT ON ALL TABLES IN SCHEMA public TO "{{name}}"`},
+		RevocationStatements: []string{`DROP OWNED BY "{{name}}"`, `DROP ROLE "{{name}}"`}, TTL: 60, MaxTTL: 120,
+	}))
+
+	stored, err := repo.GetRole(name)
+	assert.NoError(t, err)
+	assert.Equal(t, role.ID, stored.ID)
+	assert.Equal(t, "reporting", stored.Connection)
+	assert.Len(t, stored.CreationStatements, 2)
+	assert.Equal(t, `GRANT SELECT ON ALL TABLES IN SCHEMA public TO "{{name}}"`, stored.CreationStatements[1])
+	assert.Equal(t, []string{`DROP OWNED BY "{{name}}"`, `DROP ROLE "{{name}}"`}, stored.RevocationStatements)
+	assert.Equal(t, 60, stored.TTL)
+	assert.Equal(t, 120, stored.MaxTTL)
+}
+
+// TestRepoNegativeGetRoleNotFound tests that a role that does not exist is reported as not found.
+func TestRepoNegativeGetRoleNotFound(t *testing.T) {
+	repo, name := setupTestRepository(t)
+
+	_, err := repo.GetRole(name)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,66 @@
+package dbcreds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
+)
+
+// rotatorName is the name under which the engine registers its rotator.
+const rotatorName = "postgres"
+
+// passwordRotator is the rotator of secrets that hold the password of an existing PostgreSQL user.
+// It runs ALTER ROLE through one of the engine's connections, whose user must be allowed to change the password.
+//
+// Options:
+// - connection: The name of the connection, as saved with PUT /database/config/{name}.
+// - username: The name of the user whose password is changed.
+type passwordRotator struct {
+	service *service
+}
+
+// Validate checks that the connection exists and that the user name fits in a PostgreSQL identifier.
+func (r passwordRotator) Validate(options map[string]string) error {
+	if err := rotation.CheckOptions(options, "connection", "username"); err != nil {
+		return err
+	}
+	if options["username"] == "" || len(options["username"]) > maxUsernameLength {
+		return fmt.Errorf("option 'username' must be between 1 and %d characters", maxUsernameLength)
+	}
+	if _, err := r.service.GetConnection(options["connection"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Rotate sets the new password of the user.
+func (r passwordRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	connectionURL, err := r.service.connectionURL(options["connection"], masterKey)
+	if err != nil {
+		return err
+	}
+
+	statement := fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s;", quoteIdentifier(options["username"]), quoteLiteral(value))
+	if err := r.service.executor.Execute(ctx, connectionURL, []string{statement}); err != nil {
+		return fmt.Errorf("failed to change the password of user '%s': %v", options["username"], err)
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a PostgreSQL identifier, doubling the double quotes it contains.
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a PostgreSQL string literal, doubling the single quotes it contains.
+// Like libpq's PQescapeLiteral, literals that contain backslashes use the E'...' syntax with escaped backslashes,
+// so they are read the same whatever the standard_conforming_strings setting of the database.
+func quoteLiteral(literal string) string {
+	quoted := "'" + strings.ReplaceAll(strings.ReplaceAll(literal, `\`, `\\`), "'", "''") + "'"
+	if strings.Contains(literal, `\`) {
+		return "E" + quoted
+	}
+	return quoted
+}
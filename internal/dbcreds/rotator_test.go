@@ -0,0 +1,35 @@
+package dbcreds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPasswordRotator tests that the rotator changes the password of the user through the connection, with quoted values.
+func TestPasswordRotator(t *testing.T) {
+	service, _, executor := newTestService(t)
+	rotator := passwordRotator{service}
+
+	options := map[string]string{"connection": "app", "username": "app_user"}
+	assert.NoError(t, rotator.Validate(options))
+	assert.ErrorIs(t, rotator.Validate(map[string]string{"connection": "missing", "username": "app_user"}), ErrConnectionNotFound)
+	assert.Error(t, rotator.Validate(map[string]string{"connection": "app"}))
+	assert.Error(t, rotator.Validate(map[string]string{"connection": "app", "username": "app_user", "role": "readonly"}))
+
+	executor.statements = nil
+	assert.NoError(t, rotator.Rotate(context.Background(), "db/password", "it's", options, "master"))
+	assert.Equal(t, []string{`ALTER ROLE "app_user" WITH PASSWORD 'it''s';`}, executor.statements)
+
+	executor.fail = true
+	assert.Error(t, rotator.Rotate(context.Background(), "db/password", "secret", options, "master"))
+}
+
+// TestQuote tests the quoting of identifiers and string literals.
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"app ""user"""`, quoteIdentifier(`app "user"`))
+	assert.Equal(t, `'plain'`, quoteLiteral(`plain`))
+	assert.Equal(t, `'it''s'`, quoteLiteral(`it's`))
+	assert.Equal(t, `E'back\\slash'`, quoteLiteral(`back\slash`))
+}
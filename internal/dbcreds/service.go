@@ -0,0 +1,387 @@
+package dbcreds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/rotation"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+const (
+	// statementTimeout bounds the time spent connecting to a database and running the statements of a role.
+	statementTimeout = 30 * time.Second
+
+	// defaultTTL is the lifetime of the leases of roles that do not set one, in seconds.
+	defaultTTL = 3600
+
+	// defaultMaxTTL is the longest the leases of roles that do not set a maximum can be renewed to, in seconds.
+	defaultMaxTTL = 86400
+
+	// maxUsernameLength is the longest identifier PostgreSQL accepts without truncating it.
+	maxUsernameLength = 63
+
+	// leaseEngine is the name under which the engine registers the revoker of its leases.
+	leaseEngine = "database"
+
+	// leaseIDPrefix starts the ID of every lease handed out by the engine.
+	leaseIDPrefix = "database/creds/"
+)
+
+// defaultRevocationStatements drop the user of a role that does not set its own revocation statements.
+// Roles whose users are granted privileges on objects must revoke them first, or PostgreSQL refuses to drop the user.
+var defaultRevocationStatements = []string{`DROP ROLE IF EXISTS "{{name}}";`}
+
+var (
+	// ErrConnectionNotFound is returned when no connection exists with the requested name.
+	ErrConnectionNotFound = errors.New("connection not found")
+
+	// ErrRoleNotFound is returned when no role exists with the requested name.
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrInvalidName is returned when a connection or role name contains characters that are not allowed.
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrInvalidConnection is returned when a connection URL is malformed or the database cannot be reached with it.
+	ErrInvalidConnection = errors.New("invalid connection")
+
+	// ErrInvalidRole is returned when the settings of a role are inconsistent.
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrRoleNotAllowed is returned when a role uses a connection that does not list it in its allowed roles.
+	ErrRoleNotAllowed = errors.New("role not allowed by connection")
+)
+
+// namePattern restricts connection and role names to characters that are safe in URLs, logs and SQL identifiers.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// usernamePolicy and passwordPolicy generate the random parts of the users created by the engine.
+// They only use letters and digits, so they can be inserted in SQL identifiers and string literals without escaping.
+var (
+	usernamePolicy = &secrets.Policy{
+		Type:    secrets.PolicyTypePassword,
+		Length:  16,
+		Classes: map[secrets.CharacterClass]int{secrets.ClassLowercase: 0, secrets.ClassDigits: 0},
+	}
+	passwordPolicy = &secrets.Policy{
+		Type:    secrets.PolicyTypePassword,
+		Length:  32,
+		Classes: map[secrets.CharacterClass]int{secrets.ClassLowercase: 1, secrets.ClassUppercase: 1, secrets.ClassDigits: 1},
+	}
+)
+
+// Service interface defines the business logic of the dynamic database credentials engine.
+// Connection URLs are encrypted with the master key at rest, and every user created by the engine is tracked by a lease.
+type Service interface {
+	// SaveConnection checks that the database can be reached, then stores the encrypted connection URL.
+	// Returns the Connection or an error if the URL is invalid or the database cannot be reached.
+	SaveConnection(name, connectionURL string, allowedRoles []string, masterKey string) (*Connection, error)
+
+	// GetConnection retrieves a connection by name.
+	// Returns the Connection or ErrConnectionNotFound.
+	GetConnection(name string) (*Connection, error)
+
+	// SaveRole validates and stores a role, replacing any existing role with the same name.
+	// Returns an error if the role is invalid or its connection does not allow it.
+	SaveRole(role *Role) error
+
+	// GetRole retrieves a role by name.
+	// Returns the Role or ErrRoleNotFound.
+	GetRole(name string) (*Role, error)
+
+	// GenerateCredentials creates a new database user with the statements of the role, and a lease to drop it.
+	// Returns the Credentials or an error if the user cannot be created.
+	GenerateCredentials(roleName, masterKey string) (*Credentials, error)
+
+	// Revoke drops the user of a lease and deletes the lease.
+	// Returns leases.ErrLeaseNotFound or an error if the user cannot be dropped.
+	Revoke(leaseID, masterKey string) error
+}
+
+type service struct {
+	repo     Repository
+	leases   leases.Service
+	executor Executor
+}
+
+// NewService creates a new dynamic database credentials service, which creates users in PostgreSQL databases.
+// The users are tracked by the lease service, which drops them with the revocation statements of their role.
+// It also registers the "postgres" rotator, which changes the passwords of existing users through the connections of the engine.
+func NewService(repo Repository, leaseService leases.Service) Service {
+	s := &service{repo: repo, leases: leaseService, executor: postgresExecutor{}}
+	leases.RegisterRevoker(leaseEngine, s.revokeLease)
+	rotation.RegisterRotator(rotatorName, passwordRotator{s})
+	return s
+}
+
+// SaveConnection verifies and stores a connection.
+func (s *service) SaveConnection(name, connectionURL string, allowedRoles []string, masterKey string) (*Connection, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidName, name)
+	}
+	parsedURL, err := url.Parse(connectionURL)
+	if err != nil || (parsedURL.Scheme != "postgres" && parsedURL.Scheme != "postgresql") || parsedURL.Host == "" {
+		return nil, fmt.Errorf("%w: expected a postgres:// URL", ErrInvalidConnection)
+	}
+
+	// Make sure the database can be reached before saving the connection
+	ctx, cancel := context.WithTimeout(context.Background(), statementTimeout)
+	defer cancel()
+	if err := s.executor.Verify(ctx, connectionURL); err != nil {
+		err = fmt.Errorf("%w: cannot reach %s: %v", ErrInvalidConnection, parsedURL.Redacted(), err)
+		global.Logger.Debug(err)
+		return nil, err
+	}
+
+	// Encrypt the URL, as it contains the password of a user allowed to create roles
+	encryptedURL, err := secrets.Encrypt(connectionURL, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to encrypt connection URL: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	if allowedRoles == nil {
+		allowedRoles = []string{}
+	}
+	connection := &Connection{
+		ID:                     uuid.New(),
+		Name:                   name,
+		EncryptedConnectionURL: encryptedURL,
+		AllowedRoles:           allowedRoles,
+	}
+	if err := s.repo.SaveConnection(connection); err != nil {
+		err = fmt.Errorf("failed to store connection '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Saved database connection '%s' to %s", name, parsedURL.Redacted())
+	return connection, nil
+}
+
+// GetConnection retrieves a connection by name.
+func (s *service) GetConnection(name string) (*Connection, error) {
+	connection, err := s.repo.GetConnection(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrConnectionNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve connection '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return connection, nil
+}
+
+// SaveRole validates a role and stores it.
+func (s *service) SaveRole(role *Role) error {
+	if !namePattern.MatchString(role.Name) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidName, role.Name)
+	}
+	if len(role.CreationStatements) == 0 {
+		return fmt.Errorf("%w: at least one creation statement is required", ErrInvalidRole)
+	}
+	if !strings.Contains(strings.Join(role.CreationStatements, "\n"), "{{name}}") {
+		return fmt.Errorf("%w: the creation statements must use {{name}}", ErrInvalidRole)
+	}
+	if len(role.RevocationStatements) == 0 {
+		role.RevocationStatements = defaultRevocationStatements
+	}
+	if role.TTL < 0 {
+		return fmt.Errorf("%w: TTL cannot be negative", ErrInvalidRole)
+	}
+	if role.TTL == 0 {
+		role.TTL = defaultTTL
+	}
+	if role.MaxTTL == 0 {
+		role.MaxTTL = max(role.TTL, defaultMaxTTL)
+	}
+	if role.MaxTTL < role.TTL {
+		return fmt.Errorf("%w: the maximum TTL cannot be shorter than the TTL", ErrInvalidRole)
+	}
+
+	// The connection must exist and allow the role
+	connection, err := s.GetConnection(role.Connection)
+	if err != nil {
+		return err
+	}
+	if !roleAllowed(connection.AllowedRoles, role.Name) {
+		return fmt.Errorf("%w: '%s' does not allow role '%s'", ErrRoleNotAllowed, connection.Name, role.Name)
+	}
+
+	role.ID = uuid.New()
+	if err := s.repo.SaveRole(role); err != nil {
+		err = fmt.Errorf("failed to store role '%s': %v", role.Name, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Saved database role '%s' on connection '%s'", role.Name, role.Connection)
+	return nil
+}
+
+// GetRole retrieves a role by name.
+func (s *service) GetRole(name string) (*Role, error) {
+	role, err := s.repo.GetRole(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrRoleNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve role '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// GenerateCredentials creates a database user for the role.
+// The lease is stored before the user is created, so that a user can never exist without a lease that eventually drops it.
+func (s *service) GenerateCredentials(roleName, masterKey string) (*Credentials, error) {
+	role, err := s.GetRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	connectionURL, err := s.connectionURL(role.Connection, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the user name and password
+	suffix, err := secrets.GenerateValue(usernamePolicy)
+	if err != nil {
+		return nil, err
+	}
+	password, err := secrets.GenerateValue(passwordPolicy)
+	if err != nil {
+		return nil, err
+	}
+	username := usernameFor(role.Name, suffix)
+	ttl := time.Duration(role.TTL) * time.Second
+
+	// Track the user before creating it
+	lease, err := s.leases.Create(
+		leaseEngine,
+		leaseIDPrefix+role.Name+"/"+uuid.New().String(),
+		ttl,
+		time.Duration(role.MaxTTL)*time.Second,
+		map[string]string{"role": role.Name, "username": username},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the user
+	ctx, cancel := context.WithTimeout(context.Background(), statementTimeout)
+	defer cancel()
+	statements := renderStatements(role.CreationStatements, username, password, lease.MaxExpiresAt)
+	if err := s.executor.Execute(ctx, connectionURL, statements); err != nil {
+		err = fmt.Errorf("failed to create user for role '%s': %v", role.Name, err)
+		global.Logger.Error(err)
+		s.leases.Delete(lease.ID)
+		return nil, err
+	}
+
+	global.Logger.Infof("Created database user '%s' for role '%s' with lease '%s', expiring at %s", username, role.Name, lease.ID, lease.ExpiresAt.Format(time.RFC3339))
+	return &Credentials{
+		LeaseID:       lease.ID,
+		LeaseDuration: ttl,
+		ExpiresAt:     lease.ExpiresAt,
+		Username:      username,
+		Password:      password,
+	}, nil
+}
+
+// Revoke revokes a lease of the engine through the lease service.
+func (s *service) Revoke(leaseID, masterKey string) error {
+	if !strings.HasPrefix(leaseID, leaseIDPrefix) {
+		return fmt.Errorf("%w: '%s'", leases.ErrLeaseNotFound, leaseID)
+	}
+
+	return s.leases.Revoke(leaseID, masterKey)
+}
+
+// revokeLease is the revoker of the engine's leases: it runs the revocation statements of the lease's role.
+func (s *service) revokeLease(lease *leases.Lease, masterKey string) error {
+	role, err := s.GetRole(lease.Data["role"])
+	if err != nil {
+		return err
+	}
+	connectionURL, err := s.connectionURL(role.Connection, masterKey)
+	if err != nil {
+		return err
+	}
+
+	// Drop the user
+	ctx, cancel := context.WithTimeout(context.Background(), statementTimeout)
+	defer cancel()
+	statements := renderStatements(role.RevocationStatements, lease.Data["username"], "", lease.ExpiresAt)
+	if err := s.executor.Execute(ctx, connectionURL, statements); err != nil {
+		return fmt.Errorf("failed to drop user '%s': %v", lease.Data["username"], err)
+	}
+
+	global.Logger.Infof("Dropped database user '%s' of lease '%s'", lease.Data["username"], lease.ID)
+	return nil
+}
+
+// connectionURL loads and decrypts the URL of a connection.
+func (s *service) connectionURL(connectionName, masterKey string) (string, error) {
+	connection, err := s.GetConnection(connectionName)
+	if err != nil {
+		return "", err
+	}
+	connectionURL, err := secrets.Decrypt(connection.EncryptedConnectionURL, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to decrypt the URL of connection '%s': %v", connection.Name, err)
+		global.Logger.Error(err)
+		return "", err
+	}
+
+	return connectionURL, nil
+}
+
+// roleAllowed reports whether a connection's allowed roles include the role.
+func roleAllowed(allowedRoles []string, roleName string) bool {
+	for _, allowed := range allowedRoles {
+		if allowed == "*" || allowed == roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// usernameFor builds the name of a new user from the role name and a random suffix, e.g. "v-readonly-k3j5...".
+// The role name is shortened so that the result fits in a PostgreSQL identifier.
+func usernameFor(roleName, suffix string) string {
+	maxRoleLength := maxUsernameLength - len("v--") - len(suffix)
+	if len(roleName) > maxRoleLength {
+		roleName = roleName[:maxRoleLength]
+	}
+	return "v-" + roleName + "-" + suffix
+}
+
+// renderStatements replaces the placeholders of the statements of a role.
+func renderStatements(statements []string, username, password string, expiresAt time.Time) []string {
+	replacer := strings.NewReplacer(
+		"{{name}}", username,
+		"{{password}}", password,
+		"{{expiration}}", expiresAt.UTC().Format(time.RFC3339),
+	)
+
+	rendered := make([]string, len(statements))
+	for i, statement := range statements {
+		rendered[i] = replacer.Replace(statement)
+	}
+	return rendered
+}
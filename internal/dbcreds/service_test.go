@@ -0,0 +1,249 @@
+package dbcreds
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/leases"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository of connections and roles, so that the service is tested without a database.
+// The upserts of the gorm repository are tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	connections map[string]*Connection
+	roles       map[string]*Role
+}
+
+func (r *memoryRepository) SaveConnection(connection *Connection) error {
+	r.connections[connection.Name] = connection
+	return nil
+}
+
+func (r *memoryRepository) GetConnection(name string) (*Connection, error) {
+	if connection, found := r.connections[name]; found {
+		return connection, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) SaveRole(role *Role) error {
+	r.roles[role.Name] = role
+	return nil
+}
+
+func (r *memoryRepository) GetRole(name string) (*Role, error) {
+	if role, found := r.roles[name]; found {
+		return role, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// memoryLeaseRepository is an in-memory leases.Repository, so the tests use the real lease service.
+type memoryLeaseRepository struct {
+	leases map[string]*leases.Lease
+}
+
+func (r *memoryLeaseRepository) Save(lease *leases.Lease) error {
+	r.leases[lease.ID] = lease
+	return nil
+}
+
+func (r *memoryLeaseRepository) GetByID(leaseID string) (*leases.Lease, error) {
+	if lease, found := r.leases[leaseID]; found {
+		return lease, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryLeaseRepository) ListByPrefix(prefix string) ([]*leases.Lease, error) {
+	var found []*leases.Lease
+	for id, lease := range r.leases {
+		if strings.HasPrefix(id, prefix) {
+			found = append(found, lease)
+		}
+	}
+	return found, nil
+}
+
+func (r *memoryLeaseRepository) Extend(leaseID string, expiresAt time.Time) error {
+	r.leases[leaseID].ExpiresAt = expiresAt
+	r.leases[leaseID].NextAttemptAt = expiresAt
+	return nil
+}
+
+func (r *memoryLeaseRepository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*leases.Lease, error) {
+	var due []*leases.Lease
+	for _, lease := range r.leases {
+		if !lease.NextAttemptAt.After(now) && len(due) < limit {
+			lease.NextAttemptAt = claimedUntil
+			due = append(due, lease)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryLeaseRepository) RecordFailure(leaseID string, attempts int, nextAttemptAt time.Time, lastError string, failed bool) error {
+	lease := r.leases[leaseID]
+	lease.Attempts, lease.NextAttemptAt, lease.LastError, lease.Failed = attempts, nextAttemptAt, lastError, failed
+	return nil
+}
+
+func (r *memoryLeaseRepository) Delete(leaseID string) error {
+	delete(r.leases, leaseID)
+	return nil
+}
+
+// recordingExecutor is an Executor that records the statements instead of running them.
+type recordingExecutor struct {
+	statements []string
+	fail       bool
+}
+
+func (e *recordingExecutor) Verify(ctx context.Context, connectionURL string) error {
+	return nil
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, connectionURL string, statements []string) error {
+	if e.fail {
+		return errors.New("connection refused")
+	}
+	e.statements = append(e.statements, statements...)
+	return nil
+}
+
+// newTestService creates a service backed by in-memory repositories and a recording executor,
+// with a connection "app" and a role "readonly" whose leases last an hour.
+func newTestService(t *testing.T) (*service, *memoryLeaseRepository, *recordingExecutor) {
+	global.Logger = logrus.New()
+	repo := &memoryRepository{connections: map[string]*Connection{}, roles: map[string]*Role{}}
+	leaseRepo := &memoryLeaseRepository{leases: map[string]*leases.Lease{}}
+	executor := &recordingExecutor{}
+	service := NewService(repo, leases.NewService(leaseRepo)).(*service)
+	service.executor = executor
+
+	_, err := service.SaveConnection("app", "postgres://admin:secret@db:5432/app", []string{"readonly"}, "master")
+	assert.NoError(t, err)
+	err = service.SaveRole(&Role{
+		Name:               "readonly",
+		Connection:         "app",
+		CreationStatements: []string{`CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}';`},
+		TTL:                3600,
+	})
+	assert.NoError(t, err)
+
+	return service, leaseRepo, executor
+}
+
+// TestServiceGenerateCredentials tests that credentials create a user with a unique name, tracked by a lease.
+// The user is valid until the maximum expiration of the lease, so that renewing the lease does not lock it out.
+func TestServiceGenerateCredentials(t *testing.T) {
+	service, leaseRepo, executor := newTestService(t)
+
+	credentials, err := service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(credentials.LeaseID, "database/creds/readonly/"))
+	assert.True(t, strings.HasPrefix(credentials.Username, "v-readonly-"))
+	assert.Len(t, credentials.Password, 32)
+	assert.Equal(t, time.Hour, credentials.LeaseDuration)
+
+	lease := leaseRepo.leases[credentials.LeaseID]
+	assert.NotNil(t, lease)
+	assert.Equal(t, "database", lease.Engine)
+	assert.Equal(t, map[string]string{"role": "readonly", "username": credentials.Username}, lease.Data)
+	assert.Equal(t, 24*time.Hour, lease.MaxExpiresAt.Sub(lease.IssuedAt))
+	assert.Equal(t, []string{
+		`CREATE ROLE "` + credentials.Username + `" WITH LOGIN PASSWORD '` + credentials.Password + `' VALID UNTIL '` + lease.MaxExpiresAt.Format(time.RFC3339) + `';`,
+	}, executor.statements)
+
+	other, err := service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+	assert.NotEqual(t, credentials.Username, other.Username)
+}
+
+// TestServiceGenerateCredentialsFailure tests that no lease is kept when the user cannot be created.
+func TestServiceGenerateCredentialsFailure(t *testing.T) {
+	service, leaseRepo, executor := newTestService(t)
+
+	executor.fail = true
+	_, err := service.GenerateCredentials("readonly", "master")
+	assert.Error(t, err)
+	assert.Empty(t, leaseRepo.leases)
+
+	_, err = service.GenerateCredentials("missing", "master")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+// TestServiceRevokeAndExpire tests that users are dropped on revoke, by prefix and at expiry, and that failed revocations are retried.
+func TestServiceRevokeAndExpire(t *testing.T) {
+	service, leaseRepo, executor := newTestService(t)
+
+	first, err := service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+	second, err := service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+
+	// Revoke the first lease early
+	executor.statements = nil
+	assert.NoError(t, service.Revoke(first.LeaseID, "master"))
+	assert.Equal(t, []string{`DROP ROLE IF EXISTS "` + first.Username + `";`}, executor.statements)
+	assert.ErrorIs(t, service.Revoke(first.LeaseID, "master"), leases.ErrLeaseNotFound)
+	assert.ErrorIs(t, service.Revoke("pki/other", "master"), leases.ErrLeaseNotFound)
+
+	// A failed revocation at expiry keeps the lease
+	leaseRepo.leases[second.LeaseID].NextAttemptAt = time.Now().Add(-time.Minute)
+	executor.fail = true
+	revoked, err := service.leases.ExpireDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, revoked)
+	assert.Equal(t, 1, leaseRepo.leases[second.LeaseID].Attempts)
+
+	// And it is retried
+	leaseRepo.leases[second.LeaseID].NextAttemptAt = time.Now().Add(-time.Minute)
+	executor.fail = false
+	revoked, err = service.leases.ExpireDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+	assert.Empty(t, leaseRepo.leases)
+
+	// Every user of the role is dropped by prefix
+	_, err = service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+	_, err = service.GenerateCredentials("readonly", "master")
+	assert.NoError(t, err)
+	revoked, err = service.leases.RevokePrefix("database/creds/readonly/", "master")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+	assert.Empty(t, leaseRepo.leases)
+}
+
+// TestServiceSaveRoleNegative tests that invalid roles, and roles not allowed by their connection, are rejected.
+func TestServiceSaveRoleNegative(t *testing.T) {
+	service, _, _ := newTestService(t)
+
+	err := service.SaveRole(&Role{Name: "admin", Connection: "app", CreationStatements: []string{`CREATE ROLE "{{name}}";`}})
+	assert.ErrorIs(t, err, ErrRoleNotAllowed)
+	err = service.SaveRole(&Role{Name: "readonly", Connection: "missing", CreationStatements: []string{`CREATE ROLE "{{name}}";`}})
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	err = service.SaveRole(&Role{Name: "readonly", Connection: "app", CreationStatements: []string{`CREATE ROLE static;`}})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	err = service.SaveRole(&Role{Name: "readonly", Connection: "app", CreationStatements: []string{`CREATE ROLE "{{name}}";`}, TTL: 7200, MaxTTL: 3600})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	err = service.SaveRole(&Role{Name: "bad name", Connection: "app", CreationStatements: []string{`CREATE ROLE "{{name}}";`}})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	_, err = service.SaveConnection("other", "mysql://root@db/app", nil, "master")
+	assert.ErrorIs(t, err, ErrInvalidConnection)
+}
+
+// TestUsernameFor tests that user names always fit in a PostgreSQL identifier.
+func TestUsernameFor(t *testing.T) {
+	assert.Equal(t, "v-readonly-abc", usernameFor("readonly", "abc"))
+	assert.Len(t, usernameFor(strings.Repeat("r", 128), strings.Repeat("s", 16)), maxUsernameLength)
+}
@@ -2,6 +2,7 @@ package global
 
 import (
 	"github.com/sirupsen/logrus"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
 	"gorm.io/gorm"
 )
 
@@ -17,4 +18,8 @@ var (
 	// It represents the connection to the database and is used throughout the application to perform database operations.
 	// The connection is initialized once during application startup and is reused by different components.
 	Database *gorm.DB
+
+	// Config is a globally accessible instance of the loaded application configuration.
+	// It allows request handlers to consult settings (such as feature toggles) without threading the configuration through every call.
+	Config *config.Config
 )
@@ -0,0 +1,126 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// MinLogDiskSpace is the free space (in bytes) below which the disk of the log file fails the readiness probe.
+const MinLogDiskSpace = 100 * 1024 * 1024
+
+// errUnsupported is returned by freeDiskSpace on platforms where free space cannot be measured.
+var errUnsupported = errors.New("not supported on this platform")
+
+// RegisterBuiltinChecks registers the checks of the server in the registry of the server:
+//   - database (readiness, startup): the database has started and answers a ping.
+//   - master_key (readiness, startup): the master key is set and can encrypt and decrypt a test value.
+//   - log_disk_space (readiness): the disk of the log file has at least MinLogDiskSpace bytes free.
+//     It is only registered if logs are written to a file (logFilePath is not empty).
+//
+// The liveness probe has no dependency checks: a failing dependency makes the server unready, not dead,
+// and restarting it would not help.
+func RegisterBuiltinChecks(db *gorm.DB, logFilePath string) {
+	Register(Check{
+		Name:     "database",
+		Probes:   []Probe{ProbeReadiness, ProbeStartup},
+		Timeout:  2 * time.Second,
+		CacheFor: 5 * time.Second,
+		Run:      DatabaseCheck(db),
+	})
+	Register(Check{
+		Name:     "master_key",
+		Probes:   []Probe{ProbeReadiness, ProbeStartup},
+		Timeout:  time.Second,
+		CacheFor: time.Minute,
+		Run:      MasterKeyCheck,
+	})
+	if logFilePath == "" {
+		return
+	}
+	Register(Check{
+		Name:     "log_disk_space",
+		Probes:   []Probe{ProbeReadiness},
+		Timeout:  time.Second,
+		CacheFor: 30 * time.Second,
+		Run:      DiskSpaceCheck(filepath.Dir(logFilePath), MinLogDiskSpace),
+	})
+}
+
+// DatabaseCheck returns a check that passes once the database has started (it is reachable and migrated)
+// and while it answers pings.
+func DatabaseCheck(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		switch database.State() {
+		case database.StateStarting:
+			return errors.New("waiting for the database")
+		case database.StateUnavailable:
+			return errors.New("database is unavailable, reconnecting")
+		}
+
+		if db == nil {
+			return errors.New("database is not configured")
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			global.Logger.WithContext(ctx).Debugf("Health check failed to get the database object: %v", err)
+			return errors.New("failed to get database object")
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			global.Logger.WithContext(ctx).Debugf("Health check failed to ping the database: %v", err)
+			return errors.New("database connection failed")
+		}
+		return nil
+	}
+}
+
+// MasterKeyCheck passes if the master key is set and encrypts and decrypts a random value back to itself.
+func MasterKeyCheck(ctx context.Context) error {
+	masterCryptoPass := os.Getenv("MASTER_CRYPTO_PASS")
+	if masterCryptoPass == "" {
+		return errors.New("MASTER_CRYPTO_PASS is not set")
+	}
+
+	// Round-trip a random value, so that no known plaintext is ever encrypted with the master key
+	value := make([]byte, 16)
+	if _, err := rand.Read(value); err != nil {
+		return fmt.Errorf("failed to generate a test value: %v", err)
+	}
+	encrypted, err := secrets.Encrypt(hex.EncodeToString(value), masterCryptoPass)
+	if err != nil {
+		return errors.New("failed to encrypt a test value")
+	}
+	decrypted, err := secrets.Decrypt(encrypted, masterCryptoPass)
+	if err != nil || decrypted != hex.EncodeToString(value) {
+		return errors.New("failed to decrypt a test value")
+	}
+	return nil
+}
+
+// DiskSpaceCheck returns a check that passes if the disk of the directory has at least minFree bytes available.
+// The check passes on platforms where free space cannot be measured.
+func DiskSpaceCheck(directory string, minFree uint64) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		free, err := freeDiskSpace(directory)
+		if errors.Is(err, errUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to measure free disk space: %v", err)
+		}
+		if free < minFree {
+			return fmt.Errorf("%d MiB free, at least %d MiB required", free/(1024*1024), minFree/(1024*1024))
+		}
+		return nil
+	}
+}
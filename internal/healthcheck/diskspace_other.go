@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package healthcheck
+
+// freeDiskSpace cannot measure free space on this platform.
+func freeDiskSpace(directory string) (uint64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package healthcheck
+
+import "syscall"
+
+// freeDiskSpace returns the number of bytes available to unprivileged users on the disk of the directory.
+func freeDiskSpace(directory string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(directory, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
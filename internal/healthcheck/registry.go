@@ -0,0 +1,211 @@
+// Package healthcheck runs the named checks behind the liveness, readiness and startup probes.
+// Checks are registered once on startup, each for the probes it applies to, with a timeout and a cache duration
+// so that frequent probes do not hammer the dependencies they check.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe identifies the kind of question an orchestrator asks.
+type Probe string
+
+const (
+	// ProbeLiveness asks whether the process is healthy, or should be restarted.
+	ProbeLiveness Probe = "live"
+
+	// ProbeReadiness asks whether the server can serve requests, or should be taken out of the load balancer.
+	ProbeReadiness Probe = "ready"
+
+	// ProbeStartup asks whether the server has finished starting, before liveness and readiness are probed.
+	ProbeStartup Probe = "startup"
+)
+
+// Status is the outcome of a check or a probe.
+type Status string
+
+const (
+	// StatusOK means the check passed, or that every check of the probe passed.
+	StatusOK Status = "ok"
+
+	// StatusFail means the check failed or timed out, or that some check of the probe did.
+	StatusFail Status = "fail"
+)
+
+// Check is a named health check.
+type Check struct {
+	// Name identifies the check in probe reports (e.g., "database").
+	Name string
+
+	// Probes lists the probes the check applies to.
+	Probes []Probe
+
+	// Timeout is how long the check may run before it is reported as failed.
+	Timeout time.Duration
+
+	// CacheFor is how long the result of the check is reused, whether it passed or failed.
+	CacheFor time.Duration
+
+	// Run performs the check, and returns an error describing why it failed.
+	// The error is shown in unauthenticated probe responses, so it must not include credentials or addresses.
+	Run func(ctx context.Context) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	// Status is "ok" or "fail".
+	Status Status
+
+	// Error describes why the check failed. It is empty when the check passed.
+	Error string
+
+	// Latency is how long the check took to run.
+	Latency time.Duration
+
+	// CheckedAt is when the check ran.
+	CheckedAt time.Time
+
+	// Cached indicates that the result was reused from an earlier run.
+	Cached bool
+}
+
+// Report is the outcome of a probe: the result of each of its checks.
+type Report struct {
+	// Status is "ok" if every check passed, "fail" otherwise.
+	Status Status
+
+	// Checks maps the name of each check of the probe to its result.
+	Checks map[string]Result
+}
+
+// registeredCheck is a check with its last result.
+// The mutex is held while the check runs, so that concurrent probes share a single run.
+type registeredCheck struct {
+	Check
+	mutex sync.Mutex
+	last  *Result
+}
+
+// Registry holds the checks of the probes.
+type Registry struct {
+	mutex  sync.RWMutex
+	checks map[string]*registeredCheck
+	now    func() time.Time
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]*registeredCheck{}, now: time.Now}
+}
+
+// Register adds a check to the registry, replacing any check with the same name.
+func (r *Registry) Register(check Check) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks[check.Name] = &registeredCheck{Check: check}
+}
+
+// Run runs the checks of a probe concurrently, reusing the results that are still cached.
+// A probe without checks passes.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	checks := r.checksOf(probe)
+
+	results := make([]Result, len(checks))
+	var wait sync.WaitGroup
+	for i, check := range checks {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			results[i] = r.result(ctx, check)
+		}()
+	}
+	wait.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// checksOf returns the checks that apply to a probe, sorted by name.
+func (r *Registry) checksOf(probe Probe) []*registeredCheck {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var checks []*registeredCheck
+	for _, check := range r.checks {
+		for _, checkProbe := range check.Probes {
+			if checkProbe == probe {
+				checks = append(checks, check)
+				break
+			}
+		}
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
+// result returns the cached result of a check, or runs it if the cached result has expired.
+func (r *Registry) result(ctx context.Context, check *registeredCheck) Result {
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+
+	if check.last != nil && r.now().Sub(check.last.CheckedAt) < check.CacheFor {
+		cached := *check.last
+		cached.Cached = true
+		return cached
+	}
+
+	result := r.run(ctx, check.Check)
+	check.last = &result
+	return result
+}
+
+// run runs a check within its timeout.
+// The check runs in its own goroutine, so that a check that ignores its context still fails on time.
+// It is not cancelled if the client of the probe goes away, since its result is cached for the other probes.
+func (r *Registry) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), check.Timeout)
+	defer cancel()
+
+	start := r.now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", check.Timeout)
+	}
+
+	result := Result{Status: StatusOK, Latency: r.now().Sub(start), CheckedAt: start}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// defaultRegistry is the registry of the server, used by the probe endpoints.
+var defaultRegistry = NewRegistry()
+
+// Register adds a check to the registry of the server, replacing any check with the same name.
+func Register(check Check) {
+	defaultRegistry.Register(check)
+}
+
+// Run runs the checks of a probe in the registry of the server.
+func Run(ctx context.Context, probe Probe) Report {
+	return defaultRegistry.Run(ctx, probe)
+}
@@ -0,0 +1,113 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// passing returns a check function that passes and counts its runs.
+func passing(runs *atomic.Int32) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+}
+
+func TestRunFiltersChecksByProbe(t *testing.T) {
+	registry := NewRegistry()
+	var runs atomic.Int32
+	registry.Register(Check{Name: "database", Probes: []Probe{ProbeReadiness, ProbeStartup}, Timeout: time.Second, Run: passing(&runs)})
+	registry.Register(Check{Name: "disk", Probes: []Probe{ProbeReadiness}, Timeout: time.Second, Run: passing(&runs)})
+
+	report := registry.Run(context.Background(), ProbeStartup)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Len(t, report.Checks, 1)
+	assert.Contains(t, report.Checks, "database")
+
+	report = registry.Run(context.Background(), ProbeLiveness)
+	assert.Equal(t, StatusOK, report.Status, "A probe without checks passes")
+	assert.Empty(t, report.Checks)
+}
+
+func TestRunFailsIfAnyCheckFails(t *testing.T) {
+	registry := NewRegistry()
+	var runs atomic.Int32
+	registry.Register(Check{Name: "database", Probes: []Probe{ProbeReadiness}, Timeout: time.Second, Run: passing(&runs)})
+	registry.Register(Check{Name: "master_key", Probes: []Probe{ProbeReadiness}, Timeout: time.Second, Run: func(ctx context.Context) error {
+		return errors.New("master key cannot decrypt")
+	}})
+
+	report := registry.Run(context.Background(), ProbeReadiness)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusFail, report.Checks["master_key"].Status)
+	assert.Equal(t, "master key cannot decrypt", report.Checks["master_key"].Error)
+}
+
+func TestRunTimesOutChecksThatIgnoreTheirContext(t *testing.T) {
+	registry := NewRegistry()
+	release := make(chan struct{})
+	defer close(release)
+	registry.Register(Check{Name: "slow", Probes: []Probe{ProbeReadiness}, Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	report := registry.Run(context.Background(), ProbeReadiness)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, "timed out after 20ms", report.Checks["slow"].Error)
+}
+
+func TestRunIgnoresCancellationOfTheProbe(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Check{Name: "database", Probes: []Probe{ProbeReadiness}, Timeout: time.Second, Run: func(ctx context.Context) error {
+		return ctx.Err()
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := registry.Run(ctx, ProbeReadiness)
+	assert.Equal(t, StatusOK, report.Status, "A cached result must not be a failure caused by a client going away")
+}
+
+func TestRunCachesResults(t *testing.T) {
+	registry := NewRegistry()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	var runs atomic.Int32
+	registry.Register(Check{Name: "database", Probes: []Probe{ProbeReadiness}, Timeout: time.Second, CacheFor: 5 * time.Second, Run: passing(&runs)})
+
+	report := registry.Run(context.Background(), ProbeReadiness)
+	assert.False(t, report.Checks["database"].Cached)
+
+	now = now.Add(4 * time.Second)
+	report = registry.Run(context.Background(), ProbeReadiness)
+	assert.True(t, report.Checks["database"].Cached)
+	assert.Equal(t, int32(1), runs.Load())
+
+	now = now.Add(time.Second)
+	report = registry.Run(context.Background(), ProbeReadiness)
+	assert.False(t, report.Checks["database"].Cached, "The check runs again once its cache has expired")
+	assert.Equal(t, int32(2), runs.Load())
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	directory := t.TempDir()
+
+	if _, err := freeDiskSpace(directory); errors.Is(err, errUnsupported) {
+		t.Skip("Free disk space cannot be measured on this platform")
+	}
+
+	err := DiskSpaceCheck(directory, 1)(context.Background())
+	assert.NoError(t, err)
+
+	err = DiskSpaceCheck(directory, 1<<62)(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package leases
+
+import "time"
+
+// Lease tracks something time-bound handed out by an engine, such as a database user, so that it can be revoked when it expires.
+type Lease struct {
+	// ID is the unique lease ID, prefixed with the path of the engine that created it (e.g., "database/creds/readonly/<uuid>").
+	ID string `gorm:"primaryKey"`
+
+	// Engine is the name of the engine that created the lease, whose revoker is called when the lease is revoked.
+	Engine string `gorm:"not null"`
+
+	// Data holds what the engine needs to revoke the lease (e.g., the name of a database user).
+	Data map[string]string `gorm:"serializer:json;not null"`
+
+	// TTL is the lifetime of the lease, in seconds, and the default increment of a renewal.
+	TTL int `gorm:"not null"`
+
+	// IssuedAt is the time the lease was created.
+	IssuedAt time.Time `gorm:"not null"`
+
+	// ExpiresAt is the time after which the lease is revoked.
+	ExpiresAt time.Time `gorm:"not null"`
+
+	// MaxExpiresAt is the time beyond which the lease cannot be renewed.
+	MaxExpiresAt time.Time `gorm:"not null"`
+
+	// NextAttemptAt is the time the scheduler revokes the lease. It is ExpiresAt until a revocation fails,
+	// then it is pushed back after every failure.
+	NextAttemptAt time.Time `gorm:"not null"`
+
+	// Attempts is the number of failed revocations.
+	Attempts int `gorm:"not null;default:0"`
+
+	// LastError is the error of the last failed revocation.
+	LastError string `gorm:"not null;default:''"`
+
+	// Failed flags a lease whose revocation has failed too many times and needs attention. It is still retried.
+	Failed bool `gorm:"not null;default:false"`
+}
+
+// TableName sets the table name of the Lease model.
+func (Lease) TableName() string {
+	return "leases"
+}
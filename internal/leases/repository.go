@@ -0,0 +1,154 @@
+package leases
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository interface defines methods for database interactions related to leases.
+type Repository interface {
+	// Saves a new lease
+	Save(lease *Lease) error
+
+	// Retrieves a lease by its ID
+	GetByID(leaseID string) (*Lease, error)
+
+	// Retrieves all leases whose ID starts with the given prefix
+	ListByPrefix(prefix string) ([]*Lease, error)
+
+	// Sets a new expiration on a lease
+	Extend(leaseID string, expiresAt time.Time) error
+
+	// Claims leases that are due for revocation, so that other replicas skip them until claimedUntil
+	ClaimDue(now, claimedUntil time.Time, limit int) ([]*Lease, error)
+
+	// Records a failed revocation and when to try again
+	RecordFailure(leaseID string, attempts int, nextAttemptAt time.Time, lastError string, failed bool) error
+
+	// Deletes a lease by its ID
+	Delete(leaseID string) error
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the leases repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// Save inserts a new lease.
+//
+// Parameters:
+// - lease: The Lease model.
+//
+// Returns:
+// - error: Returns an error if the insertion fails, otherwise nil.
+func (r *repository) Save(lease *Lease) error {
+	return r.db.Create(lease).Error
+}
+
+// GetByID retrieves a lease by its ID.
+//
+// Parameters:
+// - leaseID: The ID of the lease to retrieve.
+//
+// Returns:
+// - Lease: The retrieved Lease model.
+// - error: Returns an error if no lease with the given ID is found or if the query fails.
+func (r *repository) GetByID(leaseID string) (*Lease, error) {
+	var lease *Lease
+	err := r.db.First(&lease, "id = ?", leaseID).Error
+	return lease, err
+}
+
+// ListByPrefix retrieves all leases whose ID starts with the given prefix, ordered by ID.
+// The LIKE wildcards (% and _) in the prefix are escaped so they are matched literally.
+//
+// Parameters:
+// - prefix: The lease ID prefix to match (e.g., "database/creds/readonly/").
+//
+// Returns:
+// - []*Lease: The matching Lease models, which may be empty.
+// - error: Returns an error if the query fails.
+func (r *repository) ListByPrefix(prefix string) ([]*Lease, error) {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	var leases []*Lease
+	err := r.db.Where(`id LIKE ? ESCAPE '\'`, escaper.Replace(prefix)+"%").Order("id").Find(&leases).Error
+	return leases, err
+}
+
+// Extend sets a new expiration on a lease, which is also when the scheduler revokes it.
+//
+// Parameters:
+// - leaseID: The ID of the lease to extend.
+// - expiresAt: The new expiration.
+//
+// Returns:
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) Extend(leaseID string, expiresAt time.Time) error {
+	return r.db.Model(&Lease{}).Where("id = ?", leaseID).Updates(map[string]interface{}{
+		"expires_at":      expiresAt,
+		"next_attempt_at": expiresAt,
+	}).Error
+}
+
+// ClaimDue selects the leases that are due for revocation and pushes their next attempt back to claimedUntil, in a single statement.
+// Rows locked by another replica are skipped, so every due lease is claimed by a single replica. If that replica stops
+// before revoking the lease, the claim runs out and another replica picks the lease up.
+//
+// Parameters:
+// - now: The current time.
+// - claimedUntil: The time until which the claimed leases are skipped by other replicas.
+// - limit: The maximum number of leases to claim.
+//
+// Returns:
+// - []*Lease: The claimed Lease models, which may be empty.
+// - error: Returns an error if the query fails.
+func (r *repository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Lease, error) {
+	var leases []*Lease
+	err := r.db.Raw(`
+		UPDATE leases SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM leases WHERE next_attempt_at <= ?
+			ORDER BY next_attempt_at LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, claimedUntil, now, limit).Scan(&leases).Error
+	return leases, err
+}
+
+// RecordFailure records a failed revocation.
+//
+// Parameters:
+// - leaseID: The ID of the lease.
+// - attempts: The number of failed revocations so far.
+// - nextAttemptAt: When the scheduler tries again.
+// - lastError: The error of the failed revocation.
+// - failed: Whether the lease is flagged as failing.
+//
+// Returns:
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) RecordFailure(leaseID string, attempts int, nextAttemptAt time.Time, lastError string, failed bool) error {
+	return r.db.Model(&Lease{}).Where("id = ?", leaseID).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+		"failed":          failed,
+	}).Error
+}
+
+// Delete removes a lease by its ID.
+//
+// Parameters:
+// - leaseID: The ID of the lease to delete.
+//
+// Returns:
+// - error: Returns an error if the deletion fails, otherwise nil.
+func (r *repository) Delete(leaseID string) error {
+	return r.db.Delete(&Lease{}, "id = ?", leaseID).Error
+}
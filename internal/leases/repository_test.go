@@ -0,0 +1,142 @@
+package leases
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+)
+
+// Set up the database connection and return the repository instance, with a unique lease ID prefix
+// whose leases are deleted when the test ends
+func setupTestRepository(t *testing.T) (Repository, string) {
+	repo := NewRepository(testdb.Open(t))
+	prefix := "test/" + uuid.NewString() + "/"
+	t.Cleanup(func() {
+		leases, _ := repo.ListByPrefix(prefix)
+		for _, lease := range leases {
+			repo.Delete(lease.ID)
+		}
+	})
+	return repo, prefix
+}
+
+// newTestLease returns a lease that is due at the given time.
+func newTestLease(id string, due time.Time) *Lease {
+	return &Lease{
+		ID:            id,
+		Engine:        "test",
+		Data:          map[string]string{"username": "v-readonly-1"},
+		TTL:           3600,
+		IssuedAt:      due.Add(-time.Hour),
+		ExpiresAt:     due,
+		MaxExpiresAt:  due.Add(time.Hour),
+		NextAttemptAt: due,
+	}
+}
+
+// TestRepoSaveLease tests that a lease is stored with its data, and found by ID and by prefix.
+func TestRepoSaveLease(t *testing.T) {
+	repo, prefix := setupTestRepository(t)
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Save leases under the prefix, including one whose ID contains LIKE wildcards
+	assert.NoError(t, repo.Save(newTestLease(prefix+"a", due)))
+	assert.NoError(t, repo.Save(newTestLease(prefix+"b_%", due)))
+
+	// Assert the data is read back from its JSON column
+	lease, err := repo.GetByID(prefix + "a")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "v-readonly-1"}, lease.Data)
+	assert.True(t, due.Equal(lease.ExpiresAt))
+
+	// Assert wildcards in the prefix are matched literally
+	leases, err := repo.ListByPrefix(prefix + "b_")
+	assert.NoError(t, err)
+	assert.Len(t, leases, 1)
+	leases, err = repo.ListByPrefix(prefix)
+	assert.NoError(t, err)
+	assert.Len(t, leases, 2)
+}
+
+// TestRepoClaimDue tests that only due leases are claimed, that claiming pushes their next attempt back,
+// and that concurrent claims never return the same lease twice.
+func TestRepoClaimDue(t *testing.T) {
+	repo, prefix := setupTestRepository(t)
+
+	// Use dates far in the past, so that leases of other tests are not due
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	claimedUntil := now.Add(5 * time.Minute)
+	require.NoError(t, repo.Save(newTestLease(prefix+"later", now.Add(time.Second))))
+	const due = 20
+	for i := 0; i < due; i++ {
+		require.NoError(t, repo.Save(newTestLease(prefix+uuid.NewString(), now.Add(-time.Duration(i+1)*time.Minute))))
+	}
+
+	// Claim the due leases from several replicas at once
+	var mutex sync.Mutex
+	claimed := map[string]int{}
+	var wg sync.WaitGroup
+	for replica := 0; replica < 4; replica++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				leases, err := repo.ClaimDue(now, claimedUntil, 3)
+				if !assert.NoError(t, err) || len(leases) == 0 {
+					return
+				}
+				mutex.Lock()
+				for _, lease := range leases {
+					if strings.HasPrefix(lease.ID, prefix) {
+						claimed[lease.ID]++
+						assert.True(t, claimedUntil.Equal(lease.NextAttemptAt))
+						assert.Equal(t, "v-readonly-1", lease.Data["username"])
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert every due lease was claimed exactly once, and the lease that is not due was left alone
+	assert.Len(t, claimed, due)
+	for id, count := range claimed {
+		assert.Equal(t, 1, count, id)
+	}
+	assert.NotContains(t, claimed, prefix+"later")
+
+	// Assert claimed leases are skipped until their claim runs out
+	leases, err := repo.ClaimDue(now, claimedUntil, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+	lease, err := repo.GetByID(prefix + "later")
+	assert.NoError(t, err)
+	assert.True(t, now.Add(time.Second).Equal(lease.NextAttemptAt))
+}
+
+// TestRepoRecordFailure tests that a failed revocation is recorded, and that extending a lease reschedules it.
+func TestRepoRecordFailure(t *testing.T) {
+	repo, prefix := setupTestRepository(t)
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Save(newTestLease(prefix+"a", due)))
+
+	assert.NoError(t, repo.RecordFailure(prefix+"a", 3, due.Add(time.Minute), "connection refused", true))
+	lease, err := repo.GetByID(prefix + "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, lease.Attempts)
+	assert.Equal(t, "connection refused", lease.LastError)
+	assert.True(t, lease.Failed)
+
+	assert.NoError(t, repo.Extend(prefix+"a", due.Add(time.Hour)))
+	lease, err = repo.GetByID(prefix + "a")
+	assert.NoError(t, err)
+	assert.True(t, due.Add(time.Hour).Equal(lease.ExpiresAt))
+	assert.True(t, due.Add(time.Hour).Equal(lease.NextAttemptAt))
+}
@@ -0,0 +1,40 @@
+package leases
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// schedulerInterval is how often the scheduler looks for leases that are due.
+const schedulerInterval = 10 * time.Second
+
+// RunScheduler revokes expired leases until the context is cancelled.
+// It is meant to run as a background worker of the server, on every replica: leases are claimed in the database,
+// so each one is revoked by a single replica. Checks are skipped while the database is not ready.
+func RunScheduler(ctx context.Context, service Service) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if database.State() != database.StateReady {
+			continue
+		}
+		revoked, err := service.ExpireDue(os.Getenv("MASTER_CRYPTO_PASS"))
+		if err != nil {
+			global.Logger.Warnf("Lease scheduler: %v", err)
+		}
+		if revoked > 0 {
+			global.Logger.Infof("Lease scheduler: revoked %d expired leases", revoked)
+		}
+	}
+}
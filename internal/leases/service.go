@@ -0,0 +1,305 @@
+package leases
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// claimDuration is how long a lease claimed by the scheduler is skipped by other replicas.
+	// Leases are claimed one at a time, right before they are revoked, so it must be longer than a single revocation takes.
+	claimDuration = 5 * time.Minute
+
+	// passSize is the maximum number of leases revoked by a single scheduler pass.
+	passSize = 100
+
+	// initialRetryDelay is the base delay before retrying a failed revocation. It doubles after every failure, with jitter.
+	initialRetryDelay = 10 * time.Second
+
+	// maxRetryDelay is the longest delay between two attempts to revoke a lease.
+	maxRetryDelay = time.Hour
+
+	// failedAttempts is the number of failed revocations after which a lease is flagged as failing.
+	failedAttempts = 8
+)
+
+var (
+	// ErrLeaseNotFound is returned when no lease exists with the requested ID.
+	ErrLeaseNotFound = errors.New("lease not found")
+
+	// ErrLeaseExpired is returned when renewing a lease that has already expired.
+	ErrLeaseExpired = errors.New("lease expired")
+
+	// ErrInvalidLease is returned when the settings of a new lease, or of a renewal, are invalid.
+	ErrInvalidLease = errors.New("invalid lease")
+
+	// ErrNoRevoker is returned when revoking a lease of an engine that did not register a revoker.
+	ErrNoRevoker = errors.New("no revoker registered for engine")
+)
+
+// Revoker revokes what a lease stands for, such as dropping a database user.
+// It may be called more than once for the same lease, e.g. when a replica stops in the middle of a revocation,
+// so it must succeed if the lease was already revoked.
+type Revoker func(lease *Lease, masterKey string) error
+
+// revokers maps the name of each engine to its revoker.
+var (
+	revokers      = map[string]Revoker{}
+	revokersMutex sync.RWMutex
+)
+
+// RegisterRevoker registers the function that revokes the leases of an engine.
+// Engines register their revoker when they are created, before the scheduler starts.
+func RegisterRevoker(engine string, revoker Revoker) {
+	revokersMutex.Lock()
+	defer revokersMutex.Unlock()
+	revokers[engine] = revoker
+}
+
+// revokerFor returns the revoker of an engine.
+func revokerFor(engine string) (Revoker, error) {
+	revokersMutex.RLock()
+	defer revokersMutex.RUnlock()
+	revoker, found := revokers[engine]
+	if !found {
+		return nil, fmt.Errorf("%w '%s'", ErrNoRevoker, engine)
+	}
+	return revoker, nil
+}
+
+// Service interface defines the lifecycle of leases: creation by the engines, renewal, revocation and expiration.
+type Service interface {
+	// Create stores a new lease for an engine.
+	// Returns the Lease or an error if the TTLs are invalid or the lease cannot be stored.
+	Create(engine, leaseID string, ttl, maxTTL time.Duration, data map[string]string) (*Lease, error)
+
+	// Get retrieves a lease by ID.
+	// Returns the Lease or ErrLeaseNotFound.
+	Get(leaseID string) (*Lease, error)
+
+	// Renew extends a lease by the increment, or by its TTL when the increment is zero, without going past its maximum expiration.
+	// Returns the renewed Lease, ErrLeaseNotFound or ErrLeaseExpired.
+	Renew(leaseID string, increment time.Duration) (*Lease, error)
+
+	// Revoke calls the revoker of the lease's engine, then deletes the lease.
+	// If the revoker fails, the lease is kept and the scheduler retries it.
+	Revoke(leaseID, masterKey string) error
+
+	// RevokePrefix revokes every lease whose ID starts with the prefix.
+	// Returns the number of revoked leases, and an error if any of them could not be revoked.
+	RevokePrefix(prefix, masterKey string) (int, error)
+
+	// Delete forgets a lease without revoking it, e.g. when what it stands for could not be created.
+	Delete(leaseID string) error
+
+	// ExpireDue claims and revokes the leases that are due, one at a time, retrying failed revocations with backoff.
+	// Returns the number of revoked leases.
+	ExpireDue(masterKey string) (int, error)
+}
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+// NewService creates a new lease service.
+func NewService(repo Repository) Service {
+	return &service{repo: repo, now: time.Now}
+}
+
+// Create stores a new lease, which expires after the TTL and cannot be renewed beyond the maximum TTL.
+func (s *service) Create(engine, leaseID string, ttl, maxTTL time.Duration, data map[string]string) (*Lease, error) {
+	if ttl <= 0 || maxTTL < ttl {
+		return nil, fmt.Errorf("%w: TTL must be positive and not exceed the maximum TTL", ErrInvalidLease)
+	}
+	if data == nil {
+		data = map[string]string{}
+	}
+
+	now := s.now().UTC()
+	lease := &Lease{
+		ID:            leaseID,
+		Engine:        engine,
+		Data:          data,
+		TTL:           int(ttl.Seconds()),
+		IssuedAt:      now,
+		ExpiresAt:     now.Add(ttl),
+		MaxExpiresAt:  now.Add(maxTTL),
+		NextAttemptAt: now.Add(ttl),
+	}
+	if err := s.repo.Save(lease); err != nil {
+		err = fmt.Errorf("failed to store lease '%s': %v", leaseID, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// Get retrieves a lease by ID.
+func (s *service) Get(leaseID string) (*Lease, error) {
+	lease, err := s.repo.GetByID(leaseID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrLeaseNotFound, leaseID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve lease '%s': %v", leaseID, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// Renew extends a lease that has not expired yet.
+func (s *service) Renew(leaseID string, increment time.Duration) (*Lease, error) {
+	if increment < 0 {
+		return nil, fmt.Errorf("%w: the increment cannot be negative", ErrInvalidLease)
+	}
+	lease, err := s.Get(leaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	if !lease.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: '%s'", ErrLeaseExpired, leaseID)
+	}
+	if increment == 0 {
+		increment = time.Duration(lease.TTL) * time.Second
+	}
+	expiresAt := now.Add(increment)
+	if expiresAt.After(lease.MaxExpiresAt) {
+		expiresAt = lease.MaxExpiresAt
+	}
+
+	if err := s.repo.Extend(lease.ID, expiresAt); err != nil {
+		err = fmt.Errorf("failed to renew lease '%s': %v", leaseID, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Renewed lease '%s' until %s", lease.ID, expiresAt.Format(time.RFC3339))
+	lease.ExpiresAt = expiresAt
+	lease.NextAttemptAt = expiresAt
+	return lease, nil
+}
+
+// Revoke revokes a lease right away.
+func (s *service) Revoke(leaseID, masterKey string) error {
+	lease, err := s.Get(leaseID)
+	if err != nil {
+		return err
+	}
+
+	return s.revoke(lease, masterKey)
+}
+
+// RevokePrefix revokes every lease under a prefix, in ID order. A lease that cannot be revoked does not stop the others.
+func (s *service) RevokePrefix(prefix, masterKey string) (int, error) {
+	leases, err := s.repo.ListByPrefix(prefix)
+	if err != nil {
+		err = fmt.Errorf("failed to list leases with prefix '%s': %v", prefix, err)
+		global.Logger.Error(err)
+		return 0, err
+	}
+
+	revoked := 0
+	failed := 0
+	for _, lease := range leases {
+		if err := s.revoke(lease, masterKey); err != nil {
+			failed++
+			continue
+		}
+		revoked++
+	}
+	if failed > 0 {
+		return revoked, fmt.Errorf("failed to revoke %d of %d leases with prefix '%s'", failed, len(leases), prefix)
+	}
+
+	global.Logger.Infof("Revoked %d leases with prefix '%s'", revoked, prefix)
+	return revoked, nil
+}
+
+// Delete forgets a lease without revoking it.
+func (s *service) Delete(leaseID string) error {
+	if err := s.repo.Delete(leaseID); err != nil {
+		err = fmt.Errorf("failed to delete lease '%s': %v", leaseID, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// ExpireDue revokes the leases that were due when the pass started. Each lease is claimed right before it is revoked,
+// so that its claim cannot run out while earlier revocations of the pass are still running; claimed leases are skipped
+// by the other replicas.
+func (s *service) ExpireDue(masterKey string) (int, error) {
+	due := s.now().UTC()
+	revoked := 0
+	for i := 0; i < passSize; i++ {
+		leases, err := s.repo.ClaimDue(due, s.now().UTC().Add(claimDuration), 1)
+		if err != nil {
+			err = fmt.Errorf("failed to claim due leases: %v", err)
+			global.Logger.Error(err)
+			return revoked, err
+		}
+		if len(leases) == 0 {
+			break
+		}
+
+		if err := s.revoke(leases[0], masterKey); err == nil {
+			revoked++
+		}
+	}
+
+	return revoked, nil
+}
+
+// revoke calls the revoker of the lease's engine and deletes the lease.
+// When the revoker fails, the failure is recorded and the next attempt is scheduled with exponential backoff.
+func (s *service) revoke(lease *Lease, masterKey string) error {
+	revoker, err := revokerFor(lease.Engine)
+	if err == nil {
+		err = revoker(lease, masterKey)
+	}
+	if err != nil {
+		s.recordFailure(lease, err)
+		return fmt.Errorf("failed to revoke lease '%s': %w", lease.ID, err)
+	}
+
+	if err := s.repo.Delete(lease.ID); err != nil {
+		err = fmt.Errorf("failed to delete revoked lease '%s': %v", lease.ID, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Revoked lease '%s'", lease.ID)
+	return nil
+}
+
+// recordFailure schedules the next attempt to revoke a lease, and flags the lease once it has failed too many times.
+func (s *service) recordFailure(lease *Lease, revokeErr error) {
+	attempts := lease.Attempts + 1
+	failed := attempts >= failedAttempts
+	nextAttemptAt := s.now().UTC().Add(utils.BackoffDelay(initialRetryDelay, maxRetryDelay, attempts-1))
+
+	if failed {
+		global.Logger.Errorf("Lease '%s' failed to be revoked %d times and needs attention, next attempt at %s: %v",
+			lease.ID, attempts, nextAttemptAt.Format(time.RFC3339), revokeErr)
+	} else {
+		global.Logger.Warnf("Failed to revoke lease '%s' (attempt %d), next attempt at %s: %v",
+			lease.ID, attempts, nextAttemptAt.Format(time.RFC3339), revokeErr)
+	}
+
+	if err := s.repo.RecordFailure(lease.ID, attempts, nextAttemptAt, revokeErr.Error(), failed); err != nil {
+		global.Logger.Errorf("Failed to record the failed revocation of lease '%s': %v", lease.ID, err)
+	}
+}
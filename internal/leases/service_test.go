@@ -0,0 +1,256 @@
+package leases
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository whose ClaimDue claims due leases in no particular order, so that the revocation loop
+// is tested without a database. Claiming with FOR UPDATE SKIP LOCKED is tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	leases map[string]*Lease
+}
+
+func (r *memoryRepository) Save(lease *Lease) error {
+	r.leases[lease.ID] = lease
+	return nil
+}
+
+func (r *memoryRepository) GetByID(leaseID string) (*Lease, error) {
+	if lease, found := r.leases[leaseID]; found {
+		return lease, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) ListByPrefix(prefix string) ([]*Lease, error) {
+	var leases []*Lease
+	for id, lease := range r.leases {
+		if strings.HasPrefix(id, prefix) {
+			leases = append(leases, lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].ID < leases[j].ID })
+	return leases, nil
+}
+
+func (r *memoryRepository) Extend(leaseID string, expiresAt time.Time) error {
+	r.leases[leaseID].ExpiresAt = expiresAt
+	r.leases[leaseID].NextAttemptAt = expiresAt
+	return nil
+}
+
+func (r *memoryRepository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Lease, error) {
+	var leases []*Lease
+	for _, lease := range r.leases {
+		if !lease.NextAttemptAt.After(now) && len(leases) < limit {
+			lease.NextAttemptAt = claimedUntil
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+func (r *memoryRepository) RecordFailure(leaseID string, attempts int, nextAttemptAt time.Time, lastError string, failed bool) error {
+	lease := r.leases[leaseID]
+	lease.Attempts = attempts
+	lease.NextAttemptAt = nextAttemptAt
+	lease.LastError = lastError
+	lease.Failed = failed
+	return nil
+}
+
+func (r *memoryRepository) Delete(leaseID string) error {
+	delete(r.leases, leaseID)
+	return nil
+}
+
+// newTestService creates a service backed by an in-memory repository, whose clock is read from now.
+// It registers a revoker for the "test" engine that records the revoked leases, and fails while *fail is true.
+func newTestService(t *testing.T, now *time.Time, fail *bool) (*service, *memoryRepository, *[]string) {
+	global.Logger = logrus.New()
+	repo := &memoryRepository{leases: map[string]*Lease{}}
+	revoked := &[]string{}
+	RegisterRevoker("test", func(lease *Lease, masterKey string) error {
+		if *fail {
+			return errors.New("connection refused")
+		}
+		*revoked = append(*revoked, lease.ID)
+		return nil
+	})
+	t.Cleanup(func() {
+		revokersMutex.Lock()
+		defer revokersMutex.Unlock()
+		delete(revokers, "test")
+	})
+
+	return &service{repo: repo, now: func() time.Time { return *now }}, repo, revoked
+}
+
+// TestServiceCreateAndRenew tests that renewals extend a lease by its TTL or the increment, up to its maximum expiration.
+func TestServiceCreateAndRenew(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fail := false
+	service, _, _ := newTestService(t, &now, &fail)
+
+	lease, err := service.Create("test", "test/one", time.Hour, 3*time.Hour, map[string]string{"user": "alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), lease.ExpiresAt)
+	assert.Equal(t, now.Add(3*time.Hour), lease.MaxExpiresAt)
+
+	// Without an increment, the lease is extended by its TTL from now
+	now = now.Add(30 * time.Minute)
+	lease, err = service.Renew("test/one", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), lease.ExpiresAt)
+
+	// Renewals are capped at the maximum expiration
+	lease, err = service.Renew("test/one", 10*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC), lease.ExpiresAt)
+	assert.Equal(t, lease.ExpiresAt, lease.NextAttemptAt)
+
+	// Expired leases cannot be renewed
+	now = now.Add(3 * time.Hour)
+	_, err = service.Renew("test/one", 0)
+	assert.ErrorIs(t, err, ErrLeaseExpired)
+
+	_, err = service.Renew("test/missing", 0)
+	assert.ErrorIs(t, err, ErrLeaseNotFound)
+	_, err = service.Renew("test/one", -time.Second)
+	assert.ErrorIs(t, err, ErrInvalidLease)
+	_, err = service.Create("test", "test/two", 2*time.Hour, time.Hour, nil)
+	assert.ErrorIs(t, err, ErrInvalidLease)
+}
+
+// TestServiceRevoke tests revocation by ID and by prefix.
+func TestServiceRevoke(t *testing.T) {
+	now := time.Now()
+	fail := false
+	service, repo, revoked := newTestService(t, &now, &fail)
+
+	for _, id := range []string{"test/a/1", "test/a/2", "test/b/1"} {
+		_, err := service.Create("test", id, time.Hour, time.Hour, nil)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, service.Revoke("test/b/1", "master"))
+	assert.ErrorIs(t, service.Revoke("test/b/1", "master"), ErrLeaseNotFound)
+
+	count, err := service.RevokePrefix("test/a/", "master")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"test/b/1", "test/a/1", "test/a/2"}, *revoked)
+	assert.Empty(t, repo.leases)
+
+	// Leases of engines without a revoker are kept
+	_, err = service.Create("unknown", "unknown/1", time.Hour, time.Hour, nil)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.Revoke("unknown/1", "master"), ErrNoRevoker)
+	assert.Contains(t, repo.leases, "unknown/1")
+}
+
+// TestServiceExpireDue tests that expired leases are revoked, and that failed revocations are retried with backoff
+// until the lease is flagged as failing.
+func TestServiceExpireDue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fail := false
+	service, repo, revoked := newTestService(t, &now, &fail)
+
+	_, err := service.Create("test", "test/one", time.Hour, time.Hour, nil)
+	assert.NoError(t, err)
+
+	// Nothing has expired yet
+	count, err := service.ExpireDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Failed revocations are retried later
+	now = now.Add(time.Hour)
+	fail = true
+	for attempt := 1; attempt <= failedAttempts; attempt++ {
+		count, err = service.ExpireDue("master")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		lease := repo.leases["test/one"]
+		assert.Equal(t, attempt, lease.Attempts)
+		base := initialRetryDelay << (attempt - 1)
+		if base > maxRetryDelay {
+			base = maxRetryDelay
+		}
+		assert.WithinRange(t, lease.NextAttemptAt, now.Add(base/2), now.Add(base))
+		assert.Equal(t, "connection refused", lease.LastError)
+		assert.Equal(t, attempt == failedAttempts, lease.Failed)
+
+		// The lease is not claimed again before the next attempt
+		count, err = service.ExpireDue("master")
+		assert.NoError(t, err)
+		assert.Equal(t, attempt, repo.leases["test/one"].Attempts)
+		now = lease.NextAttemptAt
+	}
+
+	fail = false
+	count, err = service.ExpireDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"test/one"}, *revoked)
+	assert.Empty(t, repo.leases)
+}
+
+// claimRecordingRepository records until when each claimed lease is skipped by other replicas.
+type claimRecordingRepository struct {
+	*memoryRepository
+	claims []time.Time
+}
+
+func (r *claimRecordingRepository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Lease, error) {
+	leases, err := r.memoryRepository.ClaimDue(now, claimedUntil, limit)
+	for range leases {
+		r.claims = append(r.claims, claimedUntil)
+	}
+	return leases, err
+}
+
+// TestServiceExpireDueClaimsCoverEachRevocation tests that no lease is revoked after its claim has run out,
+// even when the revocations of a pass take longer together than a claim lasts.
+func TestServiceExpireDueClaimsCoverEachRevocation(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fail := false
+	service, repo, _ := newTestService(t, &now, &fail)
+	recorder := &claimRecordingRepository{memoryRepository: repo}
+	service.repo = recorder
+	var ends []time.Time
+	RegisterRevoker("slow", func(lease *Lease, masterKey string) error {
+		now = now.Add(4 * time.Minute)
+		ends = append(ends, now)
+		return nil
+	})
+	t.Cleanup(func() {
+		revokersMutex.Lock()
+		defer revokersMutex.Unlock()
+		delete(revokers, "slow")
+	})
+
+	for _, leaseID := range []string{"slow/one", "slow/two", "slow/three"} {
+		_, err := service.Create("slow", leaseID, time.Hour, time.Hour, nil)
+		assert.NoError(t, err)
+	}
+
+	now = now.Add(time.Hour)
+	count, err := service.ExpireDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, recorder.claims, 3)
+	for i, end := range ends {
+		assert.True(t, recorder.claims[i].After(end), "revocation %d ended at %s, after its claim ran out at %s", i, end, recorder.claims[i])
+	}
+}
@@ -0,0 +1,28 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
+)
+
+// ContextHook is a custom Logrus hook that tags log entries with the ID of the request they were logged for.
+// Entries are tied to a request by logging through global.Logger.WithContext(ctx).
+type ContextHook struct{}
+
+// Levels defines the log levels to which the hook is applied.
+// This hook will be applied to all log levels, from debug to fatal.
+func (hook *ContextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the request ID carried by the context of the entry as the "request_id" field.
+// Entries logged without a context, or outside of a request, are left untouched.
+func (hook *ContextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := requestid.FromContext(entry.Context); id != "" {
+		entry.Data["request_id"] = id
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// queryStartKey is the key of the start time of a query in the gorm statement, between the callbacks around it.
+const queryStartKey = "log:query_start"
+
+// GormPlugin is a gorm plugin that logs every database query at debug level, with the context of the query,
+// so that the queries run for an HTTP request (i.e., through db.WithContext(ctx)) are tagged with its request ID
+// by the context hook.
+//
+// Entries record the operation (e.g., "SELECT"), the table, the number of affected rows and the duration of the query.
+// Its SQL and arguments are left out, since they may contain secret keys and, for some statements, secret values.
+type GormPlugin struct {
+	Logger *logrus.Logger // The logger the queries are logged to
+}
+
+// Name returns the name the plugin is registered under.
+func (GormPlugin) Name() string {
+	return "lockbox:log"
+}
+
+// Initialize registers the callbacks that time and log each kind of query.
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("log:before_create", p.startQuery),
+		callbacks.Create().After("gorm:create").Register("log:after_create", p.logQuery("INSERT")),
+		callbacks.Query().Before("gorm:query").Register("log:before_query", p.startQuery),
+		callbacks.Query().After("gorm:query").Register("log:after_query", p.logQuery("SELECT")),
+		callbacks.Update().Before("gorm:update").Register("log:before_update", p.startQuery),
+		callbacks.Update().After("gorm:update").Register("log:after_update", p.logQuery("UPDATE")),
+		callbacks.Delete().Before("gorm:delete").Register("log:before_delete", p.startQuery),
+		callbacks.Delete().After("gorm:delete").Register("log:after_delete", p.logQuery("DELETE")),
+		callbacks.Row().Before("gorm:row").Register("log:before_row", p.startQuery),
+		callbacks.Row().After("gorm:row").Register("log:after_row", p.logQuery("SELECT")),
+		callbacks.Raw().Before("gorm:raw").Register("log:before_raw", p.startQuery),
+		callbacks.Raw().After("gorm:raw").Register("log:after_raw", p.logQuery("")),
+	)
+}
+
+// startQuery records the start time of a query, unless debug entries are not logged.
+func (p GormPlugin) startQuery(db *gorm.DB) {
+	if p.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		db.InstanceSet(queryStartKey, time.Now())
+	}
+}
+
+// logQuery returns a callback that logs a query once it has run.
+// Raw queries have no known operation, so the first keyword of their SQL is used instead (e.g., "ALTER").
+func (p GormPlugin) logQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, found := db.InstanceGet(queryStartKey)
+		if !found {
+			return
+		}
+
+		queryOperation := operation
+		if queryOperation == "" {
+			queryOperation = "RAW"
+			if keyword, _, _ := strings.Cut(strings.TrimSpace(db.Statement.SQL.String()), " "); keyword != "" {
+				queryOperation = strings.ToUpper(keyword)
+			}
+		}
+		fields := logrus.Fields{
+			"table":    db.Statement.Table,
+			"rows":     db.Statement.RowsAffected,
+			"duration": time.Since(value.(time.Time)).Round(time.Microsecond).String(),
+		}
+
+		entry := p.Logger.WithContext(db.Statement.Context).WithFields(fields)
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			entry.Debugf("Database query failed: %s", queryOperation)
+			return
+		}
+		entry.Debugf("Database query: %s", queryOperation)
+	}
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testSecret is a model of the tests, whose key and value must never appear in log entries.
+type testSecret struct {
+	ID    int
+	Key   string
+	Value string
+}
+
+// TestGormPluginTagsQueriesWithRequestID tests that queries are logged with the request ID of their context,
+// their operation and table, but without their SQL or arguments.
+func TestGormPluginTagsQueriesWithRequestID(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	appLogger := logrus.New()
+	appLogger.SetOutput(io.Discard)
+	appLogger.SetLevel(logrus.DebugLevel)
+	appLogger.AddHook(&ContextHook{})
+	hook := test.NewLocal(appLogger)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{Logger: appLogger}))
+
+	mock.ExpectQuery(`SELECT \* FROM "test_secrets"`).
+		WithArgs("db/password", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "value"}).AddRow(1, "db/password", "hunter2"))
+	mock.ExpectExec(`ALTER ROLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := requestid.NewContext(context.Background(), "abc-123")
+	var secret testSecret
+	require.NoError(t, db.WithContext(ctx).First(&secret, "key = ?", "db/password").Error)
+	require.NoError(t, db.WithContext(ctx).Exec(`ALTER ROLE "app" WITH PASSWORD 'hunter2'`).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Database query: SELECT", entries[0].Message)
+	assert.Equal(t, "test_secrets", entries[0].Data["table"])
+	assert.Equal(t, "Database query: ALTER", entries[1].Message)
+	for _, entry := range entries {
+		assert.Equal(t, logrus.DebugLevel, entry.Level)
+		assert.Equal(t, "abc-123", entry.Data["request_id"])
+		line, err := entry.String()
+		require.NoError(t, err)
+		assert.NotContains(t, line, "hunter2")
+		assert.NotContains(t, line, "db/password")
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,29 +16,91 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// logFile is the rotated log file opened by InitLogger, kept so that it can be closed on shutdown.
+var logFile *lumberjack.Logger
+
+// serviceName identifies Lockbox in JSON log entries, so that log shippers can tell its entries from other services'.
+const serviceName = "lockbox"
+
+const (
+	// FormatText writes one human-readable line per entry: timestamp LEVEL - message key=value...
+	FormatText = "text"
+
+	// FormatJSON writes one JSON object per entry, with every field, the caller and the service name.
+	FormatJSON = "json"
+)
+
 // LogFormatter is a custom log formatter for logrus.
 // It inherits from logrus.TextFormatter and overrides the Format method to define a specific log format.
 type LogFormatter struct {
 	logrus.TextFormatter
 }
 
-// Format formats the log entry in a standard format: timestamp - LEVEL - message.
+// Format formats the log entry in a standard format: timestamp - LEVEL - message, followed by the fields of the entry.
 // It ensures all log messages follow a consistent structure, with timestamps in ISO8601 format
-// and log levels in uppercase.
+// and log levels in uppercase. Fields are sorted by key and written as key=value.
 func (f *LogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	// Create the formatted log entry: timestamp - LEVEL - msg
-	log := fmt.Sprintf(
-		"%s %s - %s\n",
+	var log strings.Builder
+	fmt.Fprintf(&log,
+		"%s %s - %s",
 		entry.Time.Format(time.RFC3339),       // ISO8601 timestamp format
 		strings.ToUpper(entry.Level.String()), // Convert log level to uppercase
 		entry.Message,                         // Log message
 	)
-	return []byte(log), nil
+
+	// Append the fields in a stable order
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fmt.Sprint(entry.Data[key])
+		if strings.ContainsAny(value, " =\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&log, " %s=%s", key, value)
+	}
+
+	log.WriteString("\n")
+	return []byte(log.String()), nil
+}
+
+// JSONFormatter writes log entries as JSON objects for log shippers.
+// Every field of the entry is kept, along with the time, level, message, caller and service name.
+type JSONFormatter struct {
+	logrus.JSONFormatter
+}
+
+// NewJSONFormatter creates the JSON formatter, with RFC 3339 timestamps and the caller as "file:line".
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{
+		JSONFormatter: logrus.JSONFormatter{
+			TimestampFormat:  time.RFC3339Nano,
+			FieldMap:         logrus.FieldMap{logrus.FieldKeyFile: "caller"},
+			CallerPrettyfier: shortCaller,
+		},
+	}
+}
+
+// Format adds the service name to the fields of the entry, unless the entry sets it, then renders it as JSON.
+// The fields belong to this entry only (logrus copies them for every call), so they can be changed in place.
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if _, found := entry.Data["service"]; !found {
+		entry.Data["service"] = serviceName
+	}
+	return f.JSONFormatter.Format(entry)
+}
+
+// shortCaller reports the caller of a log entry as its package directory, file and line (e.g., "secrets/service.go:42").
+func shortCaller(frame *runtime.Frame) (string, string) {
+	return "", fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File), frame.Line)
 }
 
 // InitLogger initializes and configures the logger with sanitization and log rotation.
 // It uses logrus for logging and lumberjack for log rotation. The logger is configured
-// according to the provided logging configuration (level, file path, etc.).
+// according to the provided logging configuration (level, outputs, rotation, etc.).
 func InitLogger(loggingConfig config.LoggingConfig) *logrus.Logger {
 	// Create a new instance of logrus.Logger
 	logger := logrus.New()
@@ -49,32 +114,66 @@ func InitLogger(loggingConfig config.LoggingConfig) *logrus.Logger {
 	}
 	logger.SetLevel(level)
 
-	// Configure log rotation using lumberjack.
-	// This rotates log files when they reach a certain size, and manages log retention by
-	// limiting the number of backups and the age of log files.
-	logRotation := &lumberjack.Logger{
-		Filename:   loggingConfig.FilePath, // Path to the log file
-		MaxSize:    10,                     // Maximum size of each log file in MB before rotation
-		MaxBackups: 3,                      // Maximum number of old log files to keep
-		MaxAge:     28,                     // Maximum number of days to retain old log files
-		Compress:   true,                   // Compress old log files to save space
+	// Configure the outputs of the logger: the standard output, and the rotated log file.
+	// Either can be turned off, e.g., in containers, where the standard output is collected.
+	var outputs []io.Writer
+	if loggingConfig.Stdout {
+		outputs = append(outputs, os.Stdout)
 	}
+	if loggingConfig.File {
+		// Configure log rotation using lumberjack.
+		// This rotates log files when they reach a certain size, and manages log retention by
+		// limiting the number of backups and the age of log files.
+		logFile = &lumberjack.Logger{
+			Filename:   loggingConfig.FilePath,   // Path to the log file
+			MaxSize:    loggingConfig.MaxSize,    // Maximum size of each log file in MB before rotation
+			MaxBackups: loggingConfig.MaxBackups, // Maximum number of old log files to keep
+			MaxAge:     loggingConfig.MaxAge,     // Maximum number of days to retain old log files
+			Compress:   loggingConfig.Compress,   // Compress old log files to save space
+		}
+		outputs = append(outputs, logFile)
+	}
+	if len(outputs) == 0 {
+		// If every output is turned off, log the error and exit the application.
+		log.Fatalf("Invalid logging configuration: at least one of 'output_stdout' and 'output_file' must be enabled")
+	}
+	logger.SetOutput(io.MultiWriter(outputs...))
 
-	// Configure the logger to write log output to both stdout and the rotated log file.
-	multiWriter := io.MultiWriter(os.Stdout, logRotation)
-	logger.SetOutput(multiWriter)
-
-	// Apply the custom log formatter to ensure logs follow a consistent format.
-	logger.SetFormatter(&LogFormatter{
-		TextFormatter: logrus.TextFormatter{
-			FullTimestamp: true, // Ensure logs include full timestamps
-		},
-	})
+	// Apply the log formatter of the configured format.
+	// JSON entries include the caller, so the logger reports it.
+	switch strings.ToLower(loggingConfig.Format) {
+	case FormatText:
+		logger.SetFormatter(&LogFormatter{
+			TextFormatter: logrus.TextFormatter{
+				FullTimestamp: true, // Ensure logs include full timestamps
+			},
+		})
+	case FormatJSON:
+		logger.SetFormatter(NewJSONFormatter())
+		logger.SetReportCaller(true)
+	default:
+		// If the log format is invalid, log the error and exit the application.
+		log.Fatalf("Invalid log format: '%s' (expected '%s' or '%s')", loggingConfig.Format, FormatText, FormatJSON)
+	}
 
 	// Register a custom sanitize hook to sanitize sensitive information in logs.
-	// This ensures sensitive data (e.g., passwords) is removed from logs before being output.
-	logger.AddHook(&SanitizeHook{})
+	// This ensures sensitive data (e.g., passwords) is removed from log messages and fields before being output.
+	// Messages and fields longer than the configured maximum length are truncated.
+	logger.AddHook(&SanitizeHook{MaxLength: loggingConfig.MaxLogLength})
+
+	// Register a context hook to tag the entries logged for an HTTP request with its request ID.
+	// It runs after the sanitize hook: request IDs are validated, and random-looking ones must not be masked as tokens.
+	logger.AddHook(&ContextHook{})
 
 	// Return the initialized logger.
 	return logger
 }
+
+// Close flushes and closes the log file opened by InitLogger.
+// Nothing should be logged afterwards, since writing would reopen the file.
+func Close() error {
+	if logFile == nil {
+		return nil
+	}
+	return logFile.Close()
+}
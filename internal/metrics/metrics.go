@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// namespace prefixes the name of every metric specific to Lockbox.
+const namespace = "lockbox"
+
+const (
+	// OperationEncrypt labels encryptions in the crypto metrics.
+	OperationEncrypt = "encrypt"
+
+	// OperationDecrypt labels decryptions in the crypto metrics.
+	OperationDecrypt = "decrypt"
+)
+
+// Registry holds every metric exposed by Lockbox. A dedicated registry is used instead of the default one,
+// so that only the metrics registered here are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// httpRequests counts the HTTP requests by method, route template and status code.
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	// httpRequestDuration observes the latency of the HTTP requests by method and route template.
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// cryptoOperations counts the encryptions and decryptions.
+	cryptoOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crypto",
+		Name:      "operations_total",
+		Help:      "Number of AES-256 GCM operations, by operation.",
+	}, []string{"operation"})
+
+	// cryptoErrors counts the encryptions and decryptions that failed.
+	cryptoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crypto",
+		Name:      "errors_total",
+		Help:      "Number of failed AES-256 GCM operations, by operation.",
+	}, []string{"operation"})
+
+	// buildInfo exposes the version of the running binary as labels of a constant gauge.
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the running Lockbox binary, always 1.",
+	}, []string{"version", "revision", "go_version"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		cryptoOperations,
+		cryptoErrors,
+		buildInfo,
+	)
+	for _, operation := range []string{OperationEncrypt, OperationDecrypt} {
+		cryptoOperations.WithLabelValues(operation)
+		cryptoErrors.WithLabelValues(operation)
+	}
+
+	version, revision := buildVersion()
+	buildInfo.WithLabelValues(version, revision, runtime.Version()).Set(1)
+}
+
+// otherMethod labels the requests whose method is not a standard HTTP method.
+const otherMethod = "other"
+
+// standardMethods are the HTTP methods recorded as they are. Clients can send any token as a method,
+// so the others are recorded as otherMethod rather than creating a series per method.
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// ObserveRequest records an HTTP request. The route must be the template of the matched route (e.g., "/secrets/{query}"),
+// never the raw path, which could contain secret keys and would create a series per key.
+// Non-standard methods are recorded as "other".
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	if !standardMethods[method] {
+		method = otherMethod
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveCrypto records an encryption or a decryption, and whether it failed.
+func ObserveCrypto(operation string, err error) {
+	cryptoOperations.WithLabelValues(operation).Inc()
+	if err != nil {
+		cryptoErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// RegisterDatabase exposes the statistics of the database connection pool (open, in use and idle connections, waits).
+func RegisterDatabase(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// buildVersion returns the version of the main module and the VCS revision it was built from, as recorded by the Go toolchain.
+func buildVersion() (string, string) {
+	version, revision := "unknown", "unknown"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, revision
+	}
+	if info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			revision = setting.Value
+		}
+	}
+	return version, revision
+}
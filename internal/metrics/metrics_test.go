@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape renders the registry like a Prometheus scrape would.
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+// TestMetrics tests that requests, crypto operations and build information are exposed in the text format,
+// and that non-standard request methods do not create series of their own.
+func TestMetrics(t *testing.T) {
+	ObserveRequest(http.MethodGet, "/secrets/{query}", http.StatusNotFound, 20*time.Millisecond)
+	ObserveRequest("FOO1", "unknown", http.StatusMethodNotAllowed, time.Millisecond)
+	ObserveRequest("FOO2", "unknown", http.StatusMethodNotAllowed, time.Millisecond)
+	ObserveCrypto(OperationEncrypt, nil)
+	ObserveCrypto(OperationDecrypt, errors.New("message authentication failed"))
+
+	body := scrape(t)
+	assert.Contains(t, body, `lockbox_http_requests_total{method="GET",route="/secrets/{query}",status="404"} 1`)
+	assert.Contains(t, body, `lockbox_http_request_duration_seconds_bucket{method="GET",route="/secrets/{query}",le="0.025"} 1`)
+	assert.Contains(t, body, `lockbox_http_requests_total{method="other",route="unknown",status="405"} 2`, "Non-standard methods share a single series")
+	assert.NotContains(t, body, `FOO1`)
+	assert.Contains(t, body, `lockbox_crypto_operations_total{operation="encrypt"} 1`)
+	assert.Contains(t, body, `lockbox_crypto_errors_total{operation="encrypt"} 0`)
+	assert.Contains(t, body, `lockbox_crypto_errors_total{operation="decrypt"} 1`)
+	assert.Contains(t, body, `lockbox_build_info{`)
+	assert.Contains(t, body, `go_goroutines`)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// shutdownTimeout is how long a scrape in progress is given to complete when the server shuts down.
+const shutdownTimeout = 5 * time.Second
+
+// Handler returns the handler that renders the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes GET /metrics on the address of the metrics configuration until the context is cancelled.
+// It listens separately from the API, so that metrics can be kept off the public interface.
+// It is meant to run as a background worker of the server, and returns right away if metrics are disabled.
+func Serve(ctx context.Context, metricsConfig config.MetricsConfig) {
+	if !metricsConfig.Enabled {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	metricsServer := &http.Server{
+		Addr:              fmt.Sprintf("%s:%s", metricsConfig.Host, metricsConfig.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Stop the server when the context is cancelled
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		metricsServer.Shutdown(shutdownCtx)
+	}()
+
+	global.Logger.Infof("Serving metrics on %s/metrics", metricsServer.Addr)
+	if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		global.Logger.Errorf("Metrics server failed: %v", err)
+	}
+}
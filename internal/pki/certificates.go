@@ -0,0 +1,223 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// backdate is subtracted from the start of validity of new certificates to tolerate clock skew between hosts.
+const backdate = 30 * time.Second
+
+// hostnamePattern matches lowercase DNS names without wildcards.
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// generateKey creates a new key pair of the given type.
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case KeyTypeECDSAP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA2048:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedKeyType, keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %v", err)
+	}
+	return key, nil
+}
+
+// newSerialNumber generates a random, positive 128-bit certificate serial number.
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serial, nil
+}
+
+// formatSerialNumber encodes a serial number as it is stored and used in the API.
+func formatSerialNumber(serial *big.Int) string {
+	return fmt.Sprintf("%x", serial)
+}
+
+// newCATemplate creates the template of a CA certificate.
+func newCATemplate(commonName string, ttl time.Duration) (*x509.Certificate, error) {
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-backdate),
+		NotAfter:              now.Add(ttl),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil
+}
+
+// newLeafTemplate creates the template of a TLS certificate for the given names.
+func newLeafTemplate(commonName string, dnsNames []string, ipAddresses []net.IP, notAfter time.Time) (*x509.Certificate, error) {
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		IPAddresses:           ipAddresses,
+		NotBefore:             time.Now().Add(-backdate),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}, nil
+}
+
+// signCertificate signs a certificate template with the issuer, or self-signs it when issuer is nil.
+// Returns the parsed certificate.
+func signCertificate(template, issuer *x509.Certificate, publicKey crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	if issuer == nil {
+		issuer = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, publicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %v", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// encodeCertificate encodes a certificate in PEM.
+func encodeCertificate(certificate *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+}
+
+// encodePrivateKey encodes a private key in PEM, as a PKCS #8 private key.
+func encodePrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parseCertificate decodes a PEM-encoded certificate.
+func parseCertificate(certificatePEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: expected a PEM-encoded certificate", ErrInvalidCertificate)
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	return certificate, nil
+}
+
+// parsePrivateKey decodes a PEM-encoded PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) private key.
+func parsePrivateKey(privateKeyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("%w: expected a PEM-encoded private key", ErrInvalidCertificate)
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid private key: %v", ErrInvalidCertificate, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported private key", ErrInvalidCertificate)
+	}
+	return signer, nil
+}
+
+// publicKeysMatch reports whether a private key belongs to the public key of a certificate.
+func publicKeysMatch(certificate *x509.Certificate, key crypto.Signer) bool {
+	public, ok := certificate.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && public.Equal(key.Public())
+}
+
+// nameAllowed reports whether a role allows a DNS name.
+func nameAllowed(role *Role, name string) bool {
+	for _, domain := range role.AllowedDomains {
+		if role.AllowBareDomains && name == domain {
+			return true
+		}
+		if role.AllowSubdomains && strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNames checks the names and addresses of a certificate request against a role.
+// The common name is added to the DNS names. Returns the deduplicated DNS names and the parsed IP addresses.
+func validateNames(role *Role, req IssueRequest) ([]string, []net.IP, error) {
+	var dnsNames []string
+	seen := make(map[string]bool)
+	for _, name := range append([]string{req.CommonName}, req.AltNames...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if !hostnamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("%w: '%s' is not a valid DNS name", ErrNameNotAllowed, name)
+		}
+		if !nameAllowed(role, name) {
+			return nil, nil, fmt.Errorf("%w: '%s' is not allowed by role '%s'", ErrNameNotAllowed, name, role.Name)
+		}
+		dnsNames = append(dnsNames, name)
+	}
+
+	var ipAddresses []net.IP
+	for _, value := range req.IPSANs {
+		if !role.AllowIPSANs {
+			return nil, nil, fmt.Errorf("%w: IP addresses are not allowed by role '%s'", ErrNameNotAllowed, role.Name)
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("%w: '%s' is not a valid IP address", ErrNameNotAllowed, value)
+		}
+		ipAddresses = append(ipAddresses, ip)
+	}
+
+	return dnsNames, ipAddresses, nil
+}
@@ -0,0 +1,184 @@
+package pki
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyType identifies the algorithm of a certificate key pair.
+type KeyType string
+
+const (
+	// KeyTypeECDSAP256 is an ECDSA key pair on the NIST P-256 curve.
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+
+	// KeyTypeRSA2048 is a 2048-bit RSA key pair.
+	KeyTypeRSA2048 KeyType = "rsa-2048"
+
+	// KeyTypeEd25519 is an Ed25519 key pair.
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+// valid reports whether the key type is supported.
+func (t KeyType) valid() bool {
+	return t == KeyTypeECDSAP256 || t == KeyTypeRSA2048 || t == KeyTypeEd25519
+}
+
+// CA is a certificate authority held by Lockbox, either generated or imported.
+// Root CAs are self-signed, while intermediate CAs reference the CA that signed them.
+type CA struct {
+	// ID is the unique identifier of the CA.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name used to address the CA in the API (e.g., "root", "services").
+	Name string `gorm:"unique;not null"`
+
+	// ParentID is the ID of the CA that signed this CA, or nil for a root CA.
+	ParentID *uuid.UUID
+
+	// Certificate is the PEM-encoded certificate of the CA.
+	Certificate string `gorm:"not null"`
+
+	// EncryptedPrivateKey holds the PEM-encoded PKCS #8 private key of the CA, encrypted with the master key using secrets.Encrypt.
+	EncryptedPrivateKey string `gorm:"not null"`
+
+	// NotAfter is the expiration time of the CA certificate. Issued certificates never outlive it.
+	NotAfter time.Time `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the CA was created or imported.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name of the CA model.
+func (CA) TableName() string {
+	return "pki_cas"
+}
+
+// Role is a template for issuing certificates: it restricts which names may be requested and for how long.
+type Role struct {
+	// ID is the unique identifier of the role.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name of the role, used in POST /pki/issue/{role}.
+	Name string `gorm:"unique;not null"`
+
+	// Issuer is the name of the CA that signs certificates issued with this role.
+	Issuer string `gorm:"not null"`
+
+	// AllowedDomains lists the domains that certificates may be issued for.
+	AllowedDomains []string `gorm:"serializer:json;not null"`
+
+	// AllowBareDomains allows issuing certificates for the allowed domains themselves.
+	AllowBareDomains bool `gorm:"not null"`
+
+	// AllowSubdomains allows issuing certificates for subdomains of the allowed domains.
+	AllowSubdomains bool `gorm:"not null"`
+
+	// AllowIPSANs allows IP addresses in the subject alternative names.
+	AllowIPSANs bool `gorm:"column:allow_ip_sans;not null"`
+
+	// KeyType is the algorithm of the key pairs generated for issued certificates.
+	KeyType KeyType `gorm:"not null"`
+
+	// TTL is the default lifetime of issued certificates, in seconds.
+	TTL int `gorm:"not null"`
+
+	// MaxTTL is the longest lifetime that may be requested, in seconds.
+	MaxTTL int `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the role was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the role was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Role model.
+func (Role) TableName() string {
+	return "pki_roles"
+}
+
+// Certificate records a certificate issued by Lockbox, so that it can be revoked and listed in the CRL.
+// The private key of an issued certificate is returned once to the requester and never stored.
+type Certificate struct {
+	// SerialNumber is the hex-encoded serial number of the certificate.
+	SerialNumber string `gorm:"primaryKey"`
+
+	// CAID is the ID of the CA that issued the certificate.
+	CAID uuid.UUID `gorm:"not null"`
+
+	// Role is the name of the role the certificate was issued with.
+	Role string `gorm:"not null"`
+
+	// CommonName is the common name of the certificate subject.
+	CommonName string `gorm:"not null"`
+
+	// Certificate is the PEM-encoded certificate.
+	Certificate string `gorm:"not null"`
+
+	// NotAfter is the expiration time of the certificate.
+	NotAfter time.Time `gorm:"not null"`
+
+	// RevokedAt stores the timestamp of when the certificate was revoked, or nil if it is still valid.
+	RevokedAt *time.Time
+
+	// CreatedAt stores the timestamp of when the certificate was issued.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name of the Certificate model.
+func (Certificate) TableName() string {
+	return "pki_certificates"
+}
+
+// CARequest holds the parameters of a CA to generate.
+type CARequest struct {
+	// Name is the unique name of the new CA.
+	Name string
+
+	// Parent is the name of the CA that signs an intermediate CA. It is ignored for root CAs.
+	Parent string
+
+	// CommonName is the common name of the CA certificate subject.
+	CommonName string
+
+	// KeyType is the algorithm of the CA key pair.
+	KeyType KeyType
+
+	// TTL is the lifetime of the CA certificate.
+	TTL time.Duration
+}
+
+// IssueRequest holds the parameters of a certificate to issue.
+type IssueRequest struct {
+	// CommonName is the common name of the certificate subject. It is also added to the DNS names.
+	CommonName string
+
+	// AltNames are additional DNS names.
+	AltNames []string
+
+	// IPSANs are IP addresses to include in the subject alternative names.
+	IPSANs []string
+
+	// TTL is the requested lifetime of the certificate. The role's default TTL is used when zero.
+	TTL time.Duration
+}
+
+// IssuedCertificate is the result of issuing a certificate.
+type IssuedCertificate struct {
+	// SerialNumber is the hex-encoded serial number of the certificate.
+	SerialNumber string
+
+	// Certificate is the PEM-encoded certificate.
+	Certificate string
+
+	// PrivateKey is the PEM-encoded PKCS #8 private key of the certificate. It is not stored by Lockbox.
+	PrivateKey string
+
+	// CAChain holds the PEM-encoded certificates of the issuing CA and its parents, up to the root.
+	CAChain []string
+
+	// Expiration is the expiration time of the certificate.
+	Expiration time.Time
+}
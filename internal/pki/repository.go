@@ -0,0 +1,174 @@
+package pki
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface defines methods for database interactions related to the PKI engine.
+type Repository interface {
+	// Saves a new CA to the database
+	SaveCA(ca *CA) error
+
+	// Retrieves a CA by its name
+	GetCAByName(name string) (*CA, error)
+
+	// Retrieves a CA by its UUID
+	GetCAByID(id uuid.UUID) (*CA, error)
+
+	// Creates a role, or replaces the role with the same name
+	SaveRole(role *Role) error
+
+	// Retrieves a role by its name
+	GetRole(name string) (*Role, error)
+
+	// Saves a new issued certificate to the database
+	SaveCertificate(certificate *Certificate) error
+
+	// Retrieves an issued certificate by its serial number
+	GetCertificate(serialNumber string) (*Certificate, error)
+
+	// Marks an issued certificate as revoked
+	Revoke(serialNumber string, revokedAt time.Time) error
+
+	// Lists the revoked certificates of a CA that have not expired yet
+	ListRevoked(caID uuid.UUID, now time.Time) ([]*Certificate, error)
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the PKI repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// SaveCA inserts a new CA record into the database.
+//
+// Parameters:
+// - ca: The CA model, including its encrypted private key.
+//
+// Returns:
+// - error: Returns an error if the insertion fails (e.g., the name is already taken), otherwise nil.
+func (r *repository) SaveCA(ca *CA) error {
+	return r.db.Create(ca).Error
+}
+
+// GetCAByName retrieves a CA by its name.
+//
+// Parameters:
+// - name: The name of the CA to retrieve.
+//
+// Returns:
+// - CA: The retrieved CA model.
+// - error: Returns an error if no CA with the given name is found or if the query fails.
+func (r *repository) GetCAByName(name string) (*CA, error) {
+	var ca *CA
+	err := r.db.First(&ca, "name = ?", name).Error
+	return ca, err
+}
+
+// GetCAByID retrieves a CA by its UUID.
+//
+// Parameters:
+// - id: The UUID of the CA to retrieve.
+//
+// Returns:
+// - CA: The retrieved CA model.
+// - error: Returns an error if no CA with the given ID is found or if the query fails.
+func (r *repository) GetCAByID(id uuid.UUID) (*CA, error) {
+	var ca *CA
+	err := r.db.First(&ca, "id = ?", id).Error
+	return ca, err
+}
+
+// SaveRole inserts a role, or updates every setting of the existing role with the same name.
+//
+// Parameters:
+// - role: The Role model.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) SaveRole(role *Role) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"issuer", "allowed_domains", "allow_bare_domains", "allow_subdomains", "allow_ip_sans", "key_type", "ttl", "max_ttl", "updated_at",
+		}),
+	}).Create(role).Error
+}
+
+// GetRole retrieves a role by its name.
+//
+// Parameters:
+// - name: The name of the role to retrieve.
+//
+// Returns:
+// - Role: The retrieved Role model.
+// - error: Returns an error if no role with the given name is found or if the query fails.
+func (r *repository) GetRole(name string) (*Role, error) {
+	var role *Role
+	err := r.db.First(&role, "name = ?", name).Error
+	return role, err
+}
+
+// SaveCertificate inserts a new issued certificate record into the database.
+//
+// Parameters:
+// - certificate: The Certificate model.
+//
+// Returns:
+// - error: Returns an error if the insertion fails, otherwise nil.
+func (r *repository) SaveCertificate(certificate *Certificate) error {
+	return r.db.Create(certificate).Error
+}
+
+// GetCertificate retrieves an issued certificate by its serial number.
+//
+// Parameters:
+// - serialNumber: The hex-encoded serial number of the certificate.
+//
+// Returns:
+// - Certificate: The retrieved Certificate model.
+// - error: Returns an error if no certificate with the given serial number is found or if the query fails.
+func (r *repository) GetCertificate(serialNumber string) (*Certificate, error) {
+	var certificate *Certificate
+	err := r.db.First(&certificate, "serial_number = ?", serialNumber).Error
+	return certificate, err
+}
+
+// Revoke sets the revocation time of a certificate that has not been revoked yet.
+//
+// Parameters:
+// - serialNumber: The hex-encoded serial number of the certificate.
+// - revokedAt: The revocation time.
+//
+// Returns:
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) Revoke(serialNumber string, revokedAt time.Time) error {
+	return r.db.Model(&Certificate{}).
+		Where("serial_number = ? AND revoked_at IS NULL", serialNumber).
+		Update("revoked_at", revokedAt).Error
+}
+
+// ListRevoked retrieves the revoked certificates of a CA. Expired certificates are left out, as they are no longer trusted anyway.
+//
+// Parameters:
+// - caID: The UUID of the issuing CA.
+// - now: The current time, used to leave out expired certificates.
+//
+// Returns:
+// - []*Certificate: The revoked certificates, ordered by revocation time.
+// - error: Returns an error if the query fails, otherwise nil.
+func (r *repository) ListRevoked(caID uuid.UUID, now time.Time) ([]*Certificate, error) {
+	var certificates []*Certificate
+	err := r.db.Where("ca_id = ? AND revoked_at IS NOT NULL AND not_after > ?", caID, now).
+		Order("revoked_at").
+		Find(&certificates).Error
+	return certificates, err
+}
@@ -0,0 +1,45 @@
+package pki
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+)
+
+// TestRepoSaveRole tests that saving a role again replaces its settings but keeps its ID,
+// and that its allowed domains are read back from their JSON column.
+func TestRepoSaveRole(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewRepository(db)
+	name := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		db.Delete(&Role{}, "name = ?", name)
+	})
+
+	// Save a role, then new settings for it under a new ID
+	role := &Role{
+		ID: uuid.New(), Name: name, Issuer: "root", AllowedDomains: []string{"example.com"},
+		AllowSubdomains: true, KeyType: KeyTypeECDSAP256, TTL: 3600, MaxTTL: 86400,
+	}
+	require.NoError(t, repo.SaveRole(role))
+	assert.NoError(t, repo.SaveRole(&Role{
+		ID: uuid.New(), Name: name, Issuer: "intermediate", AllowedDomains: []string{"example.com", "example.org"},
+		AllowBareDomains: true, AllowIPSANs: true, KeyType: KeyTypeEd25519, TTL: 60, MaxTTL: 120,
+	}))
+
+	// Assert the settings were replaced
+	stored, err := repo.GetRole(name)
+	assert.NoError(t, err)
+	assert.Equal(t, role.ID, stored.ID)
+	assert.Equal(t, "intermediate", stored.Issuer)
+	assert.Equal(t, []string{"example.com", "example.org"}, stored.AllowedDomains)
+	assert.True(t, stored.AllowBareDomains)
+	assert.False(t, stored.AllowSubdomains)
+	assert.True(t, stored.AllowIPSANs)
+	assert.Equal(t, KeyTypeEd25519, stored.KeyType)
+	assert.Equal(t, 60, stored.TTL)
+	assert.Equal(t, 120, stored.MaxTTL)
+}
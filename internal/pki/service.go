@@ -0,0 +1,497 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// crlLifetime is the time after which clients should fetch a new CRL.
+const crlLifetime = 24 * time.Hour
+
+var (
+	// ErrCANotFound is returned when no CA exists with the requested name.
+	ErrCANotFound = errors.New("CA not found")
+
+	// ErrCAExists is returned when creating or importing a CA with a name that is already taken.
+	ErrCAExists = errors.New("CA already exists")
+
+	// ErrRoleNotFound is returned when no role exists with the requested name.
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrCertificateNotFound is returned when no issued certificate has the requested serial number.
+	ErrCertificateNotFound = errors.New("certificate not found")
+
+	// ErrInvalidName is returned when a CA or role name contains characters that are not allowed.
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrUnsupportedKeyType is returned when a key type is unknown.
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+	// ErrInvalidCertificate is returned when an imported certificate or private key is malformed or inconsistent.
+	ErrInvalidCertificate = errors.New("invalid certificate")
+
+	// ErrInvalidRole is returned when the settings of a role are inconsistent.
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrNameNotAllowed is returned when a certificate request includes a name or address that its role does not allow.
+	ErrNameNotAllowed = errors.New("name not allowed")
+
+	// ErrTTLTooLong is returned when a certificate request asks for a lifetime beyond the role's maximum.
+	ErrTTLTooLong = errors.New("requested TTL exceeds the maximum TTL of the role")
+)
+
+// namePattern restricts CA and role names to characters that are safe in URLs and logs.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// Service interface defines the business logic of the PKI engine.
+// CA private keys are encrypted with the master key at rest and never leave the service.
+type Service interface {
+	// GenerateRootCA generates a self-signed root CA.
+	// Returns the created CA or an error if something goes wrong.
+	GenerateRootCA(req CARequest, masterKey string) (*CA, error)
+
+	// GenerateIntermediateCA generates an intermediate CA signed by the parent CA named in the request.
+	// Returns the created CA or an error if something goes wrong.
+	GenerateIntermediateCA(req CARequest, masterKey string) (*CA, error)
+
+	// ImportCA stores an existing CA certificate and its private key.
+	// When parent is not empty, the certificate must be signed by that CA.
+	// Returns the imported CA or an error if the certificate or key is invalid.
+	ImportCA(name, parent, certificatePEM, privateKeyPEM, masterKey string) (*CA, error)
+
+	// GetCA retrieves a CA by name.
+	// Returns the CA or ErrCANotFound.
+	GetCA(name string) (*CA, error)
+
+	// SaveRole validates and stores a role, replacing any existing role with the same name.
+	// Returns an error if the role is invalid or cannot be stored.
+	SaveRole(role *Role) error
+
+	// GetRole retrieves a role by name.
+	// Returns the Role or ErrRoleNotFound.
+	GetRole(name string) (*Role, error)
+
+	// Issue generates a key pair and a certificate signed by the role's issuer.
+	// Returns the certificate, its private key and the CA chain, or an error if the request is not allowed by the role.
+	Issue(roleName string, req IssueRequest, masterKey string) (*IssuedCertificate, error)
+
+	// Revoke revokes an issued certificate by serial number. Revoking a revoked certificate has no effect.
+	// Returns the revoked Certificate or ErrCertificateNotFound.
+	Revoke(serialNumber string) (*Certificate, error)
+
+	// CRL builds the certificate revocation list of a CA.
+	// Returns the PEM-encoded CRL or an error if something goes wrong.
+	CRL(caName, masterKey string) ([]byte, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new PKI service.
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// GenerateRootCA generates a key pair and a self-signed CA certificate.
+func (s *service) GenerateRootCA(req CARequest, masterKey string) (*CA, error) {
+	if err := s.checkNewCA(req.Name); err != nil {
+		return nil, err
+	}
+
+	key, err := generateKey(req.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newCATemplate(req.CommonName, req.TTL)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := signCertificate(template, nil, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveCA(req.Name, nil, certificate, key, masterKey)
+}
+
+// GenerateIntermediateCA generates a key pair and a CA certificate signed by the parent CA.
+func (s *service) GenerateIntermediateCA(req CARequest, masterKey string) (*CA, error) {
+	if err := s.checkNewCA(req.Name); err != nil {
+		return nil, err
+	}
+
+	// Load the parent CA, which signs the new certificate
+	parent, err := s.GetCA(req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	parentCertificate, parentKey, err := s.loadCA(parent, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := generateKey(req.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newCATemplate(req.CommonName, req.TTL)
+	if err != nil {
+		return nil, err
+	}
+	// An intermediate CA never outlives its parent
+	if template.NotAfter.After(parentCertificate.NotAfter) {
+		template.NotAfter = parentCertificate.NotAfter
+	}
+	certificate, err := signCertificate(template, parentCertificate, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveCA(req.Name, &parent.ID, certificate, key, masterKey)
+}
+
+// ImportCA checks that the private key matches the certificate, and that the certificate is a CA signed by the parent, if any.
+func (s *service) ImportCA(name, parent, certificatePEM, privateKeyPEM, masterKey string) (*CA, error) {
+	if err := s.checkNewCA(name); err != nil {
+		return nil, err
+	}
+
+	certificate, err := parseCertificate(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !certificate.IsCA {
+		return nil, fmt.Errorf("%w: the certificate is not a CA", ErrInvalidCertificate)
+	}
+	if !publicKeysMatch(certificate, key) {
+		return nil, fmt.Errorf("%w: the private key does not match the certificate", ErrInvalidCertificate)
+	}
+
+	// Make sure the chain is consistent when a parent is given
+	var parentID *uuid.UUID
+	if parent != "" {
+		parentCA, err := s.GetCA(parent)
+		if err != nil {
+			return nil, err
+		}
+		parentCertificate, err := parseCertificate(parentCA.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if err := certificate.CheckSignatureFrom(parentCertificate); err != nil {
+			return nil, fmt.Errorf("%w: the certificate is not signed by CA '%s': %v", ErrInvalidCertificate, parent, err)
+		}
+		parentID = &parentCA.ID
+	}
+
+	return s.saveCA(name, parentID, certificate, key, masterKey)
+}
+
+// GetCA retrieves a CA by name.
+func (s *service) GetCA(name string) (*CA, error) {
+	ca, err := s.repo.GetCAByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrCANotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve CA '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return ca, nil
+}
+
+// SaveRole validates a role and stores it.
+func (s *service) SaveRole(role *Role) error {
+	if !namePattern.MatchString(role.Name) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidName, role.Name)
+	}
+	if _, err := s.GetCA(role.Issuer); err != nil {
+		return err
+	}
+	if len(role.AllowedDomains) == 0 || (!role.AllowBareDomains && !role.AllowSubdomains) {
+		return fmt.Errorf("%w: at least one allowed domain and one of allow_bare_domains or allow_subdomains are required", ErrInvalidRole)
+	}
+	if role.TTL <= 0 || role.MaxTTL < role.TTL {
+		return fmt.Errorf("%w: ttl must be positive and not greater than max_ttl", ErrInvalidRole)
+	}
+	if !role.KeyType.valid() {
+		return fmt.Errorf("%w: '%s'", ErrUnsupportedKeyType, role.KeyType)
+	}
+
+	role.ID = uuid.New()
+	if err := s.repo.SaveRole(role); err != nil {
+		err = fmt.Errorf("failed to store role '%s': %v", role.Name, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Saved PKI role '%s' issued by CA '%s'", role.Name, role.Issuer)
+	return nil
+}
+
+// GetRole retrieves a role by name.
+func (s *service) GetRole(name string) (*Role, error) {
+	role, err := s.repo.GetRole(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrRoleNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve role '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// Issue checks the request against the role, then generates and signs a new certificate.
+func (s *service) Issue(roleName string, req IssueRequest, masterKey string) (*IssuedCertificate, error) {
+	role, err := s.GetRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the requested names and lifetime
+	dnsNames, ipAddresses, err := validateNames(role, req)
+	if err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(role.TTL) * time.Second
+	if req.TTL > 0 {
+		ttl = req.TTL
+	}
+	if ttl > time.Duration(role.MaxTTL)*time.Second {
+		return nil, fmt.Errorf("%w: %s > %ds", ErrTTLTooLong, ttl, role.MaxTTL)
+	}
+
+	// Load the issuing CA
+	ca, err := s.GetCA(role.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	caCertificate, caKey, err := s.loadCA(ca, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate and sign the certificate, which never outlives its CA
+	key, err := generateKey(role.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	notAfter := time.Now().Add(ttl)
+	if notAfter.After(caCertificate.NotAfter) {
+		notAfter = caCertificate.NotAfter
+	}
+	template, err := newLeafTemplate(dnsNames[0], dnsNames, ipAddresses, notAfter)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := signCertificate(template, caCertificate, key.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the certificate so that it can be revoked
+	issued := &Certificate{
+		SerialNumber: formatSerialNumber(certificate.SerialNumber),
+		CAID:         ca.ID,
+		Role:         role.Name,
+		CommonName:   dnsNames[0],
+		Certificate:  encodeCertificate(certificate),
+		NotAfter:     certificate.NotAfter,
+	}
+	if err := s.repo.SaveCertificate(issued); err != nil {
+		err = fmt.Errorf("failed to store issued certificate: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	privateKey, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := s.chain(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	global.Logger.Infof("Issued certificate %s for '%s' with role '%s', expiring at %s", issued.SerialNumber, issued.CommonName, role.Name, issued.NotAfter.Format(time.RFC3339))
+	return &IssuedCertificate{
+		SerialNumber: issued.SerialNumber,
+		Certificate:  issued.Certificate,
+		PrivateKey:   privateKey,
+		CAChain:      chain,
+		Expiration:   issued.NotAfter,
+	}, nil
+}
+
+// Revoke marks an issued certificate as revoked, so that it is listed in the CRL of its CA.
+func (s *service) Revoke(serialNumber string) (*Certificate, error) {
+	certificate, err := s.repo.GetCertificate(serialNumber)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrCertificateNotFound, serialNumber)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve certificate '%s': %v", serialNumber, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+	if certificate.RevokedAt != nil {
+		return certificate, nil
+	}
+
+	revokedAt := time.Now()
+	if err := s.repo.Revoke(serialNumber, revokedAt); err != nil {
+		err = fmt.Errorf("failed to revoke certificate '%s': %v", serialNumber, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Revoked certificate %s for '%s'", serialNumber, certificate.CommonName)
+	certificate.RevokedAt = &revokedAt
+	return certificate, nil
+}
+
+// CRL builds and signs the list of unexpired revoked certificates of a CA.
+// The CRL number is the creation time in nanoseconds, so it increases with every CRL without keeping a counter.
+func (s *service) CRL(caName, masterKey string) ([]byte, error) {
+	ca, err := s.GetCA(caName)
+	if err != nil {
+		return nil, err
+	}
+	caCertificate, caKey, err := s.loadCA(ca, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	revoked, err := s.repo.ListRevoked(ca.ID, now)
+	if err != nil {
+		err = fmt.Errorf("failed to list revoked certificates of CA '%s': %v", caName, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(crlLifetime),
+	}
+	for _, certificate := range revoked {
+		serial, ok := new(big.Int).SetString(certificate.SerialNumber, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial number '%s' in the database", certificate.SerialNumber)
+		}
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *certificate.RevokedAt,
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, caCertificate, caKey)
+	if err != nil {
+		err = fmt.Errorf("failed to sign CRL of CA '%s': %v", caName, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// checkNewCA makes sure a CA name is valid and not taken yet.
+func (s *service) checkNewCA(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidName, name)
+	}
+	if _, err := s.repo.GetCAByName(name); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrCAExists, name)
+	}
+	return nil
+}
+
+// saveCA encrypts the private key of a CA with the master key and stores the CA.
+func (s *service) saveCA(name string, parentID *uuid.UUID, certificate *x509.Certificate, key crypto.Signer, masterKey string) (*CA, error) {
+	privateKey, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encryptedPrivateKey, err := secrets.Encrypt(privateKey, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to encrypt CA private key: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	ca := &CA{
+		ID:                  uuid.New(),
+		Name:                name,
+		ParentID:            parentID,
+		Certificate:         encodeCertificate(certificate),
+		EncryptedPrivateKey: encryptedPrivateKey,
+		NotAfter:            certificate.NotAfter,
+	}
+	if err := s.repo.SaveCA(ca); err != nil {
+		err = fmt.Errorf("failed to store CA in the database: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Stored CA '%s' (%s), expiring at %s", name, certificate.Subject.CommonName, certificate.NotAfter.Format(time.RFC3339))
+	return ca, nil
+}
+
+// loadCA parses the certificate of a CA and decrypts its private key.
+func (s *service) loadCA(ca *CA, masterKey string) (*x509.Certificate, crypto.Signer, error) {
+	certificate, err := parseCertificate(ca.Certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := secrets.Decrypt(ca.EncryptedPrivateKey, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to decrypt private key of CA '%s': %v", ca.Name, err)
+		global.Logger.Error(err)
+		return nil, nil, err
+	}
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certificate, key, nil
+}
+
+// chain returns the PEM-encoded certificates of a CA and its parents, up to the root.
+func (s *service) chain(ca *CA) ([]string, error) {
+	chain := []string{ca.Certificate}
+	for ca.ParentID != nil {
+		parent, err := s.repo.GetCAByID(*ca.ParentID)
+		if err != nil {
+			err = fmt.Errorf("failed to retrieve parent of CA '%s': %v", ca.Name, err)
+			global.Logger.Error(err)
+			return nil, err
+		}
+		chain = append(chain, parent.Certificate)
+		ca = parent
+	}
+	return chain, nil
+}
@@ -0,0 +1,145 @@
+// Package reload re-reads the configuration while Lockbox is running, on SIGHUP or when the configuration file changes,
+// and applies the settings that can change without a restart.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gorm.io/gorm"
+)
+
+// watchInterval is how often the configuration file is checked for changes.
+const watchInterval = 5 * time.Second
+
+// active is the configuration in use, replaced as a whole when a reload is applied.
+var active atomic.Pointer[config.Config]
+
+// Active returns the configuration in use, including the settings applied by the last reload.
+func Active() *config.Config {
+	return active.Load()
+}
+
+// Reloader re-reads the configuration from the same sources as on startup, and applies the reloadable settings
+// (see config.ReloadableSettings). A reload that is invalid or changes a setting that requires a restart
+// is rejected as a whole, so the running configuration is never partially updated.
+type Reloader struct {
+	filePath  string         // The configuration file, or empty if the configuration only comes from the environment and flags
+	overrides []string       // The overrides given on the command line, which keep taking precedence
+	db        *gorm.DB       // The database whose connection pool is reconfigured
+	logger    *logrus.Logger // The logger whose level is changed
+	mutex     sync.Mutex     // Serializes reloads triggered by a signal and by a file change
+}
+
+// NewReloader creates a reloader of the configuration, which becomes the active configuration.
+func NewReloader(initial *config.Config, filePath string, overrides []string, db *gorm.DB, logger *logrus.Logger) *Reloader {
+	active.Store(initial)
+	return &Reloader{filePath: filePath, overrides: overrides, db: db, logger: logger}
+}
+
+// Reload re-reads the configuration and applies it, logging the outcome.
+// Only the names of the changed settings are logged, never their values.
+// It returns whether the configuration was applied.
+func (r *Reloader) Reload() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	newConfig, err := config.LoadConfig(r.filePath, r.overrides...)
+	if err != nil {
+		r.logger.Errorf("Configuration reload rejected: %v", err)
+		return false
+	}
+
+	current := Active()
+	changes := current.Changes(newConfig)
+	if len(changes) == 0 {
+		r.logger.Info("Configuration reloaded: no changes")
+		return true
+	}
+
+	var restartOnly []string
+	databaseChanged := false
+	for _, change := range changes {
+		if !config.ReloadableSettings[change] {
+			restartOnly = append(restartOnly, change)
+		}
+		if strings.HasPrefix(change, "database.") {
+			databaseChanged = true
+		}
+	}
+	if len(restartOnly) > 0 {
+		r.logger.Errorf("Configuration reload rejected: changing %s requires a restart; no changes were applied", strings.Join(restartOnly, ", "))
+		return false
+	}
+
+	// Apply the database settings first: a new password is tried against the database, and rejecting it
+	// rejects the whole reload before anything else is applied or the configuration becomes active
+	if databaseChanged {
+		if err := database.Reconfigure(r.db, newConfig.Database); err != nil {
+			r.logger.Errorf("Configuration reload rejected: %v", err)
+			return false
+		}
+	}
+	if level, err := logrus.ParseLevel(strings.ToLower(newConfig.Logging.Level)); err == nil {
+		r.logger.SetLevel(level)
+	}
+
+	active.Store(newConfig)
+	r.logger.Infof("Configuration reloaded: applied %s", strings.Join(changes, ", "))
+	return true
+}
+
+// Run reloads the configuration on SIGHUP, and when the configuration file is modified, until the context is cancelled.
+// The file is polled rather than watched, so that it also works when the file is replaced (e.g., a mounted ConfigMap).
+func (r *Reloader) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	lastModified := r.fileVersion()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			r.logger.Info("Received SIGHUP, reloading the configuration")
+			lastModified = r.fileVersion()
+			r.Reload()
+		case <-ticker.C:
+			if r.filePath == "" {
+				continue
+			}
+			if modified := r.fileVersion(); modified != lastModified {
+				lastModified = modified
+				r.logger.Infof("Configuration file %s changed, reloading the configuration", r.filePath)
+				r.Reload()
+			}
+		}
+	}
+}
+
+// fileVersion identifies the version of the configuration file by its modification time and size,
+// or returns an empty string if there is no file or it cannot be read.
+func (r *Reloader) fileVersion() string {
+	if r.filePath == "" {
+		return ""
+	}
+	info, err := os.Stat(r.filePath)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+}
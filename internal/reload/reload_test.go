@@ -0,0 +1,64 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+)
+
+// newTestReloader writes a configuration file and returns a reloader of it, with the configuration it started with.
+func newTestReloader(t *testing.T, content string) (*Reloader, *test.Hook, string) {
+	t.Setenv("MASTER_CRYPTO_PASS", "test")
+
+	filePath := filepath.Join(t.TempDir(), "lockbox.conf")
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0o600))
+	initial, err := config.LoadConfig(filePath)
+	require.NoError(t, err)
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.InfoLevel)
+	return NewReloader(initial, filePath, nil, nil, logger), hook, filePath
+}
+
+// TestReloadAppliesLogLevel tests that a change of the log level is applied and becomes the active configuration.
+func TestReloadAppliesLogLevel(t *testing.T) {
+	reloader, hook, filePath := newTestReloader(t, "[logging]\nlevel = info\n")
+	initial := Active()
+
+	require.NoError(t, os.WriteFile(filePath, []byte("[logging]\nlevel = debug\n"), 0o600))
+	assert.True(t, reloader.Reload())
+	assert.Equal(t, logrus.DebugLevel, reloader.logger.GetLevel())
+	assert.Equal(t, "debug", Active().Logging.Level)
+	assert.NotSame(t, initial, Active())
+	assert.Equal(t, "Configuration reloaded: applied logging.level", hook.LastEntry().Message)
+}
+
+// TestReloadRejectsRestartOnlyChanges tests that a reload changing a setting that requires a restart
+// is rejected as a whole, even if it also changes reloadable settings.
+func TestReloadRejectsRestartOnlyChanges(t *testing.T) {
+	reloader, hook, filePath := newTestReloader(t, "[server]\nport = 8080\n")
+	initial := Active()
+
+	require.NoError(t, os.WriteFile(filePath, []byte("[server]\nport = 9000\n\n[logging]\nlevel = debug\n"), 0o600))
+	assert.False(t, reloader.Reload())
+	assert.Equal(t, logrus.InfoLevel, reloader.logger.GetLevel())
+	assert.Same(t, initial, Active())
+	assert.Equal(t, "Configuration reload rejected: changing server.port requires a restart; no changes were applied", hook.LastEntry().Message)
+}
+
+// TestReloadRejectsInvalidConfiguration tests that an invalid configuration is not applied.
+func TestReloadRejectsInvalidConfiguration(t *testing.T) {
+	reloader, hook, filePath := newTestReloader(t, "[logging]\nlevel = info\n")
+	initial := Active()
+
+	require.NoError(t, os.WriteFile(filePath, []byte("[logging]\nlevel = verbose\n"), 0o600))
+	assert.False(t, reloader.Reload())
+	assert.Same(t, initial, Active())
+	assert.Contains(t, hook.LastEntry().Message, "logging.level: must be one of")
+}
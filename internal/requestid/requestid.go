@@ -0,0 +1,44 @@
+// Package requestid carries the ID of an HTTP request through contexts, so that log entries, error responses
+// and database queries can be tied back to the request that caused them.
+package requestid
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header that carries the request ID, both on requests and responses.
+const Header = "X-Request-ID"
+
+// contextKey is the type of the context key, unexported so that no other package can collide with it.
+type contextKey struct{}
+
+// validID matches the request IDs accepted from clients. IDs are written to logs and response headers,
+// so anything else (e.g., control characters, overly long values) is replaced by a generated ID.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// New generates a request ID.
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether a request ID sent by a client can be used as is.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of the context that carries the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by the context, or an empty string if there is none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
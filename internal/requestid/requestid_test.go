@@ -0,0 +1,25 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContextRoundTrip tests that a request ID stored in a context can be read back, and that contexts without one return an empty ID.
+func TestContextRoundTrip(t *testing.T) {
+	ctx := NewContext(context.Background(), "abc-123")
+	assert.Equal(t, "abc-123", FromContext(ctx))
+	assert.Empty(t, FromContext(context.Background()))
+}
+
+// TestValid tests which client-provided request IDs are accepted.
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(New()))
+	assert.True(t, Valid("trace:0af7651916cd43dd.span_1"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("line\nbreak"))
+	assert.False(t, Valid("with space"))
+	assert.False(t, Valid(string(make([]byte, 129))))
+}
@@ -0,0 +1,65 @@
+package rotation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status is the outcome of the last rotation of a secret.
+type Status string
+
+const (
+	// StatusScheduled is the status of a rotation that has not run yet.
+	StatusScheduled Status = "scheduled"
+
+	// StatusSucceeded is the status of a rotation whose last run stored a new value.
+	StatusSucceeded Status = "succeeded"
+
+	// StatusFailed is the status of a rotation whose last run failed. The secret keeps its previous value.
+	StatusFailed Status = "failed"
+)
+
+// Rotation attaches a schedule and a rotator to a secret, so that its value is replaced automatically.
+type Rotation struct {
+	// ID is the unique identifier of the rotation.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// SecretID is the ID of the rotated secret. A secret has at most one rotation, which is deleted with the secret.
+	SecretID uuid.UUID `gorm:"unique;not null"`
+
+	// Schedule is a cron expression (e.g., "0 3 * * *") or an interval (e.g., "@every 24h").
+	Schedule string `gorm:"not null"`
+
+	// Rotator is the name of the rotator that applies new values, such as "random", "postgres" or "webhook".
+	Rotator string `gorm:"not null"`
+
+	// Policy is the name of the generation policy of the new values. The default policy is used when it is empty.
+	Policy string `gorm:"not null;default:''"`
+
+	// Options holds the settings of the rotator (e.g., the URL of a webhook).
+	Options map[string]string `gorm:"serializer:json;not null"`
+
+	// Status is the outcome of the last run.
+	Status Status `gorm:"not null"`
+
+	// LastRunAt is the time of the last run, or nil if the rotation has not run yet.
+	LastRunAt *time.Time
+
+	// LastError is the error of the last run, if it failed.
+	LastError string `gorm:"not null;default:''"`
+
+	// NextRunAt is the time of the next run.
+	NextRunAt time.Time `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the rotation was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the rotation was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Rotation model.
+func (Rotation) TableName() string {
+	return "secret_rotations"
+}
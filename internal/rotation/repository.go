@@ -0,0 +1,121 @@
+package rotation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface defines methods for database interactions related to secret rotations.
+type Repository interface {
+	// Saves a rotation, replacing the settings of the existing rotation of the same secret
+	Save(rotation *Rotation) error
+
+	// Retrieves the rotation of a secret
+	GetBySecretID(secretID uuid.UUID) (*Rotation, error)
+
+	// Deletes the rotation of a secret
+	DeleteBySecretID(secretID uuid.UUID) error
+
+	// Claims rotations that are due, so that other replicas skip them until claimedUntil
+	ClaimDue(now, claimedUntil time.Time, limit int) ([]*Rotation, error)
+
+	// Records the outcome of a run and when to run next
+	RecordRun(id uuid.UUID, status Status, lastRunAt time.Time, lastError string, nextRunAt time.Time) error
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the rotation repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// Save inserts a rotation, or updates the settings and next run of the existing rotation of the same secret.
+// The status and last run of an existing rotation are kept.
+//
+// Parameters:
+// - rotation: The Rotation model.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) Save(rotation *Rotation) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "secret_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"schedule", "rotator", "policy", "options", "next_run_at", "updated_at"}),
+	}).Create(rotation).Error
+}
+
+// GetBySecretID retrieves the rotation of a secret.
+//
+// Parameters:
+// - secretID: The UUID of the rotated secret.
+//
+// Returns:
+// - Rotation: The retrieved Rotation model.
+// - error: Returns an error if the secret has no rotation or if the query fails.
+func (r *repository) GetBySecretID(secretID uuid.UUID) (*Rotation, error) {
+	var rotation *Rotation
+	err := r.db.First(&rotation, "secret_id = ?", secretID).Error
+	return rotation, err
+}
+
+// DeleteBySecretID removes the rotation of a secret.
+//
+// Parameters:
+// - secretID: The UUID of the rotated secret.
+//
+// Returns:
+// - error: Returns an error if the deletion fails, otherwise nil.
+func (r *repository) DeleteBySecretID(secretID uuid.UUID) error {
+	return r.db.Delete(&Rotation{}, "secret_id = ?", secretID).Error
+}
+
+// ClaimDue selects the rotations that are due and pushes their next run back to claimedUntil, in a single statement.
+// Rows locked by another replica are skipped, so every due rotation is claimed by a single replica.
+//
+// Parameters:
+// - now: The current time.
+// - claimedUntil: The time until which the claimed rotations are skipped by other replicas.
+// - limit: The maximum number of rotations to claim.
+//
+// Returns:
+// - []*Rotation: The claimed Rotation models, which may be empty.
+// - error: Returns an error if the query fails.
+func (r *repository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Rotation, error) {
+	var rotations []*Rotation
+	err := r.db.Raw(`
+		UPDATE secret_rotations SET next_run_at = ?
+		WHERE id IN (
+			SELECT id FROM secret_rotations WHERE next_run_at <= ?
+			ORDER BY next_run_at LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, claimedUntil, now, limit).Scan(&rotations).Error
+	return rotations, err
+}
+
+// RecordRun records the outcome of a run.
+//
+// Parameters:
+// - id: The UUID of the rotation.
+// - status: The outcome of the run.
+// - lastRunAt: When the run happened.
+// - lastError: The error of the run, or an empty string if it succeeded.
+// - nextRunAt: When the rotation runs next.
+//
+// Returns:
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) RecordRun(id uuid.UUID, status Status, lastRunAt time.Time, lastError string, nextRunAt time.Time) error {
+	return r.db.Model(&Rotation{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"last_run_at": lastRunAt,
+		"last_error":  lastError,
+		"next_run_at": nextRunAt,
+	}).Error
+}
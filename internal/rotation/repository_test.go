@@ -0,0 +1,143 @@
+package rotation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// Set up the database connection and return the repository instance, with the connection used to create secrets
+func setupTestRepository(t *testing.T) (Repository, *gorm.DB) {
+	db := testdb.Open(t)
+	return NewRepository(db), db
+}
+
+// createTestSecret stores a secret to rotate, which is deleted with its rotation when the test ends.
+func createTestSecret(t *testing.T, db *gorm.DB) uuid.UUID {
+	secret, err := secrets.CreateSecretModel(context.Background(), "test/"+uuid.NewString(), "value", "master-key")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(secret).Error)
+	t.Cleanup(func() {
+		db.Delete(&secrets.Secret{}, "id = ?", secret.ID)
+	})
+	return secret.ID
+}
+
+// TestRepoSaveRotation tests that saving the rotation of a secret again replaces its settings,
+// but keeps its ID, status and last run.
+func TestRepoSaveRotation(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	secretID := createTestSecret(t, db)
+	nextRunAt := time.Date(2030, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	// Save a rotation and record a run of it
+	rotation := &Rotation{
+		ID: uuid.New(), SecretID: secretID, Schedule: "0 3 * * *", Rotator: "webhook",
+		Options: map[string]string{"url": "https://hooks.example.com/a"}, Status: StatusScheduled, NextRunAt: nextRunAt,
+	}
+	require.NoError(t, repo.Save(rotation))
+	require.NoError(t, repo.RecordRun(rotation.ID, StatusFailed, nextRunAt, "webhook returned 500", nextRunAt.Add(time.Hour)))
+
+	// Save new settings under a new ID
+	updated := &Rotation{
+		ID: uuid.New(), SecretID: secretID, Schedule: "@every 24h", Rotator: "webhook", Policy: "strong",
+		Options: map[string]string{"url": "https://hooks.example.com/b"}, Status: StatusScheduled, NextRunAt: nextRunAt.Add(24 * time.Hour),
+	}
+	assert.NoError(t, repo.Save(updated))
+
+	// Assert the settings were replaced and the outcome of the last run was kept
+	stored, err := repo.GetBySecretID(secretID)
+	assert.NoError(t, err)
+	assert.Equal(t, rotation.ID, stored.ID)
+	assert.Equal(t, "@every 24h", stored.Schedule)
+	assert.Equal(t, "strong", stored.Policy)
+	assert.Equal(t, map[string]string{"url": "https://hooks.example.com/b"}, stored.Options)
+	assert.True(t, nextRunAt.Add(24*time.Hour).Equal(stored.NextRunAt))
+	assert.Equal(t, StatusFailed, stored.Status)
+	assert.Equal(t, "webhook returned 500", stored.LastError)
+	if assert.NotNil(t, stored.LastRunAt) {
+		assert.True(t, nextRunAt.Equal(*stored.LastRunAt))
+	}
+}
+
+// TestRepoDeleteRotationWithSecret tests that the rotation of a secret is deleted with the secret.
+func TestRepoDeleteRotationWithSecret(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	secretID := createTestSecret(t, db)
+	require.NoError(t, repo.Save(&Rotation{
+		ID: uuid.New(), SecretID: secretID, Schedule: "@every 1h", Rotator: "random",
+		Options: map[string]string{}, Status: StatusScheduled, NextRunAt: time.Now().UTC(),
+	}))
+
+	require.NoError(t, db.Delete(&secrets.Secret{}, "id = ?", secretID).Error)
+	_, err := repo.GetBySecretID(secretID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+// TestRepoClaimDue tests that only due rotations are claimed, that claiming pushes their next run back,
+// and that concurrent claims never return the same rotation twice.
+func TestRepoClaimDue(t *testing.T) {
+	repo, db := setupTestRepository(t)
+
+	// Use dates far in the past, so that rotations of other tests are not due
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	claimedUntil := now.Add(5 * time.Minute)
+	notDue := uuid.New()
+	require.NoError(t, repo.Save(&Rotation{
+		ID: notDue, SecretID: createTestSecret(t, db), Schedule: "@every 1h", Rotator: "random",
+		Options: map[string]string{}, Status: StatusScheduled, NextRunAt: now.Add(time.Second),
+	}))
+	due := map[uuid.UUID]bool{}
+	for i := 0; i < 20; i++ {
+		rotation := &Rotation{
+			ID: uuid.New(), SecretID: createTestSecret(t, db), Schedule: "@every 1h", Rotator: "random",
+			Options: map[string]string{"length": "32"}, Status: StatusScheduled, NextRunAt: now.Add(-time.Duration(i+1) * time.Minute),
+		}
+		require.NoError(t, repo.Save(rotation))
+		due[rotation.ID] = true
+	}
+
+	// Claim the due rotations from several replicas at once
+	var mutex sync.Mutex
+	claimed := map[uuid.UUID]int{}
+	var wg sync.WaitGroup
+	for replica := 0; replica < 4; replica++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				rotations, err := repo.ClaimDue(now, claimedUntil, 3)
+				if !assert.NoError(t, err) || len(rotations) == 0 {
+					return
+				}
+				mutex.Lock()
+				for _, rotation := range rotations {
+					if due[rotation.ID] {
+						claimed[rotation.ID]++
+						assert.True(t, claimedUntil.Equal(rotation.NextRunAt))
+						assert.Equal(t, map[string]string{"length": "32"}, rotation.Options)
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert every due rotation was claimed exactly once, and the rotation that is not due was left alone
+	assert.Len(t, claimed, len(due))
+	for id, count := range claimed {
+		assert.Equal(t, 1, count, id)
+	}
+	var stored Rotation
+	require.NoError(t, db.First(&stored, "id = ?", notDue).Error)
+	assert.True(t, now.Add(time.Second).Equal(stored.NextRunAt))
+}
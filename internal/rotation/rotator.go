@@ -0,0 +1,157 @@
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RotatorRandom only stores the new value. It suits secrets that are read from Lockbox by whatever uses them.
+	RotatorRandom = "random"
+
+	// RotatorWebhook posts the new value to a local webhook, which applies it before it is stored.
+	RotatorWebhook = "webhook"
+
+	// webhookTimeout is how long a webhook has to apply a new value.
+	webhookTimeout = 30 * time.Second
+)
+
+// Rotator applies the new value of a secret wherever it is used, before the value is stored.
+// A rotation fails, and the secret keeps its previous value, when Rotate returns an error.
+// If the new value cannot be stored, Rotate is called again with the previous value to undo it.
+type Rotator interface {
+	// Validate checks the options of a rotation when it is saved.
+	Validate(options map[string]string) error
+
+	// Rotate applies the new value of the secret with the given key.
+	Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error
+}
+
+// rotators maps the name of each rotator to its implementation.
+var (
+	rotators = map[string]Rotator{
+		RotatorRandom:  randomRotator{},
+		RotatorWebhook: webhookRotator{client: newWebhookClient()},
+	}
+	rotatorsMutex sync.RWMutex
+)
+
+// RegisterRotator registers a rotator under a name, used in the "rotator" field of rotations.
+// Engines register their rotators when they are created, before the scheduler starts.
+func RegisterRotator(name string, rotator Rotator) {
+	rotatorsMutex.Lock()
+	defer rotatorsMutex.Unlock()
+	rotators[name] = rotator
+}
+
+// rotatorFor returns the rotator registered under a name.
+func rotatorFor(name string) (Rotator, error) {
+	rotatorsMutex.RLock()
+	defer rotatorsMutex.RUnlock()
+	rotator, found := rotators[name]
+	if !found {
+		return nil, fmt.Errorf("%w: unknown rotator '%s'", ErrInvalidRotation, name)
+	}
+	return rotator, nil
+}
+
+// CheckOptions returns an error if the options contain a key that is not allowed.
+// Rotators use it to catch misspelled options when a rotation is saved.
+func CheckOptions(options map[string]string, allowed ...string) error {
+	var unknown []string
+	for key := range options {
+		found := false
+		for _, name := range allowed {
+			if key == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// randomRotator generates a new value without applying it anywhere.
+type randomRotator struct{}
+
+func (randomRotator) Validate(options map[string]string) error {
+	return CheckOptions(options)
+}
+
+func (randomRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	return nil
+}
+
+// webhookRotator posts the new value to a webhook running on the same host, such as a sidecar that reloads a service.
+// The request body is {"key": "<secret key>", "value": "<new value>"}, and any status other than 2xx fails the rotation.
+type webhookRotator struct {
+	client *http.Client
+}
+
+// newWebhookClient creates the HTTP client of the webhook rotator.
+// Redirects are not followed, so the new value can never be sent to another host.
+func newWebhookClient() *http.Client {
+	return &http.Client{
+		Timeout: webhookTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Validate requires an http(s) "url" option whose host is a loopback address, since the request carries the secret in clear.
+func (webhookRotator) Validate(options map[string]string) error {
+	if err := CheckOptions(options, "url"); err != nil {
+		return err
+	}
+	webhookURL, err := url.Parse(options["url"])
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") {
+		return fmt.Errorf("option 'url' must be an http or https URL")
+	}
+	host := webhookURL.Hostname()
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("option 'url' must point to localhost or a loopback address")
+	}
+	return nil
+}
+
+func (r webhookRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	body, err := json.Marshal(map[string]string{"key": secretKey, "value": value})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, options["url"], bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package rotation
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// schedulerInterval is how often the scheduler looks for rotations that are due.
+const schedulerInterval = 10 * time.Second
+
+// RunScheduler rotates secrets on their schedule until the context is cancelled.
+// It is meant to run as a background worker of the server, on every replica: rotations are claimed in the database,
+// so each one is run by a single replica. Checks are skipped while the database is not ready.
+func RunScheduler(ctx context.Context, service Service) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if database.State() != database.StateReady {
+			continue
+		}
+		rotated, err := service.RotateDue(os.Getenv("MASTER_CRYPTO_PASS"))
+		if err != nil {
+			global.Logger.Warnf("Rotation scheduler: %v", err)
+		}
+		if rotated > 0 {
+			global.Logger.Infof("Rotation scheduler: rotated %d secrets", rotated)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+const (
+	// claimDuration is how long a rotation claimed by the scheduler is skipped by other replicas.
+	// Rotations are claimed one at a time, right before they run, so it must be longer than a single rotation takes.
+	claimDuration = 5 * time.Minute
+
+	// passSize is the maximum number of rotations run by a single scheduler pass.
+	passSize = 100
+
+	// rotateTimeout is how long a rotator has to apply a new value.
+	rotateTimeout = time.Minute
+
+	// retryDelay is the delay before a failed rotation runs again, unless its schedule comes first.
+	retryDelay = 5 * time.Minute
+)
+
+var (
+	// ErrRotationNotFound is returned when a secret has no rotation.
+	ErrRotationNotFound = errors.New("rotation not found")
+
+	// ErrInvalidRotation is returned when the schedule, the rotator or the options of a rotation are invalid.
+	ErrInvalidRotation = errors.New("invalid rotation")
+)
+
+// defaultPolicy generates the new values of rotations that do not name a generation policy.
+// It only uses letters and digits, so the values can be used anywhere without escaping.
+var defaultPolicy = &secrets.Policy{
+	Type:    secrets.PolicyTypePassword,
+	Length:  32,
+	Classes: map[secrets.CharacterClass]int{secrets.ClassLowercase: 1, secrets.ClassUppercase: 1, secrets.ClassDigits: 1},
+}
+
+// Service interface defines the business logic of scheduled secret rotation.
+// New values are generated with a policy, applied by a rotator, then stored through the secrets service.
+type Service interface {
+	// SaveRotation validates and stores the rotation of a secret, replacing its existing rotation, and schedules its next run.
+	// Returns an error wrapping ErrInvalidRotation if the settings are invalid, or an error if the rotation cannot be stored.
+	SaveRotation(rotation *Rotation) error
+
+	// GetRotation retrieves the rotation of a secret, including the outcome of its last run.
+	// Returns the Rotation or ErrRotationNotFound.
+	GetRotation(secretID uuid.UUID) (*Rotation, error)
+
+	// DeleteRotation stops rotating a secret.
+	// Returns ErrRotationNotFound or an error if the rotation cannot be deleted.
+	DeleteRotation(secretID uuid.UUID) error
+
+	// RotateDue claims and runs the rotations that are due.
+	// Returns the number of successful rotations.
+	RotateDue(masterKey string) (int, error)
+}
+
+type service struct {
+	repo    Repository
+	secrets secrets.Service
+	now     func() time.Time
+}
+
+// NewService creates a new rotation service, which stores new values through the secrets service.
+func NewService(repo Repository, secretsService secrets.Service) Service {
+	return &service{repo: repo, secrets: secretsService, now: time.Now}
+}
+
+// SaveRotation validates a rotation and stores it.
+func (s *service) SaveRotation(rotation *Rotation) error {
+	schedule, err := cron.ParseStandard(rotation.Schedule)
+	if err != nil {
+		return fmt.Errorf("%w: invalid schedule '%s': %v", ErrInvalidRotation, rotation.Schedule, err)
+	}
+	if rotation.Options == nil {
+		rotation.Options = map[string]string{}
+	}
+	rotator, err := rotatorFor(rotation.Rotator)
+	if err != nil {
+		return err
+	}
+	if err := rotator.Validate(rotation.Options); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRotation, err)
+	}
+	if rotation.Policy != "" {
+		if _, err := s.secrets.GetPolicy(context.Background(), rotation.Policy); err != nil {
+			return err
+		}
+	}
+
+	// New values are encrypted by the server, so client-encrypted secrets cannot be rotated
+	secret, err := s.secrets.GetEncryptedSecretByID(context.Background(), rotation.SecretID.String())
+	if err != nil {
+		return err
+	}
+	if secret.ClientEncrypted {
+		return fmt.Errorf("%w: secret '%s': %v", ErrInvalidRotation, secret.Key, secrets.ErrClientEncrypted)
+	}
+
+	rotation.ID = uuid.New()
+	rotation.Status = StatusScheduled
+	rotation.NextRunAt = schedule.Next(s.now().UTC())
+	if err := s.repo.Save(rotation); err != nil {
+		err = fmt.Errorf("failed to store rotation of secret '%s': %v", secret.Key, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Scheduled %s rotation of secret '%s', next run at %s", rotation.Rotator, secret.Key, rotation.NextRunAt.Format(time.RFC3339))
+	return nil
+}
+
+// GetRotation retrieves the rotation of a secret.
+func (s *service) GetRotation(secretID uuid.UUID) (*Rotation, error) {
+	rotation, err := s.repo.GetBySecretID(secretID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: secret '%s'", ErrRotationNotFound, secretID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve rotation of secret '%s': %v", secretID, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return rotation, nil
+}
+
+// DeleteRotation deletes the rotation of a secret.
+func (s *service) DeleteRotation(secretID uuid.UUID) error {
+	if _, err := s.GetRotation(secretID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteBySecretID(secretID); err != nil {
+		err = fmt.Errorf("failed to delete rotation of secret '%s': %v", secretID, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Stopped rotating secret '%s'", secretID)
+	return nil
+}
+
+// RotateDue claims the rotations that are due and runs them. Claimed rotations are skipped by the other replicas.
+// Each rotation is claimed right before it runs, so that its claim cannot run out while earlier rotations of the pass
+// are still running; otherwise another replica could claim it again and rotate the secret at the same time.
+func (s *service) RotateDue(masterKey string) (int, error) {
+	due := s.now().UTC()
+	rotated := 0
+	for i := 0; i < passSize; i++ {
+		rotations, err := s.repo.ClaimDue(due, s.now().UTC().Add(claimDuration), 1)
+		if err != nil {
+			err = fmt.Errorf("failed to claim due rotations: %v", err)
+			global.Logger.Error(err)
+			return rotated, err
+		}
+		if len(rotations) == 0 {
+			break
+		}
+
+		if s.run(rotations[0], masterKey) {
+			rotated++
+		}
+	}
+
+	return rotated, nil
+}
+
+// run rotates a secret and records the outcome. It returns whether the new value was stored.
+func (s *service) run(rotation *Rotation, masterKey string) bool {
+	err := s.rotate(rotation, masterKey)
+
+	now := s.now().UTC()
+	status := StatusSucceeded
+	lastError := ""
+	nextRunAt := now.Add(retryDelay)
+	if schedule, parseErr := cron.ParseStandard(rotation.Schedule); parseErr == nil {
+		if next := schedule.Next(now); err == nil || next.Before(nextRunAt) {
+			nextRunAt = next
+		}
+	}
+	if err != nil {
+		status = StatusFailed
+		lastError = err.Error()
+		global.Logger.Errorf("Failed to rotate secret '%s', next run at %s: %v", rotation.SecretID, nextRunAt.Format(time.RFC3339), err)
+	} else {
+		global.Logger.Infof("Rotated secret '%s', next run at %s", rotation.SecretID, nextRunAt.Format(time.RFC3339))
+	}
+
+	if err := s.repo.RecordRun(rotation.ID, status, now, lastError, nextRunAt); err != nil {
+		global.Logger.Errorf("Failed to record the rotation of secret '%s': %v", rotation.SecretID, err)
+	}
+	return status == StatusSucceeded
+}
+
+// rotate generates a new value, has the rotator apply it, then stores it.
+// The value is applied first so that the stored value is never one that the rotator rejected,
+// and the previous value is applied again if the new one cannot be stored, so the target never holds a value that is not stored.
+func (s *service) rotate(rotation *Rotation, masterKey string) error {
+	ctx := context.Background()
+	secret, err := s.secrets.GetEncryptedSecretByID(ctx, rotation.SecretID.String())
+	if err != nil {
+		return err
+	}
+	rotator, err := rotatorFor(rotation.Rotator)
+	if err != nil {
+		return err
+	}
+	policy := defaultPolicy
+	if rotation.Policy != "" {
+		if policy, err = s.secrets.GetPolicy(ctx, rotation.Policy); err != nil {
+			return err
+		}
+	}
+
+	// Keep the previous value, to apply it again if the new one cannot be stored
+	previousValue, err := s.secrets.DecryptSecret(ctx, *secret, masterKey)
+	if err != nil {
+		return err
+	}
+
+	// Generate the new value
+	value, err := secrets.GenerateValue(policy)
+	if err != nil {
+		return fmt.Errorf("failed to generate a new value: %v", err)
+	}
+
+	// Apply it
+	rotateCtx, cancel := context.WithTimeout(ctx, rotateTimeout)
+	defer cancel()
+	if err := rotator.Rotate(rotateCtx, secret.Key, value, rotation.Options, masterKey); err != nil {
+		return fmt.Errorf("%s rotator failed: %v", rotation.Rotator, err)
+	}
+
+	// Store it. If this fails, apply the previous value again, so the target keeps accepting the stored one.
+	if err := s.secrets.UpdateSecret(ctx, secret.ID.String(), value, masterKey); err != nil {
+		rollbackCtx, cancelRollback := context.WithTimeout(ctx, rotateTimeout)
+		defer cancelRollback()
+		if rollbackErr := rotator.Rotate(rollbackCtx, secret.Key, previousValue, rotation.Options, masterKey); rollbackErr != nil {
+			return fmt.Errorf("the new value was applied but could not be stored (%v), and the previous value could not be applied again: %v", err, rollbackErr)
+		}
+		return fmt.Errorf("the new value could not be stored, so the previous value was applied again: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,409 @@
+package rotation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository whose ClaimDue claims due rotations in no particular order, so that scheduling
+// is tested without a database. Claiming with FOR UPDATE SKIP LOCKED is tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	rotations map[uuid.UUID]*Rotation
+}
+
+func (r *memoryRepository) Save(rotation *Rotation) error {
+	r.rotations[rotation.SecretID] = rotation
+	return nil
+}
+
+func (r *memoryRepository) GetBySecretID(secretID uuid.UUID) (*Rotation, error) {
+	if rotation, found := r.rotations[secretID]; found {
+		return rotation, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) DeleteBySecretID(secretID uuid.UUID) error {
+	delete(r.rotations, secretID)
+	return nil
+}
+
+func (r *memoryRepository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Rotation, error) {
+	var rotations []*Rotation
+	for _, rotation := range r.rotations {
+		if !rotation.NextRunAt.After(now) && len(rotations) < limit {
+			rotation.NextRunAt = claimedUntil
+			rotations = append(rotations, rotation)
+		}
+	}
+	return rotations, nil
+}
+
+func (r *memoryRepository) RecordRun(id uuid.UUID, status Status, lastRunAt time.Time, lastError string, nextRunAt time.Time) error {
+	for _, rotation := range r.rotations {
+		if rotation.ID == id {
+			rotation.Status = status
+			rotation.LastRunAt = &lastRunAt
+			rotation.LastError = lastError
+			rotation.NextRunAt = nextRunAt
+		}
+	}
+	return nil
+}
+
+// memorySecretsRepository is an in-memory secrets.Repository, so the tests use the real secrets service.
+type memorySecretsRepository struct {
+	secrets  map[uuid.UUID]*secrets.Secret
+	policies map[string]*secrets.Policy
+}
+
+func (r *memorySecretsRepository) Save(ctx context.Context, secret *secrets.Secret) error {
+	r.secrets[secret.ID] = secret
+	return nil
+}
+
+func (r *memorySecretsRepository) GetByID(ctx context.Context, secretID uuid.UUID) (*secrets.Secret, error) {
+	if secret, found := r.secrets[secretID]; found {
+		return secret, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memorySecretsRepository) GetByKey(ctx context.Context, key string) (*secrets.Secret, error) {
+	for _, secret := range r.secrets {
+		if secret.Key == key {
+			return secret, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memorySecretsRepository) ListByKeyPrefix(ctx context.Context, prefix string) ([]*secrets.Secret, error) {
+	return nil, nil
+}
+
+func (r *memorySecretsRepository) Update(ctx context.Context, secretID uuid.UUID, newValue string) error {
+	r.secrets[secretID].EncryptedValue = newValue
+	return nil
+}
+
+func (r *memorySecretsRepository) SaveBatch(ctx context.Context, created []*secrets.Secret, updated []*secrets.Secret) error {
+	for _, secret := range created {
+		r.secrets[secret.ID] = secret
+	}
+	for _, secret := range updated {
+		r.secrets[secret.ID].EncryptedValue = secret.EncryptedValue
+	}
+	return nil
+}
+
+func (r *memorySecretsRepository) Delete(ctx context.Context, secretID uuid.UUID) error {
+	delete(r.secrets, secretID)
+	return nil
+}
+
+func (r *memorySecretsRepository) SavePolicy(ctx context.Context, policy *secrets.Policy) error {
+	r.policies[policy.Name] = policy
+	return nil
+}
+
+func (r *memorySecretsRepository) GetPolicy(ctx context.Context, name string) (*secrets.Policy, error) {
+	if policy, found := r.policies[name]; found {
+		return policy, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// failingRotator is a Rotator that fails while fail is true.
+type failingRotator struct {
+	fail *bool
+}
+
+func (r failingRotator) Validate(options map[string]string) error {
+	return CheckOptions(options)
+}
+
+func (r failingRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	if *r.fail {
+		return errors.New("target unreachable")
+	}
+	return nil
+}
+
+// newTestService creates a service backed by in-memory repositories, whose clock is read from now,
+// with a secret "db/password" and a client-encrypted secret "client/token".
+func newTestService(t *testing.T, now *time.Time) (*service, *memoryRepository, secrets.Service, uuid.UUID, uuid.UUID) {
+	global.Logger = logrus.New()
+	repo := &memoryRepository{rotations: map[uuid.UUID]*Rotation{}}
+	secretsService := secrets.NewService(&memorySecretsRepository{secrets: map[uuid.UUID]*secrets.Secret{}, policies: map[string]*secrets.Policy{}})
+
+	secretID, _, err := secretsService.CreateSecret(context.Background(), "db/password", "initial", "master")
+	assert.NoError(t, err)
+	clientID, _, err := secretsService.CreateClientEncryptedSecret(context.Background(), "client/token", "envelope")
+	assert.NoError(t, err)
+
+	service := &service{repo: repo, secrets: secretsService, now: func() time.Time { return *now }}
+	return service, repo, secretsService, uuid.MustParse(secretID), uuid.MustParse(clientID)
+}
+
+// TestServiceSaveRotation tests that the next run follows the schedule, and that invalid rotations are rejected.
+func TestServiceSaveRotation(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	service, repo, secretsService, secretID, clientID := newTestService(t, &now)
+
+	assert.NoError(t, service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "0 3 * * *", Rotator: RotatorRandom}))
+	rotation, err := service.GetRotation(secretID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusScheduled, rotation.Status)
+	assert.Equal(t, time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC), rotation.NextRunAt)
+	assert.NotNil(t, rotation.Options)
+
+	assert.NoError(t, service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorRandom}))
+	assert.Equal(t, now.Add(time.Hour), repo.rotations[secretID].NextRunAt)
+
+	invalid := []*Rotation{
+		{SecretID: secretID, Schedule: "every day", Rotator: RotatorRandom},
+		{SecretID: secretID, Schedule: "@every 1h", Rotator: "ftp"},
+		{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorRandom, Options: map[string]string{"url": "http://localhost"}},
+		{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorWebhook},
+		{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorWebhook, Options: map[string]string{"url": "https://example.com/rotate"}},
+		{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorWebhook, Options: map[string]string{"url": "ftp://127.0.0.1/rotate"}},
+		{SecretID: clientID, Schedule: "@every 1h", Rotator: RotatorRandom},
+	}
+	for _, rotation := range invalid {
+		assert.ErrorIs(t, service.SaveRotation(rotation), ErrInvalidRotation, rotation.Schedule, rotation.Rotator)
+	}
+	err = service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "@every 1h", Rotator: RotatorRandom, Policy: "missing"})
+	assert.ErrorIs(t, err, secrets.ErrPolicyNotFound)
+
+	assert.NoError(t, service.SaveRotation(&Rotation{
+		SecretID: secretID,
+		Schedule: "@every 1h",
+		Rotator:  RotatorWebhook,
+		Options:  map[string]string{"url": "http://127.0.0.1:8080/rotate"},
+	}))
+
+	assert.NoError(t, service.DeleteRotation(secretID))
+	assert.ErrorIs(t, service.DeleteRotation(secretID), ErrRotationNotFound)
+	_, err = service.GetRotation(secretID)
+	assert.ErrorIs(t, err, ErrRotationNotFound)
+
+	// The secret is untouched
+	secret, err := secretsService.GetEncryptedSecretByID(context.Background(), secretID.String())
+	assert.NoError(t, err)
+	value, err := secretsService.DecryptSecret(context.Background(), *secret, "master")
+	assert.NoError(t, err)
+	assert.Equal(t, "initial", value)
+}
+
+// TestServiceRotateDue tests that due rotations store a new value, and that failed rotations keep the old one and are retried.
+func TestServiceRotateDue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service, repo, secretsService, secretID, _ := newTestService(t, &now)
+	fail := true
+	RegisterRotator("test", failingRotator{fail: &fail})
+	t.Cleanup(func() {
+		rotatorsMutex.Lock()
+		defer rotatorsMutex.Unlock()
+		delete(rotators, "test")
+	})
+
+	valueOf := func() string {
+		secret, err := secretsService.GetEncryptedSecretByID(context.Background(), secretID.String())
+		assert.NoError(t, err)
+		value, err := secretsService.DecryptSecret(context.Background(), *secret, "master")
+		assert.NoError(t, err)
+		return value
+	}
+
+	assert.NoError(t, service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "@every 24h", Rotator: "test"}))
+
+	// Nothing is due yet
+	rotated, err := service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rotated)
+
+	// A failed rotation keeps the old value and is retried before the next scheduled run
+	now = now.Add(24 * time.Hour)
+	rotated, err = service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rotated)
+	rotation := repo.rotations[secretID]
+	assert.Equal(t, StatusFailed, rotation.Status)
+	assert.Contains(t, rotation.LastError, "target unreachable")
+	assert.Equal(t, now, *rotation.LastRunAt)
+	assert.Equal(t, now.Add(retryDelay), rotation.NextRunAt)
+	assert.Equal(t, "initial", valueOf())
+
+	// Once the rotator succeeds, the new value is stored and the next run follows the schedule
+	now = now.Add(retryDelay)
+	fail = false
+	rotated, err = service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rotated)
+	assert.Equal(t, StatusSucceeded, rotation.Status)
+	assert.Empty(t, rotation.LastError)
+	assert.Equal(t, now.Add(24*time.Hour), rotation.NextRunAt)
+	value := valueOf()
+	assert.Len(t, value, 32)
+	assert.NotEqual(t, "initial", value)
+
+	// New values follow the policy of the rotation
+	assert.NoError(t, secretsService.SavePolicy(context.Background(), &secrets.Policy{Name: "hex", Type: secrets.PolicyTypeHex, Length: 8}))
+	assert.NoError(t, service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "@every 24h", Rotator: "test", Policy: "hex"}))
+	now = now.Add(24 * time.Hour)
+	rotated, err = service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rotated)
+	assert.Len(t, valueOf(), 16)
+}
+
+// recordingRotator is a Rotator that records every value it applies.
+type recordingRotator struct {
+	applied *[]string
+}
+
+func (r recordingRotator) Validate(options map[string]string) error {
+	return CheckOptions(options)
+}
+
+func (r recordingRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	*r.applied = append(*r.applied, value)
+	return nil
+}
+
+// failingUpdateService is a secrets.Service that cannot store new values.
+type failingUpdateService struct {
+	secrets.Service
+}
+
+func (s failingUpdateService) UpdateSecret(ctx context.Context, secretID, plainTextSecret, masterKey string) error {
+	return errors.New("database unavailable")
+}
+
+// TestServiceRotateDueRollsBackUnstoredValue tests that a new value that cannot be stored is undone by applying the previous value again.
+func TestServiceRotateDueRollsBackUnstoredValue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service, repo, secretsService, secretID, _ := newTestService(t, &now)
+	service.secrets = failingUpdateService{Service: secretsService}
+	var applied []string
+	RegisterRotator("recording", recordingRotator{applied: &applied})
+	t.Cleanup(func() {
+		rotatorsMutex.Lock()
+		defer rotatorsMutex.Unlock()
+		delete(rotators, "recording")
+	})
+
+	assert.NoError(t, service.SaveRotation(&Rotation{SecretID: secretID, Schedule: "@every 24h", Rotator: "recording"}))
+	now = now.Add(24 * time.Hour)
+	rotated, err := service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rotated)
+
+	// The new value was applied, then undone
+	rotation := repo.rotations[secretID]
+	assert.Equal(t, StatusFailed, rotation.Status)
+	assert.Contains(t, rotation.LastError, "database unavailable")
+	assert.Len(t, applied, 2)
+	assert.NotEqual(t, "initial", applied[0])
+	assert.Equal(t, "initial", applied[1])
+}
+
+// slowRotator is a Rotator that advances the clock of the test service by duration, and records when each rotation ends.
+type slowRotator struct {
+	now      *time.Time
+	duration time.Duration
+	ends     *[]time.Time
+}
+
+func (r slowRotator) Validate(options map[string]string) error {
+	return CheckOptions(options)
+}
+
+func (r slowRotator) Rotate(ctx context.Context, secretKey, value string, options map[string]string, masterKey string) error {
+	*r.now = r.now.Add(r.duration)
+	*r.ends = append(*r.ends, *r.now)
+	return nil
+}
+
+// claimRecordingRepository records until when each claimed rotation is skipped by other replicas.
+type claimRecordingRepository struct {
+	*memoryRepository
+	claims []time.Time
+}
+
+func (r *claimRecordingRepository) ClaimDue(now, claimedUntil time.Time, limit int) ([]*Rotation, error) {
+	rotations, err := r.memoryRepository.ClaimDue(now, claimedUntil, limit)
+	for range rotations {
+		r.claims = append(r.claims, claimedUntil)
+	}
+	return rotations, err
+}
+
+// TestServiceRotateDueClaimsCoverEachRun tests that no rotation runs after its claim has run out,
+// even when the rotations of a pass take longer together than a claim lasts.
+func TestServiceRotateDueClaimsCoverEachRun(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service, repo, secretsService, secretID, _ := newTestService(t, &now)
+	recorder := &claimRecordingRepository{memoryRepository: repo}
+	service.repo = recorder
+	var ends []time.Time
+	RegisterRotator("slow", slowRotator{now: &now, duration: 4 * time.Minute, ends: &ends})
+	t.Cleanup(func() {
+		rotatorsMutex.Lock()
+		defer rotatorsMutex.Unlock()
+		delete(rotators, "slow")
+	})
+
+	otherID, _, err := secretsService.CreateSecret(context.Background(), "api/token", "initial", "master")
+	assert.NoError(t, err)
+	for _, id := range []uuid.UUID{secretID, uuid.MustParse(otherID)} {
+		assert.NoError(t, service.SaveRotation(&Rotation{SecretID: id, Schedule: "@every 1h", Rotator: "slow"}))
+	}
+
+	now = now.Add(time.Hour)
+	rotated, err := service.RotateDue("master")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+	assert.Len(t, recorder.claims, 2)
+	for i, end := range ends {
+		assert.True(t, recorder.claims[i].After(end), "rotation %d ended at %s, after its claim ran out at %s", i, end, recorder.claims[i])
+	}
+}
+
+// TestWebhookRotator tests that the webhook receives the key and new value, and that errors fail the rotation.
+func TestWebhookRotator(t *testing.T) {
+	var received map[string]string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	rotator := webhookRotator{client: newWebhookClient()}
+	options := map[string]string{"url": server.URL}
+	assert.NoError(t, rotator.Validate(options))
+
+	assert.NoError(t, rotator.Rotate(context.Background(), "db/password", "new-value", options, "master"))
+	assert.Equal(t, map[string]string{"key": "db/password", "value": "new-value"}, received)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, rotator.Rotate(context.Background(), "db/password", "new-value", options, "master"))
+
+	// Redirects are not followed
+	status = http.StatusFound
+	assert.Error(t, rotator.Rotate(context.Background(), "db/password", "new-value", options, "master"))
+}
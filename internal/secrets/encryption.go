@@ -1,16 +1,25 @@
 package secrets
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
 	"io"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/metrics"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// cryptoAlgorithm is recorded on the spans of encryptions and decryptions. Nothing else about the data is recorded.
+var cryptoAlgorithm = attribute.String("lockbox.crypto.algorithm", "aes-256-gcm")
+
 // Encrypt encrypts the provided plain-text secret using AES-256 GCM (Galois/Counter Mode) encryption.
 // AES-256 GCM is a secure symmetric encryption algorithm that provides both confidentiality and integrity.
 //
@@ -29,33 +38,12 @@ import (
 // 4. The plainText is encrypted using AES-256 GCM, and the result (cipherText) is combined with the nonce.
 // 5. The nonce and encrypted secret are returned as a hex-encoded string.
 func Encrypt(plainText, masterKey string) (string, error) {
-	// Create a new AES cipher block using the hashed master key
-	block, err := aes.NewCipher(createHash(masterKey))
+	// Encrypt the plaintext with the hashed master key
+	encryptedSecret, err := EncryptWithKey(createHash(masterKey), []byte(plainText), nil)
 	if err != nil {
-		err := fmt.Errorf("failed to create cipher: %v", err)
-		global.Logger.Error(err)
 		return "", err
 	}
 
-	// Initialize GCM mode for AES encryption
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		err = fmt.Errorf("failed to create GCM: %v", err)
-		global.Logger.Error(err)
-		return "", err
-	}
-
-	// Generate a random nonce for this encryption operation
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		err = fmt.Errorf("failed to generate nonce: %v", err)
-		global.Logger.Error(err)
-		return "", err
-	}
-
-	// Encrypt the plaintext using AES-GCM, sealing the nonce and plaintext together
-	encryptedSecret := aesGCM.Seal(nonce, nonce, []byte(plainText), nil)
-
 	// Return the result as a hex-encoded string
 	return hex.EncodeToString(encryptedSecret), nil
 }
@@ -83,15 +71,110 @@ func Decrypt(encryptedSecretHex, masterKey string) (string, error) {
 	if err != nil {
 		err = fmt.Errorf("failed to decode encrypted secret: %v", err)
 		global.Logger.Error(err)
+		metrics.ObserveCrypto(metrics.OperationDecrypt, err)
+		return "", err
+	}
+
+	// Decrypt the secret with the hashed master key
+	decryptedSecret, err := DecryptWithKey(createHash(masterKey), encryptedSecret, nil)
+	if err != nil {
 		return "", err
 	}
 
-	// Create a new AES cipher block using the hashed master key
-	block, err := aes.NewCipher(createHash(masterKey))
+	// Return the decrypted plain-text secret
+	return string(decryptedSecret), nil
+}
+
+// EncryptContext is Encrypt, traced as a child span of the context (e.g., of the request being served).
+func EncryptContext(ctx context.Context, plainText, masterKey string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "secrets.Encrypt")
+	defer span.End()
+	span.SetAttributes(cryptoAlgorithm)
+
+	encryptedSecret, err := Encrypt(plainText, masterKey)
+	if err != nil {
+		span.SetStatus(codes.Error, "encryption failed")
+	}
+	return encryptedSecret, err
+}
+
+// DecryptContext is Decrypt, traced as a child span of the context (e.g., of the request being served).
+func DecryptContext(ctx context.Context, encryptedSecretHex, masterKey string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "secrets.Decrypt")
+	defer span.End()
+	span.SetAttributes(cryptoAlgorithm)
+
+	decryptedSecret, err := Decrypt(encryptedSecretHex, masterKey)
+	if err != nil {
+		span.SetStatus(codes.Error, "decryption failed")
+	}
+	return decryptedSecret, err
+}
+
+// EncryptWithKey encrypts data with AES-256 GCM using a raw 32-byte key.
+// It is the building block of Encrypt, and is used directly by features that manage their own keys
+// (such as transit keys or data keys) rather than deriving them from the master passphrase.
+//
+// Parameters:
+// - key: The 32-byte AES-256 key.
+// - plainText: The data to encrypt.
+// - additionalData: Optional data that is authenticated but not encrypted (may be nil).
+//
+// Returns:
+// - The nonce followed by the encrypted data and the GCM authentication tag.
+// - An error if encryption fails at any step.
+func EncryptWithKey(key, plainText, additionalData []byte) (encrypted []byte, err error) {
+	// Count the operation and whether it failed
+	defer func() { metrics.ObserveCrypto(metrics.OperationEncrypt, err) }()
+
+	// Create a new AES cipher block using the key
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err := fmt.Errorf("failed to create cipher: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	// Initialize GCM mode for AES encryption
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		err = fmt.Errorf("failed to create GCM: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	// Generate a random nonce for this encryption operation
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		err = fmt.Errorf("failed to generate nonce: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	// Encrypt the plaintext using AES-GCM, sealing the nonce and plaintext together
+	return aesGCM.Seal(nonce, nonce, plainText, additionalData), nil
+}
+
+// DecryptWithKey decrypts data produced by EncryptWithKey with the same raw 32-byte key.
+//
+// Parameters:
+// - key: The 32-byte AES-256 key.
+// - encrypted: The nonce followed by the encrypted data, as returned by EncryptWithKey.
+// - additionalData: The same additional data that was given to EncryptWithKey (may be nil).
+//
+// Returns:
+// - The decrypted data.
+// - An error if decryption fails, either due to an incorrect key, tampering, or malformed input.
+func DecryptWithKey(key, encrypted, additionalData []byte) (decrypted []byte, err error) {
+	// Count the operation and whether it failed
+	defer func() { metrics.ObserveCrypto(metrics.OperationDecrypt, err) }()
+
+	// Create a new AES cipher block using the key
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		err = fmt.Errorf("failed to create cipher: %v", err)
 		global.Logger.Error(err)
-		return "", err
+		return nil, err
 	}
 
 	// Initialize GCM mode for AES decryption
@@ -99,23 +182,27 @@ func Decrypt(encryptedSecretHex, masterKey string) (string, error) {
 	if err != nil {
 		err = fmt.Errorf("failed to create GCM: %v", err)
 		global.Logger.Error(err)
-		return "", err
+		return nil, err
 	}
 
 	// Extract the nonce from the encrypted data (the first part is the nonce)
 	nonceSize := aesGCM.NonceSize()
-	nonce, cipherText := encryptedSecret[:nonceSize], encryptedSecret[nonceSize:]
+	if len(encrypted) < nonceSize+aesGCM.Overhead() {
+		err = fmt.Errorf("failed to decrypt secret: encrypted data is too short")
+		global.Logger.Error(err)
+		return nil, err
+	}
+	nonce, cipherText := encrypted[:nonceSize], encrypted[nonceSize:]
 
 	// Decrypt the cipherText using the nonce and AES-GCM
-	decryptedSecret, err := aesGCM.Open(nil, nonce, cipherText, nil)
+	decrypted, err = aesGCM.Open(nil, nonce, cipherText, additionalData)
 	if err != nil {
 		err = fmt.Errorf("failed to decrypt secret: %v", err)
 		global.Logger.Error(err)
-		return "", err
+		return nil, err
 	}
 
-	// Return the decrypted plain-text secret
-	return string(decryptedSecret), nil
+	return decrypted, nil
 }
 
 // createHash generates a SHA-256 hash of the provided master key (passphrase).
@@ -0,0 +1,40 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// TestEncryptDecrypt tests that a secret encrypted with the master key can be decrypted with it.
+func TestEncryptDecrypt(t *testing.T) {
+	global.Logger = logrus.New()
+
+	encrypted, err := Encrypt(testPlainTextSecret, testMasterKey)
+	assert.NoError(t, err)
+	assert.NotContains(t, encrypted, testPlainTextSecret)
+
+	decrypted, err := Decrypt(encrypted, testMasterKey)
+	assert.NoError(t, err)
+	assert.Equal(t, testPlainTextSecret, decrypted)
+}
+
+// TestDecryptWithKeyNegative tests decrypting with the wrong additional data and truncated input.
+func TestDecryptWithKeyNegative(t *testing.T) {
+	global.Logger = logrus.New()
+	key := createHash(testMasterKey)
+
+	encrypted, err := EncryptWithKey(key, []byte(testPlainTextSecret), []byte("context"))
+	assert.NoError(t, err)
+
+	_, err = DecryptWithKey(key, encrypted, []byte("other-context"))
+	assert.Error(t, err)
+
+	_, err = DecryptWithKey(key, encrypted[:5], []byte("context"))
+	assert.Error(t, err)
+
+	_, err = Decrypt("", testMasterKey)
+	assert.Error(t, err)
+}
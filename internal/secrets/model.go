@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -24,6 +25,11 @@ type Secret struct {
 	// and the encrypted result is stored as a string in this field.
 	EncryptedValue string `gorm:"not null"`
 
+	// ClientEncrypted indicates that EncryptedValue is an opaque envelope encrypted by the client with its own key.
+	// Such secrets are stored and returned exactly as sent: the server never encrypts or decrypts them,
+	// so their content stays unreadable even to operators holding the master key.
+	ClientEncrypted bool `gorm:"not null;default:false"`
+
 	// CreatedAt stores the timestamp of when the secret was created.
 	// This field is automatically populated by GORM when a new record is inserted into the database.
 	CreatedAt time.Time `gorm:"autoCreateTime"`
@@ -36,18 +42,19 @@ type Secret struct {
 // CreateSecretModel encrypts the provided plain text secret and returns the model
 //
 // Parameters:
+// - ctx: The context of the request, under which the encryption is traced.
 // - plainText: The sensitive data (e.g., API key, password) that needs to be encrypted and stored.
 // - masterKey: The passphrase used to encrypt the secret.
 //
 // Returns:
 // - The created Secret model.
 // - An error if anything goes wrong during the encryption.
-func CreateSecretModel(key, plainTextSecret, masterKey string) (*Secret, error) {
+func CreateSecretModel(ctx context.Context, key, plainTextSecret, masterKey string) (*Secret, error) {
 	// Encrypt the plainText using the provided masterKey
-	encryptedValue, err := Encrypt(plainTextSecret, masterKey)
+	encryptedValue, err := EncryptContext(ctx, plainTextSecret, masterKey)
 	if err != nil {
 		err = fmt.Errorf("failed to encrypt secret: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return nil, err
 	}
 
@@ -61,3 +68,21 @@ func CreateSecretModel(key, plainTextSecret, masterKey string) (*Secret, error)
 	// Return the created secret model
 	return secret, nil
 }
+
+// CreateClientEncryptedSecretModel returns the model of a secret whose value was encrypted by the client.
+// The envelope is stored exactly as provided, without any server-side encryption.
+//
+// Parameters:
+// - key: The unique key of the secret.
+// - envelope: The opaque, client-encrypted value.
+//
+// Returns:
+// - The created Secret model.
+func CreateClientEncryptedSecretModel(key, envelope string) *Secret {
+	return &Secret{
+		ID:              uuid.New(), // Generate a new UUID for the secret
+		Key:             key,        // Store the key as a plain text
+		EncryptedValue:  envelope,   // Store the envelope as sent by the client
+		ClientEncrypted: true,
+	}
+}
@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -17,7 +18,7 @@ var (
 // TestCreateSecretModelSuccess tests successful creation of the Secret model.
 func TestCreateSecretModel(t *testing.T) {
 	// Create Secrets model
-	secret, err := CreateSecretModel(testKey, testPlainTextSecret, testMasterKey)
+	secret, err := CreateSecretModel(context.Background(), testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 	assert.NotNil(t, secret)
 
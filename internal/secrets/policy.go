@@ -0,0 +1,286 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sethvargo/go-diceware/diceware"
+)
+
+// PolicyType identifies the kind of value generated by a policy.
+type PolicyType string
+
+const (
+	// PolicyTypePassword generates a password from character classes.
+	PolicyTypePassword PolicyType = "password"
+
+	// PolicyTypePassphrase generates a passphrase of words from the EFF large word list.
+	PolicyTypePassphrase PolicyType = "passphrase"
+
+	// PolicyTypeHex generates random bytes, encoded as hexadecimal.
+	PolicyTypeHex PolicyType = "hex"
+
+	// PolicyTypeBase64 generates random bytes, encoded as standard base64.
+	PolicyTypeBase64 PolicyType = "base64"
+)
+
+// CharacterClass identifies a set of characters that passwords can be made of.
+type CharacterClass string
+
+const (
+	// ClassLowercase is the set of lowercase ASCII letters.
+	ClassLowercase CharacterClass = "lowercase"
+
+	// ClassUppercase is the set of uppercase ASCII letters.
+	ClassUppercase CharacterClass = "uppercase"
+
+	// ClassDigits is the set of decimal digits.
+	ClassDigits CharacterClass = "digits"
+
+	// ClassSymbols is a set of punctuation characters. Quotes, backslashes and spaces are left out so passwords can be pasted in shells and config files.
+	ClassSymbols CharacterClass = "symbols"
+)
+
+// characterClasses maps each character class to its characters.
+var characterClasses = map[CharacterClass]string{
+	ClassLowercase: "abcdefghijklmnopqrstuvwxyz",
+	ClassUppercase: "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	ClassDigits:    "0123456789",
+	ClassSymbols:   "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// ambiguousCharacters are removed from every class when a policy excludes ambiguous characters.
+const ambiguousCharacters = "Il1|O0o"
+
+const (
+	// maxPasswordLength is the longest password, in characters, that a policy can generate.
+	maxPasswordLength = 1024
+
+	// maxPassphraseWords is the largest number of words in a passphrase.
+	maxPassphraseWords = 64
+
+	// maxRandomBytes is the largest number of random bytes generated by hex and base64 policies.
+	maxRandomBytes = 1024
+
+	// defaultSeparator joins the words of a passphrase when the policy does not set a separator.
+	defaultSeparator = "-"
+)
+
+var (
+	// ErrPolicyNotFound is returned when no generation policy exists with the requested name.
+	ErrPolicyNotFound = errors.New("policy not found")
+
+	// ErrInvalidPolicy is returned when the name or the settings of a generation policy are invalid.
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// policyNamePattern restricts policy names to characters that are safe in URLs and logs.
+var policyNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// Policy defines how the values of generated secrets are created.
+type Policy struct {
+	// ID is the unique identifier of the policy.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name of the policy, used in the "generate" field of POST /secrets.
+	Name string `gorm:"unique;not null"`
+
+	// Type is the kind of value generated by the policy.
+	Type PolicyType `gorm:"not null"`
+
+	// Length is the number of characters of a password, the number of words of a passphrase,
+	// or the number of random bytes of a hex or base64 value.
+	Length int `gorm:"not null"`
+
+	// Classes maps each character class a password is made of to the minimum number of characters of that class.
+	// Only used by password policies.
+	Classes map[CharacterClass]int `gorm:"serializer:json;not null"`
+
+	// ExcludeAmbiguous removes characters that are easily confused when read (e.g., "l", "1" and "I") from passwords.
+	ExcludeAmbiguous bool `gorm:"not null;default:false"`
+
+	// Separator joins the words of a passphrase. Only used by passphrase policies.
+	Separator string `gorm:"not null;default:''"`
+
+	// CreatedAt stores the timestamp of when the policy was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the policy was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Policy model.
+func (Policy) TableName() string {
+	return "secret_policies"
+}
+
+// validatePolicy checks the settings of a policy and fills in the defaults of the ones that are not set.
+func validatePolicy(policy *Policy) error {
+	if !policyNamePattern.MatchString(policy.Name) {
+		return fmt.Errorf("%w: invalid name '%s'", ErrInvalidPolicy, policy.Name)
+	}
+
+	switch policy.Type {
+	case PolicyTypePassword:
+		if policy.Length < 1 || policy.Length > maxPasswordLength {
+			return fmt.Errorf("%w: length must be between 1 and %d characters", ErrInvalidPolicy, maxPasswordLength)
+		}
+		if len(policy.Classes) == 0 {
+			return fmt.Errorf("%w: at least one character class is required", ErrInvalidPolicy)
+		}
+		minimum := 0
+		for class, count := range policy.Classes {
+			if _, found := characterClasses[class]; !found {
+				return fmt.Errorf("%w: unknown character class '%s'", ErrInvalidPolicy, class)
+			}
+			if count < 0 {
+				return fmt.Errorf("%w: minimum count of '%s' cannot be negative", ErrInvalidPolicy, class)
+			}
+			minimum += count
+		}
+		if minimum > policy.Length {
+			return fmt.Errorf("%w: minimum counts add up to %d characters, more than the length of %d", ErrInvalidPolicy, minimum, policy.Length)
+		}
+		if policy.Separator != "" {
+			return fmt.Errorf("%w: separator only applies to passphrase policies", ErrInvalidPolicy)
+		}
+	case PolicyTypePassphrase:
+		if policy.Length < 1 || policy.Length > maxPassphraseWords {
+			return fmt.Errorf("%w: length must be between 1 and %d words", ErrInvalidPolicy, maxPassphraseWords)
+		}
+		if len(policy.Classes) > 0 || policy.ExcludeAmbiguous {
+			return fmt.Errorf("%w: character classes only apply to password policies", ErrInvalidPolicy)
+		}
+		if policy.Separator == "" {
+			policy.Separator = defaultSeparator
+		}
+	case PolicyTypeHex, PolicyTypeBase64:
+		if policy.Length < 1 || policy.Length > maxRandomBytes {
+			return fmt.Errorf("%w: length must be between 1 and %d bytes", ErrInvalidPolicy, maxRandomBytes)
+		}
+		if len(policy.Classes) > 0 || policy.ExcludeAmbiguous {
+			return fmt.Errorf("%w: character classes only apply to password policies", ErrInvalidPolicy)
+		}
+		if policy.Separator != "" {
+			return fmt.Errorf("%w: separator only applies to passphrase policies", ErrInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type '%s'", ErrInvalidPolicy, policy.Type)
+	}
+
+	if policy.Classes == nil {
+		policy.Classes = map[CharacterClass]int{}
+	}
+	return nil
+}
+
+// GenerateValue creates a new random value following the policy. All randomness comes from crypto/rand.
+//
+// Parameters:
+// - policy: A policy that was validated when it was saved.
+//
+// Returns:
+// - The generated value.
+// - An error if the policy is invalid or the system random number generator fails.
+func GenerateValue(policy *Policy) (string, error) {
+	switch policy.Type {
+	case PolicyTypePassword:
+		return generatePassword(policy)
+	case PolicyTypePassphrase:
+		generator, err := diceware.NewGenerator(nil)
+		if err != nil {
+			return "", err
+		}
+		words, err := generator.Generate(policy.Length)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(words, policy.Separator), nil
+	case PolicyTypeHex, PolicyTypeBase64:
+		bytes := make([]byte, policy.Length)
+		if _, err := rand.Read(bytes); err != nil {
+			return "", err
+		}
+		if policy.Type == PolicyTypeHex {
+			return hex.EncodeToString(bytes), nil
+		}
+		return base64.StdEncoding.EncodeToString(bytes), nil
+	default:
+		return "", fmt.Errorf("%w: unsupported type '%s'", ErrInvalidPolicy, policy.Type)
+	}
+}
+
+// generatePassword picks the minimum number of characters of each class, fills the rest of the password
+// from all the classes, then shuffles the result so the required characters are not at predictable positions.
+func generatePassword(policy *Policy) (string, error) {
+	// Sort the classes so the policy is applied in a stable order
+	classes := make([]CharacterClass, 0, len(policy.Classes))
+	for class := range policy.Classes {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+
+	password := make([]rune, 0, policy.Length)
+	var all []rune
+	for _, class := range classes {
+		characters := classCharacters(class, policy.ExcludeAmbiguous)
+		all = append(all, characters...)
+		for i := 0; i < policy.Classes[class]; i++ {
+			character, err := randomRune(characters)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, character)
+		}
+	}
+	for len(password) < policy.Length {
+		character, err := randomRune(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, character)
+	}
+
+	// Fisher-Yates shuffle
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+// classCharacters returns the characters of a class, without the ambiguous ones if requested.
+func classCharacters(class CharacterClass, excludeAmbiguous bool) []rune {
+	characters := characterClasses[class]
+	if excludeAmbiguous {
+		characters = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(ambiguousCharacters, r) {
+				return -1
+			}
+			return r
+		}, characters)
+	}
+	return []rune(characters)
+}
+
+// randomRune picks a character uniformly at random.
+func randomRune(characters []rune) (rune, error) {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(len(characters))))
+	if err != nil {
+		return 0, err
+	}
+	return characters[index.Int64()], nil
+}
@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGenerateValuePassword tests that passwords have the requested length and minimum counts per class.
+func TestGenerateValuePassword(t *testing.T) {
+	policy := &Policy{
+		Name:   "strong",
+		Type:   PolicyTypePassword,
+		Length: 12,
+		Classes: map[CharacterClass]int{
+			ClassLowercase: 0,
+			ClassUppercase: 3,
+			ClassDigits:    3,
+			ClassSymbols:   3,
+		},
+		ExcludeAmbiguous: true,
+	}
+	assert.NoError(t, validatePolicy(policy))
+
+	for i := 0; i < 50; i++ {
+		password, err := GenerateValue(policy)
+		assert.NoError(t, err)
+		assert.Len(t, password, 12)
+
+		counts := map[CharacterClass]int{}
+		for _, r := range password {
+			assert.NotContains(t, ambiguousCharacters, string(r))
+			for class, characters := range characterClasses {
+				if strings.ContainsRune(characters, r) {
+					counts[class]++
+				}
+			}
+		}
+		assert.GreaterOrEqual(t, counts[ClassUppercase], 3)
+		assert.GreaterOrEqual(t, counts[ClassDigits], 3)
+		assert.GreaterOrEqual(t, counts[ClassSymbols], 3)
+	}
+}
+
+// TestGenerateValuePassphrase tests that passphrases have the requested number of words and the default separator.
+func TestGenerateValuePassphrase(t *testing.T) {
+	policy := &Policy{Name: "words", Type: PolicyTypePassphrase, Length: 6}
+	assert.NoError(t, validatePolicy(policy))
+	assert.Equal(t, defaultSeparator, policy.Separator)
+
+	// A few words of the list contain the default separator, so count the words with a space instead
+	policy.Separator = " "
+	passphrase, err := GenerateValue(policy)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(passphrase, " "), 6)
+}
+
+// TestGenerateValueBytes tests the hex and base64 encodings of random bytes.
+func TestGenerateValueBytes(t *testing.T) {
+	value, err := GenerateValue(&Policy{Type: PolicyTypeHex, Length: 32})
+	assert.NoError(t, err)
+	decoded, err := hex.DecodeString(value)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 32)
+
+	value, err = GenerateValue(&Policy{Type: PolicyTypeBase64, Length: 32})
+	assert.NoError(t, err)
+	decoded, err = base64.StdEncoding.DecodeString(value)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 32)
+
+	// Two values are never the same
+	other, err := GenerateValue(&Policy{Type: PolicyTypeBase64, Length: 32})
+	assert.NoError(t, err)
+	assert.NotEqual(t, value, other)
+}
+
+// TestValidatePolicyNegative tests that inconsistent policies are rejected.
+func TestValidatePolicyNegative(t *testing.T) {
+	policies := []*Policy{
+		{Name: "bad name!", Type: PolicyTypeHex, Length: 16},
+		{Name: "unknown", Type: "uuid", Length: 16},
+		{Name: "empty", Type: PolicyTypePassword, Length: 16},
+		{Name: "class", Type: PolicyTypePassword, Length: 16, Classes: map[CharacterClass]int{"emoji": 1}},
+		{Name: "negative", Type: PolicyTypePassword, Length: 16, Classes: map[CharacterClass]int{ClassDigits: -1}},
+		{Name: "too-many", Type: PolicyTypePassword, Length: 4, Classes: map[CharacterClass]int{ClassDigits: 3, ClassSymbols: 3}},
+		{Name: "long", Type: PolicyTypePassword, Length: maxPasswordLength + 1, Classes: map[CharacterClass]int{ClassDigits: 0}},
+		{Name: "words", Type: PolicyTypePassphrase, Length: maxPassphraseWords + 1},
+		{Name: "words-classes", Type: PolicyTypePassphrase, Length: 6, Classes: map[CharacterClass]int{ClassDigits: 1}},
+		{Name: "hex-separator", Type: PolicyTypeHex, Length: 16, Separator: ":"},
+		{Name: "zero", Type: PolicyTypeBase64, Length: 0},
+	}
+	for _, policy := range policies {
+		assert.ErrorIs(t, validatePolicy(policy), ErrInvalidPolicy, policy.Name)
+	}
+}
@@ -1,27 +1,44 @@
 package secrets
 
 import (
+	"context"
+	"strings"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository interface defines methods for database interactions related to secrets.
 // It encapsulates basic CRUD operations for managing secrets in the database.
+// Every query is bound to the given context, so it is cancelled along with the request that caused it.
 type Repository interface {
 	// Saves a new secret to the database
-	Save(secret *Secret) error
+	Save(ctx context.Context, secret *Secret) error
 
 	// Retrieves a secret by its UUID
-	GetByID(secretID uuid.UUID) (*Secret, error)
+	GetByID(ctx context.Context, secretID uuid.UUID) (*Secret, error)
 
 	// Retrieves a secret by its key
-	GetByKey(key string) (*Secret, error)
+	GetByKey(ctx context.Context, key string) (*Secret, error)
+
+	// Retrieves all secrets whose key starts with the given prefix
+	ListByKeyPrefix(ctx context.Context, prefix string) ([]*Secret, error)
 
 	// Updates the encrypted value of a secret
-	Update(secretID uuid.UUID, newValue string) error
+	Update(ctx context.Context, secretID uuid.UUID, newValue string) error
+
+	// Saves new secrets and updates the encrypted values of existing ones in a single transaction
+	SaveBatch(ctx context.Context, created []*Secret, updated []*Secret) error
 
 	// Deletes a secret from the database by its UUID
-	Delete(secretID uuid.UUID) error
+	Delete(ctx context.Context, secretID uuid.UUID) error
+
+	// Saves a generation policy, replacing the existing policy with the same name
+	SavePolicy(ctx context.Context, policy *Policy) error
+
+	// Retrieves a generation policy by its name
+	GetPolicy(ctx context.Context, name string) (*Policy, error)
 }
 
 type repository struct {
@@ -38,26 +55,28 @@ func NewRepository(db *gorm.DB) Repository {
 // It takes a Secret object that contains the ID, encrypted value, and timestamps.
 //
 // Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
 // - secret: The Secret model containing the encrypted data to be stored.
 //
 // Returns:
 // - error: Returns an error if the insertion fails, otherwise nil.
-func (r *repository) Save(secret *Secret) error {
-	return r.db.Create(secret).Error
+func (r *repository) Save(ctx context.Context, secret *Secret) error {
+	return r.db.WithContext(ctx).Create(secret).Error
 }
 
 // GetByID retrieves a secret from the database by its UUID.
 // It searches for a secret with the given UUID and returns it if found.
 //
 // Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
 // - secretID: The UUID of the secret to retrieve.
 //
 // Returns:
 // - Secret: The retrieved Secret model.
 // - error: Returns an error if no secret with the given ID is found or if the query fails.
-func (r *repository) GetByID(secretID uuid.UUID) (*Secret, error) {
+func (r *repository) GetByID(ctx context.Context, secretID uuid.UUID) (*Secret, error) {
 	var secret *Secret
-	err := r.db.First(&secret, "id = ?", secretID).Error
+	err := r.db.WithContext(ctx).First(&secret, "id = ?", secretID).Error
 	return secret, err
 }
 
@@ -65,38 +84,116 @@ func (r *repository) GetByID(secretID uuid.UUID) (*Secret, error) {
 // It searches for a secret with the given key and returns it if found.
 //
 // Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
 // - key: The key of the secret to retrieve.
 //
 // Returns:
 // - Secret: The retrieved Secret model.
 // - error: Returns an error if no secret with the given ID is found or if the query fails.
-func (r *repository) GetByKey(key string) (*Secret, error) {
+func (r *repository) GetByKey(ctx context.Context, key string) (*Secret, error) {
 	var secret *Secret
-	err := r.db.First(&secret, "key = ?", key).Error
+	err := r.db.WithContext(ctx).First(&secret, "key = ?", key).Error
 	return secret, err
 }
 
+// ListByKeyPrefix retrieves all secrets whose key starts with the given prefix, ordered by key.
+// The LIKE wildcards (% and _) in the prefix are escaped so they are matched literally.
+//
+// Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
+// - prefix: The key prefix to match (e.g., "app/production/").
+//
+// Returns:
+// - []*Secret: The matching Secret models, which may be empty.
+// - error: Returns an error if the query fails.
+func (r *repository) ListByKeyPrefix(ctx context.Context, prefix string) ([]*Secret, error) {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	var secrets []*Secret
+	err := r.db.WithContext(ctx).Where(`key LIKE ? ESCAPE '\'`, escaper.Replace(prefix)+"%").Order("key").Find(&secrets).Error
+	return secrets, err
+}
+
 // Update modifies the encrypted value of an existing secret.
 // It looks up the secret by its UUID and updates its EncryptedValue field.
 //
 // Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
 // - secretID: The UUID of the secret to update.
 // - newValue: The new encrypted value that will replace the old one.
 //
 // Returns:
 // - error: Returns an error if the update fails, otherwise nil.
-func (r *repository) Update(secretID uuid.UUID, newValue string) error {
-	return r.db.Model(&Secret{}).Where("id = ?", secretID).Update("encrypted_value", newValue).Error
+func (r *repository) Update(ctx context.Context, secretID uuid.UUID, newValue string) error {
+	return r.db.WithContext(ctx).Model(&Secret{}).Where("id = ?", secretID).Update("encrypted_value", newValue).Error
+}
+
+// SaveBatch inserts new secrets and updates the encrypted values of existing ones in a single transaction.
+// If any write fails, the transaction is rolled back and none of the secrets are changed.
+//
+// Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
+// - created: The Secret models to insert.
+// - updated: The existing secrets, identified by ID, whose encrypted values should be replaced.
+//
+// Returns:
+// - error: Returns an error if any write fails, otherwise nil.
+func (r *repository) SaveBatch(ctx context.Context, created []*Secret, updated []*Secret) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, secret := range created {
+			if err := tx.Create(secret).Error; err != nil {
+				return err
+			}
+		}
+		for _, secret := range updated {
+			if err := tx.Model(&Secret{}).Where("id = ?", secret.ID).Update("encrypted_value", secret.EncryptedValue).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // Delete removes a secret from the database by its UUID.
 // It performs a hard delete of the record identified by the given UUID.
 //
 // Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
 // - secretID: The UUID of the secret to delete.
 //
 // Returns:
 // - error: Returns an error if the deletion fails, otherwise nil.
-func (r *repository) Delete(secretID uuid.UUID) error {
-	return r.db.Delete(&Secret{}, "id = ?", secretID).Error
+func (r *repository) Delete(ctx context.Context, secretID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&Secret{}, "id = ?", secretID).Error
+}
+
+// SavePolicy inserts a generation policy, or updates every setting of the existing policy with the same name.
+//
+// Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
+// - policy: The Policy model.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) SavePolicy(ctx context.Context, policy *Policy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"type", "length", "classes", "exclude_ambiguous", "separator", "updated_at",
+		}),
+	}).Create(policy).Error
+}
+
+// GetPolicy retrieves a generation policy by its name.
+//
+// Parameters:
+// - ctx: The context of the request, which cancels the query when it is done.
+// - name: The name of the policy to retrieve.
+//
+// Returns:
+// - Policy: The retrieved Policy model.
+// - error: Returns an error if no policy with the given name is found or if the query fails.
+func (r *repository) GetPolicy(ctx context.Context, name string) (*Policy, error) {
+	var policy *Policy
+	err := r.db.WithContext(ctx).First(&policy, "name = ?", name).Error
+	return policy, err
 }
@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -44,6 +45,7 @@ func setupTestRepository(t *testing.T) Repository {
 
 // TestRepoSaveSecret tests saving a new secret in the database.
 func TestRepoSaveSecret(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create a new secret
@@ -54,16 +56,17 @@ func TestRepoSaveSecret(t *testing.T) {
 	}
 
 	// Save the secret
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Clean up
-	repo.Delete(secret.ID)
+	repo.Delete(ctx, secret.ID)
 }
 
 // TestRepoNegativeSaveSecretDuplicatedKey tests saving a secret
 // with a duplicated key
 func TestRepoNegativeSaveSecretDuplicatedKey(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create a new secret
@@ -74,20 +77,21 @@ func TestRepoNegativeSaveSecretDuplicatedKey(t *testing.T) {
 	}
 
 	// Save the secret
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Create the same secret again
-	err = repo.Save(secret)
+	err = repo.Save(ctx, secret)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "duplicate key value violates unique constraint")
 
 	// Clean up
-	repo.Delete(secret.ID)
+	repo.Delete(ctx, secret.ID)
 }
 
 // TestRepoGetByID tests retrieving a secret by its UUID.
 func TestRepoGetByID(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create and save a new secret
@@ -96,22 +100,23 @@ func TestRepoGetByID(t *testing.T) {
 		Key:            "test_TestRepoGetByID",
 		EncryptedValue: "test_encrypted_value",
 	}
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Retrieve the secret by its UUID
-	retrievedSecret, err := repo.GetByID(secret.ID)
+	retrievedSecret, err := repo.GetByID(ctx, secret.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, retrievedSecret)
 	assert.Equal(t, secret.ID, retrievedSecret.ID)
 	assert.Equal(t, secret.Key, retrievedSecret.Key)
 
 	// Clean up
-	repo.Delete(secret.ID)
+	repo.Delete(ctx, secret.ID)
 }
 
 // TestRepoGetByKey tests retrieving a secret by its key.
 func TestRepoGetByKey(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create and save a new secret
@@ -120,21 +125,22 @@ func TestRepoGetByKey(t *testing.T) {
 		Key:            "test_TestRepoGetByKey",
 		EncryptedValue: "test_encrypted_value",
 	}
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Retrieve the secret by its key
-	retrievedSecret, err := repo.GetByKey(secret.Key)
+	retrievedSecret, err := repo.GetByKey(ctx, secret.Key)
 	assert.NoError(t, err)
 	assert.NotNil(t, retrievedSecret)
 	assert.Equal(t, secret.Key, retrievedSecret.Key)
 
 	// Clean up
-	repo.Delete(secret.ID)
+	repo.Delete(ctx, secret.ID)
 }
 
 // TestRepoUpdateSecret tests updating an existing secret.
 func TestRepoUpdateSecret(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create and save a new secret
@@ -143,25 +149,26 @@ func TestRepoUpdateSecret(t *testing.T) {
 		Key:            "test_TestRepoUpdateSecret",
 		EncryptedValue: "test_encrypted_value",
 	}
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Update the secret's encrypted value
 	newEncryptedValue := "updated_encrypted_value"
-	err = repo.Update(secret.ID, newEncryptedValue)
+	err = repo.Update(ctx, secret.ID, newEncryptedValue)
 	assert.NoError(t, err)
 
 	// Retrieve and check the updated value
-	updatedSecret, err := repo.GetByID(secret.ID)
+	updatedSecret, err := repo.GetByID(ctx, secret.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, newEncryptedValue, updatedSecret.EncryptedValue)
 
 	// Clean up
-	repo.Delete(secret.ID)
+	repo.Delete(ctx, secret.ID)
 }
 
 // TestRepoDeleteSecret tests deleting a secret from the database.
 func TestRepoDeleteSecret(t *testing.T) {
+	ctx := context.Background()
 	repo := setupTestRepository(t)
 
 	// Create and save a new secret
@@ -170,15 +177,49 @@ func TestRepoDeleteSecret(t *testing.T) {
 		Key:            "test_TestRepoDeleteSecret",
 		EncryptedValue: "test_encrypted_value",
 	}
-	err := repo.Save(secret)
+	err := repo.Save(ctx, secret)
 	assert.NoError(t, err)
 
 	// Delete the secret
-	err = repo.Delete(secret.ID)
+	err = repo.Delete(ctx, secret.ID)
 	assert.NoError(t, err)
 
 	// Attempt to retrieve the deleted secret (should return an error)
-	_, err = repo.GetByID(secret.ID)
+	_, err = repo.GetByID(ctx, secret.ID)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not found")
 }
+
+// TestRepoNegativeSaveBatchRollsBack tests that a failed batch leaves every secret unchanged.
+func TestRepoNegativeSaveBatchRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := setupTestRepository(t)
+
+	// Create and save an existing secret
+	existing := &Secret{
+		ID:             uuid.New(),
+		Key:            "test_TestRepoNegativeSaveBatchRollsBack",
+		EncryptedValue: "test_encrypted_value",
+	}
+	err := repo.Save(ctx, existing)
+	assert.NoError(t, err)
+
+	// Save a batch whose second secret duplicates the key of the first
+	created := []*Secret{
+		{ID: uuid.New(), Key: existing.Key + "/new", EncryptedValue: "test_encrypted_value"},
+		{ID: uuid.New(), Key: existing.Key + "/new", EncryptedValue: "test_encrypted_value"},
+	}
+	updated := []*Secret{{ID: existing.ID, EncryptedValue: "updated_encrypted_value"}}
+	err = repo.SaveBatch(ctx, created, updated)
+	assert.Error(t, err)
+
+	// Assert nothing was written
+	_, err = repo.GetByID(ctx, created[0].ID)
+	assert.Error(t, err)
+	retrievedSecret, err := repo.GetByID(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_encrypted_value", retrievedSecret.EncryptedValue)
+
+	// Clean up
+	repo.Delete(ctx, existing.ID)
+}
@@ -1,43 +1,90 @@
 package secrets
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/taint"
+	"gorm.io/gorm"
 )
 
 // Service interface defines the business logic for handling secrets.
+// Every method takes the context of the request it serves: it is passed down to the database queries,
+// and the log entries written for the request are tagged with its request ID.
 type Service interface {
 	// CreateSecret encrypts the plainTextSecret using the provided masterKey and stores it in the database.
 	// The secret is identified by a unique key for easy retrieval.
 	// Returns the key or an error if something goes wrong.
-	CreateSecret(key, plainTextSecret, masterKey string) (string, string, error)
+	CreateSecret(ctx context.Context, key, plainTextSecret, masterKey string) (string, string, error)
+
+	// CreateClientEncryptedSecret stores a value that was encrypted by the client, exactly as sent.
+	// The server does not encrypt it and cannot decrypt it.
+	// Returns the ID and key of the created secret, or an error if something goes wrong.
+	CreateClientEncryptedSecret(ctx context.Context, key, envelope string) (string, string, error)
 
 	// GetEncryptedSecretByID retrieves an encrypted secret from the database using its UUID.
 	// Decryption is deferred until the caller specifically requests it.
 	// Returns the Secret model or an error if something goes wrong.
-	GetEncryptedSecretByID(secretID string) (*Secret, error)
+	GetEncryptedSecretByID(ctx context.Context, secretID string) (*Secret, error)
 
 	// GetEncryptedSecretByKey retrieves an encrypted secret from the database using its unique Key.
 	// Decryption is deferred until the caller specifically requests it.
 	// Returns the Secret model or an error if something goes wrong.
-	GetEncryptedSecretByKey(key string) (*Secret, error)
+	GetEncryptedSecretByKey(ctx context.Context, key string) (*Secret, error)
 
 	// DecryptSecret decrypts the EncryptedValue of the Secret using the provided masterKey.
+	// Client-encrypted secrets cannot be decrypted by the server, so their envelope is returned unchanged.
+	// The plaintext is tainted in the context, so that it is scrubbed from the log entries of the request.
 	// Returns the decrypted secret or an error if decryption fails.
-	DecryptSecret(secret Secret, masterKey string) (string, error)
+	DecryptSecret(ctx context.Context, secret Secret, masterKey string) (string, error)
 
 	// UpdateSecret updates the encrypted value of an existing secret using its unique key.
 	// It re-encrypts the provided plainTextSecret and stores the new value in the database.
-	// Returns an error if the update fails.
-	UpdateSecret(secretID, plainTextSecret, masterKey string) error
+	// Returns an error if the update fails or if the secret is client-encrypted.
+	UpdateSecret(ctx context.Context, secretID, plainTextSecret, masterKey string) error
+
+	// UpdateClientEncryptedSecret replaces the envelope of an existing client-encrypted secret.
+	// Returns an error if the update fails or if the secret is encrypted by the server.
+	UpdateClientEncryptedSecret(ctx context.Context, secretID, envelope string) error
 
 	// DeleteSecret deletes a secret from the database by its UUID.
 	// Returns an error if deletion fails.
-	DeleteSecret(secretID string) error
+	DeleteSecret(ctx context.Context, secretID string) error
+
+	// ImportSecrets encrypts and stores every value under the given key prefix.
+	// Keys that already exist are handled according to the conflict policy.
+	// Returns a summary of the created, updated and skipped keys, or an error if the import fails, in which case nothing is written.
+	ImportSecrets(ctx context.Context, prefix string, values map[string]string, policy ConflictPolicy, masterKey string) (*ImportResult, error)
+
+	// ExportSecrets decrypts every secret whose key starts with the given prefix.
+	// Returns a map of keys (without the prefix) to plain-text values, or an error if any secret cannot be decrypted.
+	// Returns ErrClientEncrypted, listing their keys, if any of the secrets is client-encrypted.
+	ExportSecrets(ctx context.Context, prefix, masterKey string) (map[string]string, error)
+
+	// SavePolicy validates and stores a generation policy, replacing any existing policy with the same name.
+	// Returns an error if the policy is invalid or cannot be stored.
+	SavePolicy(ctx context.Context, policy *Policy) error
+
+	// GetPolicy retrieves a generation policy by name.
+	// Returns the Policy or ErrPolicyNotFound.
+	GetPolicy(ctx context.Context, name string) (*Policy, error)
+
+	// GenerateSecret creates a value following the named policy, then encrypts and stores it like CreateSecret.
+	// Returns the ID, key and generated value of the secret, or an error if something goes wrong.
+	GenerateSecret(ctx context.Context, key, policyName, masterKey string) (string, string, string, error)
 }
 
+// ErrClientEncrypted is returned when a server-side operation, such as re-encrypting a new value, is attempted on a client-encrypted secret.
+var ErrClientEncrypted = errors.New("secret is client-encrypted")
+
+// ErrServerEncrypted is returned when a client-encrypted envelope is written to a secret that is encrypted by the server.
+var ErrServerEncrypted = errors.New("secret is not client-encrypted")
+
 type service struct {
 	repo Repository
 }
@@ -50,19 +97,35 @@ func NewService(repo Repository) Service {
 // CreateSecret encrypts a secret and stores it in the database.
 // This function takes a key (used to identify the secret), the plain-text secret, and the masterKey for encryption.
 // Returns the key of the created secret or an error if something goes wrong.
-func (s *service) CreateSecret(key, plainTextSecret, masterKey string) (string, string, error) {
+func (s *service) CreateSecret(ctx context.Context, key, plainTextSecret, masterKey string) (string, string, error) {
 	// Create the Secret model
-	secret, err := CreateSecretModel(key, plainTextSecret, masterKey)
+	secret, err := CreateSecretModel(ctx, key, plainTextSecret, masterKey)
 	if err != nil {
 		err = fmt.Errorf("failed to create secret: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return "", "", err
 	}
 
 	// Save the secret in the repository
-	if err := s.repo.Save(secret); err != nil {
+	if err := s.repo.Save(ctx, secret); err != nil {
 		err = fmt.Errorf("failed to store secret in the database: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
+		return "", "", err
+	}
+
+	return secret.ID.String(), secret.Key, nil
+}
+
+// CreateClientEncryptedSecret stores a client-encrypted envelope in the database without encrypting it.
+// Returns the ID and key of the created secret or an error if something goes wrong.
+func (s *service) CreateClientEncryptedSecret(ctx context.Context, key, envelope string) (string, string, error) {
+	// Create the Secret model
+	secret := CreateClientEncryptedSecretModel(key, envelope)
+
+	// Save the secret in the repository
+	if err := s.repo.Save(ctx, secret); err != nil {
+		err = fmt.Errorf("failed to store secret in the database: %v", err)
+		global.Logger.WithContext(ctx).Error(err)
 		return "", "", err
 	}
 
@@ -70,20 +133,20 @@ func (s *service) CreateSecret(key, plainTextSecret, masterKey string) (string,
 }
 
 // GetEncryptedSecretByID retrieves an encrypted secret from the database using its UUID.
-func (s *service) GetEncryptedSecretByID(secretID string) (*Secret, error) {
+func (s *service) GetEncryptedSecretByID(ctx context.Context, secretID string) (*Secret, error) {
 	// Convert the string ID to a UUID
 	parserSecretID, err := uuid.Parse(secretID)
 	if err != nil {
 		err = fmt.Errorf("invalid UUID format: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return &Secret{}, err
 	}
 
 	// Retrieve the encrypted secret from the repository
-	secret, err := s.repo.GetByID(parserSecretID)
+	secret, err := s.repo.GetByID(ctx, parserSecretID)
 	if err != nil {
 		err = fmt.Errorf("failed to retrieve secret by ID '%s': %v", parserSecretID, err)
-		global.Logger.Debug(err)
+		global.Logger.WithContext(ctx).Debug(err)
 		return &Secret{}, err
 	}
 
@@ -91,12 +154,12 @@ func (s *service) GetEncryptedSecretByID(secretID string) (*Secret, error) {
 }
 
 // GetEncryptedSecretByKey retrieves an encrypted secret from the database using its unique Key.
-func (s *service) GetEncryptedSecretByKey(key string) (*Secret, error) {
+func (s *service) GetEncryptedSecretByKey(ctx context.Context, key string) (*Secret, error) {
 	// Retrieve the encrypted secret from the repository using its unique key
-	secret, err := s.repo.GetByKey(key)
+	secret, err := s.repo.GetByKey(ctx, key)
 	if err != nil {
 		err = fmt.Errorf("failed to retrieve secret by key '%s': %v", key, err)
-		global.Logger.Debug(err)
+		global.Logger.WithContext(ctx).Debug(err)
 		return &Secret{}, err
 	}
 
@@ -104,41 +167,76 @@ func (s *service) GetEncryptedSecretByKey(key string) (*Secret, error) {
 }
 
 // DecryptSecret decrypts the EncryptedValue of the Secret using the provided masterKey.
-func (s *service) DecryptSecret(secret Secret, masterKey string) (string, error) {
+// Client-encrypted secrets are returned as stored, since only the client holds their key.
+func (s *service) DecryptSecret(ctx context.Context, secret Secret, masterKey string) (string, error) {
+	// The server cannot decrypt client-encrypted envelopes
+	if secret.ClientEncrypted {
+		return secret.EncryptedValue, nil
+	}
+
 	// Decrypt the secret using the masterKey
-	decryptedValue, err := Decrypt(secret.EncryptedValue, masterKey)
+	decryptedValue, err := DecryptContext(ctx, secret.EncryptedValue, masterKey)
 	if err != nil {
 		err = fmt.Errorf("failed to decrypt secret: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return "", err
 	}
 
+	// Taint the plaintext, so that it is scrubbed from anything logged for the request
+	taint.Add(ctx, decryptedValue)
+
 	return decryptedValue, nil
 }
 
 // UpdateSecret updates the encrypted value of an existing secret.
 // It re-encrypts the provided plainTextSecret and updates the secret in the database using the unique key.
-func (s *service) UpdateSecret(secretID, plainTextSecret, masterKey string) error {
-	// Convert the string ID to a UUID
-	parserSecretID, err := uuid.Parse(secretID)
+func (s *service) UpdateSecret(ctx context.Context, secretID, plainTextSecret, masterKey string) error {
+	// Make sure the secret is encrypted by the server
+	secret, err := s.GetEncryptedSecretByID(ctx, secretID)
 	if err != nil {
-		err = fmt.Errorf("invalid UUID format: %v", err)
-		global.Logger.Error(err)
+		return err
+	}
+	if secret.ClientEncrypted {
+		err = fmt.Errorf("secret '%s': %w", secret.Key, ErrClientEncrypted)
+		global.Logger.WithContext(ctx).Debug(err)
 		return err
 	}
 
 	// Encrypt the new plain-text secret
-	encryptedValue, err := Encrypt(plainTextSecret, masterKey)
+	encryptedValue, err := EncryptContext(ctx, plainTextSecret, masterKey)
 	if err != nil {
 		err = fmt.Errorf("failed to encrypt secret: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return err
 	}
 
 	// Update the secret in the repository using its unique key
-	if err := s.repo.Update(parserSecretID, encryptedValue); err != nil {
+	if err := s.repo.Update(ctx, secret.ID, encryptedValue); err != nil {
 		err = fmt.Errorf("failed to update secret: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateClientEncryptedSecret replaces the envelope of an existing client-encrypted secret, storing it exactly as sent.
+func (s *service) UpdateClientEncryptedSecret(ctx context.Context, secretID, envelope string) error {
+	// Make sure the secret is encrypted by the client
+	secret, err := s.GetEncryptedSecretByID(ctx, secretID)
+	if err != nil {
+		return err
+	}
+	if !secret.ClientEncrypted {
+		err = fmt.Errorf("secret '%s': %w", secret.Key, ErrServerEncrypted)
+		global.Logger.WithContext(ctx).Debug(err)
+		return err
+	}
+
+	// Update the secret in the repository using its UUID
+	if err := s.repo.Update(ctx, secret.ID, envelope); err != nil {
+		err = fmt.Errorf("failed to update secret: %v", err)
+		global.Logger.WithContext(ctx).Error(err)
 		return err
 	}
 
@@ -146,21 +244,200 @@ func (s *service) UpdateSecret(secretID, plainTextSecret, masterKey string) erro
 }
 
 // DeleteSecretByID deletes a secret from the database using its UUID.
-func (s *service) DeleteSecret(secretID string) error {
+func (s *service) DeleteSecret(ctx context.Context, secretID string) error {
 	// Convert the string ID to a UUID
 	parserSecretID, err := uuid.Parse(secretID)
 	if err != nil {
 		err = fmt.Errorf("invalid UUID format: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
 		return err
 	}
 
 	// Delete the secret from the repository using its UUID
-	if err := s.repo.Delete(parserSecretID); err != nil {
+	if err := s.repo.Delete(ctx, parserSecretID); err != nil {
 		err = fmt.Errorf("failed to delete secret by ID: %v", err)
-		global.Logger.Error(err)
+		global.Logger.WithContext(ctx).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// ImportSecrets encrypts and stores a set of secrets under a common key prefix.
+// All writes happen in a single transaction, so a failed import leaves the database untouched.
+func (s *service) ImportSecrets(ctx context.Context, prefix string, values map[string]string, policy ConflictPolicy, masterKey string) (*ImportResult, error) {
+	// Find which of the imported keys already exist
+	existingSecrets, err := s.repo.ListByKeyPrefix(ctx, prefix)
+	if err != nil {
+		err = fmt.Errorf("failed to list secrets with prefix '%s': %v", prefix, err)
+		global.Logger.WithContext(ctx).Error(err)
+		return nil, err
+	}
+	existing := make(map[string]*Secret, len(existingSecrets))
+	for _, secret := range existingSecrets {
+		existing[secret.Key] = secret
+	}
+
+	// Refuse the whole import if conflicts are not allowed
+	if policy == ConflictFail {
+		var conflicts []string
+		for _, key := range sortedKeys(values) {
+			if _, found := existing[prefix+key]; found {
+				conflicts = append(conflicts, prefix+key)
+			}
+		}
+		if len(conflicts) > 0 {
+			err := fmt.Errorf("%w: %s", ErrImportConflict, strings.Join(conflicts, ", "))
+			global.Logger.WithContext(ctx).Debug(err)
+			return nil, err
+		}
+	}
+
+	// Client-encrypted secrets can only be replaced with client-provided envelopes, so refuse to overwrite them
+	if policy == ConflictOverwrite {
+		for _, key := range sortedKeys(values) {
+			if secret, found := existing[prefix+key]; found && secret.ClientEncrypted {
+				err := fmt.Errorf("cannot overwrite '%s': %w", secret.Key, ErrClientEncrypted)
+				global.Logger.WithContext(ctx).Debug(err)
+				return nil, err
+			}
+		}
+	}
+
+	// Encrypt every value, in key order, before anything is written
+	result := &ImportResult{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+	var created, updated []*Secret
+	for _, key := range sortedKeys(values) {
+		fullKey := prefix + key
+
+		if secret, found := existing[fullKey]; found {
+			if policy != ConflictOverwrite {
+				result.Skipped = append(result.Skipped, fullKey)
+				continue
+			}
+			encryptedValue, err := EncryptContext(ctx, values[key], masterKey)
+			if err != nil {
+				err = fmt.Errorf("failed to encrypt secret '%s': %v", fullKey, err)
+				global.Logger.WithContext(ctx).Error(err)
+				return nil, err
+			}
+			updated = append(updated, &Secret{ID: secret.ID, Key: fullKey, EncryptedValue: encryptedValue})
+			result.Updated = append(result.Updated, fullKey)
+			continue
+		}
+
+		secret, err := CreateSecretModel(ctx, fullKey, values[key], masterKey)
+		if err != nil {
+			err = fmt.Errorf("failed to create secret '%s': %v", fullKey, err)
+			global.Logger.WithContext(ctx).Error(err)
+			return nil, err
+		}
+		created = append(created, secret)
+		result.Created = append(result.Created, fullKey)
+	}
+
+	// Store everything in a single transaction, so the import either fully applies or has no effect
+	if err := s.repo.SaveBatch(ctx, created, updated); err != nil {
+		err = fmt.Errorf("failed to store imported secrets in the database: %v", err)
+		global.Logger.WithContext(ctx).Error(err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExportSecrets decrypts every secret stored under the given key prefix.
+// The prefix is stripped from the returned keys so that an export can be re-imported under a different prefix.
+func (s *service) ExportSecrets(ctx context.Context, prefix, masterKey string) (map[string]string, error) {
+	// Retrieve all secrets under the prefix
+	secrets, err := s.repo.ListByKeyPrefix(ctx, prefix)
+	if err != nil {
+		err = fmt.Errorf("failed to list secrets with prefix '%s': %v", prefix, err)
+		global.Logger.WithContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Client-encrypted secrets cannot be decrypted by the server, so refuse the export rather than writing their envelopes as values
+	var clientEncrypted []string
+	for _, secret := range secrets {
+		if secret.ClientEncrypted {
+			clientEncrypted = append(clientEncrypted, secret.Key)
+		}
+	}
+	if len(clientEncrypted) > 0 {
+		sort.Strings(clientEncrypted)
+		err := fmt.Errorf("cannot export %s: %w", strings.Join(clientEncrypted, ", "), ErrClientEncrypted)
+		global.Logger.WithContext(ctx).Debug(err)
+		return nil, err
+	}
+
+	// Decrypt each secret
+	values := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		decryptedValue, err := s.DecryptSecret(ctx, *secret, masterKey)
+		if err != nil {
+			return nil, err
+		}
+		values[strings.TrimPrefix(secret.Key, prefix)] = decryptedValue
+	}
+
+	return values, nil
+}
+
+// SavePolicy validates a generation policy and stores it.
+func (s *service) SavePolicy(ctx context.Context, policy *Policy) error {
+	if err := validatePolicy(policy); err != nil {
+		return err
+	}
+
+	policy.ID = uuid.New()
+	if err := s.repo.SavePolicy(ctx, policy); err != nil {
+		err = fmt.Errorf("failed to store policy '%s': %v", policy.Name, err)
+		global.Logger.WithContext(ctx).Error(err)
 		return err
 	}
 
+	global.Logger.WithContext(ctx).Infof("Saved %s generation policy '%s'", policy.Type, policy.Name)
 	return nil
 }
+
+// GetPolicy retrieves a generation policy by name.
+func (s *service) GetPolicy(ctx context.Context, name string) (*Policy, error) {
+	policy, err := s.repo.GetPolicy(ctx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrPolicyNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve policy '%s': %v", name, err)
+		global.Logger.WithContext(ctx).Error(err)
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// GenerateSecret creates a value with the named policy and stores it as a new secret.
+// The value is returned to the caller but never logged.
+func (s *service) GenerateSecret(ctx context.Context, key, policyName, masterKey string) (string, string, string, error) {
+	policy, err := s.GetPolicy(ctx, policyName)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// Generate the value
+	value, err := GenerateValue(policy)
+	if err != nil {
+		err = fmt.Errorf("failed to generate a value with policy '%s': %v", policy.Name, err)
+		global.Logger.WithContext(ctx).Error(err)
+		return "", "", "", err
+	}
+
+	// Encrypt and store it
+	secretID, secretKey, err := s.CreateSecret(ctx, key, value, masterKey)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	global.Logger.WithContext(ctx).Infof("Generated secret '%s' with policy '%s'", secretKey, policy.Name)
+	return secretID, secretKey, value, nil
+}
@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -22,30 +23,32 @@ func setupTestService(t *testing.T) Service {
 
 // TestServiceCreateSecret tests the CreateSecret method
 func TestServiceCreateSecret(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 	global.Logger = logrus.New()
 
 	// Create the Secret object
-	id, key, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, key, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.Equal(t, testKey, key)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceGetEncryptedSecretByID tests GetEncryptedSecretByID
 func TestServiceGetEncryptedSecretByID(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 
 	// Create the Secret object
-	id, key, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, key, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Get the secret by the ID
-	retrievedSecret, err := service.GetEncryptedSecretByID(id)
+	retrievedSecret, err := service.GetEncryptedSecretByID(ctx, id)
 
 	// Assert
 	assert.NoError(t, err)
@@ -54,19 +57,20 @@ func TestServiceGetEncryptedSecretByID(t *testing.T) {
 	assert.Equal(t, key, retrievedSecret.Key)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceGetEncryptedSecretByKey tests GetEncryptedSecretByID
 func TestServiceGetEncryptedSecretByKey(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 
 	// Create the Secret object
-	id, key, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, key, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Get the secret by the ID
-	retrievedSecret, err := service.GetEncryptedSecretByKey(key)
+	retrievedSecret, err := service.GetEncryptedSecretByKey(ctx, key)
 
 	// Assert
 	assert.NoError(t, err)
@@ -75,92 +79,173 @@ func TestServiceGetEncryptedSecretByKey(t *testing.T) {
 	assert.Equal(t, key, retrievedSecret.Key)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceDecryptSecret tests the DecryptSecret method
 func TestServiceDecryptSecret(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 
 	// Create the Secret object
-	id, key, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, key, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Get the secret by the ID
-	retrievedSecret, err := service.GetEncryptedSecretByKey(key)
+	retrievedSecret, err := service.GetEncryptedSecretByKey(ctx, key)
 	assert.NoError(t, err)
 
 	// Decrypt
-	decryptedValue, err := service.DecryptSecret(*retrievedSecret, testMasterKey)
+	decryptedValue, err := service.DecryptSecret(ctx, *retrievedSecret, testMasterKey)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.Equal(t, testPlainTextSecret, decryptedValue)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceNegativeDecryptSecret tests the DecryptSecret method with a wrong master key
 func TestServiceNegativeDecryptSecret(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 	global.Logger = logrus.New()
 
 	// Create the Secret object
-	id, key, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, key, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Get the secret by the ID
-	retrievedSecret, err := service.GetEncryptedSecretByKey(key)
+	retrievedSecret, err := service.GetEncryptedSecretByKey(ctx, key)
 	assert.NoError(t, err)
 
 	// Decrypt
-	_, err = service.DecryptSecret(*retrievedSecret, "not-the-true-key")
+	_, err = service.DecryptSecret(ctx, *retrievedSecret, "not-the-true-key")
 
 	// Assert
 	assert.Error(t, err)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceUpdateSecret tests the successful update of a secret.
 func TestServiceUpdateSecret(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 
 	// Create the Secret object
-	id, _, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, _, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Try updating the secret
 	newPlainSecret := "this-secret-was-updated"
-	err = service.UpdateSecret(id, newPlainSecret, testMasterKey)
+	err = service.UpdateSecret(ctx, id, newPlainSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Get secret and make sure it changed
-	retrievedSecret, err := service.GetEncryptedSecretByID(id)
+	retrievedSecret, err := service.GetEncryptedSecretByID(ctx, id)
 	assert.NoError(t, err)
-	decryptedValue, err := service.DecryptSecret(*retrievedSecret, testMasterKey)
+	decryptedValue, err := service.DecryptSecret(ctx, *retrievedSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Assert secrets match
 	assert.Equal(t, newPlainSecret, decryptedValue)
 
 	// Cleanup
-	service.DeleteSecret(id)
+	service.DeleteSecret(ctx, id)
 }
 
 // TestServiceDeleteSecret tests the successful deletion of a secret
 func TestServiceDeleteSecret(t *testing.T) {
+	ctx := context.Background()
 	service := setupTestService(t)
 
 	// Create the Secret object
-	id, _, err := service.CreateSecret(testKey, testPlainTextSecret, testMasterKey)
+	id, _, err := service.CreateSecret(ctx, testKey, testPlainTextSecret, testMasterKey)
 	assert.NoError(t, err)
 
 	// Use the delete method
-	err = service.DeleteSecret(id)
+	err = service.DeleteSecret(ctx, id)
 
 	// Assert
 	assert.NoError(t, err)
 }
+
+// TestServiceClientEncryptedSecret tests that client-encrypted envelopes are stored and returned unchanged
+func TestServiceClientEncryptedSecret(t *testing.T) {
+	ctx := context.Background()
+	service := setupTestService(t)
+	envelope := "lockbox-ce:v1:opaque-envelope"
+
+	// Create the Secret object
+	id, key, err := service.CreateClientEncryptedSecret(ctx, testKey, envelope)
+	assert.NoError(t, err)
+
+	// Get the secret and "decrypt" it
+	retrievedSecret, err := service.GetEncryptedSecretByKey(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, retrievedSecret.ClientEncrypted)
+	value, err := service.DecryptSecret(ctx, *retrievedSecret, testMasterKey)
+
+	// Assert the envelope is returned exactly as stored
+	assert.NoError(t, err)
+	assert.Equal(t, envelope, value)
+
+	// Update the envelope
+	err = service.UpdateClientEncryptedSecret(ctx, id, "lockbox-ce:v1:new-envelope")
+	assert.NoError(t, err)
+	retrievedSecret, err = service.GetEncryptedSecretByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "lockbox-ce:v1:new-envelope", retrievedSecret.EncryptedValue)
+
+	// Cleanup
+	service.DeleteSecret(ctx, id)
+}
+
+// TestServiceNegativeMixedEncryptionModes tests that secrets cannot be updated with the other encryption mode
+func TestServiceNegativeMixedEncryptionModes(t *testing.T) {
+	ctx := context.Background()
+	service := setupTestService(t)
+
+	// Create one secret of each kind
+	clientID, _, err := service.CreateClientEncryptedSecret(ctx, testKey, "lockbox-ce:v1:opaque-envelope")
+	assert.NoError(t, err)
+	serverID, _, err := service.CreateSecret(ctx, testKey+"-server", testPlainTextSecret, testMasterKey)
+	assert.NoError(t, err)
+
+	// Assert
+	assert.ErrorIs(t, service.UpdateSecret(ctx, clientID, "new-value", testMasterKey), ErrClientEncrypted)
+	assert.ErrorIs(t, service.UpdateClientEncryptedSecret(ctx, serverID, "lockbox-ce:v1:new-envelope"), ErrServerEncrypted)
+
+	// Cleanup
+	service.DeleteSecret(ctx, clientID)
+	service.DeleteSecret(ctx, serverID)
+}
+
+// TestServiceNegativeExportClientEncrypted tests that an export is refused if a secret under the prefix is client-encrypted,
+// instead of writing its envelope as if it were the value
+func TestServiceNegativeExportClientEncrypted(t *testing.T) {
+	ctx := context.Background()
+	service := setupTestService(t)
+	prefix := testKey + "-export/"
+
+	// Create one secret of each kind under the prefix
+	serverID, _, err := service.CreateSecret(ctx, prefix+"server", testPlainTextSecret, testMasterKey)
+	assert.NoError(t, err)
+	clientID, _, err := service.CreateClientEncryptedSecret(ctx, prefix+"client", "lockbox-ce:v1:opaque-envelope")
+	assert.NoError(t, err)
+
+	// Export the secrets
+	values, err := service.ExportSecrets(ctx, prefix, testMasterKey)
+
+	// Assert the client-encrypted secret is reported
+	assert.ErrorIs(t, err, ErrClientEncrypted)
+	assert.ErrorContains(t, err, prefix+"client")
+	assert.Nil(t, values)
+
+	// Cleanup
+	service.DeleteSecret(ctx, serverID)
+	service.DeleteSecret(ctx, clientID)
+}
@@ -0,0 +1,236 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies a file format that secrets can be imported from or exported to.
+type Format string
+
+const (
+	// FormatDotenv is the `KEY=value` format used by `.env` files.
+	FormatDotenv Format = "dotenv"
+
+	// FormatJSON is a flat JSON object mapping keys to values.
+	FormatJSON Format = "json"
+
+	// FormatYAML is a flat YAML mapping of keys to values.
+	FormatYAML Format = "yaml"
+)
+
+// ConflictPolicy defines what happens when an imported key already exists in the database.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing secret and ignores the imported value.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictOverwrite replaces the existing secret with the imported value.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+
+	// ConflictFail aborts the whole import, before anything is written, if any key already exists.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ErrImportConflict is returned by an import using ConflictFail when some of the keys already exist.
+var ErrImportConflict = errors.New("secrets already exist")
+
+// ImportResult summarizes the outcome of an import.
+type ImportResult struct {
+	// Created lists the keys of the secrets that did not exist and were created.
+	Created []string `json:"created"`
+
+	// Updated lists the keys of the existing secrets that were overwritten.
+	Updated []string `json:"updated"`
+
+	// Skipped lists the keys of the existing secrets that were left untouched.
+	Skipped []string `json:"skipped"`
+}
+
+// ParseFormat converts a string (e.g., "dotenv", "json", "yaml") into a Format.
+// The ".env" and "yml" spellings are accepted as aliases so file extensions can be passed directly.
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(value, ".")) {
+	case "dotenv", "env":
+		return FormatDotenv, nil
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("unsupported format '%s'", value)
+}
+
+// ParseConflictPolicy converts a string into a ConflictPolicy.
+// An empty string selects ConflictSkip, which never modifies existing secrets.
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch ConflictPolicy(strings.ToLower(value)) {
+	case "", ConflictSkip:
+		return ConflictSkip, nil
+	case ConflictOverwrite:
+		return ConflictOverwrite, nil
+	case ConflictFail:
+		return ConflictFail, nil
+	}
+	return "", fmt.Errorf("unsupported conflict policy '%s'", value)
+}
+
+// ParseSecrets decodes the content of a dotenv, JSON or YAML document into a map of keys and plain-text values.
+// JSON and YAML documents must be flat: nested objects and lists are rejected because they have no single value to store.
+func ParseSecrets(format Format, content []byte) (map[string]string, error) {
+	switch format {
+	case FormatDotenv:
+		return parseDotenv(content)
+	case FormatJSON:
+		// Decode numbers as json.Number so they keep their original spelling (e.g., no exponent notation)
+		var document map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return nil, fmt.Errorf("invalid JSON document: %v", err)
+		}
+		return flattenValues(document)
+	case FormatYAML:
+		var document map[string]interface{}
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("invalid YAML document: %v", err)
+		}
+		return flattenValues(document)
+	}
+	return nil, fmt.Errorf("unsupported format '%s'", format)
+}
+
+// RenderSecrets encodes a map of keys and plain-text values in the requested format.
+// Keys are always written in sorted order so exports are stable and easy to diff.
+func RenderSecrets(format Format, values map[string]string) ([]byte, error) {
+	switch format {
+	case FormatDotenv:
+		var builder strings.Builder
+		for _, key := range sortedKeys(values) {
+			builder.WriteString(key)
+			builder.WriteString("=")
+			builder.WriteString(strconv.Quote(values[key]))
+			builder.WriteString("\n")
+		}
+		return []byte(builder.String()), nil
+	case FormatJSON:
+		return json.MarshalIndent(values, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(values)
+	}
+	return nil, fmt.Errorf("unsupported format '%s'", format)
+}
+
+// parseDotenv parses a dotenv document.
+// It supports comments, blank lines, an optional `export` prefix, single-quoted literal values,
+// double-quoted values with escape sequences and trailing inline comments on unquoted values.
+func parseDotenv(content []byte) (map[string]string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		// Split the line into the key and the raw value
+		key, rawValue, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid dotenv line %d: expected KEY=value", lineNumber)
+		}
+		rawValue = strings.TrimSpace(rawValue)
+
+		// Decode the value depending on how it is quoted
+		var value string
+		switch {
+		case strings.HasPrefix(rawValue, `"`):
+			end := closingQuote(rawValue)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid dotenv line %d: unterminated double-quoted value", lineNumber)
+			}
+			unquoted, err := strconv.Unquote(rawValue[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid dotenv line %d: %v", lineNumber, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(rawValue, "'"):
+			end := strings.Index(rawValue[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid dotenv line %d: unterminated single-quoted value", lineNumber)
+			}
+			value = rawValue[1 : end+1]
+		default:
+			if index := strings.Index(rawValue, " #"); index >= 0 {
+				rawValue = rawValue[:index]
+			}
+			value = strings.TrimSpace(rawValue)
+		}
+
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dotenv document: %v", err)
+	}
+
+	return values, nil
+}
+
+// closingQuote returns the index of the double quote that terminates the value starting at index 0,
+// skipping quotes escaped with a backslash. It returns -1 if the value is never terminated.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// flattenValues converts the scalar values of a decoded JSON or YAML document into strings.
+func flattenValues(document map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(document))
+	for key, raw := range document {
+		switch value := raw.(type) {
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = value
+		case json.Number:
+			values[key] = value.String()
+		case bool, int, int64, uint64, float64:
+			values[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("value of '%s' is not a scalar: nested documents are not supported", key)
+		}
+	}
+	return values, nil
+}
+
+// sortedKeys returns the keys of the map in lexical order.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
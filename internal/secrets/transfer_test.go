@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseSecretsDotenv tests parsing a dotenv document with comments, quotes and exports.
+func TestParseSecretsDotenv(t *testing.T) {
+	content := `
+# Database settings
+DB_USER=lockbox
+export DB_PASSWORD="p@ss \"word\"\nline"
+API_TOKEN='literal $value'
+EMPTY=
+PORT=5432 # inline comment
+`
+	values, err := ParseSecrets(FormatDotenv, []byte(content))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_USER":     "lockbox",
+		"DB_PASSWORD": "p@ss \"word\"\nline",
+		"API_TOKEN":   "literal $value",
+		"EMPTY":       "",
+		"PORT":        "5432",
+	}, values)
+}
+
+// TestParseSecretsNegativeDotenv tests parsing an invalid dotenv document.
+func TestParseSecretsNegativeDotenv(t *testing.T) {
+	_, err := ParseSecrets(FormatDotenv, []byte("NOT_AN_ASSIGNMENT\n"))
+	assert.Error(t, err)
+
+	_, err = ParseSecrets(FormatDotenv, []byte(`KEY="unterminated`))
+	assert.Error(t, err)
+}
+
+// TestParseSecretsJSONAndYAML tests parsing flat JSON and YAML documents with non-string scalars.
+func TestParseSecretsJSONAndYAML(t *testing.T) {
+	expected := map[string]string{"token": "abc", "port": "5432", "debug": "true"}
+
+	values, err := ParseSecrets(FormatJSON, []byte(`{"token": "abc", "port": 5432, "debug": true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, values)
+
+	values, err = ParseSecrets(FormatYAML, []byte("token: abc\nport: 5432\ndebug: true\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, values)
+}
+
+// TestParseSecretsNegativeNested tests that nested documents are rejected.
+func TestParseSecretsNegativeNested(t *testing.T) {
+	_, err := ParseSecrets(FormatJSON, []byte(`{"database": {"password": "x"}}`))
+	assert.Error(t, err)
+
+	_, err = ParseSecrets(FormatYAML, []byte("database:\n  password: x\n"))
+	assert.Error(t, err)
+}
+
+// TestRenderSecretsRoundTrip tests that every format can be parsed back to the original values.
+func TestRenderSecretsRoundTrip(t *testing.T) {
+	values := map[string]string{"B_KEY": "multi\nline \"quoted\"", "A_KEY": "plain", "C_KEY": ""}
+
+	for _, format := range []Format{FormatDotenv, FormatJSON, FormatYAML} {
+		document, err := RenderSecrets(format, values)
+		assert.NoError(t, err)
+
+		parsed, err := ParseSecrets(format, document)
+		assert.NoError(t, err)
+		assert.Equal(t, values, parsed, "format %s", format)
+	}
+}
+
+// TestParseFormatAndPolicy tests the accepted spellings of formats and conflict policies.
+func TestParseFormatAndPolicy(t *testing.T) {
+	format, err := ParseFormat(".env")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatDotenv, format)
+
+	format, err = ParseFormat("yml")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatYAML, format)
+
+	_, err = ParseFormat("toml")
+	assert.Error(t, err)
+
+	policy, err := ParseConflictPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, ConflictSkip, policy)
+
+	_, err = ParseConflictPolicy("merge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// draining is set once a shutdown has started, so that readiness checks fail and load balancers stop sending traffic.
+var draining atomic.Bool
+
+// Draining reports whether the server is shutting down and draining in-flight requests.
+func Draining() bool {
+	return draining.Load()
+}
+
+// closer is a resource released at the end of the shutdown, after requests and workers have stopped.
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Server wraps an http.Server with timeouts, signal handling and an orderly shutdown.
+// On SIGTERM or SIGINT it fails readiness, drains in-flight requests within the grace period,
+// stops the background workers and finally releases resources such as the database pool and the log file.
+type Server struct {
+	httpServer  *http.Server       // The underlying HTTP server
+	gracePeriod time.Duration      // The maximum time allowed for draining requests and stopping workers
+	delay       time.Duration      // How long requests are still served while readiness fails, before draining starts
+	ctx         context.Context    // The context given to background workers, cancelled on shutdown
+	cancel      context.CancelFunc // Cancels ctx
+	workers     sync.WaitGroup     // Tracks the running background workers
+	closers     []closer           // Resources released after everything else has stopped, in registration order
+	stop        chan struct{}      // Closed by Stop to trigger a shutdown without a signal
+	stopOnce    sync.Once          // Guards stop against being closed twice
+}
+
+// New creates a server for the handler using the address, timeouts and grace period from the configuration.
+func New(serverConfig config.ServerConfig, handler http.Handler) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              fmt.Sprintf("%s:%s", serverConfig.Host, serverConfig.Port),
+			Handler:           handler,
+			ReadTimeout:       time.Duration(serverConfig.ReadTimeout) * time.Second,
+			ReadHeaderTimeout: time.Duration(serverConfig.ReadTimeout) * time.Second,
+			WriteTimeout:      time.Duration(serverConfig.WriteTimeout) * time.Second,
+			IdleTimeout:       time.Duration(serverConfig.IdleTimeout) * time.Second,
+		},
+		gracePeriod: time.Duration(serverConfig.ShutdownGracePeriod) * time.Second,
+		delay:       time.Duration(serverConfig.ShutdownDelay) * time.Second,
+		ctx:         ctx,
+		cancel:      cancel,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Go runs a background worker in its own goroutine.
+// The worker receives a context that is cancelled when the server shuts down, and the shutdown waits for it to return.
+func (s *Server) Go(name string, worker func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		worker(s.ctx)
+		global.Logger.Debugf("Background worker '%s' stopped", name)
+	}()
+}
+
+// OnShutdown registers a resource to release once requests have been drained and workers have stopped.
+// Resources are released in the order in which they were registered.
+func (s *Server) OnShutdown(name string, close func() error) {
+	s.closers = append(s.closers, closer{name: name, close: close})
+}
+
+// Stop triggers the same orderly shutdown as SIGTERM.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run starts serving requests and blocks until the server has shut down.
+// It returns an error if the server cannot listen or fails while serving; a shutdown triggered by
+// a signal or by Stop is not an error. Either way, the workers are stopped and the resources released before it returns.
+func (s *Server) Run() error {
+	// Listen for termination signals
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	// Serve requests in the background
+	serveErrors := make(chan error, 1)
+	go func() {
+		global.Logger.Infof("Starting server on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErrors <- err
+		}
+		close(serveErrors)
+	}()
+
+	// Wait for a reason to stop
+	select {
+	case err, failed := <-serveErrors:
+		if failed {
+			// Nothing was served, but the workers and resources started before Run must still be released
+			deadline, cancel := context.WithTimeout(context.Background(), s.gracePeriod)
+			defer cancel()
+			s.stopWorkersAndClose(deadline)
+			return fmt.Errorf("error starting server: %v", err)
+		}
+		return nil
+	case sig := <-signals:
+		global.Logger.Infof("Received %s, shutting down", sig)
+	case <-s.stop:
+		global.Logger.Info("Shutdown requested, shutting down")
+	}
+
+	// A second signal skips the draining and exits immediately
+	go func() {
+		sig := <-signals
+		global.Logger.Warnf("Received %s during shutdown, exiting immediately", sig)
+		os.Exit(1)
+	}()
+
+	s.shutdown()
+	return nil
+}
+
+// shutdown fails readiness, drains requests, stops the workers and releases the registered resources.
+func (s *Server) shutdown() {
+	// Fail readiness, and keep serving requests until load balancers have noticed and stopped routing new ones here
+	draining.Store(true)
+	if s.delay > 0 {
+		global.Logger.Infof("Waiting %s for load balancers to observe the failing readiness probe", s.delay)
+		time.Sleep(s.delay)
+	}
+
+	deadline, cancel := context.WithTimeout(context.Background(), s.gracePeriod)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests to complete
+	global.Logger.Infof("Draining in-flight requests (grace period %s)", s.gracePeriod)
+	if err := s.httpServer.Shutdown(deadline); err != nil {
+		global.Logger.Warnf("Grace period expired before all requests completed: %v", err)
+		s.httpServer.Close()
+	}
+
+	s.stopWorkersAndClose(deadline)
+}
+
+// stopWorkersAndClose cancels the background workers, waits for them until the deadline, and then releases
+// the registered resources, in registration order.
+func (s *Server) stopWorkersAndClose(deadline context.Context) {
+	// Stop the background workers and wait for them within what is left of the grace period
+	s.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-deadline.Done():
+		global.Logger.Warn("Grace period expired before all background workers stopped")
+	}
+
+	// Release the resources
+	for _, closer := range s.closers {
+		global.Logger.Debugf("Closing %s", closer.name)
+		if err := closer.close(); err != nil {
+			global.Logger.Errorf("Failed to close %s: %v", closer.name, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// events records the steps of a shutdown, in the order in which they happen.
+type events struct {
+	mutex sync.Mutex
+	names []string
+}
+
+func (e *events) add(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.names = append(e.names, name)
+}
+
+func (e *events) list() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]string(nil), e.names...)
+}
+
+// freePort returns a port that nothing listens on.
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// newTestServer creates a server of the handler on a free port, with a worker and two closers recording their steps.
+func newTestServer(t *testing.T, port string, delay int, handler http.Handler, recorded *events) *Server {
+	global.Logger = logrus.New()
+	t.Cleanup(func() { draining.Store(false) })
+
+	srv := New(config.ServerConfig{
+		Host: "127.0.0.1", Port: port, ReadTimeout: 5, WriteTimeout: 5, IdleTimeout: 5,
+		ShutdownGracePeriod: 5, ShutdownDelay: delay,
+	}, handler)
+	srv.Go("test", func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		recorded.add("worker stopped")
+	})
+	srv.OnShutdown("first", func() error {
+		recorded.add("first closed")
+		return nil
+	})
+	srv.OnShutdown("second", func() error {
+		recorded.add("second closed")
+		return errors.New("already closed")
+	})
+	return srv
+}
+
+// TestServerShutdownOrder tests that readiness fails while requests are still served, that an in-flight request
+// completes during the grace period, and that the workers are stopped before the resources are released in order.
+func TestServerShutdownOrder(t *testing.T) {
+	recorded := &events{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := http.NewServeMux()
+	router.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		recorded.add("request completed")
+	})
+	port := freePort(t)
+	srv := newTestServer(t, port, 1, router, recorded)
+	baseURL := "http://127.0.0.1:" + port
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Run() }()
+	require.Eventually(t, func() bool {
+		response, err := http.Get(baseURL + "/ping")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Start a request that is still in flight when the shutdown starts
+	slowResponse := make(chan *http.Response, 1)
+	go func() {
+		response, err := http.Get(baseURL + "/slow")
+		assert.NoError(t, err)
+		slowResponse <- response
+	}()
+	<-started
+	srv.Stop()
+
+	// Readiness fails right away, while new requests are still served during the delay
+	require.Eventually(t, Draining, time.Second, 10*time.Millisecond)
+	response, err := http.Get(baseURL + "/ping")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, recorded.list())
+
+	// The in-flight request completes once the server is draining
+	time.Sleep(1100 * time.Millisecond)
+	close(release)
+	response = <-slowResponse
+	if response != nil {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	require.NoError(t, <-stopped)
+	assert.Equal(t, []string{"request completed", "worker stopped", "first closed", "second closed"}, recorded.list())
+}
+
+// TestServerListenFailureReleasesResources tests that the workers are stopped and the resources released
+// when the server cannot listen.
+func TestServerListenFailureReleasesResources(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	recorded := &events{}
+	srv := newTestServer(t, port, 0, http.NewServeMux(), recorded)
+
+	assert.ErrorContains(t, srv.Run(), "error starting server")
+	assert.Equal(t, []string{"worker stopped", "first closed", "second closed"}, recorded.list())
+}
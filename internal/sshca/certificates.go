@@ -0,0 +1,194 @@
+package sshca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// supportedCriticalOptions are the critical options that roles may set on user certificates.
+var supportedCriticalOptions = []string{"force-command", "source-address"}
+
+// generateKey creates a new CA key pair of the given type.
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeECDSAP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA3072:
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedKeyType, keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %v", err)
+	}
+	return key, nil
+}
+
+// keyTypeOf returns the key type of an imported private key.
+func keyTypeOf(key crypto.Signer) (KeyType, error) {
+	switch public := key.Public().(type) {
+	case ed25519.PublicKey:
+		return KeyTypeEd25519, nil
+	case *ecdsa.PublicKey:
+		if public.Curve == elliptic.P256() {
+			return KeyTypeECDSAP256, nil
+		}
+	case *rsa.PublicKey:
+		if public.N.BitLen() >= 3072 {
+			return KeyTypeRSA3072, nil
+		}
+	}
+	return "", fmt.Errorf("%w: only Ed25519, ECDSA P-256 and RSA keys of at least 3072 bits are supported", ErrUnsupportedKeyType)
+}
+
+// encodePrivateKey encodes a private key in PEM, as a PKCS #8 private key.
+func encodePrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parsePrivateKey decodes an unencrypted private key in OpenSSH, PKCS #8, PKCS #1 or SEC 1 PEM format.
+func parsePrivateKey(privateKeyPEM string) (crypto.Signer, error) {
+	key, err := ssh.ParseRawPrivateKey([]byte(privateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	// Ed25519 keys in OpenSSH format are returned as pointers
+	if pointer, ok := key.(*ed25519.PrivateKey); ok {
+		key = *pointer
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported private key", ErrInvalidKey)
+	}
+	return signer, nil
+}
+
+// parsePublicKey decodes a public key in authorized_keys format. Certificates are refused.
+func parsePublicKey(publicKey string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if _, ok := key.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf("%w: expected a public key, not a certificate", ErrInvalidKey)
+	}
+	return key, nil
+}
+
+// newSerialNumber generates a random certificate serial number.
+func newSerialNumber() (uint64, error) {
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return binary.BigEndian.Uint64(serial[:]), nil
+}
+
+// principalAllowed reports whether a principal matches one of the allowed patterns.
+func principalAllowed(allowed []string, principal string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || pattern == principal {
+			return true
+		}
+		if suffix, found := strings.CutPrefix(pattern, "*"); found && strings.HasPrefix(suffix, ".") && strings.HasSuffix(principal, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRole checks that the settings of a role are consistent.
+func validateRole(role *Role) error {
+	if role.CertType != CertTypeUser && role.CertType != CertTypeHost {
+		return fmt.Errorf("%w: cert_type must be '%s' or '%s'", ErrInvalidRole, CertTypeUser, CertTypeHost)
+	}
+	if len(role.AllowedPrincipals) == 0 {
+		return fmt.Errorf("%w: at least one allowed principal is required", ErrInvalidRole)
+	}
+	for _, principal := range role.DefaultPrincipals {
+		if !principalAllowed(role.AllowedPrincipals, principal) {
+			return fmt.Errorf("%w: default principal '%s' is not allowed", ErrInvalidRole, principal)
+		}
+	}
+	if role.TTL <= 0 || role.MaxTTL < role.TTL {
+		return fmt.Errorf("%w: ttl must be positive and not greater than max_ttl", ErrInvalidRole)
+	}
+
+	// Host certificates have no extensions or critical options
+	if role.CertType == CertTypeHost {
+		if len(role.AllowedExtensions) > 0 || len(role.DefaultExtensions) > 0 || len(role.CriticalOptions) > 0 {
+			return fmt.Errorf("%w: host roles cannot have extensions or critical options", ErrInvalidRole)
+		}
+		return nil
+	}
+
+	for _, extension := range role.DefaultExtensions {
+		if !slices.Contains(role.AllowedExtensions, extension) {
+			return fmt.Errorf("%w: default extension '%s' is not allowed", ErrInvalidRole, extension)
+		}
+	}
+	for option, value := range role.CriticalOptions {
+		if !slices.Contains(supportedCriticalOptions, option) {
+			return fmt.Errorf("%w: unsupported critical option '%s'", ErrInvalidRole, option)
+		}
+		if option == "source-address" {
+			for _, cidr := range strings.Split(value, ",") {
+				if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+					return fmt.Errorf("%w: invalid source-address '%s'", ErrInvalidRole, cidr)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// permissions returns the critical options and extensions of a certificate signed with a role.
+// Requested extensions must be allowed by the role; the role's default extensions are used when none are requested.
+func permissions(role *Role, requested []string) (ssh.Permissions, error) {
+	if role.CertType == CertTypeHost {
+		if len(requested) > 0 {
+			return ssh.Permissions{}, fmt.Errorf("%w: host certificates have no extensions", ErrNotAllowed)
+		}
+		return ssh.Permissions{}, nil
+	}
+
+	extensions := make(map[string]string)
+	if requested == nil {
+		requested = role.DefaultExtensions
+	}
+	for _, extension := range requested {
+		if !slices.Contains(role.AllowedExtensions, extension) {
+			return ssh.Permissions{}, fmt.Errorf("%w: extension '%s' is not allowed by role '%s'", ErrNotAllowed, extension, role.Name)
+		}
+		extensions[extension] = ""
+	}
+
+	criticalOptions := make(map[string]string, len(role.CriticalOptions))
+	for option, value := range role.CriticalOptions {
+		criticalOptions[option] = value
+	}
+
+	return ssh.Permissions{CriticalOptions: criticalOptions, Extensions: extensions}, nil
+}
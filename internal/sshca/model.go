@@ -0,0 +1,130 @@
+package sshca
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyType identifies the algorithm of the SSH CA key.
+type KeyType string
+
+const (
+	// KeyTypeEd25519 is an Ed25519 key pair.
+	KeyTypeEd25519 KeyType = "ed25519"
+
+	// KeyTypeECDSAP256 is an ECDSA key pair on the NIST P-256 curve.
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+
+	// KeyTypeRSA3072 is a 3072-bit RSA key pair. Certificates are signed with rsa-sha2-512.
+	KeyTypeRSA3072 KeyType = "rsa-3072"
+)
+
+// CertType identifies whether a role signs user or host keys.
+type CertType string
+
+const (
+	// CertTypeUser certificates authenticate users to hosts that trust the CA with TrustedUserCAKeys.
+	CertTypeUser CertType = "user"
+
+	// CertTypeHost certificates authenticate hosts to users that trust the CA with @cert-authority in known_hosts.
+	CertTypeHost CertType = "host"
+)
+
+// caID is the ID of the only row of the ssh_ca table: Lockbox holds a single SSH CA.
+const caID = 1
+
+// CA is the SSH certificate authority held by Lockbox.
+type CA struct {
+	// ID is always caID, as there is a single CA.
+	ID int `gorm:"primaryKey"`
+
+	// KeyType is the algorithm of the CA key.
+	KeyType KeyType `gorm:"not null"`
+
+	// PublicKey is the public key of the CA in authorized_keys format, as used in TrustedUserCAKeys.
+	PublicKey string `gorm:"not null"`
+
+	// EncryptedPrivateKey holds the PEM-encoded PKCS #8 private key of the CA, encrypted with the master key using secrets.Encrypt.
+	EncryptedPrivateKey string `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the CA was generated or imported.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name of the CA model.
+func (CA) TableName() string {
+	return "ssh_ca"
+}
+
+// Role restricts the certificates signed with it: their type, principals, lifetime, extensions and critical options.
+type Role struct {
+	// ID is the unique identifier of the role.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name of the role, used in POST /ssh/sign/{role}.
+	Name string `gorm:"unique;not null"`
+
+	// CertType is the type of the certificates signed with this role.
+	CertType CertType `gorm:"not null"`
+
+	// AllowedPrincipals lists the principals (user names or host names) that may be requested.
+	// "*" allows any principal, and "*.example.com" allows any subdomain of example.com.
+	AllowedPrincipals []string `gorm:"serializer:json;not null"`
+
+	// DefaultPrincipals are used when a request does not list any principal.
+	DefaultPrincipals []string `gorm:"serializer:json;not null"`
+
+	// AllowedExtensions lists the extensions (e.g., "permit-pty") that user certificates may request.
+	AllowedExtensions []string `gorm:"serializer:json;not null"`
+
+	// DefaultExtensions are granted when a request does not list any extension.
+	DefaultExtensions []string `gorm:"serializer:json;not null"`
+
+	// CriticalOptions are added to every user certificate and cannot be changed by the requester (e.g., "source-address").
+	CriticalOptions map[string]string `gorm:"serializer:json;not null"`
+
+	// TTL is the default lifetime of signed certificates, in seconds.
+	TTL int `gorm:"not null"`
+
+	// MaxTTL is the longest lifetime that may be requested, in seconds.
+	MaxTTL int `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the role was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the role was last updated.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Role model.
+func (Role) TableName() string {
+	return "ssh_roles"
+}
+
+// SignRequest holds the parameters of a public key to sign.
+type SignRequest struct {
+	// PublicKey is the public key to sign, in authorized_keys format.
+	PublicKey string
+
+	// Principals are the requested principals. The role's default principals are used when empty.
+	Principals []string
+
+	// Extensions are the requested extensions. The role's default extensions are used when nil.
+	Extensions []string
+
+	// TTL is the requested lifetime. The role's default TTL is used when zero.
+	TTL time.Duration
+}
+
+// SignedKey is the result of signing a public key.
+type SignedKey struct {
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber uint64
+
+	// SignedKey is the certificate in authorized_keys format, to be saved as "<key>-cert.pub".
+	SignedKey string
+
+	// Expiration is the expiration time of the certificate.
+	Expiration time.Time
+}
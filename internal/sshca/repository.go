@@ -0,0 +1,84 @@
+package sshca
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface defines methods for database interactions related to the SSH CA.
+type Repository interface {
+	// Saves the CA to the database, failing if a CA already exists
+	SaveCA(ca *CA) error
+
+	// Retrieves the CA
+	GetCA() (*CA, error)
+
+	// Creates a role, or replaces the role with the same name
+	SaveRole(role *Role) error
+
+	// Retrieves a role by its name
+	GetRole(name string) (*Role, error)
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the SSH CA repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// SaveCA inserts the CA record into the database.
+//
+// Parameters:
+// - ca: The CA model, including its encrypted private key.
+//
+// Returns:
+// - error: Returns an error if the insertion fails (e.g., a CA already exists), otherwise nil.
+func (r *repository) SaveCA(ca *CA) error {
+	ca.ID = caID
+	return r.db.Create(ca).Error
+}
+
+// GetCA retrieves the CA.
+//
+// Returns:
+// - CA: The retrieved CA model.
+// - error: Returns an error if no CA has been generated or imported, or if the query fails.
+func (r *repository) GetCA() (*CA, error) {
+	var ca *CA
+	err := r.db.First(&ca, "id = ?", caID).Error
+	return ca, err
+}
+
+// SaveRole inserts a role, or updates every setting of the existing role with the same name.
+//
+// Parameters:
+// - role: The Role model.
+//
+// Returns:
+// - error: Returns an error if the upsert fails, otherwise nil.
+func (r *repository) SaveRole(role *Role) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"cert_type", "allowed_principals", "default_principals", "allowed_extensions", "default_extensions", "critical_options", "ttl", "max_ttl", "updated_at",
+		}),
+	}).Create(role).Error
+}
+
+// GetRole retrieves a role by its name.
+//
+// Parameters:
+// - name: The name of the role to retrieve.
+//
+// Returns:
+// - Role: The retrieved Role model.
+// - error: Returns an error if no role with the given name is found or if the query fails.
+func (r *repository) GetRole(name string) (*Role, error) {
+	var role *Role
+	err := r.db.First(&role, "name = ?", name).Error
+	return role, err
+}
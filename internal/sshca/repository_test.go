@@ -0,0 +1,48 @@
+package sshca
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+)
+
+// TestRepoSaveRole tests that saving a role again replaces its settings but keeps its ID,
+// and that its principals, extensions and critical options are read back from their JSON columns.
+func TestRepoSaveRole(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewRepository(db)
+	name := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		db.Delete(&Role{}, "name = ?", name)
+	})
+
+	// Save a role, then new settings for it under a new ID
+	role := &Role{
+		ID: uuid.New(), Name: name, CertType: CertTypeHost, AllowedPrincipals: []string{"*.example.com"},
+		DefaultPrincipals: []string{}, AllowedExtensions: []string{}, DefaultExtensions: []string{},
+		CriticalOptions: map[string]string{}, TTL: 3600, MaxTTL: 86400,
+	}
+	require.NoError(t, repo.SaveRole(role))
+	assert.NoError(t, repo.SaveRole(&Role{
+		ID: uuid.New(), Name: name, CertType: CertTypeUser, AllowedPrincipals: []string{"deploy", "ops"},
+		DefaultPrincipals: []string{"deploy"}, AllowedExtensions: []string{"permit-pty", "permit-port-forwarding"},
+		DefaultExtensions: []string{"permit-pty"}, CriticalOptions: map[string]string{"source-address": "10.0.0.0/8"},
+		TTL: 60, MaxTTL: 120,
+	}))
+
+	// Assert the settings were replaced
+	stored, err := repo.GetRole(name)
+	assert.NoError(t, err)
+	assert.Equal(t, role.ID, stored.ID)
+	assert.Equal(t, CertTypeUser, stored.CertType)
+	assert.Equal(t, []string{"deploy", "ops"}, stored.AllowedPrincipals)
+	assert.Equal(t, []string{"deploy"}, stored.DefaultPrincipals)
+	assert.Equal(t, []string{"permit-pty", "permit-port-forwarding"}, stored.AllowedExtensions)
+	assert.Equal(t, []string{"permit-pty"}, stored.DefaultExtensions)
+	assert.Equal(t, map[string]string{"source-address": "10.0.0.0/8"}, stored.CriticalOptions)
+	assert.Equal(t, 60, stored.TTL)
+	assert.Equal(t, 120, stored.MaxTTL)
+}
@@ -0,0 +1,308 @@
+package sshca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// backdate is subtracted from the start of validity of new certificates to tolerate clock skew between hosts.
+const backdate = 30 * time.Second
+
+var (
+	// ErrCANotFound is returned when no SSH CA has been generated or imported yet.
+	ErrCANotFound = errors.New("SSH CA not found")
+
+	// ErrCAExists is returned when generating or importing an SSH CA while one already exists.
+	ErrCAExists = errors.New("SSH CA already exists")
+
+	// ErrRoleNotFound is returned when no role exists with the requested name.
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrInvalidName is returned when a role name contains characters that are not allowed.
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrUnsupportedKeyType is returned when a CA key type is unknown.
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+	// ErrInvalidKey is returned when a public or private key is malformed.
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrInvalidRole is returned when the settings of a role are inconsistent.
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrNotAllowed is returned when a signing request includes a principal or extension that its role does not allow.
+	ErrNotAllowed = errors.New("not allowed")
+
+	// ErrTTLTooLong is returned when a signing request asks for a lifetime beyond the role's maximum.
+	ErrTTLTooLong = errors.New("requested TTL exceeds the maximum TTL of the role")
+)
+
+// namePattern restricts role names to characters that are safe in URLs and logs.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// Service interface defines the business logic of the SSH certificate authority.
+// The CA private key is encrypted with the master key at rest and never leaves the service.
+type Service interface {
+	// GenerateCA generates the CA key pair.
+	// Returns the created CA or ErrCAExists.
+	GenerateCA(keyType KeyType, masterKey string) (*CA, error)
+
+	// ImportCA stores an existing CA private key, in OpenSSH or PEM format.
+	// Returns the imported CA or an error if the key is invalid or a CA already exists.
+	ImportCA(privateKeyPEM, masterKey string) (*CA, error)
+
+	// GetCA retrieves the CA, including its public key.
+	// Returns the CA or ErrCANotFound.
+	GetCA() (*CA, error)
+
+	// SaveRole validates and stores a role, replacing any existing role with the same name.
+	// Returns an error if the role is invalid or cannot be stored.
+	SaveRole(role *Role) error
+
+	// GetRole retrieves a role by name.
+	// Returns the Role or ErrRoleNotFound.
+	GetRole(name string) (*Role, error)
+
+	// Sign signs a user or host public key with the CA, following the role.
+	// Returns the certificate or an error if the request is not allowed by the role.
+	Sign(roleName string, req SignRequest, masterKey string) (*SignedKey, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new SSH CA service.
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// GenerateCA generates a key pair and stores it as the CA.
+func (s *service) GenerateCA(keyType KeyType, masterKey string) (*CA, error) {
+	if err := s.checkNoCA(); err != nil {
+		return nil, err
+	}
+
+	key, err := generateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveCA(keyType, key, masterKey)
+}
+
+// ImportCA parses an existing private key and stores it as the CA.
+func (s *service) ImportCA(privateKeyPEM, masterKey string) (*CA, error) {
+	if err := s.checkNoCA(); err != nil {
+		return nil, err
+	}
+
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := keyTypeOf(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveCA(keyType, key, masterKey)
+}
+
+// GetCA retrieves the CA.
+func (s *service) GetCA() (*CA, error) {
+	ca, err := s.repo.GetCA()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCANotFound
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve SSH CA: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return ca, nil
+}
+
+// SaveRole validates a role and stores it.
+func (s *service) SaveRole(role *Role) error {
+	if !namePattern.MatchString(role.Name) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidName, role.Name)
+	}
+	if err := validateRole(role); err != nil {
+		return err
+	}
+
+	role.ID = uuid.New()
+	if err := s.repo.SaveRole(role); err != nil {
+		err = fmt.Errorf("failed to store role '%s': %v", role.Name, err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Saved SSH role '%s' for %s certificates", role.Name, role.CertType)
+	return nil
+}
+
+// GetRole retrieves a role by name.
+func (s *service) GetRole(name string) (*Role, error) {
+	role, err := s.repo.GetRole(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrRoleNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve role '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// Sign checks the request against the role, then signs the public key with the CA.
+func (s *service) Sign(roleName string, req SignRequest, masterKey string) (*SignedKey, error) {
+	role, err := s.GetRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := parsePublicKey(req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the requested principals, lifetime and extensions
+	principals := req.Principals
+	if len(principals) == 0 {
+		principals = role.DefaultPrincipals
+	}
+	if len(principals) == 0 {
+		return nil, fmt.Errorf("%w: at least one principal is required", ErrNotAllowed)
+	}
+	for _, principal := range principals {
+		if !principalAllowed(role.AllowedPrincipals, principal) {
+			return nil, fmt.Errorf("%w: principal '%s' is not allowed by role '%s'", ErrNotAllowed, principal, role.Name)
+		}
+	}
+	ttl := time.Duration(role.TTL) * time.Second
+	if req.TTL > 0 {
+		ttl = req.TTL
+	}
+	if ttl > time.Duration(role.MaxTTL)*time.Second {
+		return nil, fmt.Errorf("%w: %s > %ds", ErrTTLTooLong, ttl, role.MaxTTL)
+	}
+	perms, err := permissions(role, req.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the CA
+	signer, err := s.loadSigner(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build and sign the certificate
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	certType := uint32(ssh.UserCert)
+	if role.CertType == CertTypeHost {
+		certType = ssh.HostCert
+	}
+	now := time.Now()
+	certificate := &ssh.Certificate{
+		Key:             publicKey,
+		Serial:          serial,
+		CertType:        certType,
+		KeyId:           fmt.Sprintf("lockbox:%s:%s", role.Name, strings.Join(principals, ",")),
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-backdate).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions:     perms,
+	}
+	if err := certificate.SignCert(rand.Reader, signer); err != nil {
+		err = fmt.Errorf("failed to sign SSH certificate: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	expiration := time.Unix(int64(certificate.ValidBefore), 0)
+	global.Logger.Infof("Signed SSH %s certificate %d for principals %v with role '%s', expiring at %s",
+		role.CertType, serial, principals, role.Name, expiration.Format(time.RFC3339))
+	return &SignedKey{
+		SerialNumber: serial,
+		SignedKey:    strings.TrimSpace(string(ssh.MarshalAuthorizedKey(certificate))),
+		Expiration:   expiration,
+	}, nil
+}
+
+// checkNoCA makes sure no CA exists yet, as replacing it would invalidate every signed certificate.
+func (s *service) checkNoCA() error {
+	if _, err := s.repo.GetCA(); err == nil {
+		return ErrCAExists
+	}
+	return nil
+}
+
+// saveCA encrypts the CA private key with the master key and stores the CA.
+func (s *service) saveCA(keyType KeyType, key crypto.Signer, masterKey string) (*CA, error) {
+	sshPublicKey, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	privateKey, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encryptedPrivateKey, err := secrets.Encrypt(privateKey, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to encrypt SSH CA private key: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	ca := &CA{
+		KeyType:             keyType,
+		PublicKey:           strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))),
+		EncryptedPrivateKey: encryptedPrivateKey,
+	}
+	if err := s.repo.SaveCA(ca); err != nil {
+		err = fmt.Errorf("failed to store SSH CA in the database: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Stored SSH CA with %s key %s", keyType, ssh.FingerprintSHA256(sshPublicKey))
+	return ca, nil
+}
+
+// loadSigner decrypts the CA private key.
+func (s *service) loadSigner(masterKey string) (ssh.Signer, error) {
+	ca, err := s.GetCA()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := secrets.Decrypt(ca.EncryptedPrivateKey, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to decrypt SSH CA private key: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromSigner(key)
+}
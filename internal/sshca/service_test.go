@@ -0,0 +1,165 @@
+package sshca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository holding the CA and roles, so that signing is tested without a database.
+// The role upsert of the gorm repository is tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	ca    *CA
+	roles map[string]*Role
+}
+
+func (r *memoryRepository) SaveCA(ca *CA) error {
+	ca.ID = caID
+	r.ca = ca
+	return nil
+}
+
+func (r *memoryRepository) GetCA() (*CA, error) {
+	if r.ca == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.ca, nil
+}
+
+func (r *memoryRepository) SaveRole(role *Role) error {
+	r.roles[role.Name] = role
+	return nil
+}
+
+func (r *memoryRepository) GetRole(name string) (*Role, error) {
+	if role, found := r.roles[name]; found {
+		return role, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// newTestService creates an SSH CA service with a CA, a "developers" user role and a "servers" host role.
+func newTestService(t *testing.T) Service {
+	global.Logger = logrus.New()
+	service := NewService(&memoryRepository{roles: map[string]*Role{}})
+
+	_, err := service.GenerateCA(KeyTypeEd25519, "master")
+	assert.NoError(t, err)
+	assert.NoError(t, service.SaveRole(&Role{
+		Name: "developers", CertType: CertTypeUser,
+		AllowedPrincipals: []string{"deploy", "alice"}, DefaultPrincipals: []string{"deploy"},
+		AllowedExtensions: []string{"permit-pty", "permit-port-forwarding"}, DefaultExtensions: []string{"permit-pty"},
+		CriticalOptions: map[string]string{"source-address": "10.0.0.0/8"},
+		TTL:             3600, MaxTTL: 28800,
+	}))
+	assert.NoError(t, service.SaveRole(&Role{
+		Name: "servers", CertType: CertTypeHost, AllowedPrincipals: []string{"*.example.com"}, TTL: 86400, MaxTTL: 86400,
+	}))
+
+	return service
+}
+
+// newTestPublicKey generates a public key in authorized_keys format.
+func newTestPublicKey(t *testing.T) string {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	sshPublic, err := ssh.NewPublicKey(public)
+	assert.NoError(t, err)
+	return string(ssh.MarshalAuthorizedKey(sshPublic))
+}
+
+// parseSignedKey parses a signed key and checks it against the CA public key.
+func parseSignedKey(t *testing.T, service Service, signedKey string) *ssh.Certificate {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+	assert.NoError(t, err)
+	certificate := key.(*ssh.Certificate)
+
+	ca, err := service.GetCA()
+	assert.NoError(t, err)
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca.PublicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, caKey.Marshal(), certificate.SignatureKey.Marshal())
+	return certificate
+}
+
+// TestServiceSignUserKey tests that user certificates follow the role and are accepted by a host trusting the CA.
+func TestServiceSignUserKey(t *testing.T) {
+	service := newTestService(t)
+
+	signed, err := service.Sign("developers", SignRequest{PublicKey: newTestPublicKey(t)}, "master")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed.SignedKey, "ssh-ed25519-cert-v01@openssh.com "))
+
+	certificate := parseSignedKey(t, service, signed.SignedKey)
+	assert.Equal(t, uint32(ssh.UserCert), certificate.CertType)
+	assert.Equal(t, []string{"deploy"}, certificate.ValidPrincipals)
+	assert.Equal(t, map[string]string{"permit-pty": ""}, certificate.Extensions)
+	assert.Equal(t, map[string]string{"source-address": "10.0.0.0/8"}, certificate.CriticalOptions)
+	assert.Equal(t, signed.SerialNumber, certificate.Serial)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), signed.Expiration, time.Minute)
+
+	checker := &ssh.CertChecker{IsUserAuthority: func(auth ssh.PublicKey) bool {
+		return string(auth.Marshal()) == string(certificate.SignatureKey.Marshal())
+	}}
+	assert.NoError(t, checker.CheckCert("deploy", certificate))
+	assert.Error(t, checker.CheckCert("root", certificate))
+}
+
+// TestServiceSignHostKey tests that host certificates use wildcard principals and carry no permissions.
+func TestServiceSignHostKey(t *testing.T) {
+	service := newTestService(t)
+
+	signed, err := service.Sign("servers", SignRequest{PublicKey: newTestPublicKey(t), Principals: []string{"web-1.example.com"}}, "master")
+	assert.NoError(t, err)
+	certificate := parseSignedKey(t, service, signed.SignedKey)
+	assert.Equal(t, uint32(ssh.HostCert), certificate.CertType)
+	assert.Empty(t, certificate.Extensions)
+
+	_, err = service.Sign("servers", SignRequest{PublicKey: newTestPublicKey(t), Principals: []string{"example.com"}}, "master")
+	assert.ErrorIs(t, err, ErrNotAllowed)
+}
+
+// TestServiceNegativeSign tests requests that the role does not allow.
+func TestServiceNegativeSign(t *testing.T) {
+	service := newTestService(t)
+	publicKey := newTestPublicKey(t)
+
+	_, err := service.Sign("developers", SignRequest{PublicKey: publicKey, Principals: []string{"root"}}, "master")
+	assert.ErrorIs(t, err, ErrNotAllowed)
+	_, err = service.Sign("developers", SignRequest{PublicKey: publicKey, Extensions: []string{"permit-agent-forwarding"}}, "master")
+	assert.ErrorIs(t, err, ErrNotAllowed)
+	_, err = service.Sign("developers", SignRequest{PublicKey: publicKey, TTL: 9 * time.Hour}, "master")
+	assert.ErrorIs(t, err, ErrTTLTooLong)
+	_, err = service.Sign("developers", SignRequest{PublicKey: "ssh-ed25519 not-a-key"}, "master")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = service.Sign("missing", SignRequest{PublicKey: publicKey}, "master")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+
+	_, err = service.GenerateCA(KeyTypeEd25519, "master")
+	assert.ErrorIs(t, err, ErrCAExists)
+}
+
+// TestServiceNegativeSaveRole tests roles with inconsistent settings.
+func TestServiceNegativeSaveRole(t *testing.T) {
+	service := newTestService(t)
+
+	for _, role := range []*Role{
+		{Name: "no-principals", CertType: CertTypeUser, TTL: 60, MaxTTL: 60},
+		{Name: "bad-type", CertType: "both", AllowedPrincipals: []string{"*"}, TTL: 60, MaxTTL: 60},
+		{Name: "bad-ttl", CertType: CertTypeUser, AllowedPrincipals: []string{"*"}, TTL: 120, MaxTTL: 60},
+		{Name: "bad-default", CertType: CertTypeUser, AllowedPrincipals: []string{"deploy"}, DefaultPrincipals: []string{"root"}, TTL: 60, MaxTTL: 60},
+		{Name: "bad-option", CertType: CertTypeUser, AllowedPrincipals: []string{"*"}, CriticalOptions: map[string]string{"no-touch-required": ""}, TTL: 60, MaxTTL: 60},
+		{Name: "bad-source", CertType: CertTypeUser, AllowedPrincipals: []string{"*"}, CriticalOptions: map[string]string{"source-address": "10.0.0.1"}, TTL: 60, MaxTTL: 60},
+		{Name: "host-extensions", CertType: CertTypeHost, AllowedPrincipals: []string{"*"}, AllowedExtensions: []string{"permit-pty"}, TTL: 60, MaxTTL: 60},
+	} {
+		assert.ErrorIs(t, service.SaveRole(role), ErrInvalidRole, role.Name)
+	}
+}
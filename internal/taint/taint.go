@@ -0,0 +1,100 @@
+// Package taint tracks the plaintext values revealed while serving an HTTP request, so that the log sanitizer
+// can scrub them from every entry logged for that request, even when they end up inside an error message.
+package taint
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MinLength is the minimum length (in characters) of a tainted value.
+// Shorter values (e.g., "1", "yes") would scrub unrelated parts of log entries, so they are not tracked.
+const MinLength = 4
+
+// contextKey is the type of the context key, unexported so that no other package can collide with it.
+type contextKey struct{}
+
+// Set holds the tainted values of a request. It is safe for concurrent use.
+type Set struct {
+	mutex       sync.RWMutex
+	values      map[string]struct{}
+	replacer    *strings.Replacer
+	replacement string
+}
+
+// NewContext returns a copy of the context that carries an empty taint set.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, &Set{values: map[string]struct{}{}})
+}
+
+// FromContext returns the taint set carried by the context, or nil if there is none.
+func FromContext(ctx context.Context) *Set {
+	if ctx == nil {
+		return nil
+	}
+	set, _ := ctx.Value(contextKey{}).(*Set)
+	return set
+}
+
+// Add taints a plaintext value in the set carried by the context.
+// It does nothing outside of a request (e.g., in background jobs) or if the value is shorter than MinLength.
+func Add(ctx context.Context, value string) {
+	if set := FromContext(ctx); set != nil {
+		set.Add(value)
+	}
+}
+
+// Add taints a plaintext value, unless it is shorter than MinLength.
+func (s *Set) Add(value string) {
+	if utf8.RuneCountInString(value) < MinLength {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.values[value]; !found {
+		s.values[value] = struct{}{}
+		s.replacer = nil
+	}
+}
+
+// Scrub replaces every occurrence of a tainted value in a text with the replacement.
+// Longer values are replaced first, so a value that contains another one is scrubbed as a whole.
+func (s *Set) Scrub(text, replacement string) string {
+	if s == nil {
+		return text
+	}
+	return s.getReplacer(replacement).Replace(text)
+}
+
+// getReplacer returns the replacer of the tainted values, building it if values were added since it was last built
+// or if the replacement changed.
+func (s *Set) getReplacer(replacement string) *strings.Replacer {
+	s.mutex.RLock()
+	replacer := s.replacer
+	upToDate := replacer != nil && s.replacement == replacement
+	s.mutex.RUnlock()
+	if upToDate {
+		return replacer
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	values := make([]string, 0, len(s.values))
+	for value := range s.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, replacement)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+	s.replacement = replacement
+	return s.replacer
+}
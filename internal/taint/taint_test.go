@@ -0,0 +1,38 @@
+package taint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAddAndScrub tests that tainted values are scrubbed, longest first, and that short values are not tainted.
+func TestAddAndScrub(t *testing.T) {
+	ctx := NewContext(context.Background())
+	Add(ctx, "hunter2")
+	Add(ctx, "hunter2-admin")
+	Add(ctx, "abc")
+
+	scrubbed := FromContext(ctx).Scrub("failed to use 'hunter2-admin', then 'hunter2' as abc", "*****")
+	assert.Equal(t, "failed to use '*****', then '*****' as abc", scrubbed, "Short values are not tainted")
+}
+
+// TestScrubRebuildsAfterAdd tests that values added after a scrub, and other replacements, are taken into account.
+func TestScrubRebuildsAfterAdd(t *testing.T) {
+	set := FromContext(NewContext(context.Background()))
+	assert.Equal(t, "value s3cr3t", set.Scrub("value s3cr3t", "*****"))
+
+	set.Add("s3cr3t")
+	assert.Equal(t, "value *****", set.Scrub("value s3cr3t", "*****"))
+	assert.Equal(t, "value [x]", set.Scrub("value s3cr3t", "[x]"))
+}
+
+// TestWithoutSet tests that tainting and scrubbing do nothing outside of a request.
+func TestWithoutSet(t *testing.T) {
+	ctx := context.Background()
+	Add(ctx, "hunter2")
+
+	assert.Nil(t, FromContext(ctx))
+	assert.Equal(t, "hunter2", FromContext(ctx).Scrub("hunter2", "*****"))
+}
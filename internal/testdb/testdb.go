@@ -0,0 +1,46 @@
+// Package testdb connects repository tests to the PostgreSQL database of the test environment.
+// The connection settings are read from the same variables as in CI (DB_HOST, DB_PORT, POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_DB).
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/database"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open connects to the test database and applies the pending migrations, so that repositories are tested
+// against the same schema as in production. The test fails right away if the database cannot be reached.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// Get database variables from env
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		utils.GetEnvOrFallback("DB_HOST", "localhost"),
+		utils.GetEnvOrFallback("POSTGRES_USER", "testuser"),
+		utils.GetEnvOrFallback("POSTGRES_PASSWORD", "testpassword"),
+		utils.GetEnvOrFallback("POSTGRES_DB", "testdb"),
+		utils.GetEnvOrFallback("DB_PORT", "5432"),
+	)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	// Apply the migrations; concurrent test packages wait for each other on the migration lock
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	migrator, err := database.NewMigrator(sqlDB)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
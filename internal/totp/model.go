@@ -0,0 +1,93 @@
+package totp
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Algorithm identifies the HMAC hash function used to compute codes.
+type Algorithm string
+
+const (
+	// AlgorithmSHA1 is the default algorithm of RFC 6238, and the only one supported by some authenticator apps.
+	AlgorithmSHA1 Algorithm = "SHA1"
+
+	// AlgorithmSHA256 uses HMAC-SHA256.
+	AlgorithmSHA256 Algorithm = "SHA256"
+
+	// AlgorithmSHA512 uses HMAC-SHA512.
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+// Key is a named TOTP seed. The seed is encrypted with the master key and never returned after creation.
+type Key struct {
+	// ID is the unique identifier of the key.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name used to address the key in the API (e.g., "vendor-dashboard").
+	Name string `gorm:"unique;not null"`
+
+	// Issuer is the service the codes are used for, as shown by authenticator apps.
+	Issuer string `gorm:"not null"`
+
+	// AccountName is the account the codes are used for, as shown by authenticator apps.
+	AccountName string `gorm:"not null"`
+
+	// EncryptedSecret holds the base32-encoded seed, encrypted with the master key using secrets.Encrypt.
+	EncryptedSecret string `gorm:"not null"`
+
+	// Algorithm is the HMAC hash function used to compute codes.
+	Algorithm Algorithm `gorm:"not null"`
+
+	// Digits is the number of digits of a code (6 or 8).
+	Digits int `gorm:"not null"`
+
+	// Period is the number of seconds a code is valid for.
+	Period int `gorm:"not null"`
+
+	// Skew is the number of periods before and after the current one that are also accepted during validation.
+	Skew int `gorm:"not null"`
+
+	// LastUsedStep is the time step of the last code accepted by validation.
+	// Codes of this step or older are rejected, so a code can only be used once.
+	LastUsedStep int64 `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the key was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name of the Key model.
+func (Key) TableName() string {
+	return "totp_keys"
+}
+
+// KeyRequest holds the parameters of a TOTP key to generate.
+type KeyRequest struct {
+	// Name is the unique name of the new key.
+	Name string
+
+	// Issuer is the service the codes are used for.
+	Issuer string
+
+	// AccountName is the account the codes are used for.
+	AccountName string
+
+	// Algorithm is the HMAC hash function. Defaults to SHA1.
+	Algorithm Algorithm
+
+	// Digits is the number of digits of a code. Defaults to 6.
+	Digits int
+
+	// Period is the number of seconds a code is valid for. Defaults to 30.
+	Period int
+}
+
+// Code is a one-time code and the time it stops being current.
+type Code struct {
+	// Code is the one-time code.
+	Code string
+
+	// ValidUntil is the end of the period of the code.
+	ValidUntil time.Time
+}
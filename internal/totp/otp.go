@@ -0,0 +1,167 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default parameters of generated and imported keys, as defined by RFC 6238 and expected by most authenticator apps.
+const (
+	defaultDigits  = 6
+	defaultPeriod  = 30
+	defaultSkew    = 1
+	secretSize     = 20
+	otpauthScheme  = "otpauth"
+	otpauthTOTPKey = "totp"
+)
+
+// secretEncoding is the unpadded base32 encoding used by otpauth URLs.
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateSecret creates a new random seed.
+func generateSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+	return secret, nil
+}
+
+// decodeSecret decodes a base32 seed, ignoring case, spaces and padding as authenticator apps do.
+func decodeSecret(encoded string) ([]byte, error) {
+	encoded = strings.ToUpper(strings.ReplaceAll(encoded, " ", ""))
+	secret, err := secretEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("%w: the secret is not valid base32", ErrInvalidURL)
+	}
+	return secret, nil
+}
+
+// hashFunction returns the hash function of an algorithm.
+func hashFunction(algorithm Algorithm) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm '%s'", ErrInvalidParameters, algorithm)
+}
+
+// step returns the time step of a time for the given period.
+func step(t time.Time, period int) int64 {
+	return t.Unix() / int64(period)
+}
+
+// generateCode computes the code of a time step (RFC 4226 dynamic truncation, RFC 6238 time steps).
+func generateCode(secret []byte, counter int64, algorithm Algorithm, digits int) (string, error) {
+	newHash, err := hashFunction(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+	mac := hmac.New(newHash, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo), nil
+}
+
+// validateParameters checks the algorithm, digits and period of a key.
+func validateParameters(algorithm Algorithm, digits, period int) error {
+	if _, err := hashFunction(algorithm); err != nil {
+		return err
+	}
+	if digits != 6 && digits != 8 {
+		return fmt.Errorf("%w: digits must be 6 or 8", ErrInvalidParameters)
+	}
+	if period < 1 || period > 300 {
+		return fmt.Errorf("%w: period must be between 1 and 300 seconds", ErrInvalidParameters)
+	}
+	return nil
+}
+
+// formatURL creates the otpauth URL of a key, as understood by authenticator apps.
+func formatURL(key *Key, secret []byte) string {
+	label := key.AccountName
+	if key.Issuer != "" {
+		label = key.Issuer + ":" + key.AccountName
+	}
+
+	query := url.Values{}
+	query.Set("secret", secretEncoding.EncodeToString(secret))
+	if key.Issuer != "" {
+		query.Set("issuer", key.Issuer)
+	}
+	query.Set("algorithm", string(key.Algorithm))
+	query.Set("digits", strconv.Itoa(key.Digits))
+	query.Set("period", strconv.Itoa(key.Period))
+
+	return (&url.URL{Scheme: otpauthScheme, Host: otpauthTOTPKey, Path: "/" + label, RawQuery: query.Encode()}).String()
+}
+
+// parseURL parses an otpauth URL into the parameters of a key and its seed.
+func parseURL(rawURL string) (*Key, []byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != otpauthScheme {
+		return nil, nil, fmt.Errorf("%w: expected an otpauth:// URL", ErrInvalidURL)
+	}
+	if parsed.Host != otpauthTOTPKey {
+		return nil, nil, fmt.Errorf("%w: only TOTP keys are supported", ErrInvalidURL)
+	}
+
+	// The label is "issuer:account" or "account"
+	key := &Key{Algorithm: AlgorithmSHA1, Digits: defaultDigits, Period: defaultPeriod}
+	label := strings.TrimPrefix(parsed.Path, "/")
+	if issuer, account, found := strings.Cut(label, ":"); found {
+		key.Issuer, key.AccountName = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		key.AccountName = label
+	}
+
+	query := parsed.Query()
+	secret, err := decodeSecret(query.Get("secret"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if issuer := query.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = Algorithm(strings.ToUpper(algorithm))
+	}
+	if digits := query.Get("digits"); digits != "" {
+		if key.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid digits '%s'", ErrInvalidURL, digits)
+		}
+	}
+	if period := query.Get("period"); period != "" {
+		if key.Period, err = strconv.Atoi(period); err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid period '%s'", ErrInvalidURL, period)
+		}
+	}
+
+	return key, secret, nil
+}
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGenerateCodeRFC6238 tests code generation against the test vectors of RFC 6238, appendix B.
+func TestGenerateCodeRFC6238(t *testing.T) {
+	seeds := map[Algorithm]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	vectors := []struct {
+		unix      int64
+		algorithm Algorithm
+		code      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+
+	for _, vector := range vectors {
+		code, err := generateCode([]byte(seeds[vector.algorithm]), step(time.Unix(vector.unix, 0), 30), vector.algorithm, 8)
+		assert.NoError(t, err)
+		assert.Equal(t, vector.code, code, vector)
+	}
+}
+
+// TestURLRoundTrip tests that generated otpauth URLs can be imported back.
+func TestURLRoundTrip(t *testing.T) {
+	key := &Key{Issuer: "Vendor Inc", AccountName: "ops@example.com", Algorithm: AlgorithmSHA256, Digits: 8, Period: 60}
+	secret := []byte("12345678901234567890")
+
+	parsed, parsedSecret, err := parseURL(formatURL(key, secret))
+	assert.NoError(t, err)
+	assert.Equal(t, secret, parsedSecret)
+	assert.Equal(t, key.Issuer, parsed.Issuer)
+	assert.Equal(t, key.AccountName, parsed.AccountName)
+	assert.Equal(t, key.Algorithm, parsed.Algorithm)
+	assert.Equal(t, 8, parsed.Digits)
+	assert.Equal(t, 60, parsed.Period)
+
+	// Defaults apply when parameters are omitted, and the secret is case-insensitive
+	parsed, parsedSecret, err = parseURL("otpauth://totp/dashboard?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq")
+	assert.NoError(t, err)
+	assert.Equal(t, secret, parsedSecret)
+	assert.Equal(t, "dashboard", parsed.AccountName)
+	assert.Equal(t, AlgorithmSHA1, parsed.Algorithm)
+	assert.Equal(t, 6, parsed.Digits)
+}
+
+// TestNegativeParseURL tests importing invalid otpauth URLs.
+func TestNegativeParseURL(t *testing.T) {
+	for _, invalid := range []string{
+		"https://example.com/?secret=GEZDGNBV",
+		"otpauth://hotp/dashboard?secret=GEZDGNBV",
+		"otpauth://totp/dashboard",
+		"otpauth://totp/dashboard?secret=not-base32!",
+		"otpauth://totp/dashboard?secret=GEZDGNBV&digits=six",
+	} {
+		_, _, err := parseURL(invalid)
+		assert.ErrorIs(t, err, ErrInvalidURL, invalid)
+	}
+}
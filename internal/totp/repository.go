@@ -0,0 +1,70 @@
+package totp
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Repository interface defines methods for database interactions related to TOTP keys.
+type Repository interface {
+	// Saves a new key to the database
+	Save(key *Key) error
+
+	// Retrieves a key by its name
+	GetByName(name string) (*Key, error)
+
+	// Records the time step of an accepted code, unless the same or a later step was already used
+	MarkUsed(keyID uuid.UUID, step int64) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the TOTP key repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// Save inserts a new key record into the database.
+//
+// Parameters:
+// - key: The Key model, including its encrypted seed.
+//
+// Returns:
+// - error: Returns an error if the insertion fails (e.g., the name is already taken), otherwise nil.
+func (r *repository) Save(key *Key) error {
+	return r.db.Create(key).Error
+}
+
+// GetByName retrieves a key by its name.
+//
+// Parameters:
+// - name: The name of the key to retrieve.
+//
+// Returns:
+// - Key: The retrieved Key model.
+// - error: Returns an error if no key with the given name is found or if the query fails.
+func (r *repository) GetByName(name string) (*Key, error) {
+	var key *Key
+	err := r.db.First(&key, "name = ?", name).Error
+	return key, err
+}
+
+// MarkUsed records the time step of an accepted code.
+// The update is conditional, so that two concurrent validations of the same code cannot both succeed.
+//
+// Parameters:
+// - keyID: The UUID of the key.
+// - step: The time step of the accepted code.
+//
+// Returns:
+// - bool: Returns true if the step was recorded, or false if the same or a later step was already used.
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) MarkUsed(keyID uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&Key{}).
+		Where("id = ? AND last_used_step < ?", keyID, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
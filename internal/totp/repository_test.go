@@ -0,0 +1,41 @@
+package totp
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+)
+
+// TestRepoMarkUsed tests that a time step can only be marked as used if it is later than the last used step,
+// so that a code cannot be accepted twice by concurrent validations.
+func TestRepoMarkUsed(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewRepository(db)
+	key := &Key{
+		ID: uuid.New(), Name: "test-" + uuid.NewString(), Issuer: "Example", AccountName: "ops@example.com",
+		EncryptedSecret: "encrypted", Algorithm: AlgorithmSHA1, Digits: 6, Period: 30, Skew: 1,
+	}
+	require.NoError(t, repo.Save(key))
+	t.Cleanup(func() {
+		db.Delete(&Key{}, "id = ?", key.ID)
+	})
+
+	marked, err := repo.MarkUsed(key.ID, 100)
+	assert.NoError(t, err)
+	assert.True(t, marked)
+
+	// Assert the same and earlier steps are rejected
+	marked, err = repo.MarkUsed(key.ID, 100)
+	assert.NoError(t, err)
+	assert.False(t, marked)
+	marked, err = repo.MarkUsed(key.ID, 99)
+	assert.NoError(t, err)
+	assert.False(t, marked)
+
+	stored, err := repo.GetByName(key.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), stored.LastUsedStep)
+}
@@ -0,0 +1,234 @@
+package totp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// qrCodeSize is the width and height, in pixels, of generated QR codes.
+const qrCodeSize = 256
+
+var (
+	// ErrKeyNotFound is returned when no key exists with the requested name.
+	ErrKeyNotFound = errors.New("TOTP key not found")
+
+	// ErrKeyExists is returned when creating a key with a name that is already taken.
+	ErrKeyExists = errors.New("TOTP key already exists")
+
+	// ErrInvalidKeyName is returned when a key name contains characters that are not allowed.
+	ErrInvalidKeyName = errors.New("invalid key name")
+
+	// ErrInvalidURL is returned when an imported otpauth URL is malformed.
+	ErrInvalidURL = errors.New("invalid otpauth URL")
+
+	// ErrInvalidParameters is returned when the algorithm, digits or period of a key are not supported.
+	ErrInvalidParameters = errors.New("invalid TOTP parameters")
+)
+
+// keyNamePattern restricts key names to characters that are safe in URLs and logs.
+var keyNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// Service interface defines the business logic of the TOTP engine.
+// Seeds are encrypted with the master key at rest, and only returned once when Lockbox generates them.
+type Service interface {
+	// GenerateKey generates a new seed and stores it.
+	// Returns the created Key, its otpauth URL and a PNG QR code of the URL, to be enrolled with the service once.
+	GenerateKey(req KeyRequest, masterKey string) (*Key, string, []byte, error)
+
+	// ImportKey stores the seed of an existing otpauth URL.
+	// Returns the created Key or an error if the URL is invalid.
+	ImportKey(name, otpauthURL, masterKey string) (*Key, error)
+
+	// GetCode computes the current code of a key.
+	// Returns the Code or an error if something goes wrong.
+	GetCode(name, masterKey string) (*Code, error)
+
+	// Validate checks a submitted code, accepting the periods around the current one.
+	// A code is accepted only once: codes of an already accepted period or older are rejected.
+	// Returns whether the code is valid, or an error if something goes wrong.
+	Validate(name, code, masterKey string) (bool, error)
+}
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+// NewService creates a new TOTP service.
+func NewService(repo Repository) Service {
+	return &service{repo: repo, now: time.Now}
+}
+
+// GenerateKey generates a random seed with the requested parameters.
+func (s *service) GenerateKey(req KeyRequest, masterKey string) (*Key, string, []byte, error) {
+	key := &Key{Name: req.Name, Issuer: req.Issuer, AccountName: req.AccountName, Algorithm: req.Algorithm, Digits: req.Digits, Period: req.Period}
+	if key.Algorithm == "" {
+		key.Algorithm = AlgorithmSHA1
+	}
+	if key.Digits == 0 {
+		key.Digits = defaultDigits
+	}
+	if key.Period == 0 {
+		key.Period = defaultPeriod
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := s.save(key, secret, masterKey); err != nil {
+		return nil, "", nil, err
+	}
+
+	otpauthURL := formatURL(key, secret)
+	qrCode, err := qrcode.Encode(otpauthURL, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		err = fmt.Errorf("failed to render QR code: %v", err)
+		global.Logger.Error(err)
+		return nil, "", nil, err
+	}
+
+	return key, otpauthURL, qrCode, nil
+}
+
+// ImportKey parses an otpauth URL and stores its seed.
+func (s *service) ImportKey(name, otpauthURL, masterKey string) (*Key, error) {
+	key, secret, err := parseURL(otpauthURL)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Name = name
+	if err := s.save(key, secret, masterKey); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetCode computes the code of the current period.
+func (s *service) GetCode(name, masterKey string) (*Code, error) {
+	key, secret, err := s.load(name, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	current := step(now, key.Period)
+	code, err := generateCode(secret, current, key.Algorithm, key.Digits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Code{Code: code, ValidUntil: time.Unix((current+1)*int64(key.Period), 0)}, nil
+}
+
+// Validate compares the code with the codes of the periods within the skew, in constant time,
+// and records the period of a match so that the code cannot be replayed.
+func (s *service) Validate(name, code, masterKey string) (bool, error) {
+	key, secret, err := s.load(name, masterKey)
+	if err != nil {
+		return false, err
+	}
+	if len(code) != key.Digits {
+		return false, nil
+	}
+
+	current := step(s.now(), key.Period)
+	for counter := current - int64(key.Skew); counter <= current+int64(key.Skew); counter++ {
+		expected, err := generateCode(secret, counter, key.Algorithm, key.Digits)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		// Accept the code only if no code of this period or a later one was used before
+		if counter <= key.LastUsedStep {
+			global.Logger.Warnf("Rejected replayed TOTP code for key '%s'", name)
+			return false, nil
+		}
+		marked, err := s.repo.MarkUsed(key.ID, counter)
+		if err != nil {
+			err = fmt.Errorf("failed to record TOTP code use for key '%s': %v", name, err)
+			global.Logger.Error(err)
+			return false, err
+		}
+		if !marked {
+			global.Logger.Warnf("Rejected replayed TOTP code for key '%s'", name)
+		}
+		return marked, nil
+	}
+
+	return false, nil
+}
+
+// save validates a key, encrypts its seed with the master key and stores it.
+func (s *service) save(key *Key, secret []byte, masterKey string) error {
+	if !keyNamePattern.MatchString(key.Name) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidKeyName, key.Name)
+	}
+	if key.AccountName == "" {
+		return fmt.Errorf("%w: the account name is required", ErrInvalidParameters)
+	}
+	if err := validateParameters(key.Algorithm, key.Digits, key.Period); err != nil {
+		return err
+	}
+	if _, err := s.repo.GetByName(key.Name); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrKeyExists, key.Name)
+	}
+
+	encryptedSecret, err := secrets.Encrypt(secretEncoding.EncodeToString(secret), masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to encrypt TOTP secret: %v", err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	key.ID = uuid.New()
+	key.EncryptedSecret = encryptedSecret
+	key.Skew = defaultSkew
+	if err := s.repo.Save(key); err != nil {
+		err = fmt.Errorf("failed to store TOTP key in the database: %v", err)
+		global.Logger.Error(err)
+		return err
+	}
+
+	global.Logger.Infof("Stored TOTP key '%s' for %s (%s)", key.Name, key.AccountName, key.Issuer)
+	return nil
+}
+
+// load retrieves a key and decrypts its seed.
+func (s *service) load(name, masterKey string) (*Key, []byte, error) {
+	key, err := s.repo.GetByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: '%s'", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve TOTP key '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, nil, err
+	}
+
+	encodedSecret, err := secrets.Decrypt(key.EncryptedSecret, masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to decrypt TOTP secret of key '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, nil, err
+	}
+	secret, err := decodeSecret(encodedSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, secret, nil
+}
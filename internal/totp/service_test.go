@@ -0,0 +1,110 @@
+package totp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository of keys, so that code generation and validation are tested without a database.
+// The conditional update of MarkUsed is tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	keys map[string]*Key
+}
+
+func (r *memoryRepository) Save(key *Key) error {
+	r.keys[key.Name] = key
+	return nil
+}
+
+func (r *memoryRepository) GetByName(name string) (*Key, error) {
+	if key, found := r.keys[name]; found {
+		copied := *key
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) MarkUsed(keyID uuid.UUID, step int64) (bool, error) {
+	for _, key := range r.keys {
+		if key.ID == keyID && key.LastUsedStep < step {
+			key.LastUsedStep = step
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newTestService creates a TOTP service backed by an in-memory repository, with a clock set to the given time.
+func newTestService(now time.Time) *service {
+	global.Logger = logrus.New()
+	return &service{repo: &memoryRepository{keys: map[string]*Key{}}, now: func() time.Time { return now }}
+}
+
+// TestServiceGenerateKey tests that generated keys come with an importable URL and a PNG QR code.
+func TestServiceGenerateKey(t *testing.T) {
+	service := newTestService(time.Now())
+
+	key, otpauthURL, qrCode, err := service.GenerateKey(KeyRequest{Name: "dashboard", Issuer: "Vendor", AccountName: "ops@example.com"}, "master")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, key.Digits)
+	assert.True(t, bytes.HasPrefix(qrCode, []byte("\x89PNG")))
+
+	_, err = service.ImportKey("dashboard-copy", otpauthURL, "master")
+	assert.NoError(t, err)
+	original, err := service.GetCode("dashboard", "master")
+	assert.NoError(t, err)
+	copied, err := service.GetCode("dashboard-copy", "master")
+	assert.NoError(t, err)
+	assert.Equal(t, original.Code, copied.Code)
+
+	_, _, _, err = service.GenerateKey(KeyRequest{Name: "dashboard", AccountName: "ops@example.com"}, "master")
+	assert.ErrorIs(t, err, ErrKeyExists)
+	_, _, _, err = service.GenerateKey(KeyRequest{Name: "other", AccountName: "ops@example.com", Digits: 7}, "master")
+	assert.ErrorIs(t, err, ErrInvalidParameters)
+}
+
+// TestServiceValidateReplay tests that codes within the skew are accepted once, and rejected when replayed.
+func TestServiceValidateReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	service := newTestService(now)
+	_, err := service.ImportKey("dashboard", "otpauth://totp/Vendor:ops?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8", "master")
+	assert.NoError(t, err)
+
+	code, err := service.GetCode("dashboard", "master")
+	assert.NoError(t, err)
+	assert.Equal(t, "07081804", code.Code)
+	assert.Equal(t, time.Unix(1111111110, 0), code.ValidUntil)
+
+	// The code of the previous period is still accepted, once
+	previous, err := generateCode([]byte("12345678901234567890"), step(now, 30)-1, AlgorithmSHA1, 8)
+	assert.NoError(t, err)
+	valid, err := service.Validate("dashboard", previous, "master")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = service.Validate("dashboard", code.Code, "master")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// Replays, and codes older than the last accepted one, are rejected
+	valid, err = service.Validate("dashboard", code.Code, "master")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	valid, err = service.Validate("dashboard", previous, "master")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	valid, err = service.Validate("dashboard", "12345678", "master")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, err = service.Validate("missing", code.Code, "master")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey is the key of the span of a query in the gorm statement, between the callbacks that start and end it.
+const spanKey = "tracing:span"
+
+// querySpan is the span of a query in progress, with the operation it was started for.
+type querySpan struct {
+	span      trace.Span
+	operation string
+}
+
+// GormPlugin is a gorm plugin that records a span for every database query run with a traced context
+// (i.e., through db.WithContext(ctx) while handling a traced request).
+// Queries without a parent span, such as the polling of background workers, are not traced.
+//
+// Spans record the operation (e.g., "SELECT") and the table of the query. Its SQL and arguments are left out,
+// since they may contain secret keys and, for some statements (e.g., ALTER ROLE ... PASSWORD), secret values.
+type GormPlugin struct{}
+
+// Name returns the name the plugin is registered under.
+func (GormPlugin) Name() string {
+	return "lockbox:tracing"
+}
+
+// Initialize registers the callbacks that start and end the spans around each kind of query.
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", startSpan("INSERT")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", startSpan("SELECT")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", startSpan("UPDATE")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("DELETE")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", startSpan("SELECT")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	)
+}
+
+// startSpan returns a callback that starts the span of a query as a child of the span of its context.
+// Raw queries have no known operation, so the first keyword of their SQL is used instead (e.g., "ALTER").
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		queryOperation := operation
+		if queryOperation == "" {
+			queryOperation = "RAW"
+			if keyword, _, _ := strings.Cut(strings.TrimSpace(db.Statement.SQL.String()), " "); keyword != "" {
+				queryOperation = strings.ToUpper(keyword)
+			}
+		}
+
+		ctx, span := Tracer().Start(ctx, queryOperation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(queryOperation)),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, &querySpan{span: span, operation: queryOperation})
+	}
+}
+
+// endSpan records the table, the number of affected rows and the outcome of a query, then ends its span.
+// Only the SQLSTATE of a failed query is recorded, since database error messages may quote the values involved.
+func endSpan(db *gorm.DB) {
+	value, found := db.InstanceGet(spanKey)
+	if !found {
+		return
+	}
+	query := value.(*querySpan)
+	span := query.span
+	defer span.End()
+
+	if table := db.Statement.Table; table != "" {
+		span.SetName(query.operation + " " + table)
+		span.SetAttributes(semconv.DBCollectionName(table))
+	}
+	span.SetAttributes(attribute.Int64("db.response.rows_affected", db.Statement.RowsAffected))
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		errorType := errorType(db.Error)
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+		span.SetStatus(codes.Error, errorType)
+	}
+}
+
+// errorType describes a query error without its message: the SQLSTATE of database errors (e.g., "23505"),
+// or a generic type for the others.
+func errorType(err error) string {
+	var sqlError interface{ SQLState() string }
+	switch {
+	case errors.As(err, &sqlError):
+		return sqlError.SQLState()
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "_OTHER"
+	}
+}
@@ -0,0 +1,132 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider and its exporter, W3C trace context propagation,
+// and the spans of database queries.
+//
+// Spans must never carry secret keys or values. HTTP spans are named after route templates (e.g., "/secrets/{query}")
+// rather than paths, and database spans record the operation and table of a query but never its SQL or arguments.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by Lockbox itself.
+const instrumentationName = "gitlab.com/xrs-cloud/lockbox/core"
+
+// serviceName is the name of the service reported with every span.
+const serviceName = "lockbox"
+
+const (
+	// ExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	ExporterOTLP = "otlp"
+
+	// ExporterStdout writes spans as JSON to the standard output, for local testing.
+	ExporterStdout = "stdout"
+
+	// ExporterFile writes spans as JSON to a file, for local testing.
+	ExporterFile = "file"
+)
+
+// Tracer returns the tracer used for the spans of Lockbox.
+// Until Setup enables tracing, the spans it starts are not recorded.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the tracer provider and the W3C trace context propagator described by the tracing configuration.
+// It returns a function that flushes the pending spans and stops the exporter, to call on shutdown.
+// When tracing is disabled, nothing is installed and the returned function does nothing.
+func Setup(ctx context.Context, tracingConfig config.TracingConfig) (func(context.Context) error, error) {
+	if !tracingConfig.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if tracingConfig.SampleRatio < 0 || tracingConfig.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid tracing sample ratio %v: it must be between 0 and 1", tracingConfig.SampleRatio)
+	}
+
+	// Create the exporter
+	exporter, output, err := newExporter(ctx, tracingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Describe the service, on top of the host and process attributes (and OTEL_RESOURCE_ATTRIBUTES)
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the tracing resource: %v", err)
+	}
+
+	// Sample new traces with the configured ratio, and follow the decision of the caller for the others
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if output != nil {
+			err = errors.Join(err, output.Close())
+		}
+		return err
+	}, nil
+}
+
+// newExporter creates the span exporter of the configuration.
+// The "file" exporter also returns the file it writes to, which must be closed once the exporter is stopped.
+func newExporter(ctx context.Context, tracingConfig config.TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch tracingConfig.Exporter {
+	case ExporterOTLP:
+		// The standard OTEL_EXPORTER_OTLP_* environment variables (e.g., headers) are honoured as well
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(tracingConfig.Endpoint)}
+		if tracingConfig.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create the OTLP exporter: %v", err)
+		}
+		return exporter, nil, nil
+
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create the stdout exporter: %v", err)
+		}
+		return exporter, nil, nil
+
+	case ExporterFile:
+		file, err := os.OpenFile(tracingConfig.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open the trace file: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to create the file exporter: %v", err)
+		}
+		return exporter, file, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported tracing exporter '%s' (expected '%s', '%s' or '%s')",
+			tracingConfig.Exporter, ExporterOTLP, ExporterStdout, ExporterFile)
+	}
+}
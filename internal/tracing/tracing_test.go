@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testSecret is a model of the tests, whose key and value must never appear in spans.
+type testSecret struct {
+	ID    int
+	Key   string
+	Value string
+}
+
+// newTestRecorder installs a tracer provider that records spans in memory, and restores the previous one after the test.
+func newTestRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// newTestDatabase opens a gorm database on a SQL mock, with the tracing plugin.
+func newTestDatabase(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+	return db, mock
+}
+
+// TestGormPluginRecordsQueries tests that queries of a traced context are recorded as child spans,
+// with their operation and table but without their SQL or arguments.
+func TestGormPluginRecordsQueries(t *testing.T) {
+	recorder := newTestRecorder(t)
+	db, mock := newTestDatabase(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "test_secrets"`).
+		WithArgs("db/password", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "value"}).AddRow(1, "db/password", "hunter2"))
+	mock.ExpectExec(`ALTER ROLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, parent := Tracer().Start(context.Background(), "GET /secrets/{query}")
+	var secret testSecret
+	require.NoError(t, db.WithContext(ctx).First(&secret, "key = ?", "db/password").Error)
+	require.NoError(t, db.WithContext(ctx).Exec(`ALTER ROLE "app" WITH PASSWORD 'hunter2'`).Error)
+	parent.End()
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	query, alter := spans[0], spans[1]
+	assert.Equal(t, "SELECT test_secrets", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, "ALTER", alter.Name())
+	for _, span := range spans {
+		for _, attribute := range span.Attributes() {
+			assert.NotContains(t, attribute.Value.Emit(), "hunter2")
+			assert.NotContains(t, attribute.Value.Emit(), "db/password")
+		}
+	}
+}
+
+// TestGormPluginSkipsUntracedQueries tests that queries without a parent span, such as the polling of background workers, are not recorded.
+func TestGormPluginSkipsUntracedQueries(t *testing.T) {
+	recorder := newTestRecorder(t)
+	db, mock := newTestDatabase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "test_secrets"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, db.WithContext(context.Background()).Delete(&testSecret{}, "id = ?", 1).Error)
+
+	assert.Empty(t, recorder.Ended())
+}
+
+// TestGormPluginRecordsErrorsWithoutMessages tests that failed queries are flagged without recording their error message.
+func TestGormPluginRecordsErrorsWithoutMessages(t *testing.T) {
+	recorder := newTestRecorder(t)
+	db, mock := newTestDatabase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "test_secrets"`).WillReturnError(context.DeadlineExceeded)
+	mock.ExpectRollback()
+
+	ctx, parent := Tracer().Start(context.Background(), "POST /secrets")
+	err := db.WithContext(ctx).Create(&testSecret{Key: "db/password", Value: "hunter2"}).Error
+	parent.End()
+	require.Error(t, err)
+
+	span := recorder.Ended()[0]
+	assert.Equal(t, "INSERT test_secrets", span.Name())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, "timeout", span.Status().Description)
+}
+
+// TestSetup tests that tracing is only installed when enabled, with a valid exporter and sample ratio.
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Enabled: false, Exporter: "unknown"})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), config.TracingConfig{Enabled: true, Exporter: "unknown", SampleRatio: 1})
+	assert.ErrorContains(t, err, "unsupported tracing exporter")
+
+	_, err = Setup(context.Background(), config.TracingConfig{Enabled: true, Exporter: ExporterStdout, SampleRatio: 2})
+	assert.ErrorContains(t, err, "sample ratio")
+}
+
+// TestSetupFileExporter tests that the file exporter writes the spans to the file when tracing is shut down.
+func TestSetupFileExporter(t *testing.T) {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	filePath := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Enabled: true, Exporter: ExporterFile, FilePath: filePath, SampleRatio: 1})
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "GET /healthz")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"Name":"GET /healthz"`)
+	assert.Contains(t, string(content), `"Value":"lockbox"`)
+}
@@ -0,0 +1,107 @@
+package transit
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+)
+
+// ciphertextPrefix starts every transit ciphertext, and is followed by "v<version>:" and the base64 payload.
+const ciphertextPrefix = "lockbox:"
+
+var (
+	// ErrInvalidCiphertext is returned when a ciphertext is malformed or fails authentication.
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+	// ErrVersionNotAllowed is returned when a ciphertext uses a version below the key's minimum decryption version,
+	// or a version that does not exist.
+	ErrVersionNotAllowed = errors.New("key version is not allowed for decryption")
+)
+
+// keyring holds the decrypted material of the usable versions of a key.
+// It is built from a Key for the duration of a single operation and never persisted.
+type keyring struct {
+	keyType       KeyType        // The algorithm of the key
+	latest        int            // The version used for new encryptions
+	minDecryption int            // The oldest version allowed for decryption
+	material      map[int][]byte // The raw material of each version
+}
+
+// encrypt encrypts the plaintext with the latest version of the key.
+// The additional data, if any, is authenticated but not encrypted, and must be given again to decrypt.
+// Returns the ciphertext in the form "lockbox:v<version>:<base64>" and the version used.
+func (k *keyring) encrypt(plainText, associatedData []byte) (string, int, error) {
+	encrypted, err := secrets.EncryptWithKey(k.material[k.latest], plainText, associatedData)
+	if err != nil {
+		return "", 0, err
+	}
+	return formatCiphertext(k.latest, encrypted), k.latest, nil
+}
+
+// decrypt decrypts a ciphertext produced by encrypt with any version allowed for decryption.
+// Returns the plaintext and the version that was used to encrypt it.
+func (k *keyring) decrypt(ciphertext string, associatedData []byte) ([]byte, int, error) {
+	version, payload, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Refuse versions that have been retired or never existed
+	material, found := k.material[version]
+	if !found || version < k.minDecryption {
+		return nil, version, fmt.Errorf("%w: version %d (minimum %d, latest %d)", ErrVersionNotAllowed, version, k.minDecryption, k.latest)
+	}
+
+	plainText, err := secrets.DecryptWithKey(material, payload, associatedData)
+	if err != nil {
+		return nil, version, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	return plainText, version, nil
+}
+
+// formatCiphertext encodes a payload and the version of the key that produced it.
+func formatCiphertext(version int, payload []byte) string {
+	return fmt.Sprintf("%sv%d:%s", ciphertextPrefix, version, base64.StdEncoding.EncodeToString(payload))
+}
+
+// parseCiphertext extracts the key version and the payload from a ciphertext produced by formatCiphertext.
+func parseCiphertext(ciphertext string) (int, []byte, error) {
+	rest, found := strings.CutPrefix(ciphertext, ciphertextPrefix+"v")
+	if !found {
+		return 0, nil, fmt.Errorf("%w: missing '%sv' prefix", ErrInvalidCiphertext, ciphertextPrefix)
+	}
+	versionValue, encoded, found := strings.Cut(rest, ":")
+	if !found {
+		return 0, nil, fmt.Errorf("%w: missing version separator", ErrInvalidCiphertext)
+	}
+
+	version, err := strconv.Atoi(versionValue)
+	if err != nil || version < 1 {
+		return 0, nil, fmt.Errorf("%w: invalid version '%s'", ErrInvalidCiphertext, versionValue)
+	}
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: invalid base64 payload", ErrInvalidCiphertext)
+	}
+
+	return version, payload, nil
+}
+
+// generateMaterial creates new random key material for the given key type.
+func generateMaterial(keyType KeyType) ([]byte, error) {
+	switch keyType {
+	case KeyTypeAES256GCM:
+		material := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, material); err != nil {
+			return nil, fmt.Errorf("failed to generate key material: %v", err)
+		}
+		return material, nil
+	}
+	return generateKeyPair(keyType)
+}
@@ -0,0 +1,85 @@
+package transit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+)
+
+// newTestKeyring creates an in-memory keyring with the given number of versions.
+func newTestKeyring(t *testing.T, versions int) *keyring {
+	global.Logger = logrus.New()
+
+	ring := &keyring{keyType: KeyTypeAES256GCM, latest: versions, minDecryption: 1, material: map[int][]byte{}}
+	for version := 1; version <= versions; version++ {
+		material, err := generateMaterial(KeyTypeAES256GCM)
+		assert.NoError(t, err)
+		ring.material[version] = material
+	}
+	return ring
+}
+
+// TestKeyringEncryptDecrypt tests that ciphertexts are versioned and can be decrypted.
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	ring := newTestKeyring(t, 1)
+
+	ciphertext, version, err := ring.encrypt([]byte("4111 1111 1111 1111"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.True(t, strings.HasPrefix(ciphertext, "lockbox:v1:"))
+
+	plainText, version, err := ring.decrypt(ciphertext, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, "4111 1111 1111 1111", string(plainText))
+}
+
+// TestKeyringRotation tests that old ciphertexts still decrypt after a rotation until their version is retired.
+func TestKeyringRotation(t *testing.T) {
+	ring := newTestKeyring(t, 1)
+	oldCiphertext, _, err := ring.encrypt([]byte("pii"), nil)
+	assert.NoError(t, err)
+
+	// Rotate the key
+	material, err := generateMaterial(KeyTypeAES256GCM)
+	assert.NoError(t, err)
+	ring.material[2] = material
+	ring.latest = 2
+
+	// New encryptions use the new version, old ciphertexts still decrypt
+	newCiphertext, version, err := ring.encrypt([]byte("pii"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.True(t, strings.HasPrefix(newCiphertext, "lockbox:v2:"))
+	plainText, _, err := ring.decrypt(oldCiphertext, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "pii", string(plainText))
+
+	// Retire version 1
+	ring.minDecryption = 2
+	_, _, err = ring.decrypt(oldCiphertext, nil)
+	assert.ErrorIs(t, err, ErrVersionNotAllowed)
+	_, _, err = ring.decrypt(newCiphertext, nil)
+	assert.NoError(t, err)
+}
+
+// TestKeyringNegativeDecrypt tests decrypting malformed, tampered and mismatched ciphertexts.
+func TestKeyringNegativeDecrypt(t *testing.T) {
+	ring := newTestKeyring(t, 1)
+	ciphertext, _, err := ring.encrypt([]byte("pii"), []byte("customer-42"))
+	assert.NoError(t, err)
+
+	_, _, err = ring.decrypt(ciphertext, []byte("customer-43"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	for _, invalid := range []string{"", "vault:v1:abc", "lockbox:v0:abc", "lockbox:vx:abc", "lockbox:v1", "lockbox:v1:***"} {
+		_, _, err = ring.decrypt(invalid, nil)
+		assert.ErrorIs(t, err, ErrInvalidCiphertext, invalid)
+	}
+
+	_, _, err = ring.decrypt("lockbox:v7:"+strings.Split(ciphertext, ":")[2], nil)
+	assert.ErrorIs(t, err, ErrVersionNotAllowed)
+}
@@ -0,0 +1,88 @@
+package transit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyType identifies the algorithm of a transit key.
+type KeyType string
+
+const (
+	// KeyTypeAES256GCM is a symmetric AES-256 GCM key used for encryption and decryption.
+	KeyTypeAES256GCM KeyType = "aes256-gcm"
+
+	// KeyTypeEd25519 is an Ed25519 key pair used for signing and verification.
+	KeyTypeEd25519 KeyType = "ed25519"
+
+	// KeyTypeECDSAP256 is an ECDSA key pair on the NIST P-256 curve used for signing and verification.
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+
+	// KeyTypeRSAPSS2048 is a 2048-bit RSA key pair used for RSA-PSS signing and verification.
+	KeyTypeRSAPSS2048 KeyType = "rsa-pss-2048"
+)
+
+// asymmetric reports whether the key type is a key pair used for signatures.
+func (t KeyType) asymmetric() bool {
+	return t == KeyTypeEd25519 || t == KeyTypeECDSAP256 || t == KeyTypeRSAPSS2048
+}
+
+// Key is a named transit key. Applications use it through the transit endpoints without ever seeing its material.
+// A key has one or more versions: rotating the key adds a new version, and older versions stay available
+// for decryption until they fall below MinDecryptionVersion.
+type Key struct {
+	// ID is the unique identifier of the key.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// Name is the unique name used to address the key in the API (e.g., "customers-pii").
+	Name string `gorm:"unique;not null"`
+
+	// Type is the algorithm of the key.
+	Type KeyType `gorm:"not null"`
+
+	// LatestVersion is the version used for new encryptions and signatures.
+	LatestVersion int `gorm:"not null"`
+
+	// MinDecryptionVersion is the oldest version that may still be used to decrypt or verify.
+	// Raising it retires older versions once all data has been rewrapped.
+	MinDecryptionVersion int `gorm:"not null"`
+
+	// Versions holds the material of every version of the key.
+	Versions []KeyVersion `gorm:"foreignKey:KeyID"`
+
+	// CreatedAt stores the timestamp of when the key was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// UpdatedAt stores the timestamp of when the key was last rotated or reconfigured.
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName sets the table name of the Key model.
+func (Key) TableName() string {
+	return "transit_keys"
+}
+
+// KeyVersion holds the material of a single version of a transit key.
+type KeyVersion struct {
+	// ID is the unique identifier of the key version.
+	ID uuid.UUID `gorm:"primaryKey"`
+
+	// KeyID is the ID of the key this version belongs to.
+	KeyID uuid.UUID `gorm:"not null"`
+
+	// Version is the version number, starting at 1.
+	Version int `gorm:"not null"`
+
+	// EncryptedMaterial holds the key material, hex-encoded and encrypted with the master key using secrets.Encrypt.
+	// The material of asymmetric keys is their PKCS #8 private key.
+	EncryptedMaterial string `gorm:"not null"`
+
+	// CreatedAt stores the timestamp of when the version was created.
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name of the KeyVersion model.
+func (KeyVersion) TableName() string {
+	return "transit_key_versions"
+}
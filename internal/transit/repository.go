@@ -0,0 +1,99 @@
+package transit
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface defines methods for database interactions related to transit keys.
+type Repository interface {
+	// Saves a new key and its initial version to the database
+	Save(key *Key) error
+
+	// Retrieves a key and all its versions by the key name
+	GetByName(name string) (*Key, error)
+
+	// Adds a new version to a key and makes it the latest version
+	AddVersion(keyID uuid.UUID, encryptedMaterial string) (int, error)
+
+	// Updates the minimum decryption version of a key
+	UpdateMinDecryptionVersion(keyID uuid.UUID, version int) error
+}
+
+type repository struct {
+	db *gorm.DB // The database connection, injected into the repository
+}
+
+// NewRepository creates a new instance of the transit key repository.
+// The repository is initialized with a GORM database connection.
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+// Save inserts a new key record, along with its versions, into the database.
+//
+// Parameters:
+// - key: The Key model, including its initial version.
+//
+// Returns:
+// - error: Returns an error if the insertion fails (e.g., the name is already taken), otherwise nil.
+func (r *repository) Save(key *Key) error {
+	return r.db.Create(key).Error
+}
+
+// GetByName retrieves a key and its versions, ordered by version, by the key name.
+//
+// Parameters:
+// - name: The name of the key to retrieve.
+//
+// Returns:
+// - Key: The retrieved Key model.
+// - error: Returns an error if no key with the given name is found or if the query fails.
+func (r *repository) GetByName(name string) (*Key, error) {
+	var key *Key
+	err := r.db.Preload("Versions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("version")
+	}).First(&key, "name = ?", name).Error
+	return key, err
+}
+
+// AddVersion adds a new version to a key and makes it the latest version.
+// The key row is locked for the duration of the transaction, so concurrent rotations get distinct version numbers.
+//
+// Parameters:
+// - keyID: The UUID of the key to rotate.
+// - encryptedMaterial: The encrypted material of the new version.
+//
+// Returns:
+// - int: The number of the new version.
+// - error: Returns an error if the key does not exist or the update fails, otherwise nil.
+func (r *repository) AddVersion(keyID uuid.UUID, encryptedMaterial string) (int, error) {
+	var newVersion int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var key Key
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, "id = ?", keyID).Error; err != nil {
+			return err
+		}
+
+		newVersion = key.LatestVersion + 1
+		version := &KeyVersion{ID: uuid.New(), KeyID: keyID, Version: newVersion, EncryptedMaterial: encryptedMaterial}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Key{}).Where("id = ?", keyID).Update("latest_version", newVersion).Error
+	})
+	return newVersion, err
+}
+
+// UpdateMinDecryptionVersion modifies the minimum decryption version of a key.
+//
+// Parameters:
+// - keyID: The UUID of the key to update.
+// - version: The new minimum decryption version.
+//
+// Returns:
+// - error: Returns an error if the update fails, otherwise nil.
+func (r *repository) UpdateMinDecryptionVersion(keyID uuid.UUID, version int) error {
+	return r.db.Model(&Key{}).Where("id = ?", keyID).Update("min_decryption_version", version).Error
+}
@@ -0,0 +1,60 @@
+package transit
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/testdb"
+)
+
+// TestRepoAddVersion tests that concurrent rotations of a key each add a distinct version,
+// and that the versions are loaded in order with the key.
+func TestRepoAddVersion(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewRepository(db)
+	key := &Key{
+		ID: uuid.New(), Name: "test-" + uuid.NewString(), Type: KeyTypeAES256GCM, LatestVersion: 1, MinDecryptionVersion: 1,
+	}
+	key.Versions = []KeyVersion{{ID: uuid.New(), KeyID: key.ID, Version: 1, EncryptedMaterial: "material-1"}}
+	require.NoError(t, repo.Save(key))
+	t.Cleanup(func() {
+		db.Delete(&Key{}, "id = ?", key.ID)
+	})
+
+	// Rotate the key from several replicas at once
+	var mutex sync.Mutex
+	var added []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			version, err := repo.AddVersion(key.ID, fmt.Sprintf("material-%d", i))
+			assert.NoError(t, err)
+			mutex.Lock()
+			added = append(added, version)
+			mutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	sort.Ints(added)
+	assert.Equal(t, []int{2, 3, 4, 5, 6}, added)
+
+	// Assert the key was read back with every version, in order
+	require.NoError(t, repo.UpdateMinDecryptionVersion(key.ID, 3))
+	stored, err := repo.GetByName(key.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stored.LatestVersion)
+	assert.Equal(t, 3, stored.MinDecryptionVersion)
+	if assert.Len(t, stored.Versions, 6) {
+		for i, version := range stored.Versions {
+			assert.Equal(t, i+1, version.Version)
+		}
+		assert.Equal(t, "material-1", stored.Versions[0].EncryptedMaterial)
+	}
+}
@@ -0,0 +1,402 @@
+package transit
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/global"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrKeyNotFound is returned when no key exists with the requested name.
+	ErrKeyNotFound = errors.New("transit key not found")
+
+	// ErrKeyExists is returned when creating a key with a name that is already taken.
+	ErrKeyExists = errors.New("transit key already exists")
+
+	// ErrInvalidKeyName is returned when a key name contains characters that are not allowed.
+	ErrInvalidKeyName = errors.New("invalid key name")
+
+	// ErrUnsupportedKeyType is returned when a key type is unknown, or does not support the requested operation.
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+	// ErrInvalidVersion is returned when a minimum decryption version is outside of the existing versions.
+	ErrInvalidVersion = errors.New("invalid key version")
+)
+
+// keyNamePattern restricts key names to characters that are safe in URLs and logs.
+var keyNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+// Service interface defines the business logic of the transit engine.
+// The key material never leaves the service: callers only see ciphertexts and plaintexts.
+type Service interface {
+	// CreateKey creates a named key of the given type with a first version.
+	// The key material is encrypted with the masterKey before being stored.
+	// Returns the created Key or an error if something goes wrong.
+	CreateKey(name string, keyType KeyType, masterKey string) (*Key, error)
+
+	// GetKey retrieves a key and the metadata of its versions by name.
+	// Returns the Key or ErrKeyNotFound.
+	GetKey(name string) (*Key, error)
+
+	// RotateKey adds a new version to a key. New encryptions use the new version,
+	// and ciphertexts produced by older versions can still be decrypted.
+	// Returns the updated Key or an error if something goes wrong.
+	RotateKey(name, masterKey string) (*Key, error)
+
+	// SetMinDecryptionVersion sets the oldest version allowed for decryption, retiring older versions.
+	// Returns the updated Key or an error if the version does not exist.
+	SetMinDecryptionVersion(name string, version int) (*Key, error)
+
+	// Encrypt encrypts the plaintext with the latest version of the key.
+	// Returns the versioned ciphertext and the version used, or an error if something goes wrong.
+	Encrypt(name string, plainText, associatedData []byte, masterKey string) (string, int, error)
+
+	// Decrypt decrypts a ciphertext produced by Encrypt or Rewrap.
+	// Returns the plaintext or an error if the ciphertext is invalid or its version is not allowed.
+	Decrypt(name, ciphertext string, associatedData []byte, masterKey string) ([]byte, error)
+
+	// Rewrap decrypts a ciphertext and encrypts it again with the latest version of the key,
+	// without returning the plaintext. Returns the new ciphertext and the version used.
+	Rewrap(name, ciphertext string, associatedData []byte, masterKey string) (string, int, error)
+
+	// GenerateDataKey generates a random 256-bit data key and wraps it with the latest version of the key.
+	// The data key is not stored: the caller encrypts data locally with the plaintext and keeps the wrapped copy,
+	// which can be unwrapped later with Decrypt. Returns the plaintext data key, the wrapped data key and the version used.
+	GenerateDataKey(name string, associatedData []byte, masterKey string) ([]byte, string, int, error)
+
+	// Sign signs the data with the latest version of an asymmetric key.
+	// Returns the versioned signature and the version used, or an error if something goes wrong.
+	Sign(name string, data []byte, masterKey string) (string, int, error)
+
+	// Verify checks a signature produced by Sign.
+	// Returns whether the signature is valid, or an error if it is malformed or its version is not allowed.
+	Verify(name string, data []byte, signature, masterKey string) (bool, error)
+
+	// HMAC computes the HMAC-SHA256 of the data with the latest version of a symmetric key.
+	// Returns the versioned HMAC and the version used, or an error if something goes wrong.
+	HMAC(name string, data []byte, masterKey string) (string, int, error)
+
+	// VerifyHMAC checks an HMAC produced by HMAC.
+	// Returns whether the HMAC is valid, or an error if it is malformed or its version is not allowed.
+	VerifyHMAC(name string, data []byte, mac, masterKey string) (bool, error)
+
+	// PublicKeys returns the public key of every version of an asymmetric key allowed for verification,
+	// in PEM or JWK format. Returns a map of versions to public keys, or an error if something goes wrong.
+	PublicKeys(name, format, masterKey string) (map[int]interface{}, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService creates a new transit service.
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+// CreateKey generates the material of the first version of a new key and stores it encrypted with the master key.
+func (s *service) CreateKey(name string, keyType KeyType, masterKey string) (*Key, error) {
+	if !keyNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidKeyName, name)
+	}
+
+	// Refuse to replace an existing key, which would make its ciphertexts undecryptable
+	if _, err := s.repo.GetByName(name); err == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrKeyExists, name)
+	}
+
+	// Generate and protect the material of the first version
+	encryptedMaterial, err := newEncryptedMaterial(keyType, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the Key model
+	key := &Key{
+		ID:                   uuid.New(),
+		Name:                 name,
+		Type:                 keyType,
+		LatestVersion:        1,
+		MinDecryptionVersion: 1,
+	}
+	key.Versions = []KeyVersion{{ID: uuid.New(), KeyID: key.ID, Version: 1, EncryptedMaterial: encryptedMaterial}}
+
+	// Save the key in the repository
+	if err := s.repo.Save(key); err != nil {
+		err = fmt.Errorf("failed to store transit key in the database: %v", err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Created transit key '%s' of type %s", name, keyType)
+	return key, nil
+}
+
+// GetKey retrieves a key by name.
+func (s *service) GetKey(name string) (*Key, error) {
+	key, err := s.repo.GetByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve transit key '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// RotateKey generates the material of a new version and makes it the latest version of the key.
+func (s *service) RotateKey(name, masterKey string) (*Key, error) {
+	key, err := s.GetKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate and protect the material of the new version
+	encryptedMaterial, err := newEncryptedMaterial(key.Type, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the new version
+	version, err := s.repo.AddVersion(key.ID, encryptedMaterial)
+	if err != nil {
+		err = fmt.Errorf("failed to rotate transit key '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Rotated transit key '%s' to version %d", name, version)
+	return s.GetKey(name)
+}
+
+// SetMinDecryptionVersion sets the oldest version of the key that may be used to decrypt.
+func (s *service) SetMinDecryptionVersion(name string, version int) (*Key, error) {
+	key, err := s.GetKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The minimum cannot retire the latest version, which is still used for encryption
+	if version < 1 || version > key.LatestVersion {
+		return nil, fmt.Errorf("%w: %d must be between 1 and the latest version (%d)", ErrInvalidVersion, version, key.LatestVersion)
+	}
+
+	if err := s.repo.UpdateMinDecryptionVersion(key.ID, version); err != nil {
+		err = fmt.Errorf("failed to update transit key '%s': %v", name, err)
+		global.Logger.Error(err)
+		return nil, err
+	}
+
+	global.Logger.Infof("Set minimum decryption version of transit key '%s' to %d", name, version)
+	key.MinDecryptionVersion = version
+	return key, nil
+}
+
+// Encrypt encrypts the plaintext with the latest version of the key.
+func (s *service) Encrypt(name string, plainText, associatedData []byte, masterKey string) (string, int, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return "", 0, err
+	}
+	if ring.keyType != KeyTypeAES256GCM {
+		return "", 0, fmt.Errorf("%w: key '%s' of type %s cannot encrypt", ErrUnsupportedKeyType, name, ring.keyType)
+	}
+
+	return ring.encrypt(plainText, associatedData)
+}
+
+// Decrypt decrypts a ciphertext with the version of the key that produced it.
+func (s *service) Decrypt(name, ciphertext string, associatedData []byte, masterKey string) ([]byte, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if ring.keyType != KeyTypeAES256GCM {
+		return nil, fmt.Errorf("%w: key '%s' of type %s cannot decrypt", ErrUnsupportedKeyType, name, ring.keyType)
+	}
+
+	plainText, _, err := ring.decrypt(ciphertext, associatedData)
+	if err != nil {
+		global.Logger.Debugf("Failed to decrypt with transit key '%s': %v", name, err)
+		return nil, err
+	}
+	return plainText, nil
+}
+
+// Rewrap re-encrypts a ciphertext with the latest version of the key.
+func (s *service) Rewrap(name, ciphertext string, associatedData []byte, masterKey string) (string, int, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return "", 0, err
+	}
+	if ring.keyType != KeyTypeAES256GCM {
+		return "", 0, fmt.Errorf("%w: key '%s' of type %s cannot rewrap", ErrUnsupportedKeyType, name, ring.keyType)
+	}
+
+	plainText, _, err := ring.decrypt(ciphertext, associatedData)
+	if err != nil {
+		global.Logger.Debugf("Failed to rewrap with transit key '%s': %v", name, err)
+		return "", 0, err
+	}
+	return ring.encrypt(plainText, associatedData)
+}
+
+// GenerateDataKey generates a data key and wraps it with the latest version of the key.
+func (s *service) GenerateDataKey(name string, associatedData []byte, masterKey string) ([]byte, string, int, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if ring.keyType != KeyTypeAES256GCM {
+		return nil, "", 0, fmt.Errorf("%w: key '%s' of type %s cannot wrap data keys", ErrUnsupportedKeyType, name, ring.keyType)
+	}
+
+	// Data keys are AES-256 keys, just like the material of the wrapping key
+	dataKey, err := generateMaterial(KeyTypeAES256GCM)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	wrapped, version, err := ring.encrypt(dataKey, associatedData)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return dataKey, wrapped, version, nil
+}
+
+// Sign signs the data with the latest version of the key.
+func (s *service) Sign(name string, data []byte, masterKey string) (string, int, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return "", 0, err
+	}
+
+	signature, version, err := ring.sign(data)
+	if err != nil {
+		global.Logger.Debugf("Failed to sign with transit key '%s': %v", name, err)
+		return "", 0, err
+	}
+	return signature, version, nil
+}
+
+// Verify checks a signature with the version of the key that produced it.
+func (s *service) Verify(name string, data []byte, signature, masterKey string) (bool, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return false, err
+	}
+
+	valid, err := ring.verify(data, signature)
+	if err != nil {
+		global.Logger.Debugf("Failed to verify with transit key '%s': %v", name, err)
+		return false, err
+	}
+	return valid, nil
+}
+
+// HMAC computes the HMAC of the data with the latest version of the key.
+func (s *service) HMAC(name string, data []byte, masterKey string) (string, int, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return "", 0, err
+	}
+
+	mac, version, err := ring.hmac(data)
+	if err != nil {
+		global.Logger.Debugf("Failed to compute HMAC with transit key '%s': %v", name, err)
+		return "", 0, err
+	}
+	return mac, version, nil
+}
+
+// VerifyHMAC checks an HMAC with the version of the key that produced it.
+func (s *service) VerifyHMAC(name string, data []byte, mac, masterKey string) (bool, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return false, err
+	}
+
+	valid, err := ring.verifyHMAC(data, mac)
+	if err != nil {
+		global.Logger.Debugf("Failed to verify HMAC with transit key '%s': %v", name, err)
+		return false, err
+	}
+	return valid, nil
+}
+
+// PublicKeys derives the public keys of the usable versions of the key. The private keys never leave the service.
+func (s *service) PublicKeys(name, format, masterKey string) (map[int]interface{}, error) {
+	ring, err := s.loadKeyring(name, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if !ring.keyType.asymmetric() {
+		return nil, fmt.Errorf("%w: key '%s' of type %s has no public key", ErrUnsupportedKeyType, name, ring.keyType)
+	}
+
+	return ring.publicKeys(name, format)
+}
+
+// loadKeyring retrieves a key and decrypts the material of every version allowed for decryption.
+func (s *service) loadKeyring(name, masterKey string) (*keyring, error) {
+	key, err := s.GetKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := &keyring{
+		keyType:       key.Type,
+		latest:        key.LatestVersion,
+		minDecryption: key.MinDecryptionVersion,
+		material:      make(map[int][]byte, len(key.Versions)),
+	}
+	for _, version := range key.Versions {
+		if version.Version < key.MinDecryptionVersion {
+			continue
+		}
+
+		material, err := decryptMaterial(version.EncryptedMaterial, masterKey)
+		if err != nil {
+			err = fmt.Errorf("failed to decrypt version %d of transit key '%s': %v", version.Version, name, err)
+			global.Logger.Error(err)
+			return nil, err
+		}
+		ring.material[version.Version] = material
+	}
+
+	return ring, nil
+}
+
+// newEncryptedMaterial generates material for the key type and encrypts it with the master key.
+func newEncryptedMaterial(keyType KeyType, masterKey string) (string, error) {
+	material, err := generateMaterial(keyType)
+	if err != nil {
+		return "", err
+	}
+
+	encryptedMaterial, err := secrets.Encrypt(hex.EncodeToString(material), masterKey)
+	if err != nil {
+		err = fmt.Errorf("failed to encrypt key material: %v", err)
+		global.Logger.Error(err)
+		return "", err
+	}
+	return encryptedMaterial, nil
+}
+
+// decryptMaterial reverses newEncryptedMaterial.
+func decryptMaterial(encryptedMaterial, masterKey string) ([]byte, error) {
+	materialHex, err := secrets.Decrypt(encryptedMaterial, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(materialHex)
+}
@@ -0,0 +1,91 @@
+package transit
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository of keys and their versions, so that the cryptographic operations are tested
+// without a database. The locking of AddVersion is tested against PostgreSQL in repository_test.go.
+type memoryRepository struct {
+	keys map[string]*Key
+}
+
+func (r *memoryRepository) Save(key *Key) error {
+	r.keys[key.Name] = key
+	return nil
+}
+
+func (r *memoryRepository) GetByName(name string) (*Key, error) {
+	key, found := r.keys[name]
+	if !found {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return key, nil
+}
+
+func (r *memoryRepository) AddVersion(keyID uuid.UUID, encryptedMaterial string) (int, error) {
+	for _, key := range r.keys {
+		if key.ID == keyID {
+			key.LatestVersion++
+			key.Versions = append(key.Versions, KeyVersion{ID: uuid.New(), KeyID: keyID, Version: key.LatestVersion, EncryptedMaterial: encryptedMaterial})
+			return key.LatestVersion, nil
+		}
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) UpdateMinDecryptionVersion(keyID uuid.UUID, version int) error {
+	for _, key := range r.keys {
+		if key.ID == keyID {
+			key.MinDecryptionVersion = version
+		}
+	}
+	return nil
+}
+
+// newTestService creates a transit service backed by an in-memory repository.
+func newTestService(t *testing.T) Service {
+	newTestKeyring(t, 0)
+	return NewService(&memoryRepository{keys: map[string]*Key{}})
+}
+
+// TestServiceGenerateDataKey tests that a data key can be unwrapped with Decrypt, including after a rotation.
+func TestServiceGenerateDataKey(t *testing.T) {
+	service := newTestService(t)
+	_, err := service.CreateKey("backups", KeyTypeAES256GCM, "master")
+	assert.NoError(t, err)
+
+	dataKey, wrapped, version, err := service.GenerateDataKey("backups", []byte("backups/2024-10-23"), "master")
+	assert.NoError(t, err)
+	assert.Len(t, dataKey, 32)
+	assert.Equal(t, 1, version)
+	assert.NotContains(t, wrapped, base64.StdEncoding.EncodeToString(dataKey))
+
+	_, err = service.RotateKey("backups", "master")
+	assert.NoError(t, err)
+
+	unwrapped, err := service.Decrypt("backups", wrapped, []byte("backups/2024-10-23"), "master")
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = service.Decrypt("backups", wrapped, nil, "master")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+// TestServiceNegativeGenerateDataKey tests generating data keys with missing and asymmetric keys.
+func TestServiceNegativeGenerateDataKey(t *testing.T) {
+	service := newTestService(t)
+	_, err := service.CreateKey("releases", KeyTypeEd25519, "master")
+	assert.NoError(t, err)
+
+	_, _, _, err = service.GenerateDataKey("releases", nil, "master")
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+
+	_, _, _, err = service.GenerateDataKey("missing", nil, "master")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
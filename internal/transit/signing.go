@@ -0,0 +1,248 @@
+package transit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// hmacKeyInfo separates the HMAC key derived from a symmetric key's material from the encryption key itself.
+const hmacKeyInfo = "lockbox-transit-hmac"
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+// ecdsaScalarSize is the size in bytes of r and s in an ECDSA P-256 signature.
+const ecdsaScalarSize = 32
+
+// ErrInvalidSignature is returned when a signature or HMAC is malformed.
+// A well-formed signature that does not match the data is not an error: verification simply reports it as invalid.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Public key formats accepted by publicKeys.
+const (
+	PublicKeyFormatPEM = "pem"
+	PublicKeyFormatJWK = "jwk"
+)
+
+// JWK is the JSON Web Key (RFC 7517) representation of a public key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+}
+
+// sign signs the data with the latest version of the key.
+// Ed25519 signs the data itself, while ECDSA and RSA-PSS sign its SHA-256 digest.
+// ECDSA signatures are the fixed-length concatenation r‖s used by JWS (ES256), rather than ASN.1 DER,
+// so that they can be checked by JOSE libraries with the exported JWK.
+// Returns the signature in the form "lockbox:v<version>:<base64>" and the version used.
+func (k *keyring) sign(data []byte) (string, int, error) {
+	signer, err := k.privateKey(k.latest)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var signature []byte
+	switch key := signer.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, data)
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, key, digest[:]); err == nil {
+			signature = append(r.FillBytes(make([]byte, ecdsaScalarSize)), s.FillBytes(make([]byte, ecdsaScalarSize))...)
+		}
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		signature, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to sign: %v", err)
+	}
+
+	return formatCiphertext(k.latest, signature), k.latest, nil
+}
+
+// verify checks a signature produced by sign with any version allowed for decryption.
+// ECDSA signatures must be r‖s; other encodings, such as ASN.1 DER, do not match.
+// Returns whether the signature matches the data, and an error only if the signature is malformed or its version is not allowed.
+func (k *keyring) verify(data []byte, signature string) (bool, error) {
+	version, payload, err := k.parseVersioned(signature)
+	if err != nil {
+		return false, err
+	}
+	signer, err := k.privateKey(version)
+	if err != nil {
+		return false, err
+	}
+
+	switch key := signer.Public().(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, payload), nil
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if len(payload) != 2*ecdsaScalarSize {
+			return false, nil
+		}
+		r, s := new(big.Int).SetBytes(payload[:ecdsaScalarSize]), new(big.Int).SetBytes(payload[ecdsaScalarSize:])
+		return ecdsa.Verify(key, digest[:], r, s), nil
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPSS(key, crypto.SHA256, digest[:], payload, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil, nil
+	}
+	return false, fmt.Errorf("%w: key type %s cannot verify", ErrUnsupportedKeyType, k.keyType)
+}
+
+// hmac computes the HMAC-SHA256 of the data with the latest version of a symmetric key.
+// Returns the HMAC in the form "lockbox:v<version>:<base64>" and the version used.
+func (k *keyring) hmac(data []byte) (string, int, error) {
+	sum, err := k.hmacSum(k.latest, data)
+	if err != nil {
+		return "", 0, err
+	}
+	return formatCiphertext(k.latest, sum), k.latest, nil
+}
+
+// verifyHMAC checks an HMAC produced by hmac with any version allowed for decryption, in constant time.
+func (k *keyring) verifyHMAC(data []byte, mac string) (bool, error) {
+	version, payload, err := k.parseVersioned(mac)
+	if err != nil {
+		return false, err
+	}
+	sum, err := k.hmacSum(version, data)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(sum, payload), nil
+}
+
+// hmacSum computes the HMAC-SHA256 of the data with a key derived from the material of the given version.
+// The material is never used directly, so HMACs reveal nothing about the encryption key.
+func (k *keyring) hmacSum(version int, data []byte) ([]byte, error) {
+	if k.keyType != KeyTypeAES256GCM {
+		return nil, fmt.Errorf("%w: key type %s cannot compute an HMAC", ErrUnsupportedKeyType, k.keyType)
+	}
+
+	hmacKey := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k.material[version], nil, []byte(hmacKeyInfo)), hmacKey); err != nil {
+		return nil, fmt.Errorf("failed to derive HMAC key: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// publicKeys returns the public key of every version allowed for verification, in PEM or JWK format.
+// The JWK key ID is "<name>:v<version>", so verifiers can match it with the version prefix of a signature.
+func (k *keyring) publicKeys(name, format string) (map[int]interface{}, error) {
+	keys := make(map[int]interface{}, len(k.material))
+	for version := range k.material {
+		signer, err := k.privateKey(version)
+		if err != nil {
+			return nil, err
+		}
+
+		switch format {
+		case PublicKeyFormatPEM:
+			der, err := x509.MarshalPKIXPublicKey(signer.Public())
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode public key: %v", err)
+			}
+			keys[version] = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		case PublicKeyFormatJWK:
+			keys[version] = newJWK(signer.Public(), fmt.Sprintf("%s:v%d", name, version))
+		default:
+			return nil, fmt.Errorf("unsupported public key format '%s'", format)
+		}
+	}
+	return keys, nil
+}
+
+// privateKey parses the material of an asymmetric key version, stored as a PKCS #8 private key.
+func (k *keyring) privateKey(version int) (crypto.Signer, error) {
+	if !k.keyType.asymmetric() {
+		return nil, fmt.Errorf("%w: key type %s has no key pair", ErrUnsupportedKeyType, k.keyType)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(k.material[version])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version %d of the key: %v", version, err)
+	}
+	return parsed.(crypto.Signer), nil
+}
+
+// parseVersioned parses a signature or HMAC and checks that its version may still be used.
+func (k *keyring) parseVersioned(value string) (int, []byte, error) {
+	version, payload, err := parseCiphertext(value)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if _, found := k.material[version]; !found || version < k.minDecryption {
+		return 0, nil, fmt.Errorf("%w: version %d (minimum %d, latest %d)", ErrVersionNotAllowed, version, k.minDecryption, k.latest)
+	}
+	return version, payload, nil
+}
+
+// generateKeyPair creates a new key pair for an asymmetric key type, encoded as a PKCS #8 private key.
+func generateKeyPair(keyType KeyType) ([]byte, error) {
+	var privateKey interface{}
+	var err error
+	switch keyType {
+	case KeyTypeEd25519:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeECDSAP256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSAPSS2048:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedKeyType, keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %v", err)
+	}
+
+	return x509.MarshalPKCS8PrivateKey(privateKey)
+}
+
+// newJWK creates the JWK representation of a public key.
+func newJWK(publicKey crypto.PublicKey, keyID string) *JWK {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := &JWK{KeyID: keyID, Use: "sig"}
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Algorithm, jwk.Curve = "OKP", "EdDSA", "Ed25519"
+		jwk.X = encode(key)
+	case *ecdsa.PublicKey:
+		jwk.KeyType, jwk.Algorithm, jwk.Curve = "EC", "ES256", "P-256"
+		jwk.X = encode(key.X.FillBytes(make([]byte, 32)))
+		jwk.Y = encode(key.Y.FillBytes(make([]byte, 32)))
+	case *rsa.PublicKey:
+		jwk.KeyType, jwk.Algorithm = "RSA", "PS256"
+		jwk.Modulus = encode(key.N.Bytes())
+		jwk.Exponent = encode(big.NewInt(int64(key.E)).Bytes())
+	}
+	return jwk
+}
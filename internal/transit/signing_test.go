@@ -0,0 +1,140 @@
+package transit
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSigningKeyring creates an in-memory keyring of an asymmetric key type with the given number of versions.
+func newTestSigningKeyring(t *testing.T, keyType KeyType, versions int) *keyring {
+	ring := newTestKeyring(t, 0)
+	ring.keyType, ring.latest = keyType, versions
+	for version := 1; version <= versions; version++ {
+		material, err := generateMaterial(keyType)
+		assert.NoError(t, err)
+		ring.material[version] = material
+	}
+	return ring
+}
+
+// TestKeyringSignVerify tests signing and verifying with every asymmetric key type.
+func TestKeyringSignVerify(t *testing.T) {
+	for _, keyType := range []KeyType{KeyTypeEd25519, KeyTypeECDSAP256, KeyTypeRSAPSS2048} {
+		ring := newTestSigningKeyring(t, keyType, 2)
+
+		signature, version, err := ring.sign([]byte("release-1.2.3.tar.gz"))
+		assert.NoError(t, err, keyType)
+		assert.Equal(t, 2, version, keyType)
+		assert.True(t, strings.HasPrefix(signature, "lockbox:v2:"), keyType)
+
+		valid, err := ring.verify([]byte("release-1.2.3.tar.gz"), signature)
+		assert.NoError(t, err, keyType)
+		assert.True(t, valid, keyType)
+
+		valid, err = ring.verify([]byte("release-1.2.4.tar.gz"), signature)
+		assert.NoError(t, err, keyType)
+		assert.False(t, valid, keyType)
+
+		// A signature is only valid with the version that produced it
+		valid, err = ring.verify([]byte("release-1.2.3.tar.gz"), strings.Replace(signature, ":v2:", ":v1:", 1))
+		assert.NoError(t, err, keyType)
+		assert.False(t, valid, keyType)
+	}
+}
+
+// TestKeyringNegativeSignVerify tests signing with symmetric keys and verifying malformed or retired signatures.
+func TestKeyringNegativeSignVerify(t *testing.T) {
+	_, _, err := newTestKeyring(t, 1).sign([]byte("data"))
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+
+	ring := newTestSigningKeyring(t, KeyTypeEd25519, 2)
+	signature, _, err := ring.sign([]byte("data"))
+	assert.NoError(t, err)
+
+	_, err = ring.verify([]byte("data"), "not-a-signature")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = ring.verify([]byte("data"), strings.Replace(signature, ":v2:", ":v3:", 1))
+	assert.ErrorIs(t, err, ErrVersionNotAllowed)
+
+	ring.minDecryption = 2
+	_, err = ring.verify([]byte("data"), strings.Replace(signature, ":v2:", ":v1:", 1))
+	assert.ErrorIs(t, err, ErrVersionNotAllowed)
+}
+
+// TestKeyringHMAC tests computing and verifying HMACs with symmetric keys.
+func TestKeyringHMAC(t *testing.T) {
+	ring := newTestKeyring(t, 1)
+
+	mac, version, err := ring.hmac([]byte(`{"event":"payment"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	valid, err := ring.verifyHMAC([]byte(`{"event":"payment"}`), mac)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = ring.verifyHMAC([]byte(`{"event":"refund"}`), mac)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, _, err = newTestSigningKeyring(t, KeyTypeEd25519, 1).hmac([]byte("data"))
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+}
+
+// TestKeyringPublicKeys tests exporting public keys in PEM and JWK formats.
+func TestKeyringPublicKeys(t *testing.T) {
+	ring := newTestSigningKeyring(t, KeyTypeECDSAP256, 2)
+
+	keys, err := ring.publicKeys("releases", PublicKeyFormatPEM)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.True(t, strings.HasPrefix(keys[1].(string), "-----BEGIN PUBLIC KEY-----"))
+	assert.NotContains(t, keys[1].(string), "PRIVATE")
+
+	keys, err = ring.publicKeys("releases", PublicKeyFormatJWK)
+	assert.NoError(t, err)
+	jwk := keys[2].(*JWK)
+	assert.Equal(t, "EC", jwk.KeyType)
+	assert.Equal(t, "P-256", jwk.Curve)
+	assert.Equal(t, "releases:v2", jwk.KeyID)
+
+	_, err = ring.publicKeys("releases", "der")
+	assert.Error(t, err)
+}
+
+// TestKeyringECDSASignatureEncoding tests that ECDSA signatures are r‖s as expected by ES256 verifiers,
+// and that signatures in ASN.1 DER are rejected.
+func TestKeyringECDSASignatureEncoding(t *testing.T) {
+	ring := newTestSigningKeyring(t, KeyTypeECDSAP256, 1)
+	data := []byte("release-1.2.3.tar.gz")
+	digest := sha256.Sum256(data)
+	signer, err := ring.privateKey(1)
+	assert.NoError(t, err)
+	publicKey := signer.Public().(*ecdsa.PublicKey)
+
+	// Verify the signature the way a JWS library does
+	signature, _, err := ring.sign(data)
+	assert.NoError(t, err)
+	_, payload, err := parseCiphertext(signature)
+	assert.NoError(t, err)
+	assert.Len(t, payload, 64)
+	r, s := new(big.Int).SetBytes(payload[:32]), new(big.Int).SetBytes(payload[32:])
+	assert.True(t, ecdsa.Verify(publicKey, digest[:], r, s))
+
+	valid, err := ring.verify(data, signature)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// A DER signature of the same data does not match
+	der, err := ecdsa.SignASN1(rand.Reader, signer.(*ecdsa.PrivateKey), digest[:])
+	assert.NoError(t, err)
+	valid, err = ring.verify(data, formatCiphertext(1, der))
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
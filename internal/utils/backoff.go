@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffDelay returns the delay before the given retry attempt (starting at 0), using exponential backoff with jitter.
+// The base delay doubles on every attempt up to max, and the returned delay is randomly chosen between half
+// of the base delay and the full base delay so that many clients retrying at once do not stay synchronized.
+func BackoffDelay(initial, max time.Duration, attempt int) time.Duration {
+	if initial <= 0 {
+		return 0
+	}
+
+	// Double the delay for every attempt, stopping at the maximum
+	delay := initial
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	// Apply "equal jitter": keep half of the delay and randomize the other half
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Backoff keeps track of consecutive retry attempts and computes the delay before each one.
+type Backoff struct {
+	// Initial is the base delay before the first retry.
+	Initial time.Duration
+
+	// Max is the upper bound of the base delay.
+	Max time.Duration
+
+	attempt int // The number of delays returned since the last reset
+}
+
+// Next returns the delay before the next retry and advances the attempt counter.
+func (b *Backoff) Next() time.Duration {
+	delay := BackoffDelay(b.Initial, b.Max, b.attempt)
+	b.attempt++
+	return delay
+}
+
+// Reset starts the backoff over from the initial delay, typically after a successful attempt.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackoffDelayBounds tests that delays grow exponentially, stay within the jitter range and respect the maximum.
+func TestBackoffDelayBounds(t *testing.T) {
+	initial, max := 100*time.Millisecond, 1*time.Second
+
+	expectedBase := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for attempt, base := range expectedBase {
+		base *= time.Millisecond
+		delay := BackoffDelay(initial, max, attempt)
+		assert.GreaterOrEqual(t, delay, base/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, base, "attempt %d", attempt)
+	}
+}
+
+// TestBackoffReset tests that resetting a Backoff starts over from the initial delay.
+func TestBackoffReset(t *testing.T) {
+	backoff := &Backoff{Initial: 10 * time.Millisecond, Max: time.Second}
+	for i := 0; i < 5; i++ {
+		backoff.Next()
+	}
+
+	backoff.Reset()
+	assert.LessOrEqual(t, backoff.Next(), 10*time.Millisecond)
+}
@@ -3,11 +3,36 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/requestid"
 )
 
+// DecodeRequest decodes a JSON request body into req, and validates it with the validator of the calling package.
+// It writes a 400 Bad Request response and returns false if the body is invalid.
+func DecodeRequest(w http.ResponseWriter, r *http.Request, validate *validator.Validate, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return false
+	}
+
+	// Validate the decoded struct using the validator package
+	if err := validate.Struct(req); err != nil {
+		WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return false
+	}
+
+	return true
+}
+
 // WriteJSONResponse is a helper function that sets the response header, encodes the response data as JSON,
 // and writes the HTTP status code and JSON response to the response writer.
+// Error bodies ({"error": "..."}) also get the "request_id" of the response, so that clients can report it.
 func WriteJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	if body, isError := data.(map[string]string); isError && statusCode >= http.StatusBadRequest {
+		data = withRequestID(w, body)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -18,3 +43,31 @@ func WriteJSONResponse(w http.ResponseWriter, statusCode int, data interface{})
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
+
+// withRequestID returns a copy of an error body that includes the request ID set by the request ID middleware.
+// The body is returned unchanged if it has no "error" or if the response has no request ID.
+func withRequestID(w http.ResponseWriter, body map[string]string) map[string]string {
+	id := w.Header().Get(requestid.Header)
+	if _, found := body["error"]; !found || id == "" {
+		return body
+	}
+
+	tagged := make(map[string]string, len(body)+1)
+	for key, value := range body {
+		tagged[key] = value
+	}
+	tagged["request_id"] = id
+	return tagged
+}
+
+// WriteRawResponse is a helper function that writes a non-JSON body with the given content type.
+// It sets the same security headers as WriteJSONResponse, so raw responses are never cached or sniffed.
+func WriteRawResponse(w http.ResponseWriter, statusCode int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
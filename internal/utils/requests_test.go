@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeRequest tests that valid bodies are decoded, and that malformed or invalid bodies are answered with 400 Bad Request.
+func TestDecodeRequest(t *testing.T) {
+	validate := validator.New()
+	bodies := map[string]bool{
+		`{"name": "payments"}`: true,
+		`{"name": ""}`:         false,
+		`{"name": `:            false,
+	}
+	for body, valid := range bodies {
+		var req struct {
+			Name string `json:"name" validate:"required"`
+		}
+		recorder := httptest.NewRecorder()
+		decoded := DecodeRequest(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), validate, &req)
+
+		assert.Equal(t, valid, decoded, body)
+		if valid {
+			assert.Equal(t, "payments", req.Name)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, recorder.Code, body)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package client is a Go client for the Lockbox API that supports client-side (zero-knowledge) encryption.
+//
+// Client-encrypted secrets are encrypted in the calling process with a key held by the user.
+// Lockbox stores and returns the resulting envelope without being able to read it.
+//
+//	key, _ := client.GenerateKey() // Store this key safely, outside of Lockbox
+//	lockbox := client.New("https://lockbox.internal:8080", key)
+//	id, err := lockbox.CreateSecret(ctx, "payments/stripe-key", []byte("sk_live_..."))
+//	value, err := lockbox.GetSecret(ctx, "payments/stripe-key")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client talks to the Lockbox API and encrypts secret values before they leave the process.
+type Client struct {
+	// BaseURL is the address of the Lockbox server (e.g., "https://lockbox.internal:8080").
+	BaseURL string
+
+	// HTTPClient is the client used to send requests. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+
+	key []byte // The user-held encryption key
+}
+
+// New creates a client for the Lockbox server at baseURL, encrypting values with the given 32-byte key.
+func New(baseURL string, key []byte) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), key: key}
+}
+
+// CreateSecret encrypts the value locally and stores the envelope under the given key.
+// Returns the ID of the created secret.
+func (c *Client) CreateSecret(ctx context.Context, secretKey string, value []byte) (string, error) {
+	envelope, err := Seal(value, secretKey, c.key)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	body := map[string]interface{}{"secret_key": secretKey, "secret_value": envelope, "client_encrypted": true}
+	if err := c.do(ctx, http.MethodPost, "/secrets", body, http.StatusCreated, &response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// GetSecret retrieves a client-encrypted secret by its key or ID and decrypts it locally.
+func (c *Client) GetSecret(ctx context.Context, query string) ([]byte, error) {
+	var response struct {
+		Key             string `json:"key"`
+		Value           string `json:"value"`
+		ClientEncrypted bool   `json:"client_encrypted"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/secrets/"+url.PathEscape(query), nil, http.StatusOK, &response); err != nil {
+		return nil, err
+	}
+	if !response.ClientEncrypted {
+		return nil, fmt.Errorf("secret '%s' is not client-encrypted", response.Key)
+	}
+	return Open(response.Value, response.Key, c.key)
+}
+
+// UpdateSecret encrypts a new value locally and replaces the envelope of an existing client-encrypted secret.
+// The secret must be addressed by its key, since the key is bound to the envelope.
+func (c *Client) UpdateSecret(ctx context.Context, secretKey string, value []byte) error {
+	envelope, err := Seal(value, secretKey, c.key)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"secret_value": envelope, "client_encrypted": true}
+	return c.do(ctx, http.MethodPut, "/secrets/"+url.PathEscape(secretKey), body, http.StatusOK, nil)
+}
+
+// DeleteSecret deletes a secret by its key or ID.
+func (c *Client) DeleteSecret(ctx context.Context, query string) error {
+	return c.do(ctx, http.MethodDelete, "/secrets/"+url.PathEscape(query), nil, http.StatusOK, nil)
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil).
+// It returns an error, including the server's error message, if the status code is not the expected one.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, expectedStatus int, out interface{}) error {
+	// Encode the request body
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	// Send the request
+	request, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Report errors with the message returned by the server
+	if response.StatusCode != expectedStatus {
+		var errorBody struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(response.Body).Decode(&errorBody)
+		return fmt.Errorf("lockbox returned %d: %s", response.StatusCode, errorBody.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/api/middleware"
+	secrets_handler "gitlab.com/xrs-cloud/lockbox/core/internal/api/secrets"
+	"gitlab.com/xrs-cloud/lockbox/core/internal/secrets"
+)
+
+// memorySecrets is the part of the secrets service used by client-encrypted secrets, backed by a map.
+// The other methods are not implemented, and panic if they are called.
+type memorySecrets struct {
+	secrets.Service
+	mutex   sync.Mutex
+	secrets map[string]*secrets.Secret
+}
+
+func (m *memorySecrets) CreateClientEncryptedSecret(ctx context.Context, key, envelope string) (string, string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	secret := secrets.CreateClientEncryptedSecretModel(key, envelope)
+	m.secrets[secret.ID.String()] = secret
+	return secret.ID.String(), secret.Key, nil
+}
+
+func (m *memorySecrets) GetEncryptedSecretByID(ctx context.Context, secretID string) (*secrets.Secret, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if secret, found := m.secrets[secretID]; found {
+		copied := *secret
+		return &copied, nil
+	}
+	return nil, errors.New("secret not found")
+}
+
+func (m *memorySecrets) GetEncryptedSecretByKey(ctx context.Context, key string) (*secrets.Secret, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, secret := range m.secrets {
+		if secret.Key == key {
+			copied := *secret
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("secret not found")
+}
+
+func (m *memorySecrets) DecryptSecret(ctx context.Context, secret secrets.Secret, masterKey string) (string, error) {
+	return secret.EncryptedValue, nil
+}
+
+func (m *memorySecrets) UpdateClientEncryptedSecret(ctx context.Context, secretID, envelope string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.secrets[secretID].EncryptedValue = envelope
+	return nil
+}
+
+func (m *memorySecrets) DeleteSecret(ctx context.Context, secretID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.secrets, secretID)
+	return nil
+}
+
+// newTestServer serves the secrets routes with the same routing as the Lockbox server, backed by memorySecrets.
+func newTestServer(t *testing.T) *httptest.Server {
+	router := mux.NewRouter().UseEncodedPath()
+	router.Use(middleware.PathVarsMiddleware)
+	secrets_handler.RegisterSecretsRoutes(router, &memorySecrets{secrets: map[string]*secrets.Secret{}}, nil)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestClientRoundTrip tests that a secret whose key contains a slash can be created, read, updated and deleted by key.
+func TestClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	lockbox := New(newTestServer(t).URL, key)
+
+	id, err := lockbox.CreateSecret(ctx, "payments/stripe-key", []byte("sk_live_1"))
+	require.NoError(t, err)
+
+	value, err := lockbox.GetSecret(ctx, "payments/stripe-key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk_live_1", string(value))
+
+	require.NoError(t, lockbox.UpdateSecret(ctx, "payments/stripe-key", []byte("sk_live_2")))
+	value, err = lockbox.GetSecret(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "sk_live_2", string(value), "The secret can also be read by ID")
+
+	require.NoError(t, lockbox.DeleteSecret(ctx, "payments/stripe-key"))
+	_, err = lockbox.GetSecret(ctx, "payments/stripe-key")
+	assert.ErrorContains(t, err, "lockbox returned 404")
+}
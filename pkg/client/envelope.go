@@ -0,0 +1,90 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// envelopePrefix identifies client-encrypted envelopes and the version of their format.
+const envelopePrefix = "lockbox-ce:v1:"
+
+// KeySize is the size (in bytes) of the keys used to encrypt envelopes (AES-256).
+const KeySize = 32
+
+// ErrInvalidEnvelope is returned when an envelope is malformed or cannot be authenticated with the given key.
+var ErrInvalidEnvelope = errors.New("invalid client-encrypted envelope")
+
+// GenerateKey returns a new random key for encrypting envelopes.
+// The key must be kept by the user: Lockbox never sees it and cannot recover envelopes encrypted with it.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	return key, nil
+}
+
+// Seal encrypts the plaintext with AES-256 GCM and returns an envelope ready to be stored in Lockbox.
+// The secret key is bound to the envelope as additional authenticated data, so an envelope
+// copied to another secret by someone with database access fails to open.
+//
+// The envelope has the form "lockbox-ce:v1:<base64url(nonce || ciphertext)>".
+func Seal(plainText []byte, secretKey string, key []byte) (string, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Generate a random nonce for this encryption operation
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := aesGCM.Seal(nonce, nonce, plainText, []byte(secretKey))
+	return envelopePrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts an envelope produced by Seal for the same secret key.
+func Open(envelope, secretKey string, key []byte) ([]byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the envelope
+	encoded, found := strings.CutPrefix(envelope, envelopePrefix)
+	if !found {
+		return nil, fmt.Errorf("%w: unknown format", ErrInvalidEnvelope)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aesGCM.NonceSize() {
+		return nil, fmt.Errorf("%w: malformed payload", ErrInvalidEnvelope)
+	}
+
+	// Decrypt and authenticate the payload
+	nonce, cipherText := sealed[:aesGCM.NonceSize()], sealed[aesGCM.NonceSize():]
+	plainText, err := aesGCM.Open(nil, nonce, cipherText, []byte(secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong key or tampered envelope", ErrInvalidEnvelope)
+	}
+	return plainText, nil
+}
+
+// newGCM creates an AES-256 GCM cipher for the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSealOpen tests that an envelope can be opened with the same key and secret key.
+func TestSealOpen(t *testing.T) {
+	key, err := GenerateKey()
+	assert.NoError(t, err)
+
+	envelope, err := Seal([]byte("super-secret"), "payments/api-key", key)
+	assert.NoError(t, err)
+	assert.Contains(t, envelope, envelopePrefix)
+	assert.NotContains(t, envelope, "super-secret")
+
+	plainText, err := Open(envelope, "payments/api-key", key)
+	assert.NoError(t, err)
+	assert.Equal(t, "super-secret", string(plainText))
+}
+
+// TestOpenNegative tests that envelopes cannot be opened with another key, for another secret, or after tampering.
+func TestOpenNegative(t *testing.T) {
+	key, _ := GenerateKey()
+	otherKey, _ := GenerateKey()
+	envelope, err := Seal([]byte("super-secret"), "payments/api-key", key)
+	assert.NoError(t, err)
+
+	_, err = Open(envelope, "payments/api-key", otherKey)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+	_, err = Open(envelope, "payments/other-key", key)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+	_, err = Open(envelope[:len(envelope)-2]+"AA", "payments/api-key", key)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+	_, err = Open("not-an-envelope", "payments/api-key", key)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+	_, err = Seal([]byte("super-secret"), "payments/api-key", []byte("short"))
+	assert.Error(t, err)
+}